@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"os"
+	// the timezone database is embedded because the docker image is built from scratch
+	_ "time/tzdata"
 
 	log "github.com/sirupsen/logrus"
 
@@ -0,0 +1,67 @@
+package docs
+
+import "github.com/cubny/httpqueue/internal/infra/http/api"
+
+// swagger:parameters listDeadLettersRequest
+type ListDeadLettersRequestWrapper struct {
+	// Limit is the maximum number of dead letters in the page.
+	//
+	// in:query
+	Limit int `json:"limit"`
+	// Cursor is the next_cursor of the previous page.
+	//
+	// in:query
+	Cursor string `json:"cursor"`
+}
+
+// ListDeadLettersResponseWrapper is the wrapper.
+// swagger:response listDeadLetters
+type ListDeadLettersResponseWrapper struct {
+	// in:body
+	RequestBody api.ListDeadLettersResponse
+}
+
+// swagger:parameters getDeadLetterRequest deleteDeadLetterRequest
+type DeadLetterRequestWrapper struct {
+	// DeadLetterID that identifies a dead letter.
+	//
+	// in:path
+	DeadLetterID string `json:"dead_letter_id"`
+}
+
+// GetDeadLetterResponseWrapper is the wrapper.
+// swagger:response getDeadLetter
+type GetDeadLetterResponseWrapper struct {
+	// in:body
+	RequestBody api.DeadLetterResponse
+}
+
+// DeleteDeadLetterResponseWrapper is the wrapper of an empty response.
+// swagger:response deleteDeadLetter
+type DeleteDeadLetterResponseWrapper struct{}
+
+// swagger:parameters replayDeadLettersRequest
+type ReplayDeadLettersRequestWrapper struct {
+	// in:body
+	RequestBody api.ReplayDeadLettersRequest
+}
+
+// ReplayDeadLettersResponseWrapper is the wrapper.
+// swagger:response replayDeadLetters
+type ReplayDeadLettersResponseWrapper struct {
+	// in:body
+	RequestBody api.ReplayDeadLettersResponse
+}
+
+// swagger:parameters purgeDeadLettersRequest
+type PurgeDeadLettersRequestWrapper struct {
+	// in:body
+	RequestBody api.PurgeDeadLettersRequest
+}
+
+// PurgeDeadLettersResponseWrapper is the wrapper.
+// swagger:response purgeDeadLetters
+type PurgeDeadLettersResponseWrapper struct {
+	// in:body
+	RequestBody api.PurgeDeadLettersResponse
+}
@@ -5,6 +5,10 @@ import "github.com/cubny/httpqueue/internal/infra/http/api"
 // SetTimersRequestWrapper is the wrapper.
 // swagger:parameters setTimersRequest
 type SetTimersRequestWrapper struct {
+	// IdempotencyKey makes retrying the request safe. the timer is created once per key within the idempotency window.
+	//
+	// in:header
+	IdempotencyKey string `json:"Idempotency-Key"`
 	// in:body
 	RequestBody api.SetTimersRequest
 }
@@ -16,12 +20,30 @@ type SetTimerResponseWrapper struct {
 	RequestBody api.SetTimerResponse
 }
 
+// BatchSetTimersRequestWrapper is the wrapper.
+// swagger:parameters batchSetTimersRequest
+type BatchSetTimersRequestWrapper struct {
+	// in:body
+	RequestBody []api.SetTimersRequest
+}
+
+// BatchSetTimersResponseWrapper is the wrapper.
+// swagger:response batchSetTimers
+type BatchSetTimersResponseWrapper struct {
+	// in:body
+	RequestBody api.BatchSetTimersResponse
+}
+
 // swagger:parameters getTimerRequest
 type GetTimerRequestWrapper struct {
 	// TimerID that identifies a timer.
 	//
 	// in:path
 	TimerID string `json:"timer_id"`
+	// Expand is attempt_history to respond the latest delivery attempts as well.
+	//
+	// in:query
+	Expand string `json:"expand"`
 }
 
 // GetTimerResponseWrapper is the wrapper.
@@ -31,6 +53,87 @@ type GetTimerResponseWrapper struct {
 	RequestBody api.GetTimerResponse
 }
 
+// swagger:parameters listAttemptsRequest
+type ListAttemptsRequestWrapper struct {
+	// TimerID that identifies a timer.
+	//
+	// in:path
+	TimerID string `json:"timer_id"`
+}
+
+// ListAttemptsResponseWrapper is the wrapper.
+// swagger:response listAttempts
+type ListAttemptsResponseWrapper struct {
+	// in:body
+	RequestBody api.ListAttemptsResponse
+}
+
+// swagger:parameters listTimersRequest
+type ListTimersRequestWrapper struct {
+	// Status is one of pending, relayed, in-flight and retrying.
+	//
+	// in:query
+	Status string `json:"status"`
+	// Due lists the timers whose fire time is passed when true, and the ones whose fire time is in the future when
+	// false.
+	//
+	// in:query
+	Due bool `json:"due"`
+	// From is the RFC3339 lower bound of the fire time.
+	//
+	// in:query
+	From string `json:"from"`
+	// To is the RFC3339 upper bound of the fire time.
+	//
+	// in:query
+	To string `json:"to"`
+	// Host of the timer URL.
+	//
+	// in:query
+	Host string `json:"host"`
+	// Label in the form of key:value. it can be repeated.
+	//
+	// in:query
+	Label []string `json:"label"`
+	// Limit is the maximum number of timers in the page.
+	//
+	// in:query
+	Limit int `json:"limit"`
+	// Cursor is the next_cursor of the previous page.
+	//
+	// in:query
+	Cursor string `json:"cursor"`
+}
+
+// ListTimersResponseWrapper is the wrapper.
+// swagger:response listTimers
+type ListTimersResponseWrapper struct {
+	// in:body
+	RequestBody api.ListTimersResponse
+}
+
+// swagger:parameters rescheduleTimerRequest
+type RescheduleTimerRequestWrapper struct {
+	// TimerID that identifies a timer.
+	//
+	// in:path
+	TimerID string `json:"timer_id"`
+	// in:body
+	RequestBody api.RescheduleTimerRequest
+}
+
+// swagger:parameters cancelTimerRequest
+type CancelTimerRequestWrapper struct {
+	// TimerID that identifies a timer.
+	//
+	// in:path
+	TimerID string `json:"timer_id"`
+}
+
+// CancelTimerResponseWrapper is the wrapper of an empty response.
+// swagger:response cancelTimer
+type CancelTimerResponseWrapper struct{}
+
 // InvalidRequestBody is an error that is used when the request body fails to be decoded.
 // swagger:response invalidRequestBody
 type InvalidRequestBody struct {
@@ -82,6 +185,23 @@ type NotFoundError struct {
 	}
 }
 
+// ConflictError is an error that is used when the requested operation conflicts with the state of the resource.
+// swagger:response conflictError
+type ConflictError struct {
+	// The error body.
+	// in: body
+	ResponseBody struct {
+		// The conflict message
+		//
+		// Required: true
+		// Code of the error
+		Code int
+		// Required: true
+		// Details of the error
+		Details string
+	}
+}
+
 // ServerError is a 500 error used to show that there is a problem with the server in processing the request.
 // swagger:response serverError
 type ServerError struct {
@@ -78,15 +78,26 @@ func (a *App) ifNoError(fn func() *App) *App {
 func (a *App) initRepo() *App {
 	return a.ifNoError(func() *App {
 		a.redisClient = redis.NewRedis(&a.cfg.Redis)
-		a.db = repo.NewDB(a.redisClient, &a.cfg.DB)
+		db, err := repo.NewDB(a.redisClient, &a.cfg.DB)
+		if err != nil {
+			a.err = err
+			return a
+		}
 
+		a.db = db
 		return a
 	})
 }
 
 func (a *App) initService() *App {
 	return a.ifNoError(func() *App {
-		service, err := timer.NewService(a.db)
+		policy, err := internalHttpClient.NewDestinationPolicy(&a.cfg.Webhook)
+		if err != nil {
+			a.err = fmt.Errorf("invalid webhook destination policy, %v", err)
+			return a
+		}
+
+		service, err := timer.NewService(a.db, policy)
 		if err != nil {
 			a.err = err
 			return a
@@ -180,17 +191,28 @@ func (a *App) initAPIServer() *App {
 
 func (a *App) initConsumer() *App {
 	return a.ifNoError(func() *App {
+		retryDelay := asynqTimer.NewRetryDelayFunc(a.cfg.ConsumerMaxRetryAfter)
 		srv := asynq.NewServer(
 			a.redisClient,
 			asynq.Config{
 				// number of concurrent workers
-				Concurrency: a.cfg.ConsumerConcurrency,
+				Concurrency:    a.cfg.ConsumerConcurrency,
+				RetryDelayFunc: retryDelay,
+				IsFailure:      asynqTimer.IsFailure,
 			},
 		)
 
-		httpClient := internalHttpClient.NewClient()
+		httpClient, err := internalHttpClient.NewClient(&a.cfg.Webhook, a.redisClient)
+		if err != nil {
+			log.Fatalf("failed to initiate the webhook client, %v", err)
+		}
+
+		limiter, err := asynqTimer.NewLimiter(a.redisClient, &a.cfg.Limits)
+		if err != nil {
+			log.Fatalf("failed to initiate the webhook limiter, %v", err)
+		}
 
-		processor, err := asynqTimer.NewProcessor(a.service, httpClient)
+		processor, err := asynqTimer.NewProcessor(a.service, httpClient, retryDelay, limiter)
 		if err != nil {
 			log.Fatalf("failed to initiate the timer task processor")
 		}
@@ -0,0 +1,64 @@
+package timer
+
+import (
+	"time"
+)
+
+// error classes of the failed delivery attempts.
+const (
+	// AttemptErrorTimeout is a request that did not get a response in time.
+	AttemptErrorTimeout = "timeout"
+	// AttemptErrorConnection is a request that could not reach the receiver, e.g. a DNS or a connection failure.
+	AttemptErrorConnection = "connection"
+	// AttemptErrorHTTPStatus is a request that got an unsuccessful response.
+	AttemptErrorHTTPStatus = "http_status"
+	// AttemptErrorResponseBody is a request that got a response of an accepted status code, but whose body did not
+	// match the success criteria.
+	AttemptErrorResponseBody = "response_body"
+	// AttemptErrorRequest is a request that could not be made at all.
+	AttemptErrorRequest = "request"
+	// AttemptErrorCircuitOpen is a request that was not sent because the circuit breaker of the receiver is open.
+	AttemptErrorCircuitOpen = "circuit_open"
+)
+
+// Attempt is a delivery attempt of the webhook of a timer.
+type Attempt struct {
+	// Run is the number of the run the attempt belongs to, starting from 1. see Timer.Run.
+	Run int
+	// Number is the number of the attempt within the run starting from 1.
+	Number    int
+	StartedAt time.Time
+	Duration  time.Duration
+	// StatusCode is of the response, or zero when there was no response.
+	StatusCode int
+	// Outcome tells whether the attempt succeeded, is retried or failed permanently.
+	Outcome Outcome
+	// ErrorClass and Error are empty when the attempt succeeded.
+	ErrorClass string
+	Error      string
+	// RetryAt is the time the next attempt is scheduled at, zero when the run is not retried.
+	RetryAt time.Time
+}
+
+// NewAttempt records the delivery attempt of the current run of the timer.
+func NewAttempt(t *Timer, number int, startedAt time.Time, statusCode int) *Attempt {
+	return &Attempt{
+		Run:        t.Run(),
+		Number:     number,
+		StartedAt:  startedAt,
+		Duration:   time.Since(startedAt),
+		StatusCode: statusCode,
+	}
+}
+
+// WithError records the failure of the attempt.
+func (a *Attempt) WithError(class string, err error) *Attempt {
+	a.ErrorClass = class
+	a.Error = err.Error()
+	return a
+}
+
+// Succeeded tells whether the webhook was delivered by the attempt.
+func (a *Attempt) Succeeded() bool {
+	return a.ErrorClass == ""
+}
@@ -0,0 +1,37 @@
+package timer
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAttempt(t *testing.T) {
+	aTimer, err := NewTimer("http://valid.url", 0, 0, 0)
+	require.NoError(t, err)
+
+	startedAt := time.Now().Add(-time.Second)
+	got := NewAttempt(aTimer, 2, startedAt, http.StatusOK)
+
+	assert.Equal(t, 1, got.Run)
+	assert.Equal(t, 2, got.Number)
+	assert.Equal(t, startedAt, got.StartedAt)
+	assert.GreaterOrEqual(t, got.Duration, time.Second)
+	assert.True(t, got.Succeeded())
+
+	got.WithError(AttemptErrorHTTPStatus, assert.AnError)
+	assert.False(t, got.Succeeded())
+	assert.Equal(t, AttemptErrorHTTPStatus, got.ErrorClass)
+	assert.Equal(t, assert.AnError.Error(), got.Error)
+}
+
+func TestNewAttempt_recurring(t *testing.T) {
+	aTimer, err := NewTimer("http://valid.url", 0, 0, 0)
+	require.NoError(t, err)
+	aTimer.Recurrence = &Recurrence{Cron: "@hourly", Runs: 4}
+
+	assert.Equal(t, 5, NewAttempt(aTimer, 1, time.Now(), http.StatusOK).Run)
+}
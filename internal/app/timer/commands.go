@@ -1,10 +1,51 @@
 package timer
 
+import "time"
+
 type SetTimerCommand struct {
+	// ID is optional. a client supplied ID must be unique, otherwise a random ID is generated.
+	ID      string
 	Hours   int
 	Minutes int
 	Seconds int
 	URLRaw  string
+	// URLTemplate is an alternative to URLRaw. see URLTemplateData.
+	URLTemplate string
+	// DisableIDAppend prevents appending the timer ID to the path of URLRaw.
+	DisableIDAppend bool
+	// FireAt is the absolute time of firing. it takes precedence over the relative delays.
+	FireAt time.Time
+	// Delay is an alternative to Hours, Minutes and Seconds.
+	Delay time.Duration
+	// Cron makes the timer recurring. Timezone, EndAt and MaxRuns are only used with Cron.
+	Cron     string
+	Timezone string
+	EndAt    time.Time
+	MaxRuns  int
+	// Method, Headers, Body and ContentType describe the webhook request.
+	Method      string
+	Headers     map[string]string
+	Body        []byte
+	ContentType string
+	// SigningSecrets sign the webhook instead of the default secrets.
+	SigningSecrets []string
+	// Timeout overrides the default timeout of the webhook request.
+	Timeout time.Duration
+	Labels  map[string]string
+	// RetryPolicy overrides the default retry policy of the failed webhooks.
+	RetryPolicy *RetryPolicy
+	// SuccessCriteria overrides the default criteria of the successful responses of the webhook.
+	SuccessCriteria *SuccessCriteria
+	// IdempotencyKey makes retrying the creation safe. the timers created with the same key within the idempotency
+	// window are the same timer.
+	IdempotencyKey string
+}
+
+type RescheduleTimerCommand struct {
+	TimerID string
+	Hours   int
+	Minutes int
+	Seconds int
 }
 
 type GetTimer struct {
@@ -0,0 +1,117 @@
+package timer
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter is a run of a timer whose webhook failed permanently, i.e. it got a non-retryable response, or its
+// retries are exhausted. it is kept for inspection until it is replayed or purged.
+type DeadLetter struct {
+	// ID identifies the dead letter. it is the message ID of the failed run followed by the time it failed in unix
+	// milliseconds, e.g. order-42:3@1700000000000, so that the runs of the timers that are created with the same ID
+	// again do not overwrite each other. see Timer.MessageID.
+	ID string
+	// Timer is the timer as it was when the run failed.
+	Timer *Timer
+	// Error is the reason of the last failure.
+	Error string
+	// StatusCode and ResponseBody are of the last response, if there was any. ResponseBody is an excerpt.
+	StatusCode   int
+	ResponseBody string
+	// Attempts is the number of the delivery attempts of the run.
+	Attempts int
+	DeadAt   time.Time
+}
+
+// NewDeadLetter records the failed run of the timer.
+func NewDeadLetter(t *Timer, attempts int, failure error) *DeadLetter {
+	deadAt := time.Now()
+	return &DeadLetter{
+		ID:       fmt.Sprintf("%s@%d", t.MessageID(), deadAt.UnixMilli()),
+		Timer:    t,
+		Error:    failure.Error(),
+		Attempts: attempts,
+		DeadAt:   deadAt,
+	}
+}
+
+// WithResponse records the last response of the failed run.
+func (d *DeadLetter) WithResponse(statusCode int, body string) *DeadLetter {
+	d.StatusCode = statusCode
+	d.ResponseBody = body
+	return d
+}
+
+// Replay returns a one-off timer that shoots the webhook of the failed run again at fireAt. the timer of a run of a
+// recurring timer is identified by the message ID of the run, so that it does not clash with the recurring timer,
+// and the webhook is sent with the same message ID in both cases. rawURL is optional and sends the webhook to
+// another URL as is.
+func (d *DeadLetter) Replay(rawURL string, fireAt time.Time) (*Timer, error) {
+	t := *d.Timer
+	t.ID = d.Timer.MessageID()
+	t.Recurrence = nil
+	t.FireAt = fireAt
+	t.CreatedAt = time.Now()
+	// the task of the failed revision is still known by the broker, and the ID may be taken again after the replay
+	// is archived.
+	t.Revision++
+	t.Generation = t.CreatedAt.UnixNano()
+
+	if rawURL != "" {
+		u, err := url.ParseRequestURI(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rawURL provided: %w", err)
+		}
+		t.URL = *u
+		t.URLTemplate = ""
+	}
+
+	return &t, nil
+}
+
+// ListDeadLettersQuery paginates the dead letters, ordered by the time they failed.
+type ListDeadLettersQuery struct {
+	// Limit is the maximum number of dead letters in a page.
+	Limit int
+	// Cursor is the opaque position returned as DeadLettersPage.NextCursor.
+	Cursor string
+}
+
+// DeadLettersPage is a page of the dead letters.
+type DeadLettersPage struct {
+	DeadLetters []*DeadLetter
+	// NextCursor is empty when there are no more dead letters.
+	NextCursor string
+}
+
+func (q ListDeadLettersQuery) normalize() ListDeadLettersQuery {
+	if q.Limit <= 0 {
+		q.Limit = DefaultListLimit
+	}
+
+	if q.Limit > MaxListLimit {
+		q.Limit = MaxListLimit
+	}
+
+	return q
+}
+
+// ReplayDeadLettersCommand replays the dead letters of the IDs.
+type ReplayDeadLettersCommand struct {
+	IDs []string
+	// URLRaw optionally sends the webhooks to another URL as is.
+	URLRaw string
+}
+
+// ReplayDeadLetterResult is the outcome of replaying one of the dead letters.
+// either Timer or Err is set.
+type ReplayDeadLetterResult struct {
+	ID    string
+	Timer *Timer
+	Err   error
+}
@@ -0,0 +1,82 @@
+package timer
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeadLetter(t *testing.T) {
+	aTimer, err := NewTimer("http://valid.url", 0, 0, 0)
+	require.NoError(t, err)
+
+	got := NewDeadLetter(aTimer, 3, assert.AnError).WithResponse(http.StatusGone, "gone")
+
+	assert.Equal(t, fmt.Sprintf("%s@%d", aTimer.ID, got.DeadAt.UnixMilli()), got.ID)
+	assert.Equal(t, assert.AnError.Error(), got.Error)
+	assert.Equal(t, 3, got.Attempts)
+	assert.Equal(t, http.StatusGone, got.StatusCode)
+	assert.Equal(t, "gone", got.ResponseBody)
+	assert.WithinDuration(t, time.Now(), got.DeadAt, time.Second)
+}
+
+func TestDeadLetter_Replay(t *testing.T) {
+	now := time.Now()
+
+	t.Run("one-off timer is replayed with its ID", func(t *testing.T) {
+		aTimer, err := NewTimer("http://valid.url", 0, 0, 0)
+		require.NoError(t, err)
+
+		got, err := NewDeadLetter(aTimer, 1, assert.AnError).Replay("", now)
+		require.NoError(t, err)
+
+		assert.Equal(t, aTimer.ID, got.ID)
+		assert.Equal(t, aTimer.URL, got.URL)
+		assert.Equal(t, now, got.FireAt)
+		assert.Equal(t, 1, got.Revision)
+		assert.NotZero(t, got.Generation)
+		assert.NotEqual(t, aTimer.Generation, got.Generation)
+		assert.Equal(t, aTimer.MessageID(), got.MessageID())
+	})
+
+	t.Run("run of a recurring timer is replayed as a one-off timer", func(t *testing.T) {
+		recurrence, err := NewRecurrence("@hourly", "", time.Time{}, 0)
+		require.NoError(t, err)
+		aTimer, err := NewRecurringTimer("http://valid.url", recurrence)
+		require.NoError(t, err)
+		aTimer.Recurrence.Runs = 2
+
+		deadLetter := NewDeadLetter(aTimer, 1, assert.AnError)
+		got, err := deadLetter.Replay("", now)
+		require.NoError(t, err)
+
+		assert.Equal(t, aTimer.ID+":3", got.ID)
+		assert.False(t, got.IsRecurring())
+		assert.Equal(t, aTimer.MessageID(), got.MessageID())
+		assert.True(t, aTimer.IsRecurring(), "the recurring timer is not changed")
+	})
+
+	t.Run("to another URL", func(t *testing.T) {
+		aTimer, err := NewTimerWithDelay("http://valid.url", 0)
+		require.NoError(t, err)
+		aTimer.URLTemplate = "http://valid.url/{{.ID}}"
+
+		got, err := NewDeadLetter(aTimer, 1, assert.AnError).Replay("http://other.url/hook", now)
+		require.NoError(t, err)
+
+		assert.Equal(t, "http://other.url/hook", got.URL.String())
+		assert.Empty(t, got.URLTemplate)
+	})
+
+	t.Run("to an invalid URL", func(t *testing.T) {
+		aTimer, err := NewTimerWithDelay("http://valid.url", 0)
+		require.NoError(t, err)
+
+		_, err = NewDeadLetter(aTimer, 1, assert.AnError).Replay("invalid.url", now)
+		assert.Error(t, err)
+	})
+}
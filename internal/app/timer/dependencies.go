@@ -2,30 +2,117 @@ package timer
 
 import (
 	"context"
+	"net/url"
+	"time"
 )
 
+// Outbox is a reliable queue of the timers to relay. a dequeued timer is invisible to the other relays until it is
+// acknowledged or requeued, or until the visibility timeout passes and it is reclaimed.
 type Outbox interface {
-	DequeueOutbox(ctx context.Context, batchSize int) ([]*Timer, error)
+	// DequeueOutbox returns ErrLeaseLost when the fencing token is not of the latest lease. see Lease.
+	DequeueOutbox(ctx context.Context, fencingToken int64, batchSize int, visibilityTimeout time.Duration) ([]*Timer, error)
+	// AckOutbox removes the relayed timers from the outbox for good.
+	AckOutbox(ctx context.Context, timerIDs []string) error
+	// RequeueOutbox puts the timers that failed to be relayed back to the outbox.
+	RequeueOutbox(ctx context.Context, timerIDs []string) error
+	// ReclaimOutbox puts up to the limit of the timers whose visibility timeout has passed back to the outbox.
+	// it returns the number of the reclaimed timers.
+	ReclaimOutbox(ctx context.Context, limit int) (int, error)
+	// MarkRelayed sets the state of the timers to StateRelayed.
+	MarkRelayed(ctx context.Context, timers []*Timer) error
+
+	// AcquireLease makes the holder the leader of the relays for the TTL, or extends the lease of the holder.
+	// it returns nil, nil when another holder leads.
+	AcquireLease(ctx context.Context, holder string, ttl time.Duration) (*Lease, error)
+	// ReleaseLease gives the lease up, if it is still held, so that another relay takes it over right away.
+	ReleaseLease(ctx context.Context, lease *Lease) error
 }
 
 type Repo interface {
 	Find(ctx context.Context, timerID string) (*Timer, error)
+	List(ctx context.Context, query ListTimersQuery) (*TimersPage, error)
+	// AddTimer returns ErrTimerExists when a timer with the same ID already exists.
 	AddTimer(ctx context.Context, timer *Timer) error
-	Archive(ctx context.Context, timerID string) error
+	// AddTimerIdempotently adds the timer unless a timer is already added with the same idempotency key within the
+	// idempotency window. it returns the ID of the already added timer, or empty when the timer is added.
+	AddTimerIdempotently(ctx context.Context, timer *Timer, idempotencyKey string) (string, error)
+	// AddTimers adds the timers and their outbox entries at once. the timers whose ID already exists are skipped
+	// and their IDs are returned.
+	AddTimers(ctx context.Context, timers []*Timer) ([]string, error)
+	// Reschedule overwrites the timer of the previous revision and adds it to the outbox again. it returns
+	// ErrTimerNotFound when the timer does not exist anymore, and ErrTimerModified when the stored timer is not of the
+	// previous revision.
+	Reschedule(ctx context.Context, timer *Timer) error
+	// Archive removes the timer and adds it to the archive index. the tombstone is kept as well, when enabled.
+	Archive(ctx context.Context, tombstone *Tombstone) error
+	// IsArchived tells whether the timer is probably archived within the retention of the archive.
 	IsArchived(ctx context.Context, timerID string) (bool, error)
+	// FindTombstone returns nil, nil when nothing found.
+	FindTombstone(ctx context.Context, timerID string) (*Tombstone, error)
+	// Cancel removes the timer and keeps its state as StateCancelled, if the timer of the generation still exists.
+	// it returns ErrTimerNotFound when the timer does not exist, and ErrTimerModified when it is of another
+	// generation.
+	Cancel(ctx context.Context, timer *Timer) error
+	IsCancelled(ctx context.Context, timerID string) (bool, error)
+
+	AddDeadLetter(ctx context.Context, deadLetter *DeadLetter) error
+	// FindDeadLetter returns nil, nil when nothing found.
+	FindDeadLetter(ctx context.Context, id string) (*DeadLetter, error)
+	ListDeadLetters(ctx context.Context, query ListDeadLettersQuery) (*DeadLettersPage, error)
+	// ReplayDeadLetter adds the timer like AddTimer and removes the dead letter at once.
+	ReplayDeadLetter(ctx context.Context, id string, timer *Timer) error
+	// PurgeDeadLetters removes the dead letters of the IDs, or all of them when no ID is given.
+	// it returns the number of the removed dead letters.
+	PurgeDeadLetters(ctx context.Context, ids []string) (int, error)
+
+	// AddAttempt adds the attempt to the capped attempt history of the timer.
+	AddAttempt(ctx context.Context, timerID string, attempt *Attempt) error
+	// ListAttempts returns the attempt history of the timer, the latest attempt first.
+	ListAttempts(ctx context.Context, timerID string) ([]*Attempt, error)
+
+	// SetState keeps the state of the current run of the timer. it outlives the timer until the max TTL of timers.
+	SetState(ctx context.Context, state *TimerState) error
+	// FindState returns nil, nil when nothing found.
+	FindState(ctx context.Context, timerID string) (*TimerState, error)
 }
 
 type Producer interface {
 	Send(ctx context.Context, timer *Timer) error
 }
 
+// DestinationPolicy tells where the webhooks can be sent to.
+type DestinationPolicy interface {
+	// AllowURL returns ErrDestinationNotAllowed when the webhooks cannot be sent to the URL.
+	AllowURL(u *url.URL) error
+}
+
 type HttpClient interface {
-	Shoot(ctx context.Context, timer *Timer) error
+	// Shoot sends the webhook of the timer. attempt is the number of the delivery attempt starting from 1.
+	// it returns the status code of the response, or zero when there was no response.
+	Shoot(ctx context.Context, timer *Timer, attempt int) (int, error)
 }
 
 // Service holds all the business logic
 type Service interface {
 	CreateTimer(ctx context.Context, cmd SetTimerCommand) (*Timer, error)
+	CreateTimers(ctx context.Context, cmds []SetTimerCommand) []CreateTimerResult
 	GetTimer(ctx context.Context, timerID string) (*Timer, error)
-	ArchiveTimer(ctx context.Context, timerID string) error
+	ListTimers(ctx context.Context, query ListTimersQuery) (*TimersPage, error)
+	RescheduleTimer(ctx context.Context, cmd RescheduleTimerCommand) (*Timer, error)
+	// ArchiveTimer archives the timer whose run finished with the outcome, i.e. StateSucceeded or StateFailed.
+	ArchiveTimer(ctx context.Context, timerID string, outcome State) error
+	// ScheduleNextRun archives the timer with the outcome of its run when its recurrence has ended.
+	ScheduleNextRun(ctx context.Context, timer *Timer, outcome State) error
+	CancelTimer(ctx context.Context, timerID string) error
+	GetTimerState(ctx context.Context, timerID string) (*TimerState, error)
+	SetTimerState(ctx context.Context, state *TimerState) error
+
+	DeadLetterTimer(ctx context.Context, deadLetter *DeadLetter) error
+	GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error)
+	ListDeadLetters(ctx context.Context, query ListDeadLettersQuery) (*DeadLettersPage, error)
+	ReplayDeadLetters(ctx context.Context, cmd ReplayDeadLettersCommand) []ReplayDeadLetterResult
+	PurgeDeadLetters(ctx context.Context, ids []string) (int, error)
+
+	RecordAttempt(ctx context.Context, timerID string, attempt *Attempt) error
+	ListAttempts(ctx context.Context, timerID string) ([]*Attempt, error)
 }
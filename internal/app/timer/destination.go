@@ -0,0 +1,81 @@
+package timer
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+var (
+	ErrInvalidURLTemplate = errors.New("invalid URL template")
+	// ErrDestinationNotAllowed indicates that the webhooks cannot be sent to the URL by the destination policy.
+	ErrDestinationNotAllowed = errors.New("destination is not allowed")
+)
+
+// URLTemplateData is the data that is available to the URL templates, e.g. https://x/hooks?timer={{.ID}}&run={{.Attempt}}
+type URLTemplateData struct {
+	// ID of the timer.
+	ID string
+	// Attempt is the number of the delivery attempt starting from 1.
+	Attempt int
+	// Run is the number of the run of a recurring timer starting from 1. it is always 1 for the one-off timers.
+	Run int
+}
+
+// destination is where the webhook of a new timer is sent to.
+type destination struct {
+	url *url.URL
+	// appendID indicates whether the timer ID is appended to the path of url.
+	appendID bool
+	template *urlTemplate
+}
+
+func newDestination(rawURL string) (destination, error) {
+	validURL, err := url.ParseRequestURI(rawURL)
+	if err != nil {
+		return destination{}, fmt.Errorf("invalid rawURL provided: %w", err)
+	}
+
+	return destination{url: validURL, appendID: true}, nil
+}
+
+type urlTemplate struct {
+	raw      string
+	template *template.Template
+}
+
+// ValidateURLTemplate checks whether the URL template is parsable and renders a valid URL.
+func ValidateURLTemplate(raw string) error {
+	tmpl, err := parseURLTemplate(raw)
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpl.render(URLTemplateData{ID: "id", Attempt: 1, Run: 1})
+	return err
+}
+
+func parseURLTemplate(raw string) (*urlTemplate, error) {
+	tmpl, err := template.New("url").Option("missingkey=error").Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURLTemplate, err)
+	}
+
+	return &urlTemplate{raw: raw, template: tmpl}, nil
+}
+
+func (t *urlTemplate) render(data URLTemplateData) (*url.URL, error) {
+	var sb strings.Builder
+	if err := t.template.Execute(&sb, data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURLTemplate, err)
+	}
+
+	validURL, err := url.ParseRequestURI(sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w: rendered URL is invalid: %v", ErrInvalidURLTemplate, err)
+	}
+
+	return validURL, nil
+}
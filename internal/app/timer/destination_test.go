@@ -0,0 +1,54 @@
+package timer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateURLTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "query string",
+			raw:     "https://valid.url/hooks?timer={{.ID}}&run={{.Attempt}}",
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "path",
+			raw:     "https://valid.url/hooks/{{.ID}}/runs/{{.Run}}",
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "no placeholders",
+			raw:     "https://valid.url/hooks",
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "unparsable template",
+			raw:     "https://valid.url/hooks/{{.ID}",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "unknown field",
+			raw:     "https://valid.url/hooks/{{.Unknown}}",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "renders an invalid URL",
+			raw:     "{{.ID}}",
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateURLTemplate(tt.raw)
+			if tt.wantErr(t, err) && err != nil {
+				assert.ErrorIs(t, err, ErrInvalidURLTemplate)
+			}
+		})
+	}
+}
@@ -4,47 +4,335 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"regexp"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-var ErrFireAtInPast = errors.New("time in the past")
+var (
+	ErrFireAtInPast = errors.New("time in the past")
+	// ErrInvalidTimerID is returned when a client supplied timer ID does not match timerIDRe.
+	ErrInvalidTimerID = errors.New("invalid timer ID")
+)
+
+// timerIDRe restricts the client supplied timer IDs, so that they can be used in URL paths and Redis keys as is.
+// they cannot contain a colon, which the stores use to tell apart the records of the timers from one another.
+var timerIDRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
 
 type Timer struct {
-	ID     string
-	URL    url.URL
-	FireAt time.Time
+	ID  string
+	URL url.URL
+	// URLTemplate is optional. when set, the webhook URL of every attempt is rendered from it. see URLTemplateData.
+	URLTemplate string
+	FireAt      time.Time
+	CreatedAt   time.Time
+	// Revision is incremented every time the timer is rescheduled. tasks that carry an older revision are stale.
+	Revision int
+	// Generation tells apart the timers that are created with the same client supplied ID, e.g. after the previous
+	// one is cancelled, or the replays of a dead letter, so that the tasks of the previous timer are stale. it is zero
+	// for the generated IDs.
+	Generation int64
+	// Recurrence is set for the recurring timers only.
+	Recurrence *Recurrence
+	// Webhook is the HTTP request that is sent to the URL.
+	Webhook Webhook
+	// Labels are arbitrary key/values that the timers can be searched by.
+	Labels map[string]string
+	// RetryPolicy is optional. it overrides the default retry policy of the failed webhooks.
+	RetryPolicy *RetryPolicy
+	// SuccessCriteria is optional. it overrides the default criteria of the successful responses of the webhook.
+	SuccessCriteria *SuccessCriteria
 }
 
 func NewTimerFromCommand(cmd SetTimerCommand) (*Timer, error) {
-	return NewTimer(cmd.URLRaw, time.Duration(cmd.Hours), time.Duration(cmd.Minutes), time.Duration(cmd.Seconds))
+	if cmd.ID != "" {
+		if err := ValidateTimerID(cmd.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	webhook, err := NewWebhook(cmd.Method, cmd.Headers, cmd.Body, cmd.ContentType)
+	if err != nil {
+		return nil, err
+	}
+
+	if webhook, err = webhook.WithSigningSecrets(cmd.SigningSecrets); err != nil {
+		return nil, err
+	}
+
+	if webhook, err = webhook.WithTimeout(cmd.Timeout); err != nil {
+		return nil, err
+	}
+
+	if cmd.RetryPolicy != nil {
+		if err := cmd.RetryPolicy.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cmd.SuccessCriteria != nil {
+		if err := cmd.SuccessCriteria.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	dest, err := newDestinationFromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	fireAt, recurrence, err := scheduleFromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := newTimer(cmd.ID, dest, fireAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.ID != "" {
+		t.Generation = t.CreatedAt.UnixNano()
+	}
+
+	t.Recurrence = recurrence
+	t.Webhook = webhook
+	t.Labels = cmd.Labels
+	t.RetryPolicy = cmd.RetryPolicy
+	t.SuccessCriteria = cmd.SuccessCriteria
+	return t, nil
+}
+
+func newDestinationFromCommand(cmd SetTimerCommand) (destination, error) {
+	if cmd.URLTemplate != "" {
+		tmpl, err := parseURLTemplate(cmd.URLTemplate)
+		if err != nil {
+			return destination{}, err
+		}
+		return destination{template: tmpl}, nil
+	}
+
+	dest, err := newDestination(cmd.URLRaw)
+	if err != nil {
+		return destination{}, err
+	}
+
+	dest.appendID = !cmd.DisableIDAppend
+	return dest, nil
+}
+
+func scheduleFromCommand(cmd SetTimerCommand) (time.Time, *Recurrence, error) {
+	switch {
+	case cmd.Cron != "":
+		recurrence, err := NewRecurrence(cmd.Cron, cmd.Timezone, cmd.EndAt, cmd.MaxRuns)
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+		fireAt, err := firstOccurrence(recurrence)
+		return fireAt, recurrence, err
+	case !cmd.FireAt.IsZero():
+		fireAt, err := validFireAt(cmd.FireAt)
+		return fireAt, nil, err
+	case cmd.Delay != 0:
+		fireAt, err := fireAtFromNow(cmd.Delay)
+		return fireAt, nil, err
+	}
+
+	fireAt, err := fireAtFromNow(time.Duration(cmd.Hours)*time.Hour + time.Duration(cmd.Minutes)*time.Minute + time.Duration(cmd.Seconds)*time.Second)
+	return fireAt, nil, err
 }
 
 func NewTimer(rawURL string, hours, minutes, seconds time.Duration) (*Timer, error) {
-	id := uuid.NewString()
+	return NewTimerWithDelay(rawURL, hours*time.Hour+minutes*time.Minute+seconds*time.Second)
+}
+
+// NewTimerWithDelay creates a timer that fires after the delay is passed.
+func NewTimerWithDelay(rawURL string, delay time.Duration) (*Timer, error) {
+	dest, err := newDestination(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	fireAt, err := fireAtFromNow(delay)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTimer("", dest, fireAt)
+}
+
+// NewTimerAt creates a timer that fires at the given absolute time.
+func NewTimerAt(rawURL string, fireAt time.Time) (*Timer, error) {
+	dest, err := newDestination(rawURL)
+	if err != nil {
+		return nil, err
+	}
 
-	validURL, err := url.ParseRequestURI(rawURL)
+	fireAt, err = validFireAt(fireAt)
 	if err != nil {
-		return nil, fmt.Errorf("invalid rawURL provided: %w", err)
+		return nil, err
 	}
 
-	validURL = validURL.JoinPath(id)
+	return newTimer("", dest, fireAt)
+}
+
+// NewRecurringTimer creates a timer that fires on every occurrence of the recurrence.
+func NewRecurringTimer(rawURL string, recurrence *Recurrence) (*Timer, error) {
+	dest, err := newDestination(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	fireAt, err := firstOccurrence(recurrence)
+	if err != nil {
+		return nil, err
+	}
 
+	t, err := newTimer("", dest, fireAt)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Recurrence = recurrence
+	return t, nil
+}
+
+// ValidateTimerID checks a client supplied timer ID.
+func ValidateTimerID(id string) error {
+	if !timerIDRe.MatchString(id) {
+		return ErrInvalidTimerID
+	}
+	return nil
+}
+
+// newTimer creates a timer with the given ID, or a generated one when the ID is empty.
+func newTimer(id string, dest destination, fireAt time.Time) (*Timer, error) {
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	t := &Timer{
+		ID:        id,
+		FireAt:    fireAt,
+		CreatedAt: time.Now(),
+		Webhook:   Webhook{Method: DefaultWebhookMethod},
+	}
+
+	if dest.template != nil {
+		t.URLTemplate = dest.template.raw
+		// the URL of a templated timer is the one of its first attempt
+		validURL, err := dest.template.render(URLTemplateData{ID: id, Attempt: 1, Run: 1})
+		if err != nil {
+			return nil, err
+		}
+		t.URL = *validURL
+		return t, nil
+	}
+
+	t.URL = *dest.url
+	if dest.appendID {
+		t.URL = *dest.url.JoinPath(id)
+	}
+
+	return t, nil
+}
+
+// Reschedule moves the FireAt of the timer and bumps its revision, so that the previously scheduled task is skipped.
+func (t *Timer) Reschedule(hours, minutes, seconds time.Duration) error {
+	fireAt, err := fireAtFromNow(hours*time.Hour + minutes*time.Minute + seconds*time.Second)
+	if err != nil {
+		return err
+	}
+
+	t.FireAt = fireAt
+	t.Revision++
+	return nil
+}
+
+// IsRecurring tells whether the timer fires more than once.
+func (t *Timer) IsRecurring() bool {
+	return t.Recurrence != nil
+}
+
+// NextRun counts the current run of a recurring timer and moves FireAt to the next occurrence.
+// it returns false when the recurrence has ended.
+func (t *Timer) NextRun() bool {
+	if !t.IsRecurring() {
+		return false
+	}
+
+	t.Recurrence.Runs++
+	fireAt, ok := t.Recurrence.Next(time.Now())
+	if !ok {
+		return false
+	}
+
+	t.FireAt = fireAt
+	t.Revision++
+	return true
+}
+
+func validFireAt(fireAt time.Time) (time.Time, error) {
+	if fireAt.Before(time.Now()) {
+		return time.Time{}, ErrFireAtInPast
+	}
+	return fireAt, nil
+}
+
+func firstOccurrence(recurrence *Recurrence) (time.Time, error) {
+	fireAt, ok := recurrence.Next(time.Now())
+	if !ok {
+		return time.Time{}, ErrRecurrenceEnded
+	}
+	return fireAt, nil
+}
+
+func fireAtFromNow(delay time.Duration) (time.Time, error) {
 	//TODO: use UTC or local time and document the decision
 	now := time.Now()
-	duration := hours*time.Hour + minutes*time.Minute + seconds*time.Second
 
-	firedAt := now.Add(duration)
-	if firedAt.Before(now) {
-		return nil, ErrFireAtInPast
+	fireAt := now.Add(delay)
+	if fireAt.Before(now) {
+		return time.Time{}, ErrFireAtInPast
 	}
 
-	return &Timer{
-		ID:     id,
-		URL:    *validURL,
-		FireAt: firedAt,
-	}, nil
+	return fireAt, nil
+}
+
+// WebhookURL returns the URL of the given delivery attempt of the webhook.
+func (t *Timer) WebhookURL(attempt int) (string, error) {
+	if t.URLTemplate == "" {
+		return t.URL.String(), nil
+	}
+
+	tmpl, err := parseURLTemplate(t.URLTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := tmpl.render(URLTemplateData{ID: t.ID, Attempt: attempt, Run: t.Run()})
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// Run is the number of the current run of the timer, starting from 1. only the recurring timers run more than once.
+func (t *Timer) Run() int {
+	if !t.IsRecurring() {
+		return 1
+	}
+	return t.Recurrence.Runs + 1
+}
+
+// MessageID identifies the webhook of the current run. it is the same for all the delivery attempts of a run.
+func (t *Timer) MessageID() string {
+	if !t.IsRecurring() {
+		return t.ID
+	}
+	return fmt.Sprintf("%s:%d", t.ID, t.Run())
 }
 
 func (t *Timer) DelayFromNowSeconds() float64 {
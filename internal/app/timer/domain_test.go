@@ -3,6 +3,7 @@ package timer
 import (
 	"fmt"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -97,11 +98,101 @@ func TestNewTimerFromCommand(t *testing.T) {
 			wantFireAt: now,
 			wantErr:    assert.NoError,
 		},
+		{
+			name: "absolute fire time",
+			cmd: SetTimerCommand{
+				URLRaw: "http://valid.url",
+				FireAt: now.Add(time.Hour),
+			},
+			wantURLRaw: "http://valid.url",
+			wantFireAt: now.Add(time.Hour),
+			wantErr:    assert.NoError,
+		},
+		{
+			name: "absolute fire time in the past",
+			cmd: SetTimerCommand{
+				URLRaw: "http://valid.url",
+				FireAt: now.Add(-time.Hour),
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "delay",
+			cmd: SetTimerCommand{
+				URLRaw: "http://valid.url",
+				Delay:  90 * time.Minute,
+			},
+			wantURLRaw: "http://valid.url",
+			wantFireAt: now.Add(90 * time.Minute),
+			wantErr:    assert.NoError,
+		},
+		{
+			name: "recurring",
+			cmd: SetTimerCommand{
+				URLRaw: "http://valid.url",
+				Cron:   "@every 1h",
+			},
+			wantURLRaw: "http://valid.url",
+			wantFireAt: now.Add(time.Hour),
+			wantErr:    assert.NoError,
+		},
+		{
+			name: "recurring with invalid cron expression",
+			cmd: SetTimerCommand{
+				URLRaw: "http://valid.url",
+				Cron:   "hourly",
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "negative delay",
+			cmd: SetTimerCommand{
+				URLRaw: "http://valid.url",
+				Delay:  -time.Minute,
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "with retry policy",
+			cmd: SetTimerCommand{
+				URLRaw:      "http://valid.url",
+				RetryPolicy: &RetryPolicy{MaxAttempts: 3},
+			},
+			wantURLRaw: "http://valid.url",
+			wantFireAt: now,
+			wantErr:    assert.NoError,
+		},
+		{
+			name: "invalid retry policy",
+			cmd: SetTimerCommand{
+				URLRaw:      "http://valid.url",
+				RetryPolicy: &RetryPolicy{Jitter: 2},
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "with success criteria",
+			cmd: SetTimerCommand{
+				URLRaw:          "http://valid.url",
+				SuccessCriteria: &SuccessCriteria{AcceptedStatusCodes: []string{"200", "404"}},
+			},
+			wantURLRaw: "http://valid.url",
+			wantFireAt: now,
+			wantErr:    assert.NoError,
+		},
+		{
+			name: "invalid success criteria",
+			cmd: SetTimerCommand{
+				URLRaw:          "http://valid.url",
+				SuccessCriteria: &SuccessCriteria{AcceptedStatusCodes: []string{"ok"}},
+			},
+			wantErr: assert.Error,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got, err := NewTimerFromCommand(tt.cmd)
-			if !tt.wantErr(t, err, fmt.Sprintf("NewTimerFromCommand(%v)", tt.cmd)) {
+			if !tt.wantErr(t, err, fmt.Sprintf("NewTimerFromCommand(%v)", tt.cmd)) || err != nil {
 				return
 			}
 			assert.Equal(t, fmt.Sprintf("%s/%s", tt.wantURLRaw, got.ID), got.URL.String())
@@ -110,6 +201,128 @@ func TestNewTimerFromCommand(t *testing.T) {
 	}
 }
 
+func TestTimer_Reschedule(t *testing.T) {
+	now := time.Now()
+
+	t.Run("moves FireAt and bumps the revision", func(t *testing.T) {
+		tm, err := NewTimer("http://valid.url", 0, 0, 0)
+		require.NoError(t, err)
+
+		require.NoError(t, tm.Reschedule(1, 0, 0))
+		assert.WithinDuration(t, now.Add(time.Hour), tm.FireAt, time.Second)
+		assert.Equal(t, 1, tm.Revision)
+	})
+
+	t.Run("time in the past is rejected", func(t *testing.T) {
+		tm, err := NewTimer("http://valid.url", 1, 0, 0)
+		require.NoError(t, err)
+		fireAt := tm.FireAt
+
+		assert.ErrorIs(t, tm.Reschedule(-1, 0, 0), ErrFireAtInPast)
+		assert.Equal(t, fireAt, tm.FireAt)
+		assert.Equal(t, 0, tm.Revision)
+	})
+}
+
+func TestTimer_NextRun(t *testing.T) {
+	t.Run("recurring timer moves to the next occurrence", func(t *testing.T) {
+		r, err := NewRecurrence("@every 1m", "", time.Time{}, 2)
+		require.NoError(t, err)
+
+		tm, err := NewRecurringTimer("http://valid.url", r)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), tm.FireAt, time.Second)
+
+		assert.True(t, tm.NextRun())
+		assert.Equal(t, 1, tm.Recurrence.Runs)
+		assert.Equal(t, 1, tm.Revision)
+
+		assert.False(t, tm.NextRun(), "max runs is reached")
+		assert.Equal(t, 2, tm.Recurrence.Runs)
+	})
+
+	t.Run("one-off timer has no next run", func(t *testing.T) {
+		tm, err := NewTimer("http://valid.url", 0, 0, 0)
+		require.NoError(t, err)
+
+		assert.False(t, tm.NextRun())
+	})
+}
+
+func TestNewTimerFromCommand_URL(t *testing.T) {
+	t.Run("timer ID is appended by default", func(t *testing.T) {
+		tm, err := NewTimerFromCommand(SetTimerCommand{URLRaw: "http://valid.url/hooks"})
+		require.NoError(t, err)
+		assert.Equal(t, "http://valid.url/hooks/"+tm.ID, tm.URL.String())
+	})
+
+	t.Run("appending the timer ID can be disabled", func(t *testing.T) {
+		tm, err := NewTimerFromCommand(SetTimerCommand{URLRaw: "http://valid.url/hooks", DisableIDAppend: true})
+		require.NoError(t, err)
+		assert.Equal(t, "http://valid.url/hooks", tm.URL.String())
+		assert.Empty(t, tm.URLTemplate)
+	})
+
+	t.Run("URL template renders the first attempt", func(t *testing.T) {
+		tm, err := NewTimerFromCommand(SetTimerCommand{URLTemplate: "http://valid.url/hooks?timer={{.ID}}&run={{.Attempt}}"})
+		require.NoError(t, err)
+		assert.Equal(t, "http://valid.url/hooks?timer="+tm.ID+"&run=1", tm.URL.String())
+		assert.Equal(t, "http://valid.url/hooks?timer={{.ID}}&run={{.Attempt}}", tm.URLTemplate)
+	})
+
+	t.Run("invalid URL template", func(t *testing.T) {
+		_, err := NewTimerFromCommand(SetTimerCommand{URLTemplate: "http://valid.url/hooks?timer={{.ID"})
+		assert.ErrorIs(t, err, ErrInvalidURLTemplate)
+	})
+}
+
+func TestNewTimerFromCommand_ID(t *testing.T) {
+	t.Run("client supplied ID", func(t *testing.T) {
+		tm, err := NewTimerFromCommand(SetTimerCommand{ID: "order-42", URLRaw: "http://valid.url/hooks"})
+		require.NoError(t, err)
+		assert.Equal(t, "order-42", tm.ID)
+		assert.Equal(t, "http://valid.url/hooks/order-42", tm.URL.String())
+		assert.Equal(t, tm.CreatedAt.UnixNano(), tm.Generation)
+	})
+
+	t.Run("generated ID", func(t *testing.T) {
+		tm, err := NewTimerFromCommand(SetTimerCommand{URLRaw: "http://valid.url/hooks"})
+		require.NoError(t, err)
+		assert.Zero(t, tm.Generation)
+	})
+
+	for _, id := range []string{"-order", "order/42", "order 42", "order:42", strings.Repeat("a", 129)} {
+		t.Run("invalid ID "+id, func(t *testing.T) {
+			_, err := NewTimerFromCommand(SetTimerCommand{ID: id, URLRaw: "http://valid.url/hooks"})
+			assert.ErrorIs(t, err, ErrInvalidTimerID)
+		})
+	}
+}
+
+func TestTimer_WebhookURL(t *testing.T) {
+	t.Run("without template", func(t *testing.T) {
+		tm, err := NewTimer("http://valid.url", 0, 0, 0)
+		require.NoError(t, err)
+
+		got, err := tm.WebhookURL(3)
+		require.NoError(t, err)
+		assert.Equal(t, tm.URL.String(), got)
+	})
+
+	t.Run("with template", func(t *testing.T) {
+		tm, err := NewTimerFromCommand(SetTimerCommand{
+			URLTemplate: "http://valid.url/hooks/{{.ID}}?attempt={{.Attempt}}&run={{.Run}}",
+			Cron:        "@hourly",
+		})
+		require.NoError(t, err)
+		tm.Recurrence.Runs = 4
+
+		got, err := tm.WebhookURL(3)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("http://valid.url/hooks/%s?attempt=3&run=5", tm.ID), got)
+	})
+}
+
 func TestTimer_DelayFromNowSeconds(t1 *testing.T) {
 	now := time.Now()
 
@@ -0,0 +1,17 @@
+package timer
+
+import (
+	"errors"
+)
+
+// ErrLeaseLost indicates that the lease is taken over by another holder, i.e. its fencing token is stale.
+var ErrLeaseLost = errors.New("lease is lost")
+
+// Lease is the leadership of the relays. only the holder of the lease relays the outbox, and it keeps renewing the
+// lease while it is alive. another relay takes the lease over once it expires.
+type Lease struct {
+	Holder string
+	// Token fences off a former holder: it grows every time the lease changes hands, and the outbox refuses the
+	// tokens other than the latest one.
+	Token int64
+}
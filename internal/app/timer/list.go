@@ -0,0 +1,91 @@
+package timer
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned when the cursor of ListTimersQuery is malformed.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// DefaultListLimit and MaxListLimit bound the number of timers in a page.
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// ListTimersQuery filters and paginates the timers, ordered by their fire time. only the timers that are neither
+// archived nor cancelled are listed.
+type ListTimersQuery struct {
+	// Status is the optional lifecycle state of the timers. see IsListed.
+	Status State
+	// Due optionally lists the timers whose fire time is passed but are not delivered yet, e.g. being retried, when
+	// true, and the ones whose fire time is in the future when false.
+	Due *bool
+	// From and To are the optional inclusive bounds of the fire time.
+	From time.Time
+	To   time.Time
+	// Host is the optional host of the timer URL.
+	Host string
+	// Labels must all be present on the timer.
+	Labels map[string]string
+	// Limit is the maximum number of timers in a page.
+	Limit int
+	// Cursor is the opaque position returned as TimersPage.NextCursor.
+	Cursor string
+}
+
+// TimersPage is a page of the timers.
+type TimersPage struct {
+	// Timers are the states of the timers, each along with its timer. see TimerState.Timer.
+	Timers []*TimerState
+	// NextCursor is empty when there are no more timers.
+	NextCursor string
+}
+
+// normalize applies the defaults and turns due into fire time bounds.
+func (q ListTimersQuery) normalize(now time.Time) ListTimersQuery {
+	if q.Limit <= 0 {
+		q.Limit = DefaultListLimit
+	}
+
+	if q.Limit > MaxListLimit {
+		q.Limit = MaxListLimit
+	}
+
+	switch {
+	case q.Due == nil:
+	case *q.Due:
+		if q.To.IsZero() || q.To.After(now) {
+			q.To = now
+		}
+	default:
+		if q.From.Before(now) {
+			q.From = now
+		}
+	}
+
+	return q
+}
+
+// Matches tells whether the state of the timer satisfies the status filter, and its timer the host and labels
+// filters.
+func (q ListTimersQuery) Matches(state *TimerState) bool {
+	if q.Status != "" && q.Status != state.State {
+		return false
+	}
+
+	t := state.Timer
+	if q.Host != "" && !strings.EqualFold(q.Host, t.URL.Hostname()) {
+		return false
+	}
+
+	for key, value := range q.Labels {
+		if v, ok := t.Labels[key]; !ok || v != value {
+			return false
+		}
+	}
+
+	return true
+}
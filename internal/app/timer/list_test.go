@@ -0,0 +1,37 @@
+package timer_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+)
+
+func TestListTimersQuery_Matches(t *testing.T) {
+	tm, err := timer.NewTimer("http://API.example.com/hook", 0, 0, 1)
+	require.NoError(t, err)
+	tm.Labels = map[string]string{"team": "payments", "env": "prod"}
+	state := timer.NewTimerState(tm, timer.StateRelayed)
+
+	tests := []struct {
+		name  string
+		query timer.ListTimersQuery
+		want  bool
+	}{
+		{name: "no filters", query: timer.ListTimersQuery{}, want: true},
+		{name: "host matches case-insensitively", query: timer.ListTimersQuery{Host: "api.example.com"}, want: true},
+		{name: "host does not match", query: timer.ListTimersQuery{Host: "example.com"}, want: false},
+		{name: "all labels match", query: timer.ListTimersQuery{Labels: map[string]string{"team": "payments", "env": "prod"}}, want: true},
+		{name: "label value differs", query: timer.ListTimersQuery{Labels: map[string]string{"team": "search"}}, want: false},
+		{name: "label is missing", query: timer.ListTimersQuery{Labels: map[string]string{"region": "eu"}}, want: false},
+		{name: "status matches", query: timer.ListTimersQuery{Status: timer.StateRelayed}, want: true},
+		{name: "status does not match", query: timer.ListTimersQuery{Status: timer.StatePending}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.query.Matches(state))
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package timer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	ErrInvalidRecurrence = errors.New("invalid recurrence")
+	ErrRecurrenceEnded   = errors.New("recurrence has no further occurrence")
+)
+
+// Recurrence makes a timer fire repeatedly on the occurrences of a cron expression.
+// The recurrence ends when MaxRuns is reached or when the next occurrence is after EndAt, whichever comes first.
+type Recurrence struct {
+	// Cron is a standard cron expression with five fields or a descriptor such as @hourly or @every 15m.
+	Cron string
+	// Timezone is the IANA name of the location in which the cron expression is evaluated. defaults to UTC.
+	Timezone string
+	// EndAt is the optional time after which there is no occurrence.
+	EndAt time.Time
+	// MaxRuns is the optional maximum number of runs.
+	MaxRuns int
+	// Runs is the number of runs so far.
+	Runs int
+}
+
+// NewRecurrence constructs a valid Recurrence.
+func NewRecurrence(cronExpr, timezone string, endAt time.Time, maxRuns int) (*Recurrence, error) {
+	if maxRuns < 0 {
+		return nil, fmt.Errorf("%w: max runs cannot be negative", ErrInvalidRecurrence)
+	}
+
+	r := &Recurrence{
+		Cron:     cronExpr,
+		Timezone: timezone,
+		EndAt:    endAt,
+		MaxRuns:  maxRuns,
+	}
+
+	if _, _, err := r.schedule(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Next returns the first occurrence after the given time. it returns false when the recurrence has ended.
+func (r *Recurrence) Next(after time.Time) (time.Time, bool) {
+	if r.MaxRuns > 0 && r.Runs >= r.MaxRuns {
+		return time.Time{}, false
+	}
+
+	schedule, location, err := r.schedule()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	next := schedule.Next(after.In(location))
+	if next.IsZero() || (!r.EndAt.IsZero() && next.After(r.EndAt)) {
+		return time.Time{}, false
+	}
+
+	return next, true
+}
+
+func (r *Recurrence) schedule() (cron.Schedule, *time.Location, error) {
+	location := time.UTC
+	if r.Timezone != "" {
+		var err error
+		location, err = time.LoadLocation(r.Timezone)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidRecurrence, r.Timezone)
+		}
+	}
+
+	schedule, err := cron.ParseStandard(r.Cron)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+	}
+
+	return schedule, location, nil
+}
@@ -0,0 +1,92 @@
+package timer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRecurrence(t *testing.T) {
+	tests := []struct {
+		name     string
+		cron     string
+		timezone string
+		maxRuns  int
+		wantErr  assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "standard cron expression",
+			cron:    "*/15 * * * *",
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "descriptor",
+			cron:    "@every 15m",
+			wantErr: assert.NoError,
+		},
+		{
+			name:     "with timezone",
+			cron:     "0 9 * * 1-5",
+			timezone: "Europe/Berlin",
+			wantErr:  assert.NoError,
+		},
+		{
+			name:    "invalid cron expression",
+			cron:    "every monday",
+			wantErr: assert.Error,
+		},
+		{
+			name:     "unknown timezone",
+			cron:     "0 9 * * 1-5",
+			timezone: "Mars/Olympus",
+			wantErr:  assert.Error,
+		},
+		{
+			name:    "negative max runs",
+			cron:    "@hourly",
+			maxRuns: -1,
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRecurrence(tt.cron, tt.timezone, time.Time{}, tt.maxRuns)
+			if tt.wantErr(t, err) && err != nil {
+				assert.ErrorIs(t, err, ErrInvalidRecurrence)
+			}
+		})
+	}
+}
+
+func TestRecurrence_Next(t *testing.T) {
+	// Monday 2023-01-02 12:00 UTC, i.e. 13:00 in Berlin
+	monday := time.Date(2023, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	t.Run("evaluates the expression in the timezone", func(t *testing.T) {
+		r, err := NewRecurrence("0 9 * * 1-5", "Europe/Berlin", time.Time{}, 0)
+		require.NoError(t, err)
+
+		next, ok := r.Next(monday)
+		require.True(t, ok)
+		assert.True(t, time.Date(2023, 1, 3, 8, 0, 0, 0, time.UTC).Equal(next))
+	})
+
+	t.Run("ends after max runs", func(t *testing.T) {
+		r, err := NewRecurrence("@hourly", "", time.Time{}, 2)
+		require.NoError(t, err)
+
+		r.Runs = 2
+		_, ok := r.Next(monday)
+		assert.False(t, ok)
+	})
+
+	t.Run("ends at end date", func(t *testing.T) {
+		r, err := NewRecurrence("@hourly", "", monday.Add(30*time.Minute), 0)
+		require.NoError(t, err)
+
+		_, ok := r.Next(monday)
+		assert.False(t, ok)
+	})
+}
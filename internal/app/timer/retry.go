@@ -0,0 +1,102 @@
+package timer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var ErrInvalidRetryPolicy = errors.New("invalid retry policy")
+
+// MaxRetryAttempts is the maximum number of the delivery attempts of a run.
+const MaxRetryAttempts = 100
+
+// DefaultMaxBackoff caps the delay between two attempts when the policy has no MaxBackoff.
+const DefaultMaxBackoff = 24 * time.Hour
+
+// RetryPolicy describes how many times and how often the webhook of a failed run is retried.
+// the zero fields are not set, and fall back to the defaults of the service. see WithDefaults.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of the delivery attempts of a run, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts. DefaultMaxBackoff when not set.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after every retry. it is at least 1.
+	Multiplier float64
+	// Jitter randomises the delay by the given fraction, between 0 and 1, to spread the retries out.
+	Jitter float64
+	// Deadline is the time after the FireAt of a run after which the run is not retried anymore.
+	Deadline time.Duration
+}
+
+// Validate checks the set fields of the policy.
+func (p RetryPolicy) Validate() error {
+	switch {
+	case p.MaxAttempts < 0 || p.MaxAttempts > MaxRetryAttempts:
+		return fmt.Errorf("%w: max attempts must be between 1 and %d, or 0 for the default", ErrInvalidRetryPolicy, MaxRetryAttempts)
+	case p.InitialBackoff < 0 || p.MaxBackoff < 0 || p.Deadline < 0:
+		return fmt.Errorf("%w: durations cannot be negative", ErrInvalidRetryPolicy)
+	case p.MaxBackoff != 0 && p.InitialBackoff > p.MaxBackoff:
+		return fmt.Errorf("%w: initial backoff cannot be greater than max backoff", ErrInvalidRetryPolicy)
+	case p.Multiplier != 0 && p.Multiplier < 1:
+		return fmt.Errorf("%w: multiplier must be at least 1", ErrInvalidRetryPolicy)
+	case p.Jitter < 0 || p.Jitter > 1:
+		return fmt.Errorf("%w: jitter must be between 0 and 1", ErrInvalidRetryPolicy)
+	}
+	return nil
+}
+
+// WithDefaults fills the fields that are not set with the ones of the defaults.
+func (p RetryPolicy) WithDefaults(defaults RetryPolicy) RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = defaults.MaxAttempts
+	}
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = defaults.InitialBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = defaults.MaxBackoff
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = defaults.Multiplier
+	}
+	if p.Jitter == 0 {
+		p.Jitter = defaults.Jitter
+	}
+	if p.Deadline == 0 {
+		p.Deadline = defaults.Deadline
+	}
+	return p
+}
+
+// Backoff returns the delay before the next attempt, after the given number of retries so far.
+// random is a number in [0, 1) that decides the jitter.
+func (p RetryPolicy) Backoff(retried int, random float64) time.Duration {
+	multiplier := math.Max(p.Multiplier, 1)
+	delay := float64(p.InitialBackoff) * math.Pow(multiplier, float64(retried))
+	delay *= 1 + p.Jitter*(2*random-1)
+
+	maxBackoff := p.MaxBackoff
+	if maxBackoff == 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	// the delay may be too long for a duration, or even infinite, after many retries.
+	if delay > float64(maxBackoff) {
+		return maxBackoff
+	}
+
+	return time.Duration(delay)
+}
+
+// RetryDeadline returns the time after which the current run of the timer is not retried anymore, or false when
+// the policy has no deadline.
+func (p RetryPolicy) RetryDeadline(fireAt time.Time) (time.Time, bool) {
+	if p.Deadline == 0 {
+		return time.Time{}, false
+	}
+	return fireAt.Add(p.Deadline), true
+}
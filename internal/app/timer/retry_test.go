@@ -0,0 +1,79 @@
+package timer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{name: "empty policy uses the defaults", policy: RetryPolicy{}, wantErr: assert.NoError},
+		{
+			name:    "full policy",
+			policy:  RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: time.Minute, Multiplier: 2, Jitter: 0.5, Deadline: time.Hour},
+			wantErr: assert.NoError,
+		},
+		{name: "too many attempts", policy: RetryPolicy{MaxAttempts: MaxRetryAttempts + 1}, wantErr: assert.Error},
+		{name: "negative attempts", policy: RetryPolicy{MaxAttempts: -1}, wantErr: assert.Error},
+		{name: "negative backoff", policy: RetryPolicy{InitialBackoff: -time.Second}, wantErr: assert.Error},
+		{name: "initial backoff greater than max", policy: RetryPolicy{InitialBackoff: time.Hour, MaxBackoff: time.Minute}, wantErr: assert.Error},
+		{name: "shrinking multiplier", policy: RetryPolicy{Multiplier: 0.5}, wantErr: assert.Error},
+		{name: "jitter out of range", policy: RetryPolicy{Jitter: 1.5}, wantErr: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.wantErr(t, err) && err != nil {
+				assert.ErrorIs(t, err, ErrInvalidRetryPolicy)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_WithDefaults(t *testing.T) {
+	defaults := RetryPolicy{MaxAttempts: 11, InitialBackoff: 10 * time.Second, MaxBackoff: time.Hour, Multiplier: 2, Jitter: 0.2}
+
+	got := RetryPolicy{MaxAttempts: 3, Deadline: time.Hour}.WithDefaults(defaults)
+
+	assert.Equal(t, RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     time.Hour,
+		Multiplier:     2,
+		Jitter:         0.2,
+		Deadline:       time.Hour,
+	}, got)
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute, Multiplier: 3, Jitter: 0.5}
+
+	// random of 0.5 means no jitter
+	assert.Equal(t, time.Second, policy.Backoff(0, 0.5))
+	assert.Equal(t, 9*time.Second, policy.Backoff(2, 0.5))
+	assert.Equal(t, time.Minute, policy.Backoff(10, 0.5))
+
+	assert.Equal(t, 500*time.Millisecond, policy.Backoff(0, 0))
+	assert.Equal(t, 1500*time.Millisecond, policy.Backoff(0, 1))
+
+	// without a max backoff, the delay that would overflow is capped by the default
+	policy = RetryPolicy{InitialBackoff: time.Second, Multiplier: 1000}
+	assert.Equal(t, DefaultMaxBackoff, policy.Backoff(99, 0.5))
+}
+
+func TestRetryPolicy_RetryDeadline(t *testing.T) {
+	fireAt := time.Unix(1700000000, 0)
+
+	_, ok := RetryPolicy{}.RetryDeadline(fireAt)
+	assert.False(t, ok)
+
+	deadline, ok := RetryPolicy{Deadline: time.Hour}.RetryDeadline(fireAt)
+	assert.True(t, ok)
+	assert.Equal(t, fireAt.Add(time.Hour), deadline)
+}
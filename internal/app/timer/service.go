@@ -3,36 +3,130 @@ package timer
 import (
 	"context"
 	"errors"
+	"net/url"
+	"time"
 )
 
 var (
-	ErrTimerNotFound = errors.New("timer not found")
-	ErrTimerArchived = errors.New("timer is archived")
+	ErrTimerNotFound  = errors.New("timer not found")
+	ErrTimerArchived  = errors.New("timer is archived")
+	ErrTimerCancelled = errors.New("timer is cancelled")
+	// ErrTimerExists is returned when a timer is created with the ID of an existing timer.
+	ErrTimerExists = errors.New("timer already exists")
+	// ErrTimerModified is returned when the timer is rescheduled while another reschedule of the same revision is in
+	// progress, or when it is cancelled while it is created again with the same ID.
+	ErrTimerModified = errors.New("timer is modified concurrently")
+	// ErrTimerAlreadyCreated is returned along with the originally created timer when a timer is created again with the
+	// same idempotency key. only the ID of the original timer is set.
+	ErrTimerAlreadyCreated = errors.New("timer is already created")
 )
 
 type ServiceImp struct {
 	repo Repo
+	// policy is nil when the webhooks can be sent anywhere.
+	policy DestinationPolicy
 }
 
-// NewService creates a new Service
-func NewService(db Repo) (*ServiceImp, error) {
-	return &ServiceImp{repo: db}, nil
+// NewService creates a new Service. the destination policy is optional.
+func NewService(db Repo, policy DestinationPolicy) (*ServiceImp, error) {
+	return &ServiceImp{repo: db, policy: policy}, nil
 }
 
 // CreateTimer creates timer
 func (s *ServiceImp) CreateTimer(ctx context.Context, cmd SetTimerCommand) (*Timer, error) {
-	timer, err := NewTimerFromCommand(cmd)
+	timer, err := s.newTimer(cmd)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = s.repo.AddTimer(ctx, timer); err != nil {
+	if cmd.IdempotencyKey == "" {
+		if err = s.repo.AddTimer(ctx, timer); err != nil {
+			return timer, err
+		}
+
+		return timer, nil
+	}
+
+	originalID, err := s.repo.AddTimerIdempotently(ctx, timer, cmd.IdempotencyKey)
+	switch {
+	case err != nil:
 		return timer, err
+	case originalID != "":
+		return &Timer{ID: originalID}, ErrTimerAlreadyCreated
 	}
 
 	return timer, nil
 }
 
+// addTimersChunkSize is the number of the timers that CreateTimers adds to the repo at once.
+const addTimersChunkSize = 500
+
+// CreateTimerResult is the outcome of creating one of the timers of a batch.
+// either Timer or Err is set.
+type CreateTimerResult struct {
+	Timer *Timer
+	Err   error
+}
+
+// CreateTimers creates a batch of timers. every command succeeds or fails on its own, and the results are in the
+// order of the commands. the valid timers are added to the repo in chunks, so a failing chunk fails all its timers.
+func (s *ServiceImp) CreateTimers(ctx context.Context, cmds []SetTimerCommand) []CreateTimerResult {
+	results := make([]CreateTimerResult, len(cmds))
+
+	chunk := make([]*Timer, 0, addTimersChunkSize)
+	indexes := make([]int, 0, addTimersChunkSize)
+	flush := func() {
+		if len(chunk) == 0 {
+			return
+		}
+
+		conflicts, err := s.repo.AddTimers(ctx, chunk)
+		if err != nil {
+			for _, i := range indexes {
+				results[i] = CreateTimerResult{Err: err}
+			}
+		}
+
+		// a batch may hold the same client supplied ID more than once, then only the first one is added.
+		conflicted := make(map[string]int, len(conflicts))
+		for _, id := range conflicts {
+			conflicted[id]++
+		}
+		for j := len(indexes) - 1; j >= 0 && len(conflicted) > 0; j-- {
+			id := chunk[j].ID
+			if conflicted[id] == 0 {
+				continue
+			}
+			conflicted[id]--
+			if conflicted[id] == 0 {
+				delete(conflicted, id)
+			}
+			results[indexes[j]] = CreateTimerResult{Err: ErrTimerExists}
+		}
+
+		chunk, indexes = chunk[:0], indexes[:0]
+	}
+
+	for i, cmd := range cmds {
+		timer, err := s.newTimer(cmd)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		results[i].Timer = timer
+		chunk = append(chunk, timer)
+		indexes = append(indexes, i)
+
+		if len(chunk) == addTimersChunkSize {
+			flush()
+		}
+	}
+	flush()
+
+	return results
+}
+
 // GetTimer fetches a timer by ID from the repo
 func (s *ServiceImp) GetTimer(ctx context.Context, timerID string) (*Timer, error) {
 	timer, err := s.repo.Find(ctx, timerID)
@@ -43,18 +137,264 @@ func (s *ServiceImp) GetTimer(ctx context.Context, timerID string) (*Timer, erro
 		return timer, nil
 	}
 
+	return nil, s.missingTimerError(ctx, timerID)
+}
+
+// ArchiveTimer archives the timer whose run finished with the outcome.
+func (s *ServiceImp) ArchiveTimer(ctx context.Context, timerID string, outcome State) error {
+	return s.repo.Archive(ctx, NewTombstone(timerID, outcome))
+}
+
+// ScheduleNextRun schedules the next occurrence of a recurring timer after a run is finished.
+// the timer is archived with the outcome of the run when the recurrence has ended. the timer that is cancelled or
+// rescheduled meanwhile is left as is, as the next run is already up to the cancellation or the reschedule.
+func (s *ServiceImp) ScheduleNextRun(ctx context.Context, timer *Timer, outcome State) error {
+	if !timer.NextRun() {
+		return s.repo.Archive(ctx, NewTombstone(timer.ID, outcome))
+	}
+
+	err := s.repo.Reschedule(ctx, timer)
+	if err == ErrTimerNotFound || err == ErrTimerModified {
+		return nil
+	}
+
+	return err
+}
+
+// ListTimers lists the timers that are neither archived nor cancelled, ordered by their fire time.
+func (s *ServiceImp) ListTimers(ctx context.Context, query ListTimersQuery) (*TimersPage, error) {
+	return s.repo.List(ctx, query.normalize(time.Now()))
+}
+
+// RescheduleTimer moves the fire time of a pending timer. the timer is relayed again through the outbox and the
+// previously scheduled task becomes stale, so exactly one webhook is shot at the new time. it returns
+// ErrTimerModified when the timer is rescheduled concurrently.
+func (s *ServiceImp) RescheduleTimer(ctx context.Context, cmd RescheduleTimerCommand) (*Timer, error) {
+	timer, err := s.repo.Find(ctx, cmd.TimerID)
+	switch {
+	case err != nil:
+		return nil, err
+	case timer == nil:
+		return nil, s.missingTimerError(ctx, cmd.TimerID)
+	}
+
+	if err = timer.Reschedule(time.Duration(cmd.Hours), time.Duration(cmd.Minutes), time.Duration(cmd.Seconds)); err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Reschedule(ctx, timer)
+	switch {
+	case err == ErrTimerNotFound:
+		return nil, s.missingTimerError(ctx, cmd.TimerID)
+	case err != nil:
+		return nil, err
+	}
+
+	return timer, nil
+}
+
+// CancelTimer removes a pending timer so that its webhook is never shot.
+// Timers that are already archived or cancelled cannot be cancelled. it returns ErrTimerModified when the timer is
+// archived and created again with the same ID meanwhile.
+func (s *ServiceImp) CancelTimer(ctx context.Context, timerID string) error {
+	timer, err := s.repo.Find(ctx, timerID)
+	switch {
+	case err != nil:
+		return err
+	case timer == nil:
+		return s.missingTimerError(ctx, timerID)
+	}
+
+	err = s.repo.Cancel(ctx, timer)
+	if err == ErrTimerNotFound {
+		return s.missingTimerError(ctx, timerID)
+	}
+
+	return err
+}
+
+// GetTimerState responds the lifecycle state of the current run of the timer. a timer is pending until its current
+// revision is relayed, and the state of the archived and cancelled timers is kept until the max TTL of timers. the
+// outcome of an archived timer whose state is gone is told by its tombstone, as long as the tombstone is kept.
+func (s *ServiceImp) GetTimerState(ctx context.Context, timerID string) (*TimerState, error) {
+	state, err := s.repo.FindState(ctx, timerID)
+	if err != nil {
+		return nil, err
+	}
+
+	timer, err := s.repo.Find(ctx, timerID)
+	switch {
+	case err != nil:
+		return nil, err
+	case timer != nil:
+		return state.Of(timer), nil
+	case state != nil && state.State.IsFinal():
+		return state, nil
+	}
+
+	tombstone, err := s.repo.FindTombstone(ctx, timerID)
+	switch {
+	case err != nil:
+		return nil, err
+	case tombstone != nil:
+		return tombstone.state(), nil
+	}
+
+	return nil, s.missingTimerError(ctx, timerID)
+}
+
+// SetTimerState keeps the state of the current run of the timer.
+func (s *ServiceImp) SetTimerState(ctx context.Context, state *TimerState) error {
+	return s.repo.SetState(ctx, state)
+}
+
+// DeadLetterTimer keeps the permanently failed run of the timer in the dead letter store for inspection and replay.
+// a one-off timer is archived, while a recurring timer goes on with its next run.
+func (s *ServiceImp) DeadLetterTimer(ctx context.Context, deadLetter *DeadLetter) error {
+	if err := s.repo.AddDeadLetter(ctx, deadLetter); err != nil {
+		return err
+	}
+
+	if deadLetter.Timer.IsRecurring() {
+		return nil
+	}
+
+	return s.repo.Archive(ctx, NewTombstone(deadLetter.Timer.ID, StateFailed))
+}
+
+// GetDeadLetter fetches a dead letter by ID from the repo
+func (s *ServiceImp) GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error) {
+	deadLetter, err := s.repo.FindDeadLetter(ctx, id)
+	switch {
+	case err != nil:
+		return nil, err
+	case deadLetter == nil:
+		return nil, ErrDeadLetterNotFound
+	}
+
+	return deadLetter, nil
+}
+
+// ListDeadLetters lists the dead letters ordered by the time they failed.
+func (s *ServiceImp) ListDeadLetters(ctx context.Context, query ListDeadLettersQuery) (*DeadLettersPage, error) {
+	return s.repo.ListDeadLetters(ctx, query.normalize())
+}
+
+// ReplayDeadLetters schedules the webhooks of the dead letters to be shot again right away. every dead letter
+// succeeds or fails on its own, and the results are in the order of the IDs. a replayed dead letter is removed.
+func (s *ServiceImp) ReplayDeadLetters(ctx context.Context, cmd ReplayDeadLettersCommand) []ReplayDeadLetterResult {
+	results := make([]ReplayDeadLetterResult, 0, len(cmd.IDs))
+	for _, id := range cmd.IDs {
+		timer, err := s.replayDeadLetter(ctx, id, cmd.URLRaw)
+		results = append(results, ReplayDeadLetterResult{ID: id, Timer: timer, Err: err})
+	}
+
+	return results
+}
+
+func (s *ServiceImp) replayDeadLetter(ctx context.Context, id, rawURL string) (*Timer, error) {
+	deadLetter, err := s.GetDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	timer, err := deadLetter.Replay(rawURL, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.allowDestination(timer); err != nil {
+		return nil, err
+	}
+
+	if err = s.repo.ReplayDeadLetter(ctx, id, timer); err != nil {
+		return nil, err
+	}
+
+	return timer, nil
+}
+
+// PurgeDeadLetters removes the dead letters of the IDs, or all of them when no ID is given.
+func (s *ServiceImp) PurgeDeadLetters(ctx context.Context, ids []string) (int, error) {
+	return s.repo.PurgeDeadLetters(ctx, ids)
+}
+
+// RecordAttempt adds the delivery attempt to the attempt history of the timer.
+func (s *ServiceImp) RecordAttempt(ctx context.Context, timerID string, attempt *Attempt) error {
+	return s.repo.AddAttempt(ctx, timerID, attempt)
+}
+
+// ListAttempts lists the latest delivery attempts of the timer, the latest attempt first. the attempts of the
+// archived and cancelled timers are listed as well, and ErrTimerNotFound is returned only when the timer never
+// existed.
+func (s *ServiceImp) ListAttempts(ctx context.Context, timerID string) ([]*Attempt, error) {
+	attempts, err := s.repo.ListAttempts(ctx, timerID)
+	if err != nil || len(attempts) > 0 {
+		return attempts, err
+	}
+
+	_, err = s.GetTimer(ctx, timerID)
+	switch err {
+	case nil, ErrTimerArchived, ErrTimerCancelled:
+		return attempts, nil
+	default:
+		return nil, err
+	}
+}
+
+// missingTimerError explains why a timer is no longer in the repo:
+// it was either cancelled, archived or it never existed.
+func (s *ServiceImp) missingTimerError(ctx context.Context, timerID string) error {
+	cancelled, err := s.repo.IsCancelled(ctx, timerID)
+	switch {
+	case err != nil:
+		return err
+	case cancelled:
+		return ErrTimerCancelled
+	}
+
 	// check whether the timer is archived
 	archived, err := s.repo.IsArchived(ctx, timerID)
 	switch {
 	case err != nil:
-		return nil, err
+		return err
 	case !archived:
-		return nil, ErrTimerNotFound
+		return ErrTimerNotFound
 	default:
-		return nil, ErrTimerArchived
+		return ErrTimerArchived
 	}
 }
 
-func (s *ServiceImp) ArchiveTimer(ctx context.Context, timerID string) error {
-	return s.repo.Archive(ctx, timerID)
+// newTimer creates the timer of the command, if the destination policy allows its URL.
+func (s *ServiceImp) newTimer(cmd SetTimerCommand) (*Timer, error) {
+	timer, err := NewTimerFromCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.allowDestination(timer); err != nil {
+		return nil, err
+	}
+
+	return timer, nil
+}
+
+// allowDestination returns ErrDestinationNotAllowed when the destination policy does not allow the URL of the first
+// attempt of the timer. the URL of every attempt is checked again when the webhook is sent.
+func (s *ServiceImp) allowDestination(timer *Timer) error {
+	if s.policy == nil {
+		return nil
+	}
+
+	rawURL, err := timer.WebhookURL(1)
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	return s.policy.AllowURL(u)
 }
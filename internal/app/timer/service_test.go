@@ -50,7 +50,7 @@ func TestServiceImp_CreateTimer(t *testing.T) {
 			repo := mocks.NewRepo(ctrl)
 			repo.EXPECT().AddTimer(gomock.Any(), gomock.Any()).Return(tt.repoError)
 
-			s, err := timer.NewService(repo)
+			s, err := timer.NewService(repo, nil)
 			require.NoError(t, err)
 
 			got, err := s.CreateTimer(context.Background(), tt.cmd)
@@ -67,6 +67,126 @@ func TestServiceImp_CreateTimer(t *testing.T) {
 	}
 }
 
+func TestServiceImp_CreateTimer_destinationPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mocks.NewRepo(ctrl)
+	policy := mocks.NewDestinationPolicy(ctrl)
+
+	s, err := timer.NewService(repo, policy)
+	require.NoError(t, err)
+
+	t.Run("creates the timer of an allowed destination", func(t *testing.T) {
+		policy.EXPECT().AllowURL(gomock.Any()).Return(nil)
+		repo.EXPECT().AddTimer(gomock.Any(), gomock.Any()).Return(nil)
+
+		_, err := s.CreateTimer(context.Background(), timer.SetTimerCommand{URLRaw: "http://valid.url"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("rejects the timer of a destination that is not allowed", func(t *testing.T) {
+		policy.EXPECT().AllowURL(gomock.Any()).Return(timer.ErrDestinationNotAllowed)
+
+		_, err := s.CreateTimer(context.Background(), timer.SetTimerCommand{URLRaw: "http://169.254.169.254"})
+		assert.ErrorIs(t, err, timer.ErrDestinationNotAllowed)
+	})
+}
+
+func TestServiceImp_CreateTimer_idempotently(t *testing.T) {
+	cmd := timer.SetTimerCommand{URLRaw: "http://valid.url", IdempotencyKey: "order-42"}
+
+	t.Run("creates the timer", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewRepo(ctrl)
+		repo.EXPECT().AddTimerIdempotently(gomock.Any(), gomock.Any(), "order-42").Return("", nil)
+
+		s, err := timer.NewService(repo, nil)
+		require.NoError(t, err)
+
+		got, err := s.CreateTimer(context.Background(), cmd)
+		require.NoError(t, err)
+		assert.NotEmpty(t, got.ID)
+	})
+
+	t.Run("returns the original timer", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewRepo(ctrl)
+		repo.EXPECT().AddTimerIdempotently(gomock.Any(), gomock.Any(), "order-42").Return("1", nil)
+
+		s, err := timer.NewService(repo, nil)
+		require.NoError(t, err)
+
+		got, err := s.CreateTimer(context.Background(), cmd)
+		assert.ErrorIs(t, err, timer.ErrTimerAlreadyCreated)
+		assert.Equal(t, "1", got.ID)
+	})
+}
+
+func TestServiceImp_CreateTimers(t *testing.T) {
+	t.Run("invalid commands fail on their own", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewRepo(ctrl)
+		repo.EXPECT().AddTimers(gomock.Any(), gomock.Len(2)).Return(nil, nil)
+
+		s, err := timer.NewService(repo, nil)
+		require.NoError(t, err)
+
+		results := s.CreateTimers(context.Background(), []timer.SetTimerCommand{
+			{URLRaw: "http://valid.url"},
+			{URLRaw: "invalid.url"},
+			{URLRaw: "http://valid.url"},
+		})
+		require.Len(t, results, 3)
+		assert.NoError(t, results[0].Err)
+		assert.NotNil(t, results[0].Timer)
+		assert.Error(t, results[1].Err)
+		assert.Nil(t, results[1].Timer)
+		assert.NoError(t, results[2].Err)
+		assert.NotNil(t, results[2].Timer)
+	})
+
+	t.Run("adds the timers in chunks", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewRepo(ctrl)
+		gomock.InOrder(
+			repo.EXPECT().AddTimers(gomock.Any(), gomock.Len(500)).Return(nil, nil),
+			repo.EXPECT().AddTimers(gomock.Any(), gomock.Len(1)).Return(nil, assert.AnError),
+		)
+
+		s, err := timer.NewService(repo, nil)
+		require.NoError(t, err)
+
+		cmds := make([]timer.SetTimerCommand, 501)
+		for i := range cmds {
+			cmds[i] = timer.SetTimerCommand{URLRaw: "http://valid.url"}
+		}
+
+		results := s.CreateTimers(context.Background(), cmds)
+		require.Len(t, results, 501)
+		assert.NoError(t, results[499].Err)
+		assert.ErrorIs(t, results[500].Err, assert.AnError)
+		assert.Nil(t, results[500].Timer)
+	})
+
+	t.Run("timers with a taken ID fail", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewRepo(ctrl)
+		repo.EXPECT().AddTimers(gomock.Any(), gomock.Len(3)).Return([]string{"order-42"}, nil)
+
+		s, err := timer.NewService(repo, nil)
+		require.NoError(t, err)
+
+		results := s.CreateTimers(context.Background(), []timer.SetTimerCommand{
+			{ID: "order-42", URLRaw: "http://valid.url"},
+			{ID: "order-43", URLRaw: "http://valid.url"},
+			{ID: "order-42", URLRaw: "http://valid.url"},
+		})
+		require.Len(t, results, 3)
+		assert.NoError(t, results[0].Err)
+		assert.NoError(t, results[1].Err)
+		assert.ErrorIs(t, results[2].Err, timer.ErrTimerExists)
+	})
+}
+
 func TestServiceImp_GetTimer(t *testing.T) {
 	ctrl := gomock.NewController(t)
 
@@ -78,6 +198,7 @@ func TestServiceImp_GetTimer(t *testing.T) {
 		isRepoIsArchivedCalled bool
 		repoIsArchivedError    error
 		repoIsArchived         bool
+		repoIsCancelled        bool
 		want                   *timer.Timer
 		wantErr                error
 	}{
@@ -110,6 +231,16 @@ func TestServiceImp_GetTimer(t *testing.T) {
 			want:                   nil,
 			wantErr:                timer.ErrTimerArchived,
 		},
+		{
+			name:                   "cancelled",
+			timerID:                "1",
+			repoFindError:          nil,
+			repoFindTimer:          nil,
+			isRepoIsArchivedCalled: false,
+			repoIsCancelled:        true,
+			want:                   nil,
+			wantErr:                timer.ErrTimerCancelled,
+		},
 		{
 			name:                   "not found, isArchived errors",
 			timerID:                "1",
@@ -137,11 +268,14 @@ func TestServiceImp_GetTimer(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			repo := mocks.NewRepo(ctrl)
 			repo.EXPECT().Find(gomock.Any(), tt.timerID).Return(tt.repoFindTimer, tt.repoFindError)
+			if tt.repoFindTimer == nil && tt.repoFindError == nil {
+				repo.EXPECT().IsCancelled(gomock.Any(), tt.timerID).Return(tt.repoIsCancelled, nil)
+			}
 			if tt.isRepoIsArchivedCalled {
 				repo.EXPECT().IsArchived(gomock.Any(), tt.timerID).Return(tt.repoIsArchived, tt.repoIsArchivedError)
 			}
 
-			s, err := timer.NewService(repo)
+			s, err := timer.NewService(repo, nil)
 			require.NoError(t, err)
 
 			got, err := s.GetTimer(context.Background(), tt.timerID)
@@ -155,3 +289,550 @@ func TestServiceImp_GetTimer(t *testing.T) {
 		})
 	}
 }
+
+func TestServiceImp_RescheduleTimer(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		cmd        timer.RescheduleTimerCommand
+		mockFn     func(repo *mocks.Repo)
+		wantFireAt time.Time
+		wantErr    error
+	}{
+		{
+			name: "pending timer is rescheduled",
+			cmd:  timer.RescheduleTimerCommand{TimerID: "1", Hours: 1},
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().Find(gomock.Any(), "1").Return(&timer.Timer{ID: "1", FireAt: now}, nil)
+				repo.EXPECT().Reschedule(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantFireAt: now.Add(time.Hour),
+			wantErr:    nil,
+		},
+		{
+			name: "fire time in the past",
+			cmd:  timer.RescheduleTimerCommand{TimerID: "1", Hours: -1},
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().Find(gomock.Any(), "1").Return(&timer.Timer{ID: "1", FireAt: now}, nil)
+			},
+			wantErr: timer.ErrFireAtInPast,
+		},
+		{
+			name: "repo fails to reschedule",
+			cmd:  timer.RescheduleTimerCommand{TimerID: "1", Hours: 1},
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().Find(gomock.Any(), "1").Return(&timer.Timer{ID: "1", FireAt: now}, nil)
+				repo.EXPECT().Reschedule(gomock.Any(), gomock.Any()).Return(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "timer is rescheduled concurrently",
+			cmd:  timer.RescheduleTimerCommand{TimerID: "1", Hours: 1},
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().Find(gomock.Any(), "1").Return(&timer.Timer{ID: "1", FireAt: now}, nil)
+				repo.EXPECT().Reschedule(gomock.Any(), gomock.Any()).Return(timer.ErrTimerModified)
+			},
+			wantErr: timer.ErrTimerModified,
+		},
+		{
+			name: "timer is cancelled while being rescheduled",
+			cmd:  timer.RescheduleTimerCommand{TimerID: "1", Hours: 1},
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().Find(gomock.Any(), "1").Return(&timer.Timer{ID: "1", FireAt: now}, nil)
+				repo.EXPECT().Reschedule(gomock.Any(), gomock.Any()).Return(timer.ErrTimerNotFound)
+				repo.EXPECT().IsCancelled(gomock.Any(), "1").Return(true, nil)
+			},
+			wantErr: timer.ErrTimerCancelled,
+		},
+		{
+			name: "cancelled timer cannot be rescheduled",
+			cmd:  timer.RescheduleTimerCommand{TimerID: "1", Hours: 1},
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().Find(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().IsCancelled(gomock.Any(), "1").Return(true, nil)
+			},
+			wantErr: timer.ErrTimerCancelled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewRepo(ctrl)
+			tt.mockFn(repo)
+
+			s, err := timer.NewService(repo, nil)
+			require.NoError(t, err)
+
+			got, err := s.RescheduleTimer(context.Background(), tt.cmd)
+			assert.ErrorIs(t, err, tt.wantErr)
+			if err != nil {
+				return
+			}
+
+			assert.WithinDuration(t, tt.wantFireAt, got.FireAt, time.Second)
+			assert.Equal(t, 1, got.Revision)
+		})
+	}
+}
+
+func TestServiceImp_ScheduleNextRun(t *testing.T) {
+	newRecurringTimer := func(maxRuns int) *timer.Timer {
+		r, err := timer.NewRecurrence("@hourly", "", time.Time{}, maxRuns)
+		require.NoError(t, err)
+		tm, err := timer.NewRecurringTimer("http://valid.url", r)
+		require.NoError(t, err)
+		return tm
+	}
+
+	t.Run("reschedules the next occurrence", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewRepo(ctrl)
+
+		tm := newRecurringTimer(0)
+		repo.EXPECT().Reschedule(gomock.Any(), tm).Return(nil)
+
+		s, err := timer.NewService(repo, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, s.ScheduleNextRun(context.Background(), tm, timer.StateSucceeded))
+		assert.Equal(t, 1, tm.Recurrence.Runs)
+	})
+
+	t.Run("leaves the timer that is rescheduled or cancelled meanwhile", func(t *testing.T) {
+		for _, rescheduleErr := range []error{timer.ErrTimerModified, timer.ErrTimerNotFound} {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewRepo(ctrl)
+
+			tm := newRecurringTimer(0)
+			repo.EXPECT().Reschedule(gomock.Any(), tm).Return(rescheduleErr)
+
+			s, err := timer.NewService(repo, nil)
+			require.NoError(t, err)
+
+			assert.NoError(t, s.ScheduleNextRun(context.Background(), tm, timer.StateSucceeded))
+		}
+	})
+
+	t.Run("archives when the recurrence has ended", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewRepo(ctrl)
+
+		tm := newRecurringTimer(1)
+		repo.EXPECT().Archive(gomock.Any(), gomock.Any()).
+			Do(func(_ context.Context, tombstone *timer.Tombstone) {
+				assert.Equal(t, tm.ID, tombstone.TimerID)
+				assert.Equal(t, timer.StateFailed, tombstone.State)
+				assert.False(t, tombstone.FinishedAt.IsZero())
+			}).
+			Return(nil)
+
+		s, err := timer.NewService(repo, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, s.ScheduleNextRun(context.Background(), tm, timer.StateFailed))
+	})
+}
+
+func TestServiceImp_CancelTimer(t *testing.T) {
+	tests := []struct {
+		name    string
+		mockFn  func(repo *mocks.Repo)
+		wantErr error
+	}{
+		{
+			name: "pending timer is cancelled",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().Find(gomock.Any(), "1").Return(&timer.Timer{ID: "1"}, nil)
+				repo.EXPECT().Cancel(gomock.Any(), &timer.Timer{ID: "1"}).Return(nil)
+			},
+			wantErr: nil,
+		},
+		{
+			name: "repo fails to cancel",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().Find(gomock.Any(), "1").Return(&timer.Timer{ID: "1"}, nil)
+				repo.EXPECT().Cancel(gomock.Any(), &timer.Timer{ID: "1"}).Return(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "timer is archived before it is cancelled",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().Find(gomock.Any(), "1").Return(&timer.Timer{ID: "1"}, nil)
+				repo.EXPECT().Cancel(gomock.Any(), &timer.Timer{ID: "1"}).Return(timer.ErrTimerNotFound)
+				repo.EXPECT().IsCancelled(gomock.Any(), "1").Return(false, nil)
+				repo.EXPECT().IsArchived(gomock.Any(), "1").Return(true, nil)
+			},
+			wantErr: timer.ErrTimerArchived,
+		},
+		{
+			name: "timer is created again before it is cancelled",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().Find(gomock.Any(), "1").Return(&timer.Timer{ID: "1"}, nil)
+				repo.EXPECT().Cancel(gomock.Any(), &timer.Timer{ID: "1"}).Return(timer.ErrTimerModified)
+			},
+			wantErr: timer.ErrTimerModified,
+		},
+		{
+			name: "already cancelled",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().Find(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().IsCancelled(gomock.Any(), "1").Return(true, nil)
+			},
+			wantErr: timer.ErrTimerCancelled,
+		},
+		{
+			name: "archived timer cannot be cancelled",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().Find(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().IsCancelled(gomock.Any(), "1").Return(false, nil)
+				repo.EXPECT().IsArchived(gomock.Any(), "1").Return(true, nil)
+			},
+			wantErr: timer.ErrTimerArchived,
+		},
+		{
+			name: "not found",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().Find(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().IsCancelled(gomock.Any(), "1").Return(false, nil)
+				repo.EXPECT().IsArchived(gomock.Any(), "1").Return(false, nil)
+			},
+			wantErr: timer.ErrTimerNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewRepo(ctrl)
+			tt.mockFn(repo)
+
+			s, err := timer.NewService(repo, nil)
+			require.NoError(t, err)
+
+			err = s.CancelTimer(context.Background(), "1")
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestServiceImp_ListTimers(t *testing.T) {
+	due, notDue := true, false
+	tests := []struct {
+		name   string
+		query  timer.ListTimersQuery
+		assert func(t *testing.T, q timer.ListTimersQuery)
+	}{
+		{
+			name:  "applies the default limit",
+			query: timer.ListTimersQuery{},
+			assert: func(t *testing.T, q timer.ListTimersQuery) {
+				assert.Equal(t, timer.DefaultListLimit, q.Limit)
+			},
+		},
+		{
+			name:  "caps the limit",
+			query: timer.ListTimersQuery{Limit: timer.MaxListLimit + 1},
+			assert: func(t *testing.T, q timer.ListTimersQuery) {
+				assert.Equal(t, timer.MaxListLimit, q.Limit)
+			},
+		},
+		{
+			name:  "not due starts from now",
+			query: timer.ListTimersQuery{Due: &notDue, Limit: 10},
+			assert: func(t *testing.T, q timer.ListTimersQuery) {
+				assert.WithinDuration(t, time.Now(), q.From, time.Second)
+				assert.True(t, q.To.IsZero())
+			},
+		},
+		{
+			name:  "due ends at now",
+			query: timer.ListTimersQuery{Due: &due, To: time.Now().Add(time.Hour), Limit: 10},
+			assert: func(t *testing.T, q timer.ListTimersQuery) {
+				assert.WithinDuration(t, time.Now(), q.To, time.Second)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewRepo(ctrl)
+
+			page := &timer.TimersPage{}
+			repo.EXPECT().List(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, q timer.ListTimersQuery) (*timer.TimersPage, error) {
+					tt.assert(t, q)
+					return page, nil
+				})
+
+			s, err := timer.NewService(repo, nil)
+			require.NoError(t, err)
+
+			got, err := s.ListTimers(context.Background(), tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, page, got)
+		})
+	}
+}
+
+func TestServiceImp_DeadLetterTimer(t *testing.T) {
+	oneOff := &timer.Timer{ID: "1"}
+	recurring := &timer.Timer{ID: "2", Recurrence: &timer.Recurrence{Cron: "@hourly", Runs: 2}}
+
+	tests := []struct {
+		name       string
+		deadLetter *timer.DeadLetter
+		mockFn     func(repo *mocks.Repo)
+		wantErr    error
+	}{
+		{
+			name:       "one-off timer is archived",
+			deadLetter: timer.NewDeadLetter(oneOff, 3, assert.AnError),
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().AddDeadLetter(gomock.Any(), gomock.Any()).Return(nil)
+				repo.EXPECT().Archive(gomock.Any(), gomock.Any()).
+					Do(func(_ context.Context, tombstone *timer.Tombstone) {
+						assert.Equal(t, "1", tombstone.TimerID)
+						assert.Equal(t, timer.StateFailed, tombstone.State)
+					}).
+					Return(nil)
+			},
+		},
+		{
+			name:       "recurring timer goes on",
+			deadLetter: timer.NewDeadLetter(recurring, 3, assert.AnError),
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().AddDeadLetter(gomock.Any(), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name:       "repo fails to add the dead letter",
+			deadLetter: timer.NewDeadLetter(oneOff, 3, assert.AnError),
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().AddDeadLetter(gomock.Any(), gomock.Any()).Return(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewRepo(ctrl)
+			tt.mockFn(repo)
+
+			s, err := timer.NewService(repo, nil)
+			require.NoError(t, err)
+
+			err = s.DeadLetterTimer(context.Background(), tt.deadLetter)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestServiceImp_ReplayDeadLetters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mocks.NewRepo(ctrl)
+
+	aTimer, err := timer.NewTimer("http://valid.url", 0, 0, 0)
+	require.NoError(t, err)
+	deadLetter := timer.NewDeadLetter(aTimer, 1, assert.AnError)
+
+	repo.EXPECT().FindDeadLetter(gomock.Any(), aTimer.ID).Return(deadLetter, nil)
+	repo.EXPECT().ReplayDeadLetter(gomock.Any(), aTimer.ID, gomock.Any()).
+		Do(func(_ context.Context, _ string, replayed *timer.Timer) {
+			assert.Equal(t, aTimer.ID, replayed.ID)
+			assert.Equal(t, "http://other.url/hook", replayed.URL.String())
+			assert.Equal(t, aTimer.Revision+1, replayed.Revision)
+		}).
+		Return(nil)
+	repo.EXPECT().FindDeadLetter(gomock.Any(), "missing").Return(nil, nil)
+	repo.EXPECT().FindDeadLetter(gomock.Any(), "failing").Return(nil, assert.AnError)
+
+	s, err := timer.NewService(repo, nil)
+	require.NoError(t, err)
+
+	results := s.ReplayDeadLetters(context.Background(), timer.ReplayDeadLettersCommand{
+		IDs:    []string{aTimer.ID, "missing", "failing"},
+		URLRaw: "http://other.url/hook",
+	})
+
+	require.Len(t, results, 3)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, aTimer.ID, results[0].Timer.ID)
+	assert.ErrorIs(t, results[1].Err, timer.ErrDeadLetterNotFound)
+	assert.ErrorIs(t, results[2].Err, assert.AnError)
+}
+
+func TestServiceImp_ListAttempts(t *testing.T) {
+	attempts := []*timer.Attempt{{Run: 1, Number: 1, StatusCode: 200}}
+
+	tests := []struct {
+		name    string
+		mockFn  func(repo *mocks.Repo)
+		want    []*timer.Attempt
+		wantErr error
+	}{
+		{
+			name: "has attempts",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().ListAttempts(gomock.Any(), "1").Return(attempts, nil)
+			},
+			want: attempts,
+		},
+		{
+			name: "pending timer without attempts",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().ListAttempts(gomock.Any(), "1").Return([]*timer.Attempt{}, nil)
+				repo.EXPECT().Find(gomock.Any(), "1").Return(&timer.Timer{ID: "1"}, nil)
+			},
+			want: []*timer.Attempt{},
+		},
+		{
+			name: "archived timer without attempts",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().ListAttempts(gomock.Any(), "1").Return([]*timer.Attempt{}, nil)
+				repo.EXPECT().Find(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().IsCancelled(gomock.Any(), "1").Return(false, nil)
+				repo.EXPECT().IsArchived(gomock.Any(), "1").Return(true, nil)
+			},
+			want: []*timer.Attempt{},
+		},
+		{
+			name: "timer does not exist",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().ListAttempts(gomock.Any(), "1").Return([]*timer.Attempt{}, nil)
+				repo.EXPECT().Find(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().IsCancelled(gomock.Any(), "1").Return(false, nil)
+				repo.EXPECT().IsArchived(gomock.Any(), "1").Return(false, nil)
+			},
+			wantErr: timer.ErrTimerNotFound,
+		},
+		{
+			name: "repo fails",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().ListAttempts(gomock.Any(), "1").Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewRepo(ctrl)
+			tt.mockFn(repo)
+
+			s, err := timer.NewService(repo, nil)
+			require.NoError(t, err)
+
+			got, err := s.ListAttempts(context.Background(), "1")
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestServiceImp_GetTimerState(t *testing.T) {
+	aTimer := &timer.Timer{ID: "1", Revision: 2}
+
+	tests := []struct {
+		name      string
+		mockFn    func(repo *mocks.Repo)
+		wantState timer.State
+		wantTimer bool
+		wantErr   error
+	}{
+		{
+			name: "pending timer without state",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().FindState(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().Find(gomock.Any(), "1").Return(aTimer, nil)
+			},
+			wantState: timer.StatePending,
+			wantTimer: true,
+		},
+		{
+			name: "relayed timer",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().FindState(gomock.Any(), "1").Return(&timer.TimerState{TimerID: "1", State: timer.StateRelayed, Revision: 2}, nil)
+				repo.EXPECT().Find(gomock.Any(), "1").Return(aTimer, nil)
+			},
+			wantState: timer.StateRelayed,
+			wantTimer: true,
+		},
+		{
+			name: "state of the previous revision",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().FindState(gomock.Any(), "1").Return(&timer.TimerState{TimerID: "1", State: timer.StateSucceeded, Revision: 1}, nil)
+				repo.EXPECT().Find(gomock.Any(), "1").Return(aTimer, nil)
+			},
+			wantState: timer.StatePending,
+			wantTimer: true,
+		},
+		{
+			name: "archived timer",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().FindState(gomock.Any(), "1").Return(&timer.TimerState{TimerID: "1", State: timer.StateFailed}, nil)
+				repo.EXPECT().Find(gomock.Any(), "1").Return(nil, nil)
+			},
+			wantState: timer.StateFailed,
+		},
+		{
+			name: "archived timer with tombstone",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().FindState(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().Find(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().FindTombstone(gomock.Any(), "1").Return(&timer.Tombstone{TimerID: "1", State: timer.StateSucceeded}, nil)
+			},
+			wantState: timer.StateSucceeded,
+		},
+		{
+			name: "archived timer without state",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().FindState(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().Find(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().FindTombstone(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().IsCancelled(gomock.Any(), "1").Return(false, nil)
+				repo.EXPECT().IsArchived(gomock.Any(), "1").Return(true, nil)
+			},
+			wantErr: timer.ErrTimerArchived,
+		},
+		{
+			name: "timer does not exist",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().FindState(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().Find(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().FindTombstone(gomock.Any(), "1").Return(nil, nil)
+				repo.EXPECT().IsCancelled(gomock.Any(), "1").Return(false, nil)
+				repo.EXPECT().IsArchived(gomock.Any(), "1").Return(false, nil)
+			},
+			wantErr: timer.ErrTimerNotFound,
+		},
+		{
+			name: "repo fails",
+			mockFn: func(repo *mocks.Repo) {
+				repo.EXPECT().FindState(gomock.Any(), "1").Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewRepo(ctrl)
+			tt.mockFn(repo)
+
+			s, err := timer.NewService(repo, nil)
+			require.NoError(t, err)
+
+			got, err := s.GetTimerState(context.Background(), "1")
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+
+			assert.Equal(t, tt.wantState, got.State)
+			assert.Equal(t, tt.wantTimer, got.Timer != nil)
+		})
+	}
+}
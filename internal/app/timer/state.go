@@ -0,0 +1,95 @@
+package timer
+
+import (
+	"time"
+)
+
+// State is the lifecycle state of a timer.
+type State string
+
+const (
+	// StatePending is a timer that waits for the relay to hand it to the workers.
+	StatePending State = "pending"
+	// StateRelayed is a timer that is handed to the workers, and waits for its fire time.
+	StateRelayed State = "relayed"
+	// StateInFlight is a timer whose webhook is being shot.
+	StateInFlight State = "in-flight"
+	// StateRetrying is a timer whose webhook failed and is going to be retried.
+	StateRetrying State = "retrying"
+	// StateSucceeded is a timer whose webhook is delivered.
+	StateSucceeded State = "succeeded"
+	// StateFailed is a timer whose webhook failed permanently. see DeadLetter.
+	StateFailed State = "failed"
+	// StateCancelled is a timer that is cancelled before its webhook was shot.
+	StateCancelled State = "cancelled"
+)
+
+// IsFinal tells whether the timer stays in the state, i.e. it is archived or cancelled. the run of a recurring timer
+// that succeeded or failed is followed by the next run, though.
+func (s State) IsFinal() bool {
+	return s == StateSucceeded || s == StateFailed || s == StateCancelled
+}
+
+// IsListed tells whether the timers in the state are listed, i.e. they are neither archived nor cancelled. see
+// ListTimersQuery.
+func (s State) IsListed() bool {
+	return s == StatePending || s == StateRelayed || s == StateInFlight || s == StateRetrying
+}
+
+// TimerState is the lifecycle state of the current run of a timer. it is kept after the timer is archived or
+// cancelled, so that the outcome of the timer can be told.
+type TimerState struct {
+	TimerID string
+	State   State
+	// Revision is of the timer that the state belongs to. the state of an older revision is stale.
+	Revision  int
+	FireAt    time.Time
+	CreatedAt time.Time
+	// Attempts is the number of the delivery attempts of the current run so far.
+	Attempts int
+	// LastError is the failure of the last attempt, if it failed.
+	LastError string
+	// FinishedAt is the time the timer got into a final state. see State.IsFinal.
+	FinishedAt time.Time
+
+	// Timer is set as long as the timer is neither archived nor cancelled. it is not kept along with the state.
+	Timer *Timer
+}
+
+// NewTimerState returns the state of the current run of the timer.
+func NewTimerState(t *Timer, state State) *TimerState {
+	s := &TimerState{
+		TimerID:   t.ID,
+		State:     state,
+		Revision:  t.Revision,
+		FireAt:    t.FireAt,
+		CreatedAt: t.CreatedAt,
+		Timer:     t,
+	}
+
+	if state.IsFinal() {
+		s.FinishedAt = time.Now()
+	}
+
+	return s
+}
+
+// WithAttempt records the number of the attempts so far and the failure of the last attempt, if any.
+func (s *TimerState) WithAttempt(attempts int, failure error) *TimerState {
+	s.Attempts = attempts
+	if failure != nil {
+		s.LastError = failure.Error()
+	}
+	return s
+}
+
+// Of returns the state of the timer, or the pending state when the state is missing or belongs to another revision
+// of the timer, e.g. the timer is rescheduled or it is a recurring timer that is waiting for its next run.
+func (s *TimerState) Of(t *Timer) *TimerState {
+	if s == nil || s.Revision != t.Revision {
+		return NewTimerState(t, StatePending)
+	}
+
+	s.Timer = t
+	return s
+}
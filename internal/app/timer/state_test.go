@@ -0,0 +1,59 @@
+package timer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTimerState(t *testing.T) {
+	aTimer, err := NewTimer("http://valid.url", 0, 0, 0)
+	require.NoError(t, err)
+
+	got := NewTimerState(aTimer, StateRetrying).WithAttempt(2, assert.AnError)
+
+	assert.Equal(t, aTimer.ID, got.TimerID)
+	assert.Equal(t, StateRetrying, got.State)
+	assert.Equal(t, aTimer.FireAt, got.FireAt)
+	assert.Equal(t, aTimer.CreatedAt, got.CreatedAt)
+	assert.Equal(t, 2, got.Attempts)
+	assert.Equal(t, assert.AnError.Error(), got.LastError)
+	assert.False(t, got.State.IsFinal())
+	assert.True(t, got.FinishedAt.IsZero())
+
+	assert.False(t, NewTimerState(aTimer, StateFailed).FinishedAt.IsZero())
+}
+
+func TestTombstone_state(t *testing.T) {
+	tombstone := NewTombstone("1", StateSucceeded)
+
+	got := tombstone.state()
+	assert.Equal(t, "1", got.TimerID)
+	assert.Equal(t, StateSucceeded, got.State)
+	assert.Equal(t, tombstone.FinishedAt, got.FinishedAt)
+	assert.Nil(t, got.Timer)
+}
+
+func TestTimerState_of(t *testing.T) {
+	aTimer, err := NewTimer("http://valid.url", 0, 0, 0)
+	require.NoError(t, err)
+
+	var missing *TimerState
+	assert.Equal(t, StatePending, missing.Of(aTimer).State)
+
+	state := NewTimerState(aTimer, StateSucceeded)
+	assert.Equal(t, StateSucceeded, state.Of(aTimer).State)
+
+	require.NoError(t, aTimer.Reschedule(0, 1, 0))
+	assert.Equal(t, StatePending, state.Of(aTimer).State, "the state of the previous revision is stale")
+}
+
+func TestState_IsFinal(t *testing.T) {
+	for _, state := range []State{StateSucceeded, StateFailed, StateCancelled} {
+		assert.True(t, state.IsFinal(), state)
+	}
+	for _, state := range []State{StatePending, StateRelayed, StateInFlight, StateRetrying} {
+		assert.False(t, state.IsFinal(), state)
+	}
+}
@@ -0,0 +1,224 @@
+package timer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidSuccessCriteria = errors.New("invalid success criteria")
+
+// Outcome is how a delivery attempt is classified.
+type Outcome string
+
+const (
+	// OutcomeSuccess is an attempt that delivered the webhook.
+	OutcomeSuccess Outcome = "success"
+	// OutcomeRetry is a failed attempt that is retried.
+	OutcomeRetry Outcome = "retry"
+	// OutcomeFailure is a failed attempt that is not retried, i.e. the run failed permanently.
+	OutcomeFailure Outcome = "failure"
+)
+
+var (
+	// DefaultAcceptedStatusCodes are the status codes of the successful responses, unless the criteria say otherwise.
+	DefaultAcceptedStatusCodes = []string{"2xx"}
+	// DefaultRetryableStatusCodes are the status codes of the failed responses that are retried, unless the criteria
+	// say otherwise. the 5xx are retried, as they are typically not permanent, except 501 Not Implemented, and so are
+	// the invalid status codes above them.
+	DefaultRetryableStatusCodes = []string{"429", "500", "502-999"}
+)
+
+// SuccessCriteria tell which responses of a webhook are successful, and which of the failed ones are retried. a
+// response is successful when its status code is accepted and its body matches, otherwise it is retried when its
+// status code is retryable, and fails permanently when it is not.
+// the zero fields are not set, and fall back to the defaults of the service. see WithDefaults.
+type SuccessCriteria struct {
+	// AcceptedStatusCodes are status codes, e.g. 200, classes of them, e.g. 2xx, or ranges of them, e.g. 200-204.
+	// DefaultAcceptedStatusCodes when not set.
+	AcceptedStatusCodes []string
+	// RetryableStatusCodes are in the format of AcceptedStatusCodes. DefaultRetryableStatusCodes when not set.
+	RetryableStatusCodes []string
+	// BodyPattern is a regular expression that the body of a successful response matches.
+	BodyPattern string
+	// JSONPath is a dot separated path into the JSON body of a successful response, e.g. data.status or items.0.id,
+	// whose value is JSONValue. the strings are compared verbatim and the other values in their JSON form, e.g. true.
+	// the path only has to exist when JSONValue is empty.
+	JSONPath  string
+	JSONValue string
+}
+
+// Validate checks the set fields of the criteria.
+func (c SuccessCriteria) Validate() error {
+	for _, codes := range [][]string{c.AcceptedStatusCodes, c.RetryableStatusCodes} {
+		for _, code := range codes {
+			if _, _, err := parseStatusCodes(code); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidSuccessCriteria, err)
+			}
+		}
+	}
+
+	if _, err := regexp.Compile(c.BodyPattern); err != nil {
+		return fmt.Errorf("%w: invalid body pattern: %v", ErrInvalidSuccessCriteria, err)
+	}
+
+	if c.JSONPath == "" && c.JSONValue != "" {
+		return fmt.Errorf("%w: JSON value is set without a JSON path", ErrInvalidSuccessCriteria)
+	}
+
+	if c.JSONPath != "" {
+		for _, key := range strings.Split(c.JSONPath, ".") {
+			if key == "" {
+				return fmt.Errorf("%w: invalid JSON path %q", ErrInvalidSuccessCriteria, c.JSONPath)
+			}
+		}
+	}
+
+	return nil
+}
+
+// WithDefaults fills the fields that are not set with the ones of the defaults. the JSON path and value are filled
+// together.
+func (c SuccessCriteria) WithDefaults(defaults SuccessCriteria) SuccessCriteria {
+	if len(c.AcceptedStatusCodes) == 0 {
+		c.AcceptedStatusCodes = defaults.AcceptedStatusCodes
+	}
+	if len(c.RetryableStatusCodes) == 0 {
+		c.RetryableStatusCodes = defaults.RetryableStatusCodes
+	}
+	if c.BodyPattern == "" {
+		c.BodyPattern = defaults.BodyPattern
+	}
+	if c.JSONPath == "" {
+		c.JSONPath, c.JSONValue = defaults.JSONPath, defaults.JSONValue
+	}
+	return c
+}
+
+// Classify returns the outcome of a response, given its status code and its body. a retryable response is
+// classified as OutcomeRetry even if the run is not going to be retried anymore.
+func (c SuccessCriteria) Classify(statusCode int, body []byte) Outcome {
+	switch {
+	case c.Accepts(statusCode) && c.MatchBody(body):
+		return OutcomeSuccess
+	case c.Retries(statusCode):
+		return OutcomeRetry
+	default:
+		return OutcomeFailure
+	}
+}
+
+// Accepts tells whether the status code is of a successful response.
+func (c SuccessCriteria) Accepts(statusCode int) bool {
+	if len(c.AcceptedStatusCodes) == 0 {
+		return containsStatusCode(DefaultAcceptedStatusCodes, statusCode)
+	}
+	return containsStatusCode(c.AcceptedStatusCodes, statusCode)
+}
+
+// Retries tells whether the status code is of a failed response that is retried.
+func (c SuccessCriteria) Retries(statusCode int) bool {
+	if len(c.RetryableStatusCodes) == 0 {
+		return containsStatusCode(DefaultRetryableStatusCodes, statusCode)
+	}
+	return containsStatusCode(c.RetryableStatusCodes, statusCode)
+}
+
+// MatchesBody tells whether the criteria look into the body of the responses.
+func (c SuccessCriteria) MatchesBody() bool {
+	return c.BodyPattern != "" || c.JSONPath != ""
+}
+
+// MatchBody tells whether the body of a response matches the body pattern and the JSON path of the criteria.
+func (c SuccessCriteria) MatchBody(body []byte) bool {
+	if c.BodyPattern != "" {
+		re, err := regexp.Compile(c.BodyPattern)
+		if err != nil || !re.Match(body) {
+			return false
+		}
+	}
+
+	if c.JSONPath != "" {
+		return c.matchJSON(body)
+	}
+
+	return true
+}
+
+func (c SuccessCriteria) matchJSON(body []byte) bool {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return false
+	}
+
+	for _, key := range strings.Split(c.JSONPath, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if value, ok = v[key]; !ok {
+				return false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return false
+			}
+			value = v[i]
+		default:
+			return false
+		}
+	}
+
+	if c.JSONValue == "" {
+		return true
+	}
+
+	if s, ok := value.(string); ok {
+		return s == c.JSONValue
+	}
+
+	encoded, err := json.Marshal(value)
+	return err == nil && string(encoded) == c.JSONValue
+}
+
+func containsStatusCode(codes []string, statusCode int) bool {
+	for _, code := range codes {
+		from, to, err := parseStatusCodes(code)
+		if err == nil && statusCode >= from && statusCode <= to {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStatusCodes parses a status code, e.g. 200, a class of them, e.g. 2xx, or a range of them, e.g. 200-204, into
+// an inclusive range.
+func parseStatusCodes(code string) (from, to int, err error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if len(code) == 3 && strings.HasSuffix(code, "xx") && code[0] >= '1' && code[0] <= '9' {
+		from = int(code[0]-'0') * 100
+		return from, from + 99, nil
+	}
+
+	first, last, isRange := strings.Cut(code, "-")
+	if !isRange {
+		last = first
+	}
+
+	if from, err = strconv.Atoi(first); err == nil {
+		to, err = strconv.Atoi(last)
+	}
+
+	if err != nil || from < 100 || to > 999 || from > to {
+		return 0, 0, fmt.Errorf("invalid status code %q", code)
+	}
+
+	return from, to, nil
+}
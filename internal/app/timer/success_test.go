@@ -0,0 +1,171 @@
+package timer
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuccessCriteria_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		criteria SuccessCriteria
+		wantErr  assert.ErrorAssertionFunc
+	}{
+		{name: "empty criteria use the defaults", criteria: SuccessCriteria{}, wantErr: assert.NoError},
+		{
+			name: "full criteria",
+			criteria: SuccessCriteria{
+				AcceptedStatusCodes:  []string{"200", "2xx", "300-302"},
+				RetryableStatusCodes: []string{"409", "5XX"},
+				BodyPattern:          `^ok`,
+				JSONPath:             "data.items.0.status",
+				JSONValue:            "done",
+			},
+			wantErr: assert.NoError,
+		},
+		{name: "invalid status code", criteria: SuccessCriteria{AcceptedStatusCodes: []string{"ok"}}, wantErr: assert.Error},
+		{name: "status code out of range", criteria: SuccessCriteria{AcceptedStatusCodes: []string{"99"}}, wantErr: assert.Error},
+		{name: "reversed range", criteria: SuccessCriteria{RetryableStatusCodes: []string{"599-500"}}, wantErr: assert.Error},
+		{name: "invalid body pattern", criteria: SuccessCriteria{BodyPattern: `(`}, wantErr: assert.Error},
+		{name: "JSON value without a path", criteria: SuccessCriteria{JSONValue: "done"}, wantErr: assert.Error},
+		{name: "invalid JSON path", criteria: SuccessCriteria{JSONPath: "data..status"}, wantErr: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.criteria.Validate()
+			if tt.wantErr(t, err) && err != nil {
+				assert.ErrorIs(t, err, ErrInvalidSuccessCriteria)
+			}
+		})
+	}
+}
+
+func TestSuccessCriteria_WithDefaults(t *testing.T) {
+	defaults := SuccessCriteria{
+		AcceptedStatusCodes:  []string{"2xx"},
+		RetryableStatusCodes: []string{"5xx"},
+		JSONPath:             "status",
+		JSONValue:            "ok",
+	}
+
+	got := SuccessCriteria{AcceptedStatusCodes: []string{"200"}, BodyPattern: "ok"}.WithDefaults(defaults)
+
+	assert.Equal(t, SuccessCriteria{
+		AcceptedStatusCodes:  []string{"200"},
+		RetryableStatusCodes: []string{"5xx"},
+		BodyPattern:          "ok",
+		JSONPath:             "status",
+		JSONValue:            "ok",
+	}, got)
+}
+
+func TestSuccessCriteria_Classify(t *testing.T) {
+	tests := []struct {
+		name       string
+		criteria   SuccessCriteria
+		statusCode int
+		body       string
+		want       Outcome
+	}{
+		{name: "2xx by default", statusCode: http.StatusNoContent, want: OutcomeSuccess},
+		{name: "3xx fails by default", statusCode: http.StatusFound, want: OutcomeFailure},
+		{name: "404 fails by default", statusCode: http.StatusNotFound, want: OutcomeFailure},
+		{name: "429 is retried by default", statusCode: http.StatusTooManyRequests, want: OutcomeRetry},
+		{name: "5xx is retried by default", statusCode: http.StatusBadGateway, want: OutcomeRetry},
+		{name: "501 fails by default", statusCode: http.StatusNotImplemented, want: OutcomeFailure},
+		{
+			name:       "accepted status code",
+			criteria:   SuccessCriteria{AcceptedStatusCodes: []string{"2xx", "410"}},
+			statusCode: http.StatusGone,
+			want:       OutcomeSuccess,
+		},
+		{
+			name:       "status code that is not accepted",
+			criteria:   SuccessCriteria{AcceptedStatusCodes: []string{"200"}},
+			statusCode: http.StatusAccepted,
+			want:       OutcomeFailure,
+		},
+		{
+			name:       "retryable status code",
+			criteria:   SuccessCriteria{RetryableStatusCodes: []string{"409"}},
+			statusCode: http.StatusConflict,
+			want:       OutcomeRetry,
+		},
+		{
+			name:       "status code that is not retryable",
+			criteria:   SuccessCriteria{RetryableStatusCodes: []string{"409"}},
+			statusCode: http.StatusServiceUnavailable,
+			want:       OutcomeFailure,
+		},
+		{
+			name:       "body matches the pattern",
+			criteria:   SuccessCriteria{BodyPattern: `^OK\b`},
+			statusCode: http.StatusOK,
+			body:       "OK thanks",
+			want:       OutcomeSuccess,
+		},
+		{
+			name:       "body does not match the pattern",
+			criteria:   SuccessCriteria{BodyPattern: `^OK\b`},
+			statusCode: http.StatusOK,
+			body:       "NOT OK",
+			want:       OutcomeFailure,
+		},
+		{
+			name:       "body does not match but the status code is retryable",
+			criteria:   SuccessCriteria{AcceptedStatusCodes: []string{"2xx"}, RetryableStatusCodes: []string{"202"}, JSONPath: "status", JSONValue: "done"},
+			statusCode: http.StatusAccepted,
+			body:       `{"status":"pending"}`,
+			want:       OutcomeRetry,
+		},
+		{
+			name:       "JSON string value",
+			criteria:   SuccessCriteria{JSONPath: "data.items.1.status", JSONValue: "done"},
+			statusCode: http.StatusOK,
+			body:       `{"data":{"items":[{"status":"pending"},{"status":"done"}]}}`,
+			want:       OutcomeSuccess,
+		},
+		{
+			name:       "JSON boolean value",
+			criteria:   SuccessCriteria{JSONPath: "ok", JSONValue: "true"},
+			statusCode: http.StatusOK,
+			body:       `{"ok":true}`,
+			want:       OutcomeSuccess,
+		},
+		{
+			name:       "JSON number value",
+			criteria:   SuccessCriteria{JSONPath: "id", JSONValue: "12345678901234567890"},
+			statusCode: http.StatusOK,
+			body:       `{"id":12345678901234567890}`,
+			want:       OutcomeSuccess,
+		},
+		{
+			name:       "JSON path exists",
+			criteria:   SuccessCriteria{JSONPath: "id"},
+			statusCode: http.StatusOK,
+			body:       `{"id":null}`,
+			want:       OutcomeSuccess,
+		},
+		{
+			name:       "JSON path does not exist",
+			criteria:   SuccessCriteria{JSONPath: "data.id"},
+			statusCode: http.StatusOK,
+			body:       `{"data":[]}`,
+			want:       OutcomeFailure,
+		},
+		{
+			name:       "body is not JSON",
+			criteria:   SuccessCriteria{JSONPath: "id"},
+			statusCode: http.StatusOK,
+			body:       `OK`,
+			want:       OutcomeFailure,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.criteria.Classify(tt.statusCode, []byte(tt.body)))
+		})
+	}
+}
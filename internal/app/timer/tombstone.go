@@ -0,0 +1,32 @@
+package timer
+
+import (
+	"time"
+)
+
+// Tombstone is the compact outcome of an archived timer. unlike the archive index, which is probabilistic, it tells
+// for sure that the timer is archived, and whether its webhook succeeded or failed.
+type Tombstone struct {
+	TimerID string
+	// State is either StateSucceeded or StateFailed.
+	State      State
+	FinishedAt time.Time
+}
+
+// NewTombstone records the outcome of the timer that is finished now.
+func NewTombstone(timerID string, state State) *Tombstone {
+	return &Tombstone{
+		TimerID:    timerID,
+		State:      state,
+		FinishedAt: time.Now(),
+	}
+}
+
+// state returns the state of the archived timer as far as the tombstone tells.
+func (t *Tombstone) state() *TimerState {
+	return &TimerState{
+		TimerID:    t.TimerID,
+		State:      t.State,
+		FinishedAt: t.FinishedAt,
+	}
+}
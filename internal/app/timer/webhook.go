@@ -0,0 +1,88 @@
+package timer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cubny/httpqueue/pkg/webhooks"
+)
+
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// DefaultWebhookMethod is the HTTP method of the webhooks that do not specify one.
+const DefaultWebhookMethod = http.MethodPost
+
+// MaxWebhookTimeout is the longest timeout a webhook can have.
+const MaxWebhookTimeout = 5 * time.Minute
+
+var allowedWebhookMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// Webhook describes the HTTP request that is sent when the timer fires.
+type Webhook struct {
+	// Method is the HTTP method. empty means DefaultWebhookMethod.
+	Method string
+	// Headers are sent verbatim.
+	Headers map[string]string
+	// Body is sent verbatim.
+	Body []byte
+	// ContentType of the Body.
+	ContentType string
+	// SigningSecrets sign the webhook instead of the default secrets of the client. see webhooks.SetHeaders.
+	SigningSecrets []string
+	// Timeout overrides the overall timeout of the webhook request of the client. zero means the default timeout.
+	Timeout time.Duration
+}
+
+// NewWebhook constructs a valid Webhook.
+func NewWebhook(method string, headers map[string]string, body []byte, contentType string) (Webhook, error) {
+	if method == "" {
+		method = DefaultWebhookMethod
+	}
+
+	if !allowedWebhookMethods[method] {
+		return Webhook{}, fmt.Errorf("%w: unsupported method %q", ErrInvalidWebhook, method)
+	}
+
+	return Webhook{
+		Method:      method,
+		Headers:     headers,
+		Body:        body,
+		ContentType: contentType,
+	}, nil
+}
+
+// WithSigningSecrets sets the secrets in the whsec_<base64> format to sign the webhook with.
+func (w Webhook) WithSigningSecrets(secrets []string) (Webhook, error) {
+	if _, err := webhooks.ParseSecrets(secrets); err != nil {
+		return Webhook{}, fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+
+	w.SigningSecrets = secrets
+	return w, nil
+}
+
+// WithTimeout sets the overall timeout of the webhook request, which is at most MaxWebhookTimeout.
+func (w Webhook) WithTimeout(timeout time.Duration) (Webhook, error) {
+	if timeout < 0 || timeout > MaxWebhookTimeout {
+		return Webhook{}, fmt.Errorf("%w: timeout must be between 0 and %s", ErrInvalidWebhook, MaxWebhookTimeout)
+	}
+
+	w.Timeout = timeout
+	return w, nil
+}
+
+// MethodOrDefault returns the HTTP method of the webhook.
+func (w Webhook) MethodOrDefault() string {
+	if w.Method == "" {
+		return DefaultWebhookMethod
+	}
+	return w.Method
+}
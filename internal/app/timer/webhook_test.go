@@ -0,0 +1,66 @@
+package timer
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWebhook(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		wantMethod string
+		wantErr    assert.ErrorAssertionFunc
+	}{
+		{
+			name:       "defaults to POST",
+			method:     "",
+			wantMethod: http.MethodPost,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:       "PUT",
+			method:     http.MethodPut,
+			wantMethod: http.MethodPut,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:    "unsupported method",
+			method:  http.MethodConnect,
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewWebhook(tt.method, map[string]string{"X-Key": "value"}, []byte("{}"), "application/json")
+			if !tt.wantErr(t, err) || err != nil {
+				return
+			}
+
+			assert.Equal(t, tt.wantMethod, got.Method)
+			assert.Equal(t, map[string]string{"X-Key": "value"}, got.Headers)
+			assert.Equal(t, []byte("{}"), got.Body)
+			assert.Equal(t, "application/json", got.ContentType)
+		})
+	}
+}
+
+func TestWebhook_MethodOrDefault(t *testing.T) {
+	assert.Equal(t, http.MethodPost, Webhook{}.MethodOrDefault())
+	assert.Equal(t, http.MethodGet, Webhook{Method: http.MethodGet}.MethodOrDefault())
+}
+
+func TestWebhook_WithTimeout(t *testing.T) {
+	got, err := Webhook{}.WithTimeout(10 * time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Second, got.Timeout)
+
+	_, err = Webhook{}.WithTimeout(-time.Second)
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+
+	_, err = Webhook{}.WithTimeout(MaxWebhookTimeout + time.Second)
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+}
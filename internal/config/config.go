@@ -17,12 +17,17 @@ type Config struct {
 	// ConsumerConcurrency number of concurrent consumers.
 	// Note: the words consumers and workers are used interchangeably
 	ConsumerConcurrency int `env:"CONSUMER_CONCURRENCY,default=10"`
+	// ConsumerMaxRetryAfter caps the delay that the webhook receivers can ask for using the Retry-After header
+	// before a failed webhook is retried.
+	ConsumerMaxRetryAfter time.Duration `env:"CONSUMER_MAX_RETRY_AFTER,default=1h"`
 
 	HTTP     HTTP
 	DB       DB
+	Limits   Limits
 	Producer Producer
 	Redis    Redis
 	Relay    Relay
+	Webhook  Webhook
 }
 
 type HTTP struct {
@@ -33,11 +38,66 @@ type HTTP struct {
 type DB struct {
 	// TimerMaxTTLDays indicates the TTL of the timers record in the DB
 	TimerMaxTTLDays int `env:"DB_TIMER_MAX_TTL_DAYS,default=180"`
+	// IdempotencyWindow indicates how long an idempotency key of a created timer is remembered.
+	IdempotencyWindow time.Duration `env:"DB_IDEMPOTENCY_WINDOW,default=24h"`
+	// AttemptHistorySize is the number of the latest delivery attempts that are kept per timer.
+	AttemptHistorySize int `env:"DB_ATTEMPT_HISTORY_SIZE,default=50"`
+	// ArchiveIndex is the index of the archived timers, either bitmap, which is a Bloom filter on a plain Redis
+	// bitmap, or redisbloom, which requires the RedisBloom module.
+	ArchiveIndex string `env:"DB_ARCHIVE_INDEX,default=bitmap"`
+	// ArchiveCapacity is the number of the archived timers per partition the bitmap index is sized for. beyond it
+	// the false positive rate grows. changing it starts a new, empty index.
+	ArchiveCapacity uint64 `env:"DB_ARCHIVE_CAPACITY,default=1000000"`
+	// ArchiveFalsePositiveRate is the probability of the bitmap index telling that a timer is archived when it is not.
+	// changing it starts a new, empty index.
+	ArchiveFalsePositiveRate float64 `env:"DB_ARCHIVE_FALSE_POSITIVE_RATE,default=0.001"`
+	// ArchivePartition is the period of time the archived timers share a partition of the index in.
+	ArchivePartition time.Duration `env:"DB_ARCHIVE_PARTITION,default=24h"`
+	// ArchiveRetention is how long a partition of the index is kept after its period ends.
+	ArchiveRetention time.Duration `env:"DB_ARCHIVE_RETENTION,default=720h"`
+	// ArchiveTombstoneTTL is how long the outcome of an archived timer is kept. zero does not keep the outcomes.
+	ArchiveTombstoneTTL time.Duration `env:"DB_ARCHIVE_TOMBSTONE_TTL,default=720h"`
 }
 
+// Limits holds the limits of the webhooks per destination host and per tenant, that the workers enforce together.
+// the webhooks over the limits are deferred rather than failed. zero means no limit.
+type Limits struct {
+	// HostRate is the number of webhooks per second that a destination host receives at most.
+	HostRate float64 `env:"CONSUMER_HOST_RATE_LIMIT,default=0"`
+	// HostBurst is the number of webhooks that a destination host receives at once at most, within the rate limit.
+	HostBurst int `env:"CONSUMER_HOST_BURST,default=1"`
+	// HostMaxInFlight is the number of the concurrent webhooks of a destination host at most.
+	HostMaxInFlight int `env:"CONSUMER_HOST_MAX_IN_FLIGHT,default=0"`
+	// TenantLabel is the label of the timers that holds their tenant. the timers without it are not limited per
+	// tenant.
+	TenantLabel string `env:"CONSUMER_TENANT_LABEL,default=tenant"`
+	// TenantRate is the number of webhooks per second of a tenant at most.
+	TenantRate float64 `env:"CONSUMER_TENANT_RATE_LIMIT,default=0"`
+	// TenantBurst is the number of webhooks of a tenant at once at most, within the rate limit.
+	TenantBurst int `env:"CONSUMER_TENANT_BURST,default=1"`
+	// TenantMaxInFlight is the number of the concurrent webhooks of a tenant at most.
+	TenantMaxInFlight int `env:"CONSUMER_TENANT_MAX_IN_FLIGHT,default=0"`
+	// InFlightTTL is how long a webhook is counted as in-flight at most, e.g. when its worker dies before it is done.
+	InFlightTTL time.Duration `env:"CONSUMER_IN_FLIGHT_TTL,default=5m"`
+	// DeferDelay is how long a webhook over an in-flight limit is deferred.
+	DeferDelay time.Duration `env:"CONSUMER_DEFER_DELAY,default=1s"`
+}
+
+// Producer holds the default retry policy of the timers. a timer can override it with its own retry policy.
 type Producer struct {
 	// MaxRetry indicates how many times the timer.Timer webhook is allowed to be called at maximum in case of failure.
 	MaxRetry int `env:"PRODUCER_MAX_RETRY,default=10"`
+	// InitialBackoff is the delay before the first retry of a failed webhook.
+	InitialBackoff time.Duration `env:"PRODUCER_INITIAL_BACKOFF,default=10s"`
+	// MaxBackoff caps the delay between two attempts.
+	MaxBackoff time.Duration `env:"PRODUCER_MAX_BACKOFF,default=1h"`
+	// BackoffMultiplier grows the delay after every retry.
+	BackoffMultiplier float64 `env:"PRODUCER_BACKOFF_MULTIPLIER,default=2"`
+	// BackoffJitter randomises the delay by the given fraction, between 0 and 1.
+	BackoffJitter float64 `env:"PRODUCER_BACKOFF_JITTER,default=0.2"`
+	// RetryDeadline is the time after the scheduled time of a webhook after which it is not retried anymore.
+	// zero means no deadline.
+	RetryDeadline time.Duration `env:"PRODUCER_RETRY_DEADLINE,default=0"`
 }
 
 // Redis is the configuration for Redis.
@@ -60,6 +120,88 @@ type Relay struct {
 	BatchSize int `env:"RELAY_BATCH_SIZE,default=1"`
 	// FrequencyMilliSeconds is the intervals between outbox dequeue.
 	FrequencyMilliSeconds int `env:"RELAY_FREQUENCY_MILLI_SECONDS,default=500"`
+	// VisibilityTimeout is how long a dequeued timer is hidden from the other relays. a timer that is neither relayed
+	// nor requeued by then, e.g. its relay died, is reclaimed and relayed again.
+	VisibilityTimeout time.Duration `env:"RELAY_VISIBILITY_TIMEOUT,default=30s"`
+	// InstanceID identifies the relay among the others in the logs and metrics. it defaults to the hostname along
+	// with a random suffix.
+	InstanceID string `env:"RELAY_INSTANCE_ID"`
+	// LeaseTTL is how long the leadership of a relay lasts unless it is renewed, i.e. how long the other relays wait
+	// before they take over from a dead leader. it must be longer than the frequency.
+	LeaseTTL time.Duration `env:"RELAY_LEASE_TTL,default=10s"`
+}
+
+type Webhook struct {
+	// SigningSecrets are comma separated secrets in the whsec_<base64> format. the webhooks are signed with all of
+	// them, so that a secret can be rotated by adding the new one before removing the old one.
+	SigningSecrets []string `env:"WEBHOOK_SIGNING_SECRETS"`
+	// BreakerFailureThreshold is the number of consecutive retryable failures of a destination host that opens its
+	// circuit breaker. zero disables the circuit breakers.
+	BreakerFailureThreshold int `env:"WEBHOOK_BREAKER_FAILURE_THRESHOLD,default=5"`
+	// BreakerFailureWindow is how long a failure is remembered, i.e. the failures of a host are consecutive when
+	// each one is within the window of the previous one.
+	BreakerFailureWindow time.Duration `env:"WEBHOOK_BREAKER_FAILURE_WINDOW,default=1m"`
+	// BreakerOpenDuration is how long the breaker of a host stays open before it lets probe requests through.
+	BreakerOpenDuration time.Duration `env:"WEBHOOK_BREAKER_OPEN_DURATION,default=30s"`
+	// BreakerProbes is the number of the probe requests that a half-open breaker lets through per open duration.
+	BreakerProbes int `env:"WEBHOOK_BREAKER_PROBES,default=1"`
+	// AllowPrivateDestinations lets the webhooks be sent to the private, loopback, link-local and the other
+	// non-public addresses, which are blocked otherwise.
+	AllowPrivateDestinations bool `env:"WEBHOOK_ALLOW_PRIVATE_DESTINATIONS,default=false"`
+	// AllowedCIDRs are the address ranges that the webhooks can be sent to even when they are not public.
+	AllowedCIDRs []string `env:"WEBHOOK_ALLOWED_CIDRS"`
+	// DeniedCIDRs are the address ranges that the webhooks cannot be sent to. they take precedence over AllowedCIDRs.
+	DeniedCIDRs []string `env:"WEBHOOK_DENIED_CIDRS"`
+	// AllowedHosts are the only hostnames that the webhooks can be sent to, when set. *.example.com matches the
+	// subdomains of example.com.
+	AllowedHosts []string `env:"WEBHOOK_ALLOWED_HOSTS"`
+	// DeniedHosts are the hostnames that the webhooks cannot be sent to, in the format of AllowedHosts.
+	DeniedHosts []string `env:"WEBHOOK_DENIED_HOSTS"`
+	// AllowedSchemes are the URL schemes of the webhooks. http and https are allowed when it is empty.
+	AllowedSchemes []string `env:"WEBHOOK_ALLOWED_SCHEMES,default=http,https"`
+	// AllowedPorts are the ports, e.g. 443, or the port ranges, e.g. 8000-8999, that the webhooks can be sent to.
+	// any port is allowed when it is empty.
+	AllowedPorts []string `env:"WEBHOOK_ALLOWED_PORTS"`
+	// Timeout is the overall timeout of a webhook request, including reading the response. a timer can override it.
+	// zero means no timeout.
+	Timeout time.Duration `env:"WEBHOOK_TIMEOUT,default=30s"`
+	// ConnectTimeout is the timeout of establishing the connection to the destination. zero means no timeout.
+	ConnectTimeout time.Duration `env:"WEBHOOK_CONNECT_TIMEOUT,default=10s"`
+	// TLSHandshakeTimeout is the timeout of the TLS handshake. zero means no timeout.
+	TLSHandshakeTimeout time.Duration `env:"WEBHOOK_TLS_HANDSHAKE_TIMEOUT,default=10s"`
+	// ResponseHeaderTimeout is the timeout of waiting for the response headers after the request is written. zero
+	// means no timeout other than the overall one.
+	ResponseHeaderTimeout time.Duration `env:"WEBHOOK_RESPONSE_HEADER_TIMEOUT,default=0"`
+	// MaxIdleConnsPerHost is the number of the idle connections that are kept open per destination host.
+	MaxIdleConnsPerHost int `env:"WEBHOOK_MAX_IDLE_CONNS_PER_HOST,default=10"`
+	// CAFile is a PEM bundle of the certificate authorities that are trusted besides the ones of the system.
+	CAFile string `env:"WEBHOOK_CA_FILE"`
+	// ClientCertFile and ClientKeyFile are the PEM certificate and key that the webhooks are sent with, for the
+	// destinations that require mutual TLS. they are set together.
+	ClientCertFile string `env:"WEBHOOK_CLIENT_CERT_FILE"`
+	ClientKeyFile  string `env:"WEBHOOK_CLIENT_KEY_FILE"`
+	// ProxyURL is the HTTP(S) proxy that the webhooks are sent through, e.g. http://proxy:3128. the proxy settings
+	// of the environment, e.g. HTTPS_PROXY, are not used. the hostnames of the webhooks are resolved and checked by
+	// the destination policy before they are handed to the proxy, but the proxy resolves them once more, so the
+	// proxy should also refuse the private destinations to be safe from DNS rebinding.
+	ProxyURL string `env:"WEBHOOK_PROXY_URL"`
+	// NoProxy are the hostnames that the webhooks are sent to directly, in the format of AllowedHosts.
+	NoProxy []string `env:"WEBHOOK_NO_PROXY"`
+	// MaxRedirects is the number of the redirects that a webhook follows at most. zero does not follow the
+	// redirects, i.e. the redirect response is the response of the webhook.
+	MaxRedirects int `env:"WEBHOOK_MAX_REDIRECTS,default=10"`
+	// AcceptedStatusCodes are the status codes of the successful responses, e.g. 200, classes of them, e.g. 2xx, or
+	// ranges of them, e.g. 200-204. a timer can override the success criteria.
+	AcceptedStatusCodes []string `env:"WEBHOOK_ACCEPTED_STATUS_CODES,default=2xx"`
+	// RetryableStatusCodes are the status codes of the failed responses that are retried, in the format of
+	// AcceptedStatusCodes.
+	RetryableStatusCodes []string `env:"WEBHOOK_RETRYABLE_STATUS_CODES,default=429,500,502-999"`
+	// SuccessBodyPattern is a regular expression that the body of a successful response matches.
+	SuccessBodyPattern string `env:"WEBHOOK_SUCCESS_BODY_PATTERN"`
+	// SuccessJSONPath is a dot separated path into the JSON body of a successful response, e.g. data.status, whose
+	// value is SuccessJSONValue. the path only has to exist when the value is empty.
+	SuccessJSONPath  string `env:"WEBHOOK_SUCCESS_JSON_PATH"`
+	SuccessJSONValue string `env:"WEBHOOK_SUCCESS_JSON_VALUE"`
 }
 
 // New constructs the config.
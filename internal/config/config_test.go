@@ -3,6 +3,7 @@ package config
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -13,4 +14,29 @@ func TestNewWithContext(t *testing.T) {
 	require.NoError(t, err)
 
 	assert.Equal(t, got.DB.TimerMaxTTLDays, 180)
+	assert.Equal(t, got.DB.IdempotencyWindow, 24*time.Hour)
+	assert.Equal(t, got.DB.AttemptHistorySize, 50)
+	assert.Equal(t, got.DB.ArchiveIndex, "bitmap")
+	assert.Equal(t, got.DB.ArchiveCapacity, uint64(1_000_000))
+	assert.Equal(t, got.DB.ArchiveFalsePositiveRate, 0.001)
+	assert.Equal(t, got.DB.ArchivePartition, 24*time.Hour)
+	assert.Equal(t, got.DB.ArchiveRetention, 30*24*time.Hour)
+	assert.Equal(t, got.DB.ArchiveTombstoneTTL, 30*24*time.Hour)
+	assert.Equal(t, got.ConsumerMaxRetryAfter, time.Hour)
+	assert.Equal(t, got.Producer.InitialBackoff, 10*time.Second)
+	assert.Equal(t, got.Producer.BackoffMultiplier, 2.0)
+	assert.Equal(t, got.Producer.RetryDeadline, time.Duration(0))
+	assert.Equal(t, got.Relay.VisibilityTimeout, 30*time.Second)
+	assert.Equal(t, got.Relay.LeaseTTL, 10*time.Second)
+	assert.Equal(t, got.Webhook.BreakerFailureThreshold, 5)
+	assert.Equal(t, got.Webhook.BreakerFailureWindow, time.Minute)
+	assert.Equal(t, got.Webhook.BreakerOpenDuration, 30*time.Second)
+	assert.Equal(t, got.Webhook.BreakerProbes, 1)
+	assert.False(t, got.Webhook.AllowPrivateDestinations)
+	assert.Equal(t, got.Webhook.AllowedSchemes, []string{"http", "https"})
+	assert.Equal(t, got.Limits.HostRate, 0.0)
+	assert.Equal(t, got.Limits.HostBurst, 1)
+	assert.Equal(t, got.Limits.TenantLabel, "tenant")
+	assert.Equal(t, got.Limits.InFlightTTL, 5*time.Minute)
+	assert.Equal(t, got.Limits.DeferDelay, time.Second)
 }
@@ -0,0 +1,277 @@
+package timer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+	"github.com/cubny/httpqueue/internal/config"
+)
+
+// limitKeyTag is the hash tag of the keys of the limits. acquireLimitsScript takes a webhook into the limits of its
+// host and its tenant at once, which Redis Cluster only allows for the keys of one hash slot, so all the keys of the
+// limits are pinned to one slot on purpose.
+const limitKeyTag = "{webhookLimits}"
+
+const (
+	// limitRateKeyFmt holds the theoretical arrival time of the next webhook of a scope, e.g. of a host, in
+	// microseconds. see acquireLimitsScript.
+	limitRateKeyFmt = limitKeyTag + ":webhookRate:%s:%s"
+	// limitInFlightKeyFmt is a sorted set of the in-flight webhooks of a scope, scored by the time their slot expires
+	// in microseconds.
+	limitInFlightKeyFmt = limitKeyTag + ":webhookInFlight:%s:%s"
+)
+
+const (
+	limitScopeHost   = "host"
+	limitScopeTenant = "tenant"
+)
+
+// ThrottledError is a webhook that is deferred for Delay because it is over a limit of its host or tenant. the
+// deferred tasks are retried without counting as failed. see IsFailure.
+type ThrottledError struct {
+	// Scope is either host or tenant.
+	Scope string
+	// Limit is either rate or in_flight.
+	Limit string
+	Delay time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("over the %s limit of the %s, deferred for %s", e.Limit, e.Scope, e.Delay)
+}
+
+// IsFailure tells the workers whether the error of a task is a failure. the deferred tasks are not failures, so
+// they do not use up the retries of the task.
+func IsFailure(err error) bool {
+	var throttledErr *ThrottledError
+	return !errors.As(err, &throttledErr)
+}
+
+// limit is the limit of the webhooks of a scope, e.g. of every host.
+type limit struct {
+	scope       string
+	rate        float64
+	burst       int
+	maxInFlight int
+}
+
+func (l limit) validate() error {
+	switch {
+	case l.rate < 0:
+		return fmt.Errorf("%s rate limit must not be negative", l.scope)
+	case l.rate > 0 && l.burst < 1:
+		return fmt.Errorf("%s burst must be positive", l.scope)
+	case l.maxInFlight < 0:
+		return fmt.Errorf("%s max in-flight must not be negative", l.scope)
+	}
+	return nil
+}
+
+func (l limit) isSet() bool {
+	return l.rate > 0 || l.maxInFlight > 0
+}
+
+// interval is the time between two webhooks at the rate, in microseconds.
+func (l limit) interval() int64 {
+	if l.rate == 0 {
+		return 0
+	}
+	return int64(math.Ceil(float64(time.Second/time.Microsecond) / l.rate))
+}
+
+// limitedScope is a scope that a webhook is limited by, e.g. the host example.com.
+type limitedScope struct {
+	limit
+	name string
+}
+
+// Limiter limits the webhooks per destination host and per tenant, by rate and by the number of in-flight webhooks.
+// the limits are kept in Redis so that all the workers enforce them together.
+type Limiter struct {
+	redisClient redis.UniversalClient
+	host        limit
+	tenant      limit
+	tenantLabel string
+	inFlightTTL time.Duration
+	deferDelay  time.Duration
+}
+
+// NewLimiter constructs a Limiter.
+func NewLimiter(redisClient redis.UniversalClient, cfg *config.Limits) (*Limiter, error) {
+	l := &Limiter{
+		redisClient: redisClient,
+		host:        limit{scope: limitScopeHost, rate: cfg.HostRate, burst: cfg.HostBurst, maxInFlight: cfg.HostMaxInFlight},
+		tenant:      limit{scope: limitScopeTenant, rate: cfg.TenantRate, burst: cfg.TenantBurst, maxInFlight: cfg.TenantMaxInFlight},
+		tenantLabel: cfg.TenantLabel,
+		inFlightTTL: cfg.InFlightTTL,
+		deferDelay:  cfg.DeferDelay,
+	}
+
+	if redisClient == nil {
+		return nil, errors.New("redisClient is not set up")
+	}
+
+	for _, lim := range []limit{l.host, l.tenant} {
+		if err := lim.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.inFlightTTL <= 0 {
+		return nil, errors.New("in-flight TTL must be positive")
+	}
+
+	if l.deferDelay <= 0 {
+		return nil, errors.New("defer delay must be positive")
+	}
+
+	return l, nil
+}
+
+// Acquire takes the webhook of the attempt of the timer into the limits of its host and its tenant. it returns a
+// ThrottledError when the webhook is over a limit, otherwise the returned release gives the in-flight slots of the
+// webhook back once it is done. the webhook is not limited when the limits are unavailable.
+func (l *Limiter) Acquire(ctx context.Context, t *timer.Timer, attempt int) (release func(context.Context), err error) {
+	release = func(context.Context) {}
+
+	scopes := l.scopes(t, attempt)
+	if len(scopes) == 0 {
+		return release, nil
+	}
+
+	keys := make([]string, 0, 2*len(scopes))
+	args := []interface{}{time.Now().UnixMicro(), uuid.NewString(), l.inFlightTTL.Microseconds(), l.deferDelay.Microseconds()}
+	for _, s := range scopes {
+		keys = append(keys, fmt.Sprintf(limitRateKeyFmt, s.scope, s.name), fmt.Sprintf(limitInFlightKeyFmt, s.scope, s.name))
+		args = append(args, s.interval(), s.burst, s.maxInFlight)
+	}
+
+	res, err := acquireLimitsScript.Run(ctx, l.redisClient, keys, args...).Int64Slice()
+	if err == nil && (len(res) != 3 || res[1] < 0 || res[1] > int64(len(scopes))) {
+		err = fmt.Errorf("unexpected limiter reply %v", res)
+	}
+
+	if err != nil {
+		consumerLimiterErrorInc()
+		logrus.WithError(err).WithFields(logrus.Fields{"timer_id": t.ID}).Error("failed to check the limits of the webhook")
+		return release, nil
+	}
+
+	if delay := res[0]; delay > 0 {
+		throttledErr := &ThrottledError{Scope: scopes[res[1]-1].scope, Limit: "rate", Delay: time.Duration(delay) * time.Microsecond}
+		if res[2] == 2 {
+			throttledErr.Limit = "in_flight"
+		}
+		consumerThrottledInc(throttledErr.Scope, throttledErr.Limit)
+		return release, throttledErr
+	}
+
+	var inFlightKeys []string
+	for i, s := range scopes {
+		if s.maxInFlight > 0 {
+			inFlightKeys = append(inFlightKeys, keys[2*i+1])
+		}
+	}
+
+	if len(inFlightKeys) == 0 {
+		return release, nil
+	}
+
+	slot := args[1]
+	return func(ctx context.Context) {
+		pipe := l.redisClient.Pipeline()
+		for _, key := range inFlightKeys {
+			pipe.ZRem(ctx, key, slot)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{"timer_id": t.ID}).Error("failed to release the in-flight webhook")
+		}
+	}, nil
+}
+
+// scopes returns the scopes that the webhook of the attempt of the timer is limited by.
+func (l *Limiter) scopes(t *timer.Timer, attempt int) []limitedScope {
+	var scopes []limitedScope
+	if l.host.isSet() {
+		scopes = append(scopes, limitedScope{limit: l.host, name: webhookHost(t, attempt)})
+	}
+
+	if tenant := t.Labels[l.tenantLabel]; l.tenant.isSet() && tenant != "" {
+		scopes = append(scopes, limitedScope{limit: l.tenant, name: tenant})
+	}
+
+	return scopes
+}
+
+// webhookHost returns the host of the webhook of the attempt, which falls back to the host of the timer URL when the
+// URL of the attempt cannot be rendered.
+func webhookHost(t *timer.Timer, attempt int) string {
+	rawURL, err := t.WebhookURL(attempt)
+	if err != nil {
+		return t.URL.Host
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return t.URL.Host
+	}
+
+	return u.Host
+}
+
+// acquireLimitsScript takes a webhook into the limits of its scopes, only if it is within all of them. the rate is
+// limited by the generic cell rate algorithm, i.e. a webhook is let through when the theoretical arrival time of the
+// next webhook is at most burst intervals ahead. the in-flight webhooks hold a slot in the sorted set of the scope
+// until they are released or the slot expires.
+//
+// it returns the delay (µs) along with the number of the scope and the limit (1 rate, 2 in-flight) that deferred the
+// webhook, or 0, 0, 0 when the webhook is let through.
+//
+// KEYS: rate, in-flight of every scope
+// ARGV: now (µs), slot, in-flight TTL (µs), defer delay (µs), then interval (µs), burst, max in-flight of every scope
+var acquireLimitsScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local wait, scope, limit = 0, 0, 0
+local tats = {}
+for i = 1, #KEYS / 2 do
+	local interval = tonumber(ARGV[3 * i + 2])
+	local burst = tonumber(ARGV[3 * i + 3])
+	local maxInFlight = tonumber(ARGV[3 * i + 4])
+	if interval > 0 then
+		local tat = math.max(tonumber(redis.call('GET', KEYS[2 * i - 1]) or '0'), now) + interval
+		local early = tat - now - burst * interval
+		if early > wait then
+			wait, scope, limit = early, i, 1
+		end
+		tats[i] = tat
+	end
+	if maxInFlight > 0 then
+		redis.call('ZREMRANGEBYSCORE', KEYS[2 * i], '-inf', now)
+		if redis.call('ZCARD', KEYS[2 * i]) >= maxInFlight and tonumber(ARGV[4]) > wait then
+			wait, scope, limit = tonumber(ARGV[4]), i, 2
+		end
+	end
+end
+if wait > 0 then
+	return {math.ceil(wait), scope, limit}
+end
+for i = 1, #KEYS / 2 do
+	if tats[i] then
+		redis.call('SET', KEYS[2 * i - 1], string.format('%d', tats[i]), 'PX', math.ceil((tats[i] - now) / 1000))
+	end
+	if tonumber(ARGV[3 * i + 4]) > 0 then
+		redis.call('ZADD', KEYS[2 * i], now + tonumber(ARGV[3]), ARGV[2])
+		redis.call('PEXPIRE', KEYS[2 * i], math.ceil(tonumber(ARGV[3]) / 1000))
+	end
+end
+return {0, 0, 0}
+`)
@@ -0,0 +1,166 @@
+package timer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+	"github.com/cubny/httpqueue/internal/config"
+	redisMocks "github.com/cubny/httpqueue/internal/mocks/external/redis"
+)
+
+func newLimitsConfig() *config.Limits {
+	return &config.Limits{
+		HostRate:          10,
+		HostBurst:         2,
+		TenantLabel:       "tenant",
+		TenantMaxInFlight: 5,
+		InFlightTTL:       time.Minute,
+		DeferDelay:        time.Second,
+	}
+}
+
+func newLimitedTimer(t *testing.T, labels map[string]string) *timer.Timer {
+	u, err := url.Parse("http://example.com:8080/hooks")
+	require.NoError(t, err)
+	return &timer.Timer{ID: "1", URL: *u, Labels: labels}
+}
+
+func TestNewLimiter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	redisClient := redisMocks.NewRedisClient(ctrl)
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *config.Limits)
+		wantErr bool
+	}{
+		{name: "valid config", modify: func(cfg *config.Limits) {}},
+		{name: "no limits", modify: func(cfg *config.Limits) { *cfg = config.Limits{InFlightTTL: time.Minute, DeferDelay: time.Second} }},
+		{name: "negative rate", modify: func(cfg *config.Limits) { cfg.TenantRate = -1 }, wantErr: true},
+		{name: "rate without burst", modify: func(cfg *config.Limits) { cfg.HostBurst = 0 }, wantErr: true},
+		{name: "negative max in-flight", modify: func(cfg *config.Limits) { cfg.HostMaxInFlight = -1 }, wantErr: true},
+		{name: "zero in-flight TTL", modify: func(cfg *config.Limits) { cfg.InFlightTTL = 0 }, wantErr: true},
+		{name: "zero defer delay", modify: func(cfg *config.Limits) { cfg.DeferDelay = 0 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newLimitsConfig()
+			tt.modify(cfg)
+
+			_, err := NewLimiter(redisClient, cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	_, err := NewLimiter(nil, newLimitsConfig())
+	assert.Error(t, err)
+}
+
+func TestLimiter_Acquire(t *testing.T) {
+	hostKeys := []string{"{webhookLimits}:webhookRate:host:example.com:8080", "{webhookLimits}:webhookInFlight:host:example.com:8080"}
+	tenantKeys := []string{"{webhookLimits}:webhookRate:tenant:acme", "{webhookLimits}:webhookInFlight:tenant:acme"}
+
+	tests := []struct {
+		name       string
+		labels     map[string]string
+		wantKeys   []string
+		evalResult *redis.Cmd
+		wantErr    *ThrottledError
+		wantZRem   []string
+	}{
+		{
+			name:       "lets the webhook of a host through",
+			wantKeys:   hostKeys,
+			evalResult: redis.NewCmdResult([]interface{}{int64(0), int64(0), int64(0)}, nil),
+		},
+		{
+			name:       "lets the webhook of a tenant through and releases its in-flight slot",
+			labels:     map[string]string{"tenant": "acme"},
+			wantKeys:   append(append([]string{}, hostKeys...), tenantKeys...),
+			evalResult: redis.NewCmdResult([]interface{}{int64(0), int64(0), int64(0)}, nil),
+			wantZRem:   []string{tenantKeys[1]},
+		},
+		{
+			name:       "defers the webhook over the rate of the host",
+			wantKeys:   hostKeys,
+			evalResult: redis.NewCmdResult([]interface{}{int64(100_000), int64(1), int64(1)}, nil),
+			wantErr:    &ThrottledError{Scope: limitScopeHost, Limit: "rate", Delay: 100 * time.Millisecond},
+		},
+		{
+			name:       "defers the webhook over the in-flight limit of the tenant",
+			labels:     map[string]string{"tenant": "acme"},
+			wantKeys:   append(append([]string{}, hostKeys...), tenantKeys...),
+			evalResult: redis.NewCmdResult([]interface{}{int64(1_000_000), int64(2), int64(2)}, nil),
+			wantErr:    &ThrottledError{Scope: limitScopeTenant, Limit: "in_flight", Delay: time.Second},
+		},
+		{
+			name:       "lets the webhook through when the limits are unavailable",
+			wantKeys:   hostKeys,
+			evalResult: redis.NewCmdResult(nil, assert.AnError),
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := redisMocks.NewRedisClient(ctrl)
+			l, err := NewLimiter(redisClient, newLimitsConfig())
+			require.NoError(t, err)
+
+			redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(), tt.wantKeys, gomock.Any()).Return(tt.evalResult)
+
+			release, err := l.Acquire(context.Background(), newLimitedTimer(t, tt.labels), 1)
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+
+			if len(tt.wantZRem) > 0 {
+				pipeliner := redisMocks.NewRedisPipeliner(ctrl)
+				redisClient.EXPECT().Pipeline().Return(pipeliner)
+				for _, key := range tt.wantZRem {
+					pipeliner.EXPECT().ZRem(gomock.Any(), key, gomock.Any())
+				}
+				pipeliner.EXPECT().Exec(gomock.Any()).Return(nil, nil)
+			}
+			release(context.Background())
+		})
+	}
+
+	t.Run("does not limit the webhook without limits", func(t *testing.T) {
+		redisClient := redisMocks.NewRedisClient(ctrl)
+		l, err := NewLimiter(redisClient, &config.Limits{TenantLabel: "tenant", InFlightTTL: time.Minute, DeferDelay: time.Second})
+		require.NoError(t, err)
+
+		release, err := l.Acquire(context.Background(), newLimitedTimer(t, map[string]string{"tenant": "acme"}), 1)
+		require.NoError(t, err)
+		release(context.Background())
+	})
+}
+
+func TestLimit_interval(t *testing.T) {
+	assert.Equal(t, int64(0), limit{}.interval())
+	assert.Equal(t, int64(100_000), limit{rate: 10}.interval())
+	assert.Equal(t, int64(2_000_000), limit{rate: 0.5}.interval())
+}
+
+func TestIsFailure(t *testing.T) {
+	assert.True(t, IsFailure(assert.AnError))
+	assert.False(t, IsFailure(fmt.Errorf("webhook is deferred: %w", &ThrottledError{Delay: time.Second})))
+}
@@ -17,10 +17,46 @@ var (
 			Name:      "error_counter",
 			Help:      "Counter of relay errors",
 		}, []string{"type", "reason"})
+	relayReclaimedCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "httpqueue",
+			Subsystem: "relay",
+			Name:      "reclaimed_counter",
+			Help:      "Counter of the outbox entries that are reclaimed after their visibility timeout",
+		})
+	relayLeaderGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "httpqueue",
+			Subsystem: "relay",
+			Name:      "leader",
+			Help:      "Whether the relay instance holds the lease and relays the outbox, 1 or 0",
+		}, []string{"instance"})
+	relayLeadershipChangeCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "httpqueue",
+			Subsystem: "relay",
+			Name:      "leadership_change_counter",
+			Help:      "Counter of the leases the relay instance acquired or lost",
+		}, []string{"change"})
+	consumerThrottledCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "httpqueue",
+			Subsystem: "consumer",
+			Name:      "throttled_counter",
+			Help:      "Counter of the webhooks that are deferred because they are over a limit of their host or tenant",
+		}, []string{"scope", "limit"})
+	consumerErrorCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "httpqueue",
+			Subsystem: "consumer",
+			Name:      "error_counter",
+			Help:      "Counter of consumer errors",
+		}, []string{"type"})
 )
 
 func init() {
-	prometheus.MustRegister(relayErrorCount)
+	prometheus.MustRegister(relayErrorCount, relayReclaimedCount, relayLeaderGauge, relayLeadershipChangeCount,
+		consumerThrottledCount, consumerErrorCount)
 }
 
 type dequeueError string
@@ -56,6 +92,7 @@ type producerError string
 const (
 	producerErrorDuplicateTask  producerError = "duplicateTask"
 	producerErrorTaskIDConflict producerError = "idConflict"
+	producerErrorInvalidTimer   producerError = "invalidTimer"
 	producerErrorOthers         producerError = "others"
 )
 
@@ -69,6 +106,8 @@ func producerErrorReason(err error) producerError {
 		return producerErrorDuplicateTask
 	case errors.Is(err, asynq.ErrTaskIDConflict):
 		return producerErrorTaskIDConflict
+	case errors.Is(err, ErrInvalidTimer):
+		return producerErrorInvalidTimer
 	default:
 		return producerErrorOthers
 	}
@@ -78,3 +117,33 @@ func relayProducerErrorTypeInc(err error) {
 	reason := producerErrorReason(err)
 	relayErrorCount.With(prometheus.Labels{"type": "producer", "reason": reason.String()}).Inc()
 }
+
+func relayStateErrorInc() {
+	relayErrorCount.With(prometheus.Labels{"type": "state", "reason": "others"}).Inc()
+}
+
+// relayOutboxErrorInc counts the failures of the operation on the outbox, i.e. ack, requeue or reclaim.
+func relayOutboxErrorInc(operation string) {
+	relayErrorCount.With(prometheus.Labels{"type": "outbox", "reason": operation}).Inc()
+}
+
+// relayLeaseErrorInc counts the failures of the operation on the lease, i.e. acquire or release.
+func relayLeaseErrorInc(operation string) {
+	relayErrorCount.With(prometheus.Labels{"type": "lease", "reason": operation}).Inc()
+}
+
+// relayLeadershipChangeInc counts the leases that are acquired or lost.
+func relayLeadershipChangeInc(change string) {
+	relayLeadershipChangeCount.With(prometheus.Labels{"change": change}).Inc()
+}
+
+// consumerThrottledInc counts the webhooks that are deferred by the limit, i.e. rate or in_flight, of the scope, i.e.
+// host or tenant.
+func consumerThrottledInc(scope, limit string) {
+	consumerThrottledCount.With(prometheus.Labels{"scope": scope, "limit": limit}).Inc()
+}
+
+// consumerLimiterErrorInc counts the failures to check the limits of the webhooks.
+func consumerLimiterErrorInc() {
+	consumerErrorCount.With(prometheus.Labels{"type": "limiter"}).Inc()
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/hibiken/asynq"
 	"github.com/sirupsen/logrus"
@@ -20,10 +21,14 @@ import (
 type Processor struct {
 	service    timer.Service
 	httpClient timer.HttpClient
+	// retryDelay is the retry delay of the workers, and tells when a failed attempt is retried.
+	retryDelay asynq.RetryDelayFunc
+	// limiter is nil when the webhooks are not limited.
+	limiter *Limiter
 }
 
-// NewProcessor constructs a Processor
-func NewProcessor(service timer.Service, httpClient timer.HttpClient) (*Processor, error) {
+// NewProcessor constructs a Processor. the limiter is optional.
+func NewProcessor(service timer.Service, httpClient timer.HttpClient, retryDelay asynq.RetryDelayFunc, limiter *Limiter) (*Processor, error) {
 	if service == nil {
 		return nil, errors.New("service is not set up")
 	}
@@ -32,9 +37,15 @@ func NewProcessor(service timer.Service, httpClient timer.HttpClient) (*Processo
 		return nil, errors.New("httpClient is not set up")
 	}
 
+	if retryDelay == nil {
+		return nil, errors.New("retryDelay is not set up")
+	}
+
 	return &Processor{
 		service:    service,
 		httpClient: httpClient,
+		retryDelay: retryDelay,
+		limiter:    limiter,
 	}, nil
 }
 
@@ -52,20 +63,162 @@ func (p *Processor) ProcessTask(ctx context.Context, task *asynq.Task) error {
 		return fmt.Errorf("timer does not exist: %v: %w", err, asynq.SkipRetry)
 	case err == timer.ErrTimerArchived:
 		return nil
+	case err == timer.ErrTimerCancelled:
+		logrus.WithFields(logrus.Fields{"timer_id": payload.TimerID}).Debug("timer is cancelled, skipping")
+		return nil
 	case err != nil:
 		// this could be due to unavailability of the underlying DB
 		return fmt.Errorf("failed to find the timer in storage: %v", err)
 	}
 
+	if t.Generation != payload.Generation {
+		// the timer of the task is gone and another timer is created with the same ID
+		logrus.WithFields(logrus.Fields{"timer_id": payload.TimerID}).Debug("stale task of a re-created timer, skipping")
+		return nil
+	}
+
+	if t.Revision != payload.Revision {
+		// the timer is rescheduled and the task of the new revision shoots the webhook
+		logrus.WithFields(logrus.Fields{"timer_id": payload.TimerID}).Debug("stale task of a rescheduled timer, skipping")
+		return nil
+	}
+
+	// the deadline is checked on the first attempt as well, as a throttled attempt is deferred without counting as a
+	// retry, and would be deferred past the deadline forever otherwise.
+	if payload.deadlineExceeded(time.Now()) {
+		err = errors.New("retry deadline of the timer is exceeded")
+		p.deadLetter(ctx, t, err)
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+
+	release, err := p.acquire(ctx, t)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"timer_id": t.ID}).Debug("webhook is over the limits, deferring")
+		return fmt.Errorf("webhook is deferred: %w", err)
+	}
+	defer release(ctx)
+
 	logrus.WithFields(logrus.Fields{"timer": t}).Debug("making HTTP call")
-	err = p.httpClient.Shoot(ctx, t)
+	p.setState(ctx, timer.NewTimerState(t, timer.StateInFlight).WithAttempt(attempt(ctx), nil))
+
+	startedAt := time.Now()
+	statusCode, err := p.httpClient.Shoot(ctx, t, attempt(ctx))
+
+	retryable := internalHttpClient.Outcome(err) == timer.OutcomeRetry
+	deadlineExceeded := retryable && payload.deadlineExceeded(time.Now())
+	retry := retryable && !deadlineExceeded && !isLastAttempt(ctx)
+	p.recordAttempt(ctx, task, t, timer.NewAttempt(t, attempt(ctx), startedAt, statusCode), err, retry)
+
 	switch {
-	case errors.Is(err, internalHttpClient.ErrRetryableRequestFailure):
+	case err == nil:
+		p.setState(ctx, timer.NewTimerState(t, timer.StateSucceeded).WithAttempt(attempt(ctx), nil))
+	case retry:
+		p.setState(ctx, timer.NewTimerState(t, timer.StateRetrying).WithAttempt(attempt(ctx), err))
+	}
+
+	switch {
+	case deadlineExceeded:
+		p.deadLetter(ctx, t, err)
+		return fmt.Errorf("failed to call the timer URL before the retry deadline: %v: %w", err, asynq.SkipRetry)
+	case retryable:
+		if isLastAttempt(ctx) {
+			p.deadLetter(ctx, t, err)
+		}
 		return fmt.Errorf("temporarliy failed to call the timer URL: %w", err)
 	case err != nil:
-		// TODO: publish to DLQ for troubleshooting and remove from the main queue
+		p.deadLetter(ctx, t, err)
 		return fmt.Errorf("permenantly failed to call the timer URL: %v: %w", err, asynq.SkipRetry)
+	case t.IsRecurring():
+		return p.service.ScheduleNextRun(ctx, t, timer.StateSucceeded)
 	default:
-		return p.service.ArchiveTimer(ctx, payload.TimerID)
+		return p.service.ArchiveTimer(ctx, payload.TimerID, timer.StateSucceeded)
+	}
+}
+
+// acquire takes the webhook of the current attempt into the limits of its host and tenant, if the webhooks are
+// limited. see Limiter.Acquire.
+func (p *Processor) acquire(ctx context.Context, t *timer.Timer) (func(context.Context), error) {
+	if p.limiter == nil {
+		return func(context.Context) {}, nil
+	}
+	return p.limiter.Acquire(ctx, t, attempt(ctx))
+}
+
+// setState keeps the state of the current run of the timer. failing to keep the state does not fail the task.
+func (p *Processor) setState(ctx context.Context, state *timer.TimerState) {
+	if err := p.service.SetTimerState(ctx, state); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"timer_id": state.TimerID}).Error("failed to set the timer state")
+	}
+}
+
+// recordAttempt keeps the outcome of the delivery attempt in the attempt history of the timer. when the failed
+// attempt is going to be retried, the time of the retry is recorded as well. a retryable failure that is not
+// retried, e.g. the last attempt, is recorded as a permanent failure. failing to record the attempt does not fail the
+// task.
+func (p *Processor) recordAttempt(ctx context.Context, task *asynq.Task, t *timer.Timer, a *timer.Attempt, failure error, retry bool) {
+	a.Outcome = timer.OutcomeSuccess
+	if failure != nil {
+		a.Outcome = timer.OutcomeFailure
+		a.WithError(internalHttpClient.ErrorClass(failure), failure)
+	}
+
+	if retry {
+		a.Outcome = timer.OutcomeRetry
+		retried, _ := asynq.GetRetryCount(ctx)
+		a.RetryAt = time.Now().Add(p.retryDelay(retried, failure, task))
+	}
+
+	if err := p.service.RecordAttempt(ctx, t.ID, a); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"timer_id": t.ID}).Error("failed to record the delivery attempt")
+	}
+}
+
+// deadLetter keeps the permanently failed run in the dead letter store, and makes sure that the failure does not
+// end the recurrence of a recurring timer.
+func (p *Processor) deadLetter(ctx context.Context, t *timer.Timer, failure error) {
+	deadLetter := timer.NewDeadLetter(t, attempt(ctx), failure)
+
+	var respErr *internalHttpClient.ResponseError
+	if errors.As(failure, &respErr) {
+		deadLetter.WithResponse(respErr.StatusCode, respErr.Body)
+	}
+
+	p.setState(ctx, timer.NewTimerState(t, timer.StateFailed).WithAttempt(attempt(ctx), failure))
+	if err := p.service.DeadLetterTimer(ctx, deadLetter); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"timer_id": t.ID}).Error("failed to dead letter the timer")
+	}
+
+	p.scheduleNextRunOnFailure(ctx, t)
+}
+
+// scheduleNextRunOnFailure makes sure that a failed run does not end the recurrence of a recurring timer.
+func (p *Processor) scheduleNextRunOnFailure(ctx context.Context, t *timer.Timer) {
+	if !t.IsRecurring() {
+		return
 	}
+
+	if err := p.service.ScheduleNextRun(ctx, t, timer.StateFailed); err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"timer_id": t.ID}).Error("failed to schedule the next run")
+	}
+}
+
+// attempt returns the number of the current delivery attempt starting from 1.
+func attempt(ctx context.Context) int {
+	retried, _ := asynq.GetRetryCount(ctx)
+	return retried + 1
+}
+
+// isLastAttempt tells whether the task is not going to be retried by the workers anymore.
+func isLastAttempt(ctx context.Context) bool {
+	retried, ok := asynq.GetRetryCount(ctx)
+	if !ok {
+		return false
+	}
+
+	maxRetry, ok := asynq.GetMaxRetry(ctx)
+	if !ok {
+		return false
+	}
+
+	return retried >= maxRetry
 }
@@ -4,10 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
 	"testing"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/golang/mock/gomock"
 	"github.com/hibiken/asynq"
 	"github.com/stretchr/testify/assert"
@@ -16,6 +19,7 @@ import (
 	"github.com/cubny/httpqueue/internal/app/timer"
 	timer2 "github.com/cubny/httpqueue/internal/infra/http/client/timer"
 	mocks "github.com/cubny/httpqueue/internal/mocks/app/timer"
+	redisMocks "github.com/cubny/httpqueue/internal/mocks/external/redis"
 )
 
 func TestNewProcessor(t *testing.T) {
@@ -25,30 +29,41 @@ func TestNewProcessor(t *testing.T) {
 		name       string
 		service    timer.Service
 		httpClient timer.HttpClient
+		retryDelay asynq.RetryDelayFunc
 		wantErr    bool
 	}{
 		{
 			name:       "valid",
 			service:    mocks.NewService(ctrl),
 			httpClient: mocks.NewHttpClient(ctrl),
+			retryDelay: asynq.DefaultRetryDelayFunc,
 			wantErr:    false,
 		},
 		{
 			name:       "no service",
 			service:    nil,
 			httpClient: mocks.NewHttpClient(ctrl),
+			retryDelay: asynq.DefaultRetryDelayFunc,
 			wantErr:    true,
 		},
 		{
 			name:       "no http client",
 			service:    mocks.NewService(ctrl),
 			httpClient: nil,
+			retryDelay: asynq.DefaultRetryDelayFunc,
+			wantErr:    true,
+		},
+		{
+			name:       "no retry delay",
+			service:    mocks.NewService(ctrl),
+			httpClient: mocks.NewHttpClient(ctrl),
+			retryDelay: nil,
 			wantErr:    true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := NewProcessor(tt.service, tt.httpClient)
+			got, err := NewProcessor(tt.service, tt.httpClient, tt.retryDelay, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewProcessor() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -65,6 +80,8 @@ func TestNewProcessor(t *testing.T) {
 func TestProcessor_ProcessTask(t *testing.T) {
 	type spec struct {
 		mockFn             func(service *mocks.Service, httpClient *mocks.HttpClient)
+		payload            *Payload
+		wantStates         []timer.State
 		wantError          bool
 		wantRetryableError bool
 	}
@@ -76,17 +93,29 @@ func TestProcessor_ProcessTask(t *testing.T) {
 			service := mocks.NewService(ctrl)
 			httpClient := mocks.NewHttpClient(ctrl)
 
-			p, err := NewProcessor(service, httpClient)
+			p, err := NewProcessor(service, httpClient, func(int, error, *asynq.Task) time.Duration { return time.Minute }, nil)
 			require.NoError(t, err)
 
 			payload := &Payload{TimerID: "1"}
+			if s.payload != nil {
+				payload = s.payload
+			}
 			payloadBytes, err := json.Marshal(payload)
 			require.NoError(t, err)
 
 			task := asynq.NewTask(TypeName, payloadBytes)
 			s.mockFn(service, httpClient)
 
+			var gotStates []timer.State
+			service.EXPECT().SetTimerState(gomock.Any(), gomock.Any()).
+				Do(func(_ context.Context, state *timer.TimerState) {
+					gotStates = append(gotStates, state.State)
+				}).
+				Return(nil).
+				AnyTimes()
+
 			perr := p.ProcessTask(context.Background(), task)
+			assert.Equal(t, s.wantStates, gotStates)
 			if (perr != nil) != s.wantError {
 				t.Errorf("ProcessTask error = %v, wantErr %v", perr, s.wantError)
 				return
@@ -104,11 +133,81 @@ func TestProcessor_ProcessTask(t *testing.T) {
 
 			foundTimer := &timer.Timer{ID: "1", URL: *u, FireAt: time.Now()}
 			service.EXPECT().GetTimer(gomock.Any(), "1").Return(foundTimer, nil)
-			service.EXPECT().ArchiveTimer(gomock.Any(), "1").Return(nil)
+			service.EXPECT().RecordAttempt(gomock.Any(), "1", gomock.Any()).
+				Do(func(_ context.Context, _ string, attempt *timer.Attempt) {
+					assert.True(t, attempt.Succeeded())
+					assert.Equal(t, timer.OutcomeSuccess, attempt.Outcome)
+					assert.Equal(t, 1, attempt.Run)
+					assert.Equal(t, 1, attempt.Number)
+					assert.Equal(t, http.StatusOK, attempt.StatusCode)
+					assert.True(t, attempt.RetryAt.IsZero())
+				}).
+				Return(nil)
+			service.EXPECT().ArchiveTimer(gomock.Any(), "1", timer.StateSucceeded).Return(nil)
 
-			httpClient.EXPECT().Shoot(gomock.Any(), foundTimer).Return(nil)
+			httpClient.EXPECT().Shoot(gomock.Any(), foundTimer, 1).Return(http.StatusOK, nil)
 		},
-		wantError: false,
+		wantStates: []timer.State{timer.StateInFlight, timer.StateSucceeded},
+		wantError:  false,
+	}))
+
+	t.Run("failing to record the attempt does not fail the task", testFn(spec{
+		mockFn: func(service *mocks.Service, httpClient *mocks.HttpClient) {
+			u, err := url.Parse("http://valid.url")
+			require.NoError(t, err)
+
+			foundTimer := &timer.Timer{ID: "1", URL: *u, FireAt: time.Now()}
+			service.EXPECT().GetTimer(gomock.Any(), "1").Return(foundTimer, nil)
+			service.EXPECT().RecordAttempt(gomock.Any(), "1", gomock.Any()).Return(assert.AnError)
+			service.EXPECT().ArchiveTimer(gomock.Any(), "1", timer.StateSucceeded).Return(nil)
+
+			httpClient.EXPECT().Shoot(gomock.Any(), foundTimer, 1).Return(http.StatusOK, nil)
+		},
+		wantStates: []timer.State{timer.StateInFlight, timer.StateSucceeded},
+		wantError:  false,
+	}))
+
+	t.Run("finds the recurring timer, shoots webhook and schedules the next run", testFn(spec{
+		mockFn: func(service *mocks.Service, httpClient *mocks.HttpClient) {
+			u, err := url.Parse("http://valid.url")
+			require.NoError(t, err)
+
+			foundTimer := &timer.Timer{ID: "1", URL: *u, FireAt: time.Now(), Recurrence: &timer.Recurrence{Cron: "@hourly"}}
+			service.EXPECT().GetTimer(gomock.Any(), "1").Return(foundTimer, nil)
+			service.EXPECT().RecordAttempt(gomock.Any(), "1", gomock.Any()).Return(nil)
+			service.EXPECT().ScheduleNextRun(gomock.Any(), foundTimer, timer.StateSucceeded).Return(nil)
+
+			httpClient.EXPECT().Shoot(gomock.Any(), foundTimer, 1).Return(http.StatusOK, nil)
+		},
+		wantStates: []timer.State{timer.StateInFlight, timer.StateSucceeded},
+		wantError:  false,
+	}))
+
+	t.Run("finds the recurring timer, permanent failure still schedules the next run", testFn(spec{
+		mockFn: func(service *mocks.Service, httpClient *mocks.HttpClient) {
+			u, err := url.Parse("http://valid.url")
+			require.NoError(t, err)
+
+			foundTimer := &timer.Timer{ID: "1", URL: *u, FireAt: time.Now(), Recurrence: &timer.Recurrence{Cron: "@hourly"}}
+			service.EXPECT().GetTimer(gomock.Any(), "1").Return(foundTimer, nil)
+			service.EXPECT().DeadLetterTimer(gomock.Any(), gomock.Any()).
+				Do(func(_ context.Context, deadLetter *timer.DeadLetter) {
+					assert.Equal(t, fmt.Sprintf("1:1@%d", deadLetter.DeadAt.UnixMilli()), deadLetter.ID)
+				}).
+				Return(nil)
+			service.EXPECT().RecordAttempt(gomock.Any(), "1", gomock.Any()).
+				Do(func(_ context.Context, _ string, attempt *timer.Attempt) {
+					assert.Equal(t, timer.AttemptErrorRequest, attempt.ErrorClass)
+					assert.Equal(t, assert.AnError.Error(), attempt.Error)
+				}).
+				Return(nil)
+			service.EXPECT().ScheduleNextRun(gomock.Any(), foundTimer, timer.StateFailed).Return(nil)
+
+			httpClient.EXPECT().Shoot(gomock.Any(), foundTimer, 1).Return(0, assert.AnError)
+		},
+		wantError:          true,
+		wantStates:         []timer.State{timer.StateInFlight, timer.StateFailed},
+		wantRetryableError: false,
 	}))
 
 	t.Run("finds the timer, shoots the webhook, get responded with non-retryable HTTP error", testFn(spec{
@@ -118,10 +217,29 @@ func TestProcessor_ProcessTask(t *testing.T) {
 
 			foundTimer := &timer.Timer{ID: "1", URL: *u, FireAt: time.Now()}
 			service.EXPECT().GetTimer(gomock.Any(), "1").Return(foundTimer, nil)
+			service.EXPECT().DeadLetterTimer(gomock.Any(), gomock.Any()).
+				Do(func(_ context.Context, deadLetter *timer.DeadLetter) {
+					assert.Equal(t, fmt.Sprintf("1@%d", deadLetter.DeadAt.UnixMilli()), deadLetter.ID)
+					assert.Equal(t, http.StatusNotFound, deadLetter.StatusCode)
+					assert.Equal(t, "no such hook", deadLetter.ResponseBody)
+					assert.Equal(t, 1, deadLetter.Attempts)
+				}).
+				Return(nil)
 
-			httpClient.EXPECT().Shoot(gomock.Any(), foundTimer).Return(assert.AnError)
+			service.EXPECT().RecordAttempt(gomock.Any(), "1", gomock.Any()).
+				Do(func(_ context.Context, _ string, attempt *timer.Attempt) {
+					assert.Equal(t, timer.AttemptErrorHTTPStatus, attempt.ErrorClass)
+					assert.Equal(t, timer.OutcomeFailure, attempt.Outcome)
+					assert.Equal(t, http.StatusNotFound, attempt.StatusCode)
+					assert.True(t, attempt.RetryAt.IsZero())
+				}).
+				Return(nil)
+
+			httpClient.EXPECT().Shoot(gomock.Any(), foundTimer, 1).
+				Return(http.StatusNotFound, fmt.Errorf("http request failed: %w", &timer2.ResponseError{StatusCode: http.StatusNotFound, Body: "no such hook"}))
 		},
 		wantError:          true,
+		wantStates:         []timer.State{timer.StateInFlight, timer.StateFailed},
 		wantRetryableError: false,
 	}))
 
@@ -132,13 +250,72 @@ func TestProcessor_ProcessTask(t *testing.T) {
 
 			foundTimer := &timer.Timer{ID: "1", URL: *u, FireAt: time.Now()}
 			service.EXPECT().GetTimer(gomock.Any(), "1").Return(foundTimer, nil)
+			service.EXPECT().RecordAttempt(gomock.Any(), "1", gomock.Any()).
+				Do(func(_ context.Context, _ string, attempt *timer.Attempt) {
+					assert.Equal(t, timer.AttemptErrorHTTPStatus, attempt.ErrorClass)
+					assert.Equal(t, timer.OutcomeRetry, attempt.Outcome)
+					assert.Equal(t, http.StatusServiceUnavailable, attempt.StatusCode)
+					assert.WithinDuration(t, time.Now().Add(time.Minute), attempt.RetryAt, time.Second)
+				}).
+				Return(nil)
 
-			httpClient.EXPECT().Shoot(gomock.Any(), foundTimer).Return(timer2.ErrRetryableRequestFailure)
+			httpClient.EXPECT().Shoot(gomock.Any(), foundTimer, 1).
+				Return(http.StatusServiceUnavailable, fmt.Errorf("http request failed: %w", &timer2.ResponseError{StatusCode: http.StatusServiceUnavailable, Retryable: true}))
 		},
 		wantError:          true,
+		wantStates:         []timer.State{timer.StateInFlight, timer.StateRetrying},
 		wantRetryableError: true,
 	}))
 
+	t.Run("finds the timer, shoots the webhook, retryable HTTP error after the retry deadline", testFn(spec{
+		mockFn: func(service *mocks.Service, httpClient *mocks.HttpClient) {
+			u, err := url.Parse("http://valid.url")
+			require.NoError(t, err)
+
+			foundTimer := &timer.Timer{ID: "1", URL: *u, FireAt: time.Now().Add(-time.Hour)}
+			service.EXPECT().GetTimer(gomock.Any(), "1").Return(foundTimer, nil)
+			service.EXPECT().RecordAttempt(gomock.Any(), "1", gomock.Any()).
+				Do(func(_ context.Context, _ string, attempt *timer.Attempt) {
+					assert.Equal(t, timer.OutcomeFailure, attempt.Outcome)
+					assert.True(t, attempt.RetryAt.IsZero())
+				}).
+				Return(nil)
+			service.EXPECT().DeadLetterTimer(gomock.Any(), gomock.Any()).Return(nil)
+
+			// the deadline passes while the webhook is being shot
+			httpClient.EXPECT().Shoot(gomock.Any(), foundTimer, 1).
+				DoAndReturn(func(context.Context, *timer.Timer, int) (int, error) {
+					time.Sleep(100 * time.Millisecond)
+					return 0, timer2.ErrRetryableRequestFailure
+				})
+		},
+		payload: &Payload{TimerID: "1", Retry: &RetryPayload{Deadline: func() *time.Time {
+			deadline := time.Now().Add(50 * time.Millisecond)
+			return &deadline
+		}()}},
+		wantError:          true,
+		wantStates:         []timer.State{timer.StateInFlight, timer.StateFailed},
+		wantRetryableError: false,
+	}))
+
+	t.Run("finds the timer, the retry deadline is exceeded before the first attempt, does not call the webhook", testFn(spec{
+		mockFn: func(service *mocks.Service, httpClient *mocks.HttpClient) {
+			u, err := url.Parse("http://valid.url")
+			require.NoError(t, err)
+
+			foundTimer := &timer.Timer{ID: "1", URL: *u, FireAt: time.Now().Add(-time.Hour)}
+			service.EXPECT().GetTimer(gomock.Any(), "1").Return(foundTimer, nil)
+			service.EXPECT().DeadLetterTimer(gomock.Any(), gomock.Any()).Return(nil)
+		},
+		payload: &Payload{TimerID: "1", Retry: &RetryPayload{Deadline: func() *time.Time {
+			deadline := time.Now().Add(-time.Minute)
+			return &deadline
+		}()}},
+		wantError:          true,
+		wantStates:         []timer.State{timer.StateFailed},
+		wantRetryableError: false,
+	}))
+
 	t.Run("timer does not exist", testFn(spec{
 		mockFn: func(service *mocks.Service, httpClient *mocks.HttpClient) {
 			service.EXPECT().GetTimer(gomock.Any(), "1").Return(nil, timer.ErrTimerNotFound)
@@ -155,6 +332,36 @@ func TestProcessor_ProcessTask(t *testing.T) {
 		wantRetryableError: true,
 	}))
 
+	t.Run("timer is rescheduled, stale task does not call the webhook", testFn(spec{
+		mockFn: func(service *mocks.Service, httpClient *mocks.HttpClient) {
+			u, err := url.Parse("http://valid.url")
+			require.NoError(t, err)
+
+			foundTimer := &timer.Timer{ID: "1", URL: *u, FireAt: time.Now(), Revision: 1}
+			service.EXPECT().GetTimer(gomock.Any(), "1").Return(foundTimer, nil)
+		},
+		wantError: false,
+	}))
+
+	t.Run("timer is re-created with the same ID, stale task does not call the webhook", testFn(spec{
+		mockFn: func(service *mocks.Service, httpClient *mocks.HttpClient) {
+			u, err := url.Parse("http://valid.url")
+			require.NoError(t, err)
+
+			foundTimer := &timer.Timer{ID: "1", URL: *u, FireAt: time.Now(), Generation: 2}
+			service.EXPECT().GetTimer(gomock.Any(), "1").Return(foundTimer, nil)
+		},
+		payload:   &Payload{TimerID: "1", Generation: 1},
+		wantError: false,
+	}))
+
+	t.Run("timer is cancelled, does not call the webhook", testFn(spec{
+		mockFn: func(service *mocks.Service, httpClient *mocks.HttpClient) {
+			service.EXPECT().GetTimer(gomock.Any(), "1").Return(nil, timer.ErrTimerCancelled)
+		},
+		wantError: false,
+	}))
+
 	t.Run("timer is archived, does not call the webhook", testFn(spec{
 		mockFn: func(service *mocks.Service, httpClient *mocks.HttpClient) {
 			service.EXPECT().GetTimer(gomock.Any(), "1").Return(nil, timer.ErrTimerArchived)
@@ -162,3 +369,32 @@ func TestProcessor_ProcessTask(t *testing.T) {
 		wantError: false,
 	}))
 }
+
+func TestProcessor_ProcessTask_DefersThrottledWebhook(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := mocks.NewService(ctrl)
+	httpClient := mocks.NewHttpClient(ctrl)
+	redisClient := redisMocks.NewRedisClient(ctrl)
+
+	limiter, err := NewLimiter(redisClient, newLimitsConfig())
+	require.NoError(t, err)
+
+	p, err := NewProcessor(service, httpClient, func(int, error, *asynq.Task) time.Duration { return time.Minute }, limiter)
+	require.NoError(t, err)
+
+	foundTimer := newLimitedTimer(t, nil)
+	service.EXPECT().GetTimer(gomock.Any(), "1").Return(foundTimer, nil)
+	redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(redis.NewCmdResult([]interface{}{int64(100_000), int64(1), int64(1)}, nil))
+
+	payloadBytes, err := json.Marshal(&Payload{TimerID: "1"})
+	require.NoError(t, err)
+
+	err = p.ProcessTask(context.Background(), asynq.NewTask(TypeName, payloadBytes))
+
+	var throttledErr *ThrottledError
+	require.ErrorAs(t, err, &throttledErr)
+	assert.Equal(t, 100*time.Millisecond, throttledErr.Delay)
+	assert.False(t, IsFailure(err))
+	assert.NotErrorIs(t, err, asynq.SkipRetry)
+}
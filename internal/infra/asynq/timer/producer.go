@@ -2,6 +2,7 @@ package timer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/hibiken/asynq"
@@ -10,9 +11,13 @@ import (
 	"github.com/cubny/httpqueue/internal/config"
 )
 
+// ErrInvalidTimer indicates a timer that can never be sent.
+var ErrInvalidTimer = errors.New("timer is invalid")
+
 type Producer struct {
-	broker   Broker
-	maxRetry int
+	broker Broker
+	// retryPolicy is the default retry policy of the timers.
+	retryPolicy timer.RetryPolicy
 }
 
 type Broker interface {
@@ -21,21 +26,49 @@ type Broker interface {
 
 func NewProducer(broker Broker, cfg *config.Producer) *Producer {
 	return &Producer{
-		broker:   broker,
-		maxRetry: cfg.MaxRetry,
+		broker:      broker,
+		retryPolicy: defaultRetryPolicy(cfg),
+	}
+}
+
+// defaultRetryPolicy is the retry policy of the timers that do not override it.
+func defaultRetryPolicy(cfg *config.Producer) timer.RetryPolicy {
+	return timer.RetryPolicy{
+		MaxAttempts:    cfg.MaxRetry + 1,
+		InitialBackoff: cfg.InitialBackoff,
+		MaxBackoff:     cfg.MaxBackoff,
+		Multiplier:     cfg.BackoffMultiplier,
+		Jitter:         cfg.BackoffJitter,
+		Deadline:       cfg.RetryDeadline,
 	}
 }
 
 func (p *Producer) Send(ctx context.Context, timer *timer.Timer) error {
 	if err := timer.Validate(); err != nil {
-		return fmt.Errorf("timer is invalid: %w", err)
+		return fmt.Errorf("%w: %v", ErrInvalidTimer, err)
 	}
 
-	task, err := NewTask(timer.ID)
+	policy := p.retryPolicy
+	if timer.RetryPolicy != nil {
+		policy = timer.RetryPolicy.WithDefaults(p.retryPolicy)
+	}
+
+	task, err := NewTask(Payload{
+		TimerID:    timer.ID,
+		Revision:   timer.Revision,
+		Generation: timer.Generation,
+		Retry:      newRetryPayload(policy, timer.FireAt),
+	})
 	if err != nil {
 		return err
 	}
 
-	_, err = p.broker.EnqueueContext(ctx, task, asynq.MaxRetry(p.maxRetry), asynq.ProcessAt(timer.FireAt))
+	_, err = p.broker.EnqueueContext(ctx, task,
+		asynq.MaxRetry(policy.MaxAttempts-1), asynq.ProcessAt(timer.FireAt), asynq.TaskID(TaskID(timer.ID, timer.Generation, timer.Revision)))
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		// the revision of the timer is already enqueued, e.g. the relay is sending it again
+		return nil
+	}
+
 	return err
 }
@@ -2,10 +2,13 @@ package timer
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/hibiken/asynq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -33,6 +36,20 @@ func TestProducer_Send(t *testing.T) {
 			enqueueCalledTimes: 1,
 			wantErr:            assert.NoError,
 		},
+		{
+			name:               "already enqueued timer",
+			timer:              aTimer,
+			enqueueErr:         asynq.ErrTaskIDConflict,
+			enqueueCalledTimes: 1,
+			wantErr:            assert.NoError,
+		},
+		{
+			name:               "broker error",
+			timer:              aTimer,
+			enqueueErr:         assert.AnError,
+			enqueueCalledTimes: 1,
+			wantErr:            assert.Error,
+		},
 		{
 			name:               "nil timer will err",
 			timer:              nil,
@@ -51,7 +68,7 @@ func TestProducer_Send(t *testing.T) {
 
 			broker.
 				EXPECT().
-				EnqueueContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				EnqueueContext(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 				Return(nil, tt.enqueueErr).
 				Times(tt.enqueueCalledTimes)
 
@@ -61,3 +78,36 @@ func TestProducer_Send(t *testing.T) {
 		})
 	}
 }
+
+func TestTaskID(t *testing.T) {
+	assert.Equal(t, "order-42", TaskID("order-42", 0, 0))
+	assert.Equal(t, "order-42:3", TaskID("order-42", 0, 3))
+	assert.Equal(t, "order-42@1700000000000000000", TaskID("order-42", 1700000000000000000, 0))
+	assert.Equal(t, "order-42@1700000000000000000:3", TaskID("order-42", 1700000000000000000, 3))
+}
+
+func TestProducer_Send_RetryPolicy(t *testing.T) {
+	aTimer, err := timer.NewTimer("http://valid.url", 1, 0, 0)
+	require.NoError(t, err)
+	aTimer.RetryPolicy = &timer.RetryPolicy{MaxAttempts: 3, Deadline: time.Hour}
+
+	cfg := &config.Producer{MaxRetry: 10, InitialBackoff: time.Second, MaxBackoff: time.Minute, BackoffMultiplier: 2}
+	ctrl := gomock.NewController(t)
+	broker := mocks2.NewBroker(ctrl)
+
+	broker.
+		EXPECT().
+		EnqueueContext(gomock.Any(), gomock.Any(), asynq.MaxRetry(2), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, task *asynq.Task, _ ...asynq.Option) (*asynq.TaskInfo, error) {
+			var payload Payload
+			require.NoError(t, json.Unmarshal(task.Payload(), &payload))
+			require.NotNil(t, payload.Retry)
+			assert.Equal(t, time.Second, payload.Retry.InitialBackoff)
+			assert.Equal(t, time.Minute, payload.Retry.MaxBackoff)
+			require.NotNil(t, payload.Retry.Deadline)
+			assert.WithinDuration(t, aTimer.FireAt.Add(time.Hour), *payload.Retry.Deadline, time.Second)
+			return nil, nil
+		})
+
+	require.NoError(t, NewProducer(broker, cfg).Send(context.Background(), aTimer))
+}
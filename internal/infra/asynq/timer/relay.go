@@ -3,20 +3,29 @@ package timer
 import (
 	"context"
 	"errors"
+	"os"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/cubny/httpqueue/internal/app/timer"
 	"github.com/cubny/httpqueue/internal/config"
 )
 
-// Relay is responsible for relaying messages from the outbox to the producer
+// Relay is responsible for relaying messages from the outbox to the producer. many relays can run at once, but only
+// the one that holds the lease relays the outbox, while the others stand by to take the lease over. see timer.Lease.
 type Relay struct {
-	outbox    timer.Outbox
-	producer  timer.Producer
-	ticker    *time.Ticker
-	batchSize int
+	outbox            timer.Outbox
+	producer          timer.Producer
+	ticker            *time.Ticker
+	batchSize         int
+	visibilityTimeout time.Duration
+	instance          string
+	leaseTTL          time.Duration
+	// lease is set as long as the relay leads.
+	lease *timer.Lease
 }
 
 // NewRelay constructs a Relay.
@@ -29,25 +38,56 @@ func NewRelay(cfg *config.Relay, outbox timer.Outbox, producer timer.Producer) (
 		return nil, errors.New("producer is not set up")
 	}
 
+	if cfg.VisibilityTimeout <= 0 {
+		return nil, errors.New("visibility timeout must be positive")
+	}
+
+	frequency := time.Duration(cfg.FrequencyMilliSeconds) * time.Millisecond
+	if cfg.LeaseTTL <= frequency {
+		return nil, errors.New("lease TTL must be longer than the frequency")
+	}
+
 	relay := &Relay{
-		outbox:    outbox,
-		producer:  producer,
-		ticker:    time.NewTicker(time.Duration(cfg.FrequencyMilliSeconds) * time.Millisecond),
-		batchSize: cfg.BatchSize,
+		outbox:            outbox,
+		producer:          producer,
+		ticker:            time.NewTicker(frequency),
+		batchSize:         cfg.BatchSize,
+		visibilityTimeout: cfg.VisibilityTimeout,
+		instance:          instanceID(cfg),
+		leaseTTL:          cfg.LeaseTTL,
 	}
+	relayLeaderGauge.With(prometheus.Labels{"instance": relay.instance}).Set(0)
 
 	return relay, nil
 
 }
 
-// Start makes periodic dispatch based on the ticker frequency.
+// instanceID identifies the relay among the others. unless it is configured, it is unique per process.
+func instanceID(cfg *config.Relay) string {
+	if cfg.InstanceID != "" {
+		return cfg.InstanceID
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "relay"
+	}
+
+	return hostname + "-" + uuid.NewString()[:8]
+}
+
+// Start makes periodic dispatch based on the ticker frequency, as long as the relay leads. the lease is given up
+// when the context is done.
 func (r *Relay) Start(ctx context.Context) {
 	for {
 		select {
 		case <-r.ticker.C:
-			r.dispatch(ctx)
+			if r.lead(ctx) {
+				r.dispatch(ctx)
+			}
 		case <-ctx.Done():
 			r.Stop()
+			r.resign()
 			return
 		}
 	}
@@ -58,18 +98,122 @@ func (r *Relay) Stop() {
 	r.ticker.Stop()
 }
 
-// dispatch dequeues the outbox and send them to the workers via the producer.
+// lead acquires or extends the lease, and tells whether the relay leads.
+func (r *Relay) lead(ctx context.Context) bool {
+	lease, err := r.outbox.AcquireLease(ctx, r.instance, r.leaseTTL)
+	if err != nil {
+		relayLeaseErrorInc("acquire")
+		log.WithContext(ctx).Errorf("unable to acquire the lease, %v", err)
+	}
+
+	r.setLease(ctx, lease)
+	return lease != nil
+}
+
+// resign gives the lease up, so that another relay takes it over without waiting for the lease to expire.
+func (r *Relay) resign() {
+	if r.lease == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := r.outbox.ReleaseLease(ctx, r.lease); err != nil {
+		relayLeaseErrorInc("release")
+		log.WithContext(ctx).Errorf("unable to release the lease, %v", err)
+	}
+
+	r.setLease(ctx, nil)
+}
+
+// setLease keeps the lease, and reports the change of the leadership.
+func (r *Relay) setLease(ctx context.Context, lease *timer.Lease) {
+	logger := log.WithContext(ctx).WithField("instance", r.instance)
+	switch {
+	case lease != nil && (r.lease == nil || r.lease.Token != lease.Token):
+		relayLeadershipChangeInc("acquired")
+		relayLeaderGauge.With(prometheus.Labels{"instance": r.instance}).Set(1)
+		logger.WithField("token", lease.Token).Info("the relay leads")
+	case lease == nil && r.lease != nil:
+		relayLeadershipChangeInc("lost")
+		relayLeaderGauge.With(prometheus.Labels{"instance": r.instance}).Set(0)
+		logger.Warn("the relay no longer leads")
+	}
+
+	r.lease = lease
+}
+
+// dispatch dequeues the outbox and send them to the workers via the producer. the sent timers are acknowledged and
+// the ones that failed to be sent are requeued, so that a timer is handed to the workers at least once. the timers
+// that a dead relay left behind are reclaimed first.
 func (r *Relay) dispatch(ctx context.Context) {
-	timers, err := r.outbox.DequeueOutbox(ctx, r.batchSize)
+	r.reclaim(ctx)
+
+	timers, err := r.outbox.DequeueOutbox(ctx, r.lease.Token, r.batchSize, r.visibilityTimeout)
+	if errors.Is(err, timer.ErrLeaseLost) {
+		// another relay has taken the lease over in the meantime
+		r.setLease(ctx, nil)
+		return
+	}
+
 	if err != nil {
 		relayDequeueErrorTypeInc(err)
 		log.WithContext(ctx).Errorf("unable to dequeue the outbox, %v", err)
 	}
 
+	if len(timers) == 0 {
+		return
+	}
+
+	// the timers are marked before they are sent, so that the state of a timer that the workers pick up right away
+	// is not overwritten by the relay.
+	if err = r.outbox.MarkRelayed(ctx, timers); err != nil {
+		relayStateErrorInc()
+		log.WithContext(ctx).Errorf("unable to mark the timers as relayed, %v", err)
+	}
+
+	var sent, failed []string
 	for _, t := range timers {
-		if err = r.producer.Send(ctx, t); err != nil {
+		err = r.producer.Send(ctx, t)
+		switch {
+		case err == nil:
+			sent = append(sent, t.ID)
+		case errors.Is(err, ErrInvalidTimer):
+			// sending it again does not help
+			relayProducerErrorTypeInc(err)
+			log.WithContext(ctx).Errorf("dropping the timer %s that cannot be relayed, %v", t.ID, err)
+			sent = append(sent, t.ID)
+		default:
 			relayProducerErrorTypeInc(err)
 			log.WithContext(ctx).Errorf("unable to relay messages to the producer, %v", err)
+			failed = append(failed, t.ID)
 		}
 	}
+
+	// the timers that are neither acknowledged nor requeued are reclaimed after the visibility timeout
+	if err = r.outbox.AckOutbox(ctx, sent); err != nil {
+		relayOutboxErrorInc("ack")
+		log.WithContext(ctx).Errorf("unable to acknowledge the relayed timers, %v", err)
+	}
+
+	if err = r.outbox.RequeueOutbox(ctx, failed); err != nil {
+		relayOutboxErrorInc("requeue")
+		log.WithContext(ctx).Errorf("unable to requeue the timers that failed to be relayed, %v", err)
+	}
+}
+
+// reclaim puts the timers whose visibility timeout has passed back to the outbox.
+func (r *Relay) reclaim(ctx context.Context) {
+	reclaimed, err := r.outbox.ReclaimOutbox(ctx, r.batchSize)
+	if err != nil {
+		relayOutboxErrorInc("reclaim")
+		log.WithContext(ctx).Errorf("unable to reclaim the outbox, %v", err)
+		return
+	}
+
+	if reclaimed > 0 {
+		relayReclaimedCount.Add(float64(reclaimed))
+		log.WithContext(ctx).Warnf("reclaimed %d timers whose visibility timeout has passed", reclaimed)
+	}
 }
@@ -32,7 +32,7 @@ func TestNewRelay(t *testing.T) {
 		{
 			name: "valid",
 			args: args{
-				cfg:      &config.Relay{FrequencyMilliSeconds: 500},
+				cfg:      &config.Relay{FrequencyMilliSeconds: 500, VisibilityTimeout: time.Second, LeaseTTL: time.Minute},
 				outbox:   mocks.NewOutbox(ctrl),
 				producer: mocks.NewProducer(ctrl),
 			},
@@ -41,17 +41,35 @@ func TestNewRelay(t *testing.T) {
 		{
 			name: "nil outbox is unaccepted",
 			args: args{
-				cfg:      &config.Relay{FrequencyMilliSeconds: 500},
+				cfg:      &config.Relay{FrequencyMilliSeconds: 500, VisibilityTimeout: time.Second, LeaseTTL: time.Minute},
 				outbox:   nil,
 				producer: mocks.NewProducer(ctrl),
 			},
 			wantErr: assert.Error,
 		},
 		{
-			name: "nil producer is unaccepted",
+			name: "zero visibility timeout is unaccepted",
 			args: args{
 				cfg:      &config.Relay{FrequencyMilliSeconds: 500},
 				outbox:   mocks.NewOutbox(ctrl),
+				producer: mocks.NewProducer(ctrl),
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "lease TTL that is not longer than the frequency is unaccepted",
+			args: args{
+				cfg:      &config.Relay{FrequencyMilliSeconds: 500, VisibilityTimeout: time.Second, LeaseTTL: 500 * time.Millisecond},
+				outbox:   mocks.NewOutbox(ctrl),
+				producer: mocks.NewProducer(ctrl),
+			},
+			wantErr: assert.Error,
+		},
+		{
+			name: "nil producer is unaccepted",
+			args: args{
+				cfg:      &config.Relay{FrequencyMilliSeconds: 500, VisibilityTimeout: time.Second, LeaseTTL: time.Minute},
+				outbox:   mocks.NewOutbox(ctrl),
 				producer: nil,
 			},
 			wantErr: assert.Error,
@@ -73,6 +91,9 @@ func TestRelay_Start(t *testing.T) {
 		cfg := &config.Relay{
 			BatchSize:             1,
 			FrequencyMilliSeconds: 100,
+			VisibilityTimeout:     time.Second,
+			InstanceID:            "relay-1",
+			LeaseTTL:              time.Minute,
 		}
 
 		ctrl := gomock.NewController(t)
@@ -84,7 +105,14 @@ func TestRelay_Start(t *testing.T) {
 			{ID: "3", URL: url.URL{Scheme: "http://", Host: "valid3.url"}, FireAt: now},
 		}
 		outbox := mocks.NewOutbox(ctrl)
-		outbox.EXPECT().DequeueOutbox(gomock.Any(), gomock.Any()).Return(tms, nil).Times(3)
+		outbox.EXPECT().DequeueOutbox(gomock.Any(), int64(1), gomock.Any(), time.Second).Return(tms, nil).Times(3)
+		outbox.EXPECT().MarkRelayed(gomock.Any(), tms).Return(nil).Times(3)
+		outbox.EXPECT().ReclaimOutbox(gomock.Any(), 1).Return(0, nil).Times(3)
+		lease := &timer.Lease{Holder: "relay-1", Token: 1}
+		outbox.EXPECT().AcquireLease(gomock.Any(), "relay-1", time.Minute).Return(lease, nil).Times(3)
+		outbox.EXPECT().ReleaseLease(gomock.Any(), lease).Return(nil)
+		outbox.EXPECT().AckOutbox(gomock.Any(), []string{"1", "2", "3"}).Return(nil).Times(3)
+		outbox.EXPECT().RequeueOutbox(gomock.Any(), nil).Return(nil).Times(3)
 
 		producer := mocks.NewProducer(ctrl)
 		producer.EXPECT().Send(gomock.Any(), gomock.Any()).Return(nil).MinTimes(3 * 3) // producer is called per timer
@@ -94,9 +122,16 @@ func TestRelay_Start(t *testing.T) {
 
 		ctx, cancel := context.WithCancel(context.Background())
 
-		go r.Start(ctx)
+		done := make(chan struct{})
+		go func() {
+			r.Start(ctx)
+			close(done)
+		}()
 		time.Sleep(350 * time.Millisecond)
 		cancel()
+
+		// the lease is released once the relay stops
+		<-done
 	})
 }
 
@@ -114,25 +149,95 @@ func TestRelay_dispatch(t *testing.T) {
 					{ID: "2", URL: url.URL{Scheme: "http://", Host: "valid2.url"}, FireAt: now},
 					{ID: "3", URL: url.URL{Scheme: "http://", Host: "valid3.url"}, FireAt: now},
 				}
-				outbox.EXPECT().DequeueOutbox(gomock.Any(), gomock.Any()).Return(tms, nil)
+				outbox.EXPECT().DequeueOutbox(gomock.Any(), int64(1), gomock.Any(), time.Second).Return(tms, nil)
+				outbox.EXPECT().MarkRelayed(gomock.Any(), tms).Return(nil)
 				producer.EXPECT().Send(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+				outbox.EXPECT().AckOutbox(gomock.Any(), []string{"1", "2", "3"}).Return(nil)
+				outbox.EXPECT().RequeueOutbox(gomock.Any(), nil).Return(nil)
+			},
+		},
+		{
+			name: "timers are relayed even if they cannot be marked",
+			mockFn: func(producer *mocks.Producer, outbox *mocks.Outbox) {
+				tms := []*timer.Timer{
+					{ID: "1", URL: url.URL{Scheme: "http://", Host: "valid1.url"}, FireAt: time.Now()},
+				}
+				outbox.EXPECT().DequeueOutbox(gomock.Any(), int64(1), gomock.Any(), time.Second).Return(tms, nil)
+				outbox.EXPECT().MarkRelayed(gomock.Any(), tms).Return(assert.AnError)
+				producer.EXPECT().Send(gomock.Any(), tms[0]).Return(nil)
+				outbox.EXPECT().AckOutbox(gomock.Any(), []string{"1"}).Return(nil)
+				outbox.EXPECT().RequeueOutbox(gomock.Any(), nil).Return(nil)
 			},
 		},
 		{
 			name: "producer is not called when queue is empty",
 			mockFn: func(producer *mocks.Producer, outbox *mocks.Outbox) {
 				tms := make([]*timer.Timer, 0)
-				outbox.EXPECT().DequeueOutbox(gomock.Any(), gomock.Any()).Return(tms, nil)
+				outbox.EXPECT().DequeueOutbox(gomock.Any(), int64(1), gomock.Any(), time.Second).Return(tms, nil)
 				producer.EXPECT().Send(gomock.Any(), gomock.Any()).Return(nil).Times(0)
 			},
 		},
+		{
+			name: "the relay resigns when its lease is taken over",
+			mockFn: func(producer *mocks.Producer, outbox *mocks.Outbox) {
+				outbox.EXPECT().DequeueOutbox(gomock.Any(), int64(1), gomock.Any(), time.Second).Return(nil, timer.ErrLeaseLost)
+				producer.EXPECT().Send(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
 		{
 			name: "producer is not called when dequeue returns error",
 			mockFn: func(producer *mocks.Producer, outbox *mocks.Outbox) {
-				outbox.EXPECT().DequeueOutbox(gomock.Any(), gomock.Any()).Return(nil, assert.AnError)
+				outbox.EXPECT().DequeueOutbox(gomock.Any(), int64(1), gomock.Any(), time.Second).Return(nil, assert.AnError)
 				producer.EXPECT().Send(gomock.Any(), gomock.Any()).Return(nil).Times(0)
 			},
 		},
+		{
+			name: "timers are relayed along with the error of the dequeue",
+			mockFn: func(producer *mocks.Producer, outbox *mocks.Outbox) {
+				tms := []*timer.Timer{
+					{ID: "1", URL: url.URL{Scheme: "http://", Host: "valid1.url"}, FireAt: time.Now()},
+				}
+				outbox.EXPECT().DequeueOutbox(gomock.Any(), int64(1), gomock.Any(), time.Second).Return(tms, assert.AnError)
+				outbox.EXPECT().MarkRelayed(gomock.Any(), tms).Return(nil)
+				producer.EXPECT().Send(gomock.Any(), tms[0]).Return(nil)
+				outbox.EXPECT().AckOutbox(gomock.Any(), []string{"1"}).Return(nil)
+				outbox.EXPECT().RequeueOutbox(gomock.Any(), nil).Return(nil)
+			},
+		},
+		{
+			name: "failed sends are requeued and invalid timers are dropped",
+			mockFn: func(producer *mocks.Producer, outbox *mocks.Outbox) {
+				now := time.Now()
+				tms := []*timer.Timer{
+					{ID: "1", URL: url.URL{Scheme: "http://", Host: "valid1.url"}, FireAt: now},
+					{ID: "2", URL: url.URL{Scheme: "http://", Host: "valid2.url"}, FireAt: now},
+					{ID: "3", URL: url.URL{Scheme: "http://", Host: "valid3.url"}},
+				}
+				outbox.EXPECT().DequeueOutbox(gomock.Any(), int64(1), gomock.Any(), time.Second).Return(tms, nil)
+				outbox.EXPECT().MarkRelayed(gomock.Any(), tms).Return(nil)
+				producer.EXPECT().Send(gomock.Any(), tms[0]).Return(nil)
+				producer.EXPECT().Send(gomock.Any(), tms[1]).Return(assert.AnError)
+				producer.EXPECT().Send(gomock.Any(), tms[2]).Return(fmt.Errorf("%w: FireAt cannot be zero", ErrInvalidTimer))
+				outbox.EXPECT().AckOutbox(gomock.Any(), []string{"1", "3"}).Return(nil)
+				outbox.EXPECT().RequeueOutbox(gomock.Any(), []string{"2"}).Return(nil)
+			},
+		},
+		{
+			name: "failing to acknowledge or requeue leaves the timers to be reclaimed",
+			mockFn: func(producer *mocks.Producer, outbox *mocks.Outbox) {
+				now := time.Now()
+				tms := []*timer.Timer{
+					{ID: "1", URL: url.URL{Scheme: "http://", Host: "valid1.url"}, FireAt: now},
+					{ID: "2", URL: url.URL{Scheme: "http://", Host: "valid2.url"}, FireAt: now},
+				}
+				outbox.EXPECT().DequeueOutbox(gomock.Any(), int64(1), gomock.Any(), time.Second).Return(tms, nil)
+				outbox.EXPECT().MarkRelayed(gomock.Any(), tms).Return(nil)
+				producer.EXPECT().Send(gomock.Any(), tms[0]).Return(nil)
+				producer.EXPECT().Send(gomock.Any(), tms[1]).Return(assert.AnError)
+				outbox.EXPECT().AckOutbox(gomock.Any(), []string{"1"}).Return(assert.AnError)
+				outbox.EXPECT().RequeueOutbox(gomock.Any(), []string{"2"}).Return(assert.AnError)
+			},
+		},
 		{
 			name: "producer errors are instrumented",
 			mockFn: func(producer *mocks.Producer, outbox *mocks.Outbox) {
@@ -142,8 +247,11 @@ func TestRelay_dispatch(t *testing.T) {
 					{ID: "2", URL: url.URL{Scheme: "http://", Host: "valid2.url"}, FireAt: now},
 					{ID: "3", URL: url.URL{Scheme: "http://", Host: "valid3.url"}, FireAt: now},
 				}
-				outbox.EXPECT().DequeueOutbox(gomock.Any(), gomock.Any()).Return(tms, nil)
+				outbox.EXPECT().DequeueOutbox(gomock.Any(), int64(1), gomock.Any(), time.Second).Return(tms, nil)
+				outbox.EXPECT().MarkRelayed(gomock.Any(), tms).Return(nil)
 				producer.EXPECT().Send(gomock.Any(), gomock.Any()).Return(assert.AnError).Times(3)
+				outbox.EXPECT().AckOutbox(gomock.Any(), nil).Return(nil)
+				outbox.EXPECT().RequeueOutbox(gomock.Any(), []string{"1", "2", "3"}).Return(nil)
 			},
 		},
 	}
@@ -156,14 +264,98 @@ func TestRelay_dispatch(t *testing.T) {
 			cfg := &config.Relay{
 				BatchSize:             1,
 				FrequencyMilliSeconds: 100,
+				VisibilityTimeout:     time.Second,
+				LeaseTTL:              time.Minute,
 			}
 
 			r, err := NewRelay(cfg, outbox, producer)
 			require.NoError(t, err)
 
 			// the expectations are asserted in mockFn
+			outbox.EXPECT().ReclaimOutbox(gomock.Any(), 1).Return(0, nil)
 			tt.mockFn(producer, outbox)
+			r.lease = &timer.Lease{Holder: "relay-1", Token: 1}
 			r.dispatch(context.Background())
 		})
 	}
 }
+
+func TestRelay_lead(t *testing.T) {
+	lease := &timer.Lease{Holder: "relay-1", Token: 1}
+	takenOver := &timer.Lease{Holder: "relay-1", Token: 3}
+
+	tests := []struct {
+		name      string
+		lease     *timer.Lease
+		acquired  *timer.Lease
+		err       error
+		want      bool
+		wantLease *timer.Lease
+	}{
+		{name: "acquires the lease", acquired: lease, want: true, wantLease: lease},
+		{name: "extends the lease", lease: lease, acquired: lease, want: true, wantLease: lease},
+		{name: "acquires the lease again after it expired", lease: lease, acquired: takenOver, want: true, wantLease: takenOver},
+		{name: "another relay leads", lease: lease},
+		{name: "acquiring fails", lease: lease, err: assert.AnError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			outbox := mocks.NewOutbox(ctrl)
+			cfg := &config.Relay{FrequencyMilliSeconds: 100, VisibilityTimeout: time.Second, InstanceID: "relay-1", LeaseTTL: time.Minute}
+			r, err := NewRelay(cfg, outbox, mocks.NewProducer(ctrl))
+			require.NoError(t, err)
+			r.lease = tt.lease
+
+			outbox.EXPECT().AcquireLease(gomock.Any(), "relay-1", time.Minute).Return(tt.acquired, tt.err)
+			assert.Equal(t, tt.want, r.lead(context.Background()))
+			assert.Equal(t, tt.wantLease, r.lease)
+		})
+	}
+}
+
+func TestRelay_resign(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	outbox := mocks.NewOutbox(ctrl)
+	cfg := &config.Relay{FrequencyMilliSeconds: 100, VisibilityTimeout: time.Second, InstanceID: "relay-1", LeaseTTL: time.Minute}
+	r, err := NewRelay(cfg, outbox, mocks.NewProducer(ctrl))
+	require.NoError(t, err)
+
+	// nothing to release
+	r.resign()
+
+	lease := &timer.Lease{Holder: "relay-1", Token: 1}
+	r.lease = lease
+	outbox.EXPECT().ReleaseLease(gomock.Any(), lease).Return(assert.AnError)
+	r.resign()
+	assert.Nil(t, r.lease)
+}
+
+func TestRelay_reclaim(t *testing.T) {
+	tests := []struct {
+		name      string
+		reclaimed int
+		err       error
+	}{
+		{name: "nothing to reclaim"},
+		{name: "timers are reclaimed", reclaimed: 2},
+		{name: "reclaim fails", err: assert.AnError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			outbox := mocks.NewOutbox(ctrl)
+			cfg := &config.Relay{BatchSize: 10, FrequencyMilliSeconds: 100, VisibilityTimeout: time.Second, LeaseTTL: time.Minute}
+			r, err := NewRelay(cfg, outbox, mocks.NewProducer(ctrl))
+			require.NoError(t, err)
+
+			outbox.EXPECT().ReclaimOutbox(gomock.Any(), 10).Return(tt.reclaimed, tt.err)
+			r.reclaim(context.Background())
+		})
+	}
+}
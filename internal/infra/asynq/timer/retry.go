@@ -0,0 +1,65 @@
+package timer
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	internalHttpClient "github.com/cubny/httpqueue/internal/infra/http/client/timer"
+)
+
+// NewRetryDelayFunc returns the delay before retrying a failed task. when the webhook receiver asked for a delay
+// using Retry-After, the delay is respected up to maxRetryAfter, otherwise the backoff of the retry policy of the
+// task is used. the tasks without a retry policy fall back to asynq's default exponential backoff. the tasks that
+// are failed fast by an open circuit breaker wait for the breaker as well, so that they do not run out of retries
+// sooner than they would by calling the host. the deferred tasks are delayed as long as their limits ask, plus up to
+// as much again so that they do not come back all at once.
+func NewRetryDelayFunc(maxRetryAfter time.Duration) asynq.RetryDelayFunc {
+	return func(n int, err error, task *asynq.Task) time.Duration {
+		var throttledErr *ThrottledError
+		if errors.As(err, &throttledErr) {
+			return throttledErr.Delay + time.Duration(float64(throttledErr.Delay)*jitterRandom(task, n))
+		}
+
+		var retryAfterErr *internalHttpClient.RetryAfterError
+		if errors.As(err, &retryAfterErr) {
+			if retryAfterErr.Delay > maxRetryAfter {
+				return maxRetryAfter
+			}
+			return retryAfterErr.Delay
+		}
+
+		delay := backoff(n, err, task)
+
+		var circuitOpenErr *internalHttpClient.CircuitOpenError
+		if errors.As(err, &circuitOpenErr) && circuitOpenErr.Delay > delay {
+			return circuitOpenErr.Delay
+		}
+
+		return delay
+	}
+}
+
+// backoff returns the delay of the retry policy of the task, or asynq's default when the task has no policy.
+func backoff(n int, err error, task *asynq.Task) time.Duration {
+	var payload Payload
+	if json.Unmarshal(task.Payload(), &payload) != nil || payload.Retry == nil || payload.Retry.InitialBackoff == 0 {
+		return asynq.DefaultRetryDelayFunc(n, err, task)
+	}
+
+	return payload.Retry.policy().Backoff(n, jitterRandom(task, n))
+}
+
+// jitterRandom returns a number in [0, 1) that decides the jitter of the delay before the retry n of the task. it is
+// derived from the task, rather than being random, so that the delay that is recorded along with a failed attempt is
+// the delay that the workers apply.
+func jitterRandom(task *asynq.Task, n int) float64 {
+	h := fnv.New64a()
+	_, _ = h.Write(task.Payload())
+	_, _ = h.Write([]byte(strconv.Itoa(n)))
+	return float64(h.Sum64()>>11) / (1 << 53)
+}
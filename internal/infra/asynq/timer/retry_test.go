@@ -0,0 +1,84 @@
+package timer
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	internalHttpClient "github.com/cubny/httpqueue/internal/infra/http/client/timer"
+)
+
+func TestNewRetryDelayFunc(t *testing.T) {
+	retryDelay := NewRetryDelayFunc(time.Hour)
+	task := asynq.NewTask(TypeName, nil)
+
+	t.Run("respects Retry-After", func(t *testing.T) {
+		err := fmt.Errorf("temporarily failed: %w", &internalHttpClient.RetryAfterError{Delay: time.Minute})
+		assert.Equal(t, time.Minute, retryDelay(1, err, task))
+	})
+
+	t.Run("caps Retry-After", func(t *testing.T) {
+		err := fmt.Errorf("temporarily failed: %w", &internalHttpClient.RetryAfterError{Delay: 2 * time.Hour})
+		assert.Equal(t, time.Hour, retryDelay(1, err, task))
+	})
+
+	t.Run("caps the longest Retry-After", func(t *testing.T) {
+		err := fmt.Errorf("temporarily failed: %w", &internalHttpClient.RetryAfterError{Delay: time.Duration(math.MaxInt64)})
+		assert.Equal(t, time.Hour, retryDelay(1, err, task))
+	})
+
+	t.Run("falls back to the default backoff", func(t *testing.T) {
+		err := fmt.Errorf("temporarily failed: %w", internalHttpClient.ErrRetryableRequestFailure)
+		got := retryDelay(1, err, task)
+		assert.Greater(t, got, time.Duration(0))
+		assert.LessOrEqual(t, got, 2*time.Minute)
+	})
+
+	t.Run("uses the backoff of the retry policy", func(t *testing.T) {
+		task, err := NewTask(Payload{TimerID: "1", Retry: &RetryPayload{
+			InitialBackoff: time.Second,
+			MaxBackoff:     10 * time.Second,
+			Multiplier:     2,
+		}})
+		require.NoError(t, err)
+
+		err = fmt.Errorf("temporarily failed: %w", internalHttpClient.ErrRetryableRequestFailure)
+		assert.Equal(t, time.Second, retryDelay(0, err, task))
+		assert.Equal(t, 4*time.Second, retryDelay(2, err, task))
+		assert.Equal(t, 10*time.Second, retryDelay(5, err, task))
+	})
+	t.Run("waits for the open circuit breaker", func(t *testing.T) {
+		task, err := NewTask(Payload{TimerID: "1", Retry: &RetryPayload{InitialBackoff: time.Second, Multiplier: 2}})
+		require.NoError(t, err)
+
+		err = fmt.Errorf("temporarily failed: %w", &internalHttpClient.CircuitOpenError{Host: "example.com", Delay: 30 * time.Second})
+		assert.Equal(t, 30*time.Second, retryDelay(0, err, task))
+		assert.Equal(t, 64*time.Second, retryDelay(6, err, task))
+	})
+	t.Run("defers the throttled task by up to twice its delay", func(t *testing.T) {
+		err := fmt.Errorf("webhook is deferred: %w", &ThrottledError{Delay: time.Second})
+		got := retryDelay(3, err, task)
+		assert.Equal(t, got, retryDelay(3, err, task))
+		assert.GreaterOrEqual(t, got, time.Second)
+		assert.Less(t, got, 2*time.Second)
+	})
+	t.Run("jitter is the same for the same retry of the task", func(t *testing.T) {
+		task, err := NewTask(Payload{TimerID: "1", Retry: &RetryPayload{
+			InitialBackoff: 10 * time.Second,
+			Multiplier:     2,
+			Jitter:         0.5,
+		}})
+		require.NoError(t, err)
+
+		err = fmt.Errorf("temporarily failed: %w", internalHttpClient.ErrRetryableRequestFailure)
+		got := retryDelay(1, err, task)
+		assert.Equal(t, got, retryDelay(1, err, task))
+		assert.GreaterOrEqual(t, got, 10*time.Second)
+		assert.LessOrEqual(t, got, 30*time.Second)
+	})
+}
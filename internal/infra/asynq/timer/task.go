@@ -2,20 +2,84 @@ package timer
 
 import (
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/hibiken/asynq"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
 )
 
 const TypeName = "timer:webhook"
 
 type Payload struct {
 	TimerID string
+	// Revision of the timer at the time of scheduling. a task whose revision differs from the stored timer is stale.
+	Revision int `json:",omitempty"`
+	// Generation of the timer. a task whose generation differs from the stored timer is of a timer that was created
+	// with the same ID before, and is stale.
+	Generation int64 `json:",omitempty"`
+	// Retry is nil for the tasks that are scheduled before the retry policies were introduced.
+	Retry *RetryPayload `json:",omitempty"`
+}
+
+// RetryPayload is the retry policy of the timer resolved with the defaults at the time of scheduling, so that the
+// retries are scheduled without looking the timer up.
+type RetryPayload struct {
+	InitialBackoff time.Duration `json:",omitempty"`
+	MaxBackoff     time.Duration `json:",omitempty"`
+	Multiplier     float64       `json:",omitempty"`
+	Jitter         float64       `json:",omitempty"`
+	// Deadline is the time after which the task is not retried anymore. it is nil when there is no deadline.
+	Deadline *time.Time `json:",omitempty"`
+}
+
+func newRetryPayload(policy timer.RetryPolicy, fireAt time.Time) *RetryPayload {
+	r := &RetryPayload{
+		InitialBackoff: policy.InitialBackoff,
+		MaxBackoff:     policy.MaxBackoff,
+		Multiplier:     policy.Multiplier,
+		Jitter:         policy.Jitter,
+	}
+
+	if deadline, ok := policy.RetryDeadline(fireAt); ok {
+		r.Deadline = &deadline
+	}
+
+	return r
+}
+
+func (r *RetryPayload) policy() timer.RetryPolicy {
+	return timer.RetryPolicy{
+		InitialBackoff: r.InitialBackoff,
+		MaxBackoff:     r.MaxBackoff,
+		Multiplier:     r.Multiplier,
+		Jitter:         r.Jitter,
+	}
+}
+
+// deadlineExceeded tells whether the task must not be retried anymore.
+func (p Payload) deadlineExceeded(now time.Time) bool {
+	return p.Retry != nil && p.Retry.Deadline != nil && now.After(*p.Retry.Deadline)
+}
+
+// TaskID identifies the task of a revision of a generation of the timer, so that the broker deduplicates the tasks.
+// the first revision of the generation zero is identified by the timer ID as is. the generation is separated by @,
+// which the timer IDs do not contain.
+func TaskID(timerID string, generation int64, revision int) string {
+	if generation != 0 {
+		timerID = fmt.Sprintf("%s@%d", timerID, generation)
+	}
+	if revision == 0 {
+		return timerID
+	}
+	return fmt.Sprintf("%s:%d", timerID, revision)
 }
 
-func NewTask(timerID string) (*asynq.Task, error) {
-	payload, err := json.Marshal(Payload{TimerID: timerID})
+func NewTask(payload Payload) (*asynq.Task, error) {
+	bytes, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
-	return asynq.NewTask(TypeName, payload), nil
+	return asynq.NewTask(TypeName, bytes), nil
 }
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+)
+
+// maxDeadLettersRequestBytes is the maximum size of the replay and purge request payloads.
+const maxDeadLettersRequestBytes = 256 << 10
+
+// listDeadLetters is the handler for
+// swagger:route GET /dead-letters listDeadLettersRequest
+//
+// Lists the permanently failed timers ordered by the time they failed.
+//
+// Responses:
+//
+//	200: listDeadLetters
+//	422: invalidParams
+//	500: serverError
+func (h *Router) listDeadLetters(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query, err := toListDeadLettersQuery(r)
+	if err != nil {
+		_ = InvalidParams(w, fmt.Sprintf("invalid param: %v", err))
+		return
+	}
+
+	page, err := h.service.ListDeadLetters(r.Context(), query)
+	switch {
+	case err == timer.ErrInvalidCursor:
+		_ = InvalidParams(w, "invalid param: cursor is invalid")
+		return
+	case err != nil:
+		log.WithError(err).Errorf("listDeadLetters: service %s", err)
+		api500Count.With(prometheus.Labels{"method": "listDeadLetters", "reason": "service"}).Inc()
+		_ = InternalError(w, "failed to list dead letters due to server internal error")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(toListDeadLettersResponse(page)); err != nil {
+		log.WithError(err).Errorf("listDeadLetters: encoder %s", err)
+		api500Count.With(prometheus.Labels{"method": "listDeadLetters", "reason": "encoder"}).Inc()
+		_ = InternalError(w, "cannot encode response")
+		return
+	}
+}
+
+// getDeadLetter is the handler for
+// swagger:route GET /dead-letters/{dead_letter_id} getDeadLetterRequest
+//
+// Responds the permanently failed run of a timer along with its last error and response.
+//
+// Responses:
+//
+//	200: getDeadLetter
+//	404: notFoundError
+//	500: serverError
+func (h *Router) getDeadLetter(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	deadLetter, err := h.service.GetDeadLetter(r.Context(), p.ByName("id"))
+	switch {
+	case err == timer.ErrDeadLetterNotFound:
+		_ = NotFound(w, "dead letter does not exist")
+		return
+	case err != nil:
+		log.WithError(err).Errorf("getDeadLetter: service %s", err)
+		api500Count.With(prometheus.Labels{"method": "getDeadLetter", "reason": "service"}).Inc()
+		_ = InternalError(w, "failed to get dead letter due to server internal error")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(toDeadLetterResponse(deadLetter)); err != nil {
+		log.WithError(err).Errorf("getDeadLetter: encoder %s", err)
+		api500Count.With(prometheus.Labels{"method": "getDeadLetter", "reason": "encoder"}).Inc()
+		_ = InternalError(w, "cannot encode response")
+		return
+	}
+}
+
+// deleteDeadLetter is the handler for
+// swagger:route DELETE /dead-letters/{dead_letter_id} deleteDeadLetterRequest
+//
+// Purges a dead letter.
+//
+// Responses:
+//
+//	204: deleteDeadLetter
+//	404: notFoundError
+//	500: serverError
+func (h *Router) deleteDeadLetter(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	purged, err := h.service.PurgeDeadLetters(r.Context(), []string{p.ByName("id")})
+	switch {
+	case err != nil:
+		log.WithError(err).Errorf("deleteDeadLetter: service %s", err)
+		api500Count.With(prometheus.Labels{"method": "deleteDeadLetter", "reason": "service"}).Inc()
+		_ = InternalError(w, "failed to delete dead letter due to server internal error")
+		return
+	case purged == 0:
+		_ = NotFound(w, "dead letter does not exist")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deadLettersAction routes the custom methods of /dead-letters, i.e. /dead-letters:replay and /dead-letters:purge.
+func (h *Router) deadLettersAction(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	switch p.ByName("action") {
+	case ":replay":
+		h.replayDeadLetters(w, r)
+	case ":purge":
+		h.purgeDeadLetters(w, r)
+	default:
+		_ = NotFound(w, "")
+	}
+}
+
+// replayDeadLetters is the handler for
+// swagger:route POST /dead-letters:replay replayDeadLettersRequest
+//
+// Shoots the webhooks of the dead letters again right away, optionally to another URL. every dead letter is
+// replayed on its own, and a replayed dead letter is removed.
+//
+// Responses:
+//
+//	200: replayDeadLetters
+//	400: invalidRequestBody
+//	422: invalidParams
+//	500: serverError
+func (h *Router) replayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	request := &ReplayDeadLettersRequest{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDeadLettersRequestBytes)).Decode(request); err != nil {
+		_ = BadRequest(w, "cannot replay dead letters, bad request payload")
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		_ = InvalidParams(w, fmt.Sprintf("invalid param: %v", err))
+		return
+	}
+
+	results := h.service.ReplayDeadLetters(r.Context(), request.toReplayDeadLettersCommand())
+	resp := ReplayDeadLettersResponse{Results: make([]ReplayDeadLetterResult, 0, len(results))}
+
+	var serviceErr error
+	for _, result := range results {
+		item := ReplayDeadLetterResult{ID: result.ID}
+		switch {
+		case result.Err == timer.ErrDeadLetterNotFound:
+			item.Error = batchItemError(errNotFound, "dead letter does not exist")
+		case result.Err == timer.ErrTimerExists:
+			item.Error = batchItemError(errConflict, "the timer of the dead letter is scheduled again already")
+		case errors.Is(result.Err, timer.ErrDestinationNotAllowed):
+			item.Error = batchItemError(errInvalidParams, fmt.Sprintf("invalid param: %v", result.Err))
+		case result.Err != nil:
+			serviceErr = result.Err
+			item.Error = batchItemError(errInternalError, "failed to replay dead letter due to server internal error")
+		default:
+			item.TimerID = result.Timer.ID
+		}
+
+		if item.Error != nil {
+			resp.Failed++
+		} else {
+			resp.Replayed++
+		}
+		resp.Results = append(resp.Results, item)
+	}
+
+	if serviceErr != nil {
+		log.WithError(serviceErr).Errorf("replayDeadLetters: service %s", serviceErr)
+		api500Count.With(prometheus.Labels{"method": "replayDeadLetters", "reason": "service"}).Inc()
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.WithError(err).Errorf("replayDeadLetters: encoder %s", err)
+		api500Count.With(prometheus.Labels{"method": "replayDeadLetters", "reason": "encoder"}).Inc()
+		_ = InternalError(w, "cannot encode response")
+		return
+	}
+}
+
+// purgeDeadLetters is the handler for
+// swagger:route POST /dead-letters:purge purgeDeadLettersRequest
+//
+// Purges the dead letters of the given IDs, or all of them.
+//
+// Responses:
+//
+//	200: purgeDeadLetters
+//	400: invalidRequestBody
+//	422: invalidParams
+//	500: serverError
+func (h *Router) purgeDeadLetters(w http.ResponseWriter, r *http.Request) {
+	request := &PurgeDeadLettersRequest{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxDeadLettersRequestBytes)).Decode(request); err != nil {
+		_ = BadRequest(w, "cannot purge dead letters, bad request payload")
+		return
+	}
+
+	if err := request.Validate(); err != nil {
+		_ = InvalidParams(w, fmt.Sprintf("invalid param: %v", err))
+		return
+	}
+
+	purged, err := h.service.PurgeDeadLetters(r.Context(), request.IDs)
+	if err != nil {
+		log.WithError(err).Errorf("purgeDeadLetters: service %s", err)
+		api500Count.With(prometheus.Labels{"method": "purgeDeadLetters", "reason": "service"}).Inc()
+		_ = InternalError(w, "failed to purge dead letters due to server internal error")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(PurgeDeadLettersResponse{Purged: purged}); err != nil {
+		log.WithError(err).Errorf("purgeDeadLetters: encoder %s", err)
+		api500Count.With(prometheus.Labels{"method": "purgeDeadLetters", "reason": "encoder"}).Inc()
+		_ = InternalError(w, "cannot encode response")
+		return
+	}
+}
@@ -0,0 +1,256 @@
+package api_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+	mocks "github.com/cubny/httpqueue/internal/mocks/app/timer"
+)
+
+func TestRouter_listDeadLetters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := mocks.NewService(ctrl)
+	fireAt := time.Now().Add(-time.Hour)
+	deadAt := time.Now()
+
+	specs := []spec{
+		{
+			Name:   "ok",
+			Method: http.MethodGet,
+			Target: "/dead-letters?limit=1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().ListDeadLetters(gomock.Any(), timer.ListDeadLettersQuery{Limit: 1}).Return(&timer.DeadLettersPage{
+					DeadLetters: []*timer.DeadLetter{{
+						ID: "1",
+						Timer: &timer.Timer{
+							ID:      "1",
+							URL:     url.URL{Scheme: "http", Host: "valid.url", Path: "/1"},
+							FireAt:  fireAt,
+							Webhook: timer.Webhook{Method: http.MethodPost},
+						},
+						Error:        "http request failed: 404 Not Found, unexpected HTTP status 404",
+						StatusCode:   http.StatusNotFound,
+						ResponseBody: "no such hook",
+						Attempts:     1,
+						DeadAt:       deadAt,
+					}},
+					NextCursor: "next",
+				}, nil)
+			},
+			ExpectedBody: fmt.Sprintf(`{"dead_letters":[{"id":"1", "timer_id":"1", "url":"http://valid.url/1", "method":"POST", "fire_at":"%s",
+				"error":"http request failed: 404 Not Found, unexpected HTTP status 404", "status_code":404, "response_body":"no such hook",
+				"attempts":1, "dead_at":"%s"}], "next_cursor":"next"}`, fireAt.Format(time.RFC3339), deadAt.Format(time.RFC3339)),
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:   "empty page",
+			Method: http.MethodGet,
+			Target: "/dead-letters",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().ListDeadLetters(gomock.Any(), timer.ListDeadLettersQuery{}).Return(&timer.DeadLettersPage{}, nil)
+			},
+			ExpectedBody:   `{"dead_letters":[]}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:           "invalid limit",
+			Method:         http.MethodGet,
+			Target:         "/dead-letters?limit=0",
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "invalid cursor",
+			Method: http.MethodGet,
+			Target: "/dead-letters?cursor=x",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().ListDeadLetters(gomock.Any(), timer.ListDeadLettersQuery{Cursor: "x"}).Return(nil, timer.ErrInvalidCursor)
+			},
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "service unknown error",
+			Method: http.MethodGet,
+			Target: "/dead-letters",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().ListDeadLetters(gomock.Any(), gomock.Any()).Return(nil, assert.AnError)
+			},
+			ExpectedBody:   `{"error":{"code":500, "details":"Internal error - failed to list dead letters due to server internal error"}}`,
+			ExpectedStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, s := range specs {
+		t.Run(s.Name, s.execHTTPTestCases(service))
+	}
+}
+
+func TestRouter_getDeadLetter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := mocks.NewService(ctrl)
+
+	specs := []spec{
+		{
+			Name:   "not found",
+			Method: http.MethodGet,
+			Target: "/dead-letters/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().GetDeadLetter(gomock.Any(), "1").Return(nil, timer.ErrDeadLetterNotFound)
+			},
+			ExpectedBody:   `{"error":{"code":404, "details":"Not found - dead letter does not exist"}}`,
+			ExpectedStatus: http.StatusNotFound,
+		},
+		{
+			Name:   "service unknown error",
+			Method: http.MethodGet,
+			Target: "/dead-letters/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().GetDeadLetter(gomock.Any(), "1").Return(nil, assert.AnError)
+			},
+			ExpectedStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, s := range specs {
+		t.Run(s.Name, s.execHTTPTestCases(service))
+	}
+}
+
+func TestRouter_replayDeadLetters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := mocks.NewService(ctrl)
+
+	specs := []spec{
+		{
+			Name:    "replays every dead letter on its own",
+			Method:  http.MethodPost,
+			Target:  "/dead-letters:replay",
+			ReqBody: `{"ids":["1","2:3","3"],"url":"http://other.url/hook"}`,
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().ReplayDeadLetters(gomock.Any(), timer.ReplayDeadLettersCommand{
+					IDs:    []string{"1", "2:3", "3"},
+					URLRaw: "http://other.url/hook",
+				}).Return([]timer.ReplayDeadLetterResult{
+					{ID: "1", Timer: &timer.Timer{ID: "1"}},
+					{ID: "2:3", Err: timer.ErrTimerExists},
+					{ID: "3", Err: timer.ErrDeadLetterNotFound},
+				})
+			},
+			ExpectedBody: `{"replayed":1, "failed":2, "results":[
+				{"id":"1", "timer_id":"1"},
+				{"id":"2:3", "error":{"code":409, "details":"Conflict - the timer of the dead letter is scheduled again already"}},
+				{"id":"3", "error":{"code":404, "details":"Not found - dead letter does not exist"}}]}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:           "no ids",
+			Method:         http.MethodPost,
+			Target:         "/dead-letters:replay",
+			ReqBody:        `{"ids":[]}`,
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedBody:   `{"error":{"code":422, "details":"Invalid params - invalid param: 'ids' cannot be empty"}}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:           "invalid url",
+			Method:         http.MethodPost,
+			Target:         "/dead-letters:replay",
+			ReqBody:        `{"ids":["1"],"url":"invalid.url"}`,
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:           "bad payload",
+			Method:         http.MethodPost,
+			Target:         "/dead-letters:replay",
+			ReqBody:        `{"ids":`,
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			Name:           "unknown action",
+			Method:         http.MethodPost,
+			Target:         "/dead-letters:retry",
+			ReqBody:        `{"ids":["1"]}`,
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedStatus: http.StatusNotFound,
+		},
+	}
+	for _, s := range specs {
+		t.Run(s.Name, s.execHTTPTestCases(service))
+	}
+}
+
+func TestRouter_purgeDeadLetters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := mocks.NewService(ctrl)
+
+	specs := []spec{
+		{
+			Name:    "purges by ids",
+			Method:  http.MethodPost,
+			Target:  "/dead-letters:purge",
+			ReqBody: `{"ids":["1","2"]}`,
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().PurgeDeadLetters(gomock.Any(), []string{"1", "2"}).Return(2, nil)
+			},
+			ExpectedBody:   `{"purged":2}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:    "purges all",
+			Method:  http.MethodPost,
+			Target:  "/dead-letters:purge",
+			ReqBody: `{"all":true}`,
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().PurgeDeadLetters(gomock.Any(), nil).Return(10, nil)
+			},
+			ExpectedBody:   `{"purged":10}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:           "neither ids nor all",
+			Method:         http.MethodPost,
+			Target:         "/dead-letters:purge",
+			ReqBody:        `{}`,
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "deletes one",
+			Method: http.MethodDelete,
+			Target: "/dead-letters/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().PurgeDeadLetters(gomock.Any(), []string{"1"}).Return(1, nil)
+			},
+			ExpectedStatus: http.StatusNoContent,
+		},
+		{
+			Name:   "deletes a missing one",
+			Method: http.MethodDelete,
+			Target: "/dead-letters/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().PurgeDeadLetters(gomock.Any(), []string{"1"}).Return(0, nil)
+			},
+			ExpectedStatus: http.StatusNotFound,
+		},
+		{
+			Name:    "service unknown error",
+			Method:  http.MethodPost,
+			Target:  "/dead-letters:purge",
+			ReqBody: `{"all":true}`,
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().PurgeDeadLetters(gomock.Any(), nil).Return(0, assert.AnError)
+			},
+			ExpectedStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, s := range specs {
+		t.Run(s.Name, s.execHTTPTestCases(service))
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errInvalidDuration = errors.New("invalid duration")
+
+	// iso8601DurationRe matches the ISO-8601 durations with fixed length designators, i.e. weeks, days, hours, minutes
+	// and seconds. Years and months are not supported because their length depends on the calendar.
+	iso8601DurationRe = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$`)
+)
+
+// parseDuration parses either an ISO-8601 duration such as PT1H30M or a Go duration such as 1h30m.
+func parseDuration(s string) (time.Duration, error) {
+	if !strings.HasPrefix(s, "P") {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, errInvalidDuration
+		}
+		return d, nil
+	}
+
+	return parseISO8601Duration(s)
+}
+
+func parseISO8601Duration(s string) (time.Duration, error) {
+	matches := iso8601DurationRe.FindStringSubmatch(s)
+	if matches == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, errInvalidDuration
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	var d time.Duration
+	for i, unit := range units {
+		match := matches[i+1]
+		if match == "" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(strings.Replace(match, ",", ".", 1), 64)
+		if err != nil {
+			return 0, errInvalidDuration
+		}
+
+		d += time.Duration(v * float64(unit))
+	}
+
+	return d, nil
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    time.Duration
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "go duration",
+			input:   "1h30m",
+			want:    90 * time.Minute,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "iso-8601 time part",
+			input:   "PT1H30M10S",
+			want:    time.Hour + 30*time.Minute + 10*time.Second,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "iso-8601 weeks and days",
+			input:   "P1W2D",
+			want:    9 * 24 * time.Hour,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "iso-8601 days and time",
+			input:   "P1DT2H",
+			want:    26 * time.Hour,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "iso-8601 fractional seconds",
+			input:   "PT0,5S",
+			want:    500 * time.Millisecond,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "iso-8601 months are not supported",
+			input:   "P1M",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "empty iso-8601 duration",
+			input:   "P",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "empty iso-8601 time part",
+			input:   "P1DT",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "garbage",
+			input:   "soon",
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDuration(tt.input)
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -15,6 +15,7 @@ var (
 	errBadRequest    errorType = 400
 	errInvalidParams errorType = 422
 	errNotFound      errorType = 404
+	errConflict      errorType = 409
 )
 
 // JsonError is used to return http errors encoded in json
@@ -38,6 +39,8 @@ func newJsonError(errorType errorType, details string) JsonError {
 		e.Details = "Invalid params"
 	case errNotFound:
 		e.Details = "Not found"
+	case errConflict:
+		e.Details = "Conflict"
 	default:
 		e.Code = 100999
 		e.Details = "Unknown error"
@@ -82,3 +85,8 @@ func InvalidParams(w http.ResponseWriter, details string) error {
 func NotFound(w http.ResponseWriter, details string) error {
 	return newJsonError(errNotFound, details).write(w, http.StatusNotFound)
 }
+
+// Conflict writes the Conflict error details in json with the provided details
+func Conflict(w http.ResponseWriter, details string) error {
+	return newJsonError(errConflict, details).write(w, http.StatusConflict)
+}
@@ -61,6 +61,18 @@ func TestNotFound(t *testing.T) {
 	assertBody(t, expectedBody, w.Body)
 }
 
+func TestConflict(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	err := api.Conflict(w, "test")
+	require.NoError(t, err)
+
+	assert.Equal(t, w.Code, http.StatusConflict)
+
+	expectedBody := `{"error":{"code":409, "details":"Conflict - test"}}`
+	assertBody(t, expectedBody, w.Body)
+}
+
 func assertBody(t *testing.T, expectedBody string, actualBody *bytes.Buffer) {
 	t.Helper()
 
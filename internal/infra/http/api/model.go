@@ -1,25 +1,163 @@
 package api
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cubny/httpqueue/internal/app/timer"
+	"github.com/cubny/httpqueue/pkg/webhooks"
 )
 
 // SetTimersRequest is the request model to set a new timer
 //
 // swagger:model setTimersRequest
 type SetTimersRequest struct {
+	// ID is optional, e.g. a natural key like an order ID. it must be unique, start with a letter or a digit and
+	// consist of at most 128 letters, digits, '_', '.' and '-'. a random ID is generated when it is empty.
+	ID      string `json:"id,omitempty"`
 	Hours   int    `json:"hours"`
 	Minutes int    `json:"minutes"`
 	Seconds int    `json:"seconds"`
 	URL     string `json:"url"`
+	// URLTemplate is an alternative to URL, e.g. https://x/hooks?timer={{.ID}}&run={{.Attempt}}
+	// the available fields are ID, Attempt and Run. the timer ID is not appended to the rendered URL.
+	URLTemplate string `json:"url_template,omitempty"`
+	// AppendID controls whether the timer ID is appended to the path of URL. defaults to true.
+	AppendID *bool `json:"append_id,omitempty"`
+	// FireAt is the absolute time of firing in RFC3339 format, e.g. 2023-01-02T15:04:05+01:00
+	FireAt string `json:"fire_at,omitempty"`
+	// Delay is either an ISO-8601 duration (e.g. PT1H30M) or a Go duration (e.g. 1h30m)
+	Delay string `json:"delay,omitempty"`
+	// Cron makes the timer recurring, e.g. "*/15 * * * *", "0 9 * * 1-5" or "@every 15m"
+	Cron string `json:"cron,omitempty"`
+	// Timezone is the IANA timezone of the cron expression, e.g. Europe/Berlin. defaults to UTC.
+	Timezone string `json:"timezone,omitempty"`
+	// EndAt ends the recurrence at the given RFC3339 timestamp
+	EndAt string `json:"end_at,omitempty"`
+	// MaxRuns ends the recurrence after the given number of runs
+	MaxRuns int `json:"max_runs,omitempty"`
+	// Method is the HTTP method of the webhook. defaults to POST.
+	Method string `json:"method,omitempty"`
+	// Headers are sent verbatim with the webhook.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is a JSON value that is sent verbatim with the webhook. the content type defaults to application/json.
+	Body json.RawMessage `json:"body,omitempty"`
+	// BodyBase64 is the base64 encoded raw body of the webhook. it is an alternative to Body.
+	BodyBase64 string `json:"body_base64,omitempty"`
+	// ContentType of the webhook body.
+	ContentType string `json:"content_type,omitempty"`
+	// SigningSecrets sign the webhook instead of the default secrets of the service, in the whsec_<base64> format.
+	// the webhook is signed with all of them, so that a secret can be rotated.
+	SigningSecrets []string `json:"signing_secrets,omitempty"`
+	// Timeout overrides the default timeout of the webhook request, either an ISO-8601 or a Go duration of at most
+	// 5 minutes, e.g. 10s
+	Timeout string `json:"timeout,omitempty"`
+	// Labels are arbitrary key/values to search the timers by, e.g. {"team": "payments"}
+	Labels map[string]string `json:"labels,omitempty"`
+	// RetryPolicy overrides the default retry policy of the failed webhooks. the fields that are not set fall back
+	// to the defaults.
+	RetryPolicy *RetryPolicyRequest `json:"retry_policy,omitempty"`
+	// SuccessCriteria override the default criteria of the successful responses of the webhook. the fields that are
+	// not set fall back to the defaults.
+	SuccessCriteria *SuccessCriteriaRequest `json:"success_criteria,omitempty"`
 }
 
+// RetryPolicyRequest is the request model of the retry policy of a timer
+//
+// swagger:model retryPolicyRequest
+type RetryPolicyRequest struct {
+	// MaxAttempts is the maximum number of the delivery attempts, including the first one, e.g. 5
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// InitialBackoff is the delay before the first retry, either an ISO-8601 or a Go duration, e.g. 10s
+	InitialBackoff string `json:"initial_backoff,omitempty"`
+	// MaxBackoff caps the delay between two attempts, e.g. 1h
+	MaxBackoff string `json:"max_backoff,omitempty"`
+	// Multiplier grows the delay after every retry, e.g. 2
+	Multiplier float64 `json:"multiplier,omitempty"`
+	// Jitter randomises the delay by the given fraction between 0 and 1, e.g. 0.2
+	Jitter float64 `json:"jitter,omitempty"`
+	// Deadline stops retrying when the given duration has passed since the scheduled time, e.g. PT6H
+	Deadline string `json:"deadline,omitempty"`
+}
+
+// SuccessCriteriaRequest is the request model of the success criteria of a timer. a response is successful when its
+// status code is accepted and its body matches, otherwise it is retried when its status code is retryable
+//
+// swagger:model successCriteriaRequest
+type SuccessCriteriaRequest struct {
+	// AcceptedStatusCodes are status codes, classes or ranges of them, e.g. ["200", "2xx", "200-204"]. defaults to 2xx
+	AcceptedStatusCodes []string `json:"accepted_status_codes,omitempty"`
+	// RetryableStatusCodes are in the format of AcceptedStatusCodes, e.g. ["409", "5xx"]. defaults to 429 and 5xx
+	// except 501
+	RetryableStatusCodes []string `json:"retryable_status_codes,omitempty"`
+	// BodyPattern is a regular expression that the body of a successful response matches, e.g. ^OK$
+	BodyPattern string `json:"body_pattern,omitempty"`
+	// JSONPath is a dot separated path into the JSON body of a successful response, e.g. data.status
+	JSONPath string `json:"json_path,omitempty"`
+	// JSONValue is the value at JSONPath, e.g. "done" or "true". the path only has to exist when it is not set.
+	JSONValue string `json:"json_value,omitempty"`
+}
+
+const (
+	// maxWebhookBodyBytes is the maximum size of the webhook body.
+	maxWebhookBodyBytes = 64 << 10
+	// maxWebhookHeaders is the maximum number of the webhook headers.
+	maxWebhookHeaders = 32
+	// maxWebhookHeadersBytes is the maximum total size of the webhook header names and values.
+	maxWebhookHeadersBytes = 8 << 10
+	// maxSetTimerRequestBytes is the maximum size of the set timer request payload. it leaves room for a base64
+	// encoded webhook body.
+	maxSetTimerRequestBytes = 256 << 10
+	// maxRescheduleTimerRequestBytes is the maximum size of the reschedule timer request payload.
+	maxRescheduleTimerRequestBytes = 4 << 10
+	// maxLabels is the maximum number of the labels of a timer.
+	maxLabels = 16
+	// maxLabelValueLength is the maximum length of a label value.
+	maxLabelValueLength = 255
+	// maxSigningSecrets is the maximum number of the signing secrets of a webhook.
+	maxSigningSecrets = 5
+	// maxSuccessStatusCodes is the maximum number of the accepted or retryable status codes of the success criteria.
+	maxSuccessStatusCodes = 16
+	// maxSuccessMatchLength is the maximum length of the body pattern, JSON path and JSON value of the success criteria.
+	maxSuccessMatchLength = 1024
+	// maxIdempotencyKeyLength is the maximum length of the idempotency key.
+	maxIdempotencyKeyLength = 255
+)
+
+const (
+	// idempotencyKeyHeader makes retrying the timer creation safe.
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader is set on the response when the timer is already created with the idempotency key.
+	idempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// forbiddenWebhookHeaders are managed by the HTTP client and cannot be set by the callers.
+var forbiddenWebhookHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+var (
+	headerNameRe = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+	// headerValueRe matches the valid header values, i.e. the ones without control characters other than tab, as
+	// httpguts.ValidHeaderFieldValue does.
+	headerValueRe = regexp.MustCompile(`^[^\x00-\x08\x0a-\x1f\x7f]*$`)
+	labelKeyRe    = regexp.MustCompile("^[A-Za-z0-9_.-]{1,63}$")
+	// idempotencyKeyRe matches the visible ASCII characters, or an empty key.
+	idempotencyKeyRe = regexp.MustCompile(`^[\x21-\x7e]*$`)
+)
+
 // SetTimerResponse is the response model to set a new timer
 //
 // swagger:model setTimersResponse
@@ -28,16 +166,317 @@ type SetTimerResponse struct {
 }
 
 func (r *SetTimersRequest) Validate() error {
-	if _, err := url.ParseRequestURI(r.URL); err != nil {
-		return errors.New("invalid 'POST' field 'url'")
+	if r.ID != "" {
+		if err := timer.ValidateTimerID(r.ID); err != nil {
+			return errors.New("invalid 'POST' field 'id'")
+		}
+	}
+
+	if err := r.validateURL(); err != nil {
+		return err
+	}
+
+	schedules := 0
+	if r.Hours != 0 || r.Minutes != 0 || r.Seconds != 0 {
+		schedules++
+	}
+
+	if r.FireAt != "" {
+		schedules++
+		fireAt, err := r.fireAt()
+		if err != nil {
+			return errors.New("invalid 'POST' field 'fire_at', expected an RFC3339 timestamp")
+		}
+		if fireAt.Before(time.Now()) {
+			return errors.New("invalid 'POST' field 'fire_at', time is in the past")
+		}
+	}
+
+	if r.Delay != "" {
+		schedules++
+		delay, err := r.delay()
+		if err != nil {
+			return errors.New("invalid 'POST' field 'delay', expected an ISO-8601 or Go duration")
+		}
+		if delay < 0 {
+			return errors.New("invalid 'POST' field 'delay', duration is negative")
+		}
+	}
+
+	if r.Cron != "" {
+		schedules++
+	}
+
+	if schedules > 1 {
+		return errors.New("only one of 'hours/minutes/seconds', 'fire_at', 'delay' or 'cron' can be set")
+	}
+
+	if err := r.validateRecurrence(); err != nil {
+		return err
+	}
+
+	if err := validateLabels(r.Labels); err != nil {
+		return err
+	}
+
+	if _, err := r.RetryPolicy.toRetryPolicy(); err != nil {
+		return err
+	}
+
+	if _, err := r.SuccessCriteria.toSuccessCriteria(); err != nil {
+		return err
+	}
+
+	return r.validateWebhook()
+}
+
+func validateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("too many 'labels', at most %d are allowed", maxLabels)
+	}
+
+	for key, value := range labels {
+		if !labelKeyRe.MatchString(key) {
+			return fmt.Errorf("invalid label key %q", key)
+		}
+		if len(value) > maxLabelValueLength {
+			return fmt.Errorf("the value of label %q is too long, at most %d characters are allowed", key, maxLabelValueLength)
+		}
+	}
+
+	return nil
+}
+
+func (r *SetTimersRequest) validateWebhook() error {
+	if _, err := timer.NewWebhook(r.Method, nil, nil, ""); err != nil {
+		return errors.New("invalid 'POST' field 'method'")
+	}
+
+	if len(r.Headers) > maxWebhookHeaders {
+		return fmt.Errorf("too many 'headers', at most %d are allowed", maxWebhookHeaders)
+	}
+
+	headersBytes := 0
+	for name, value := range r.Headers {
+		if !headerNameRe.MatchString(name) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if forbiddenWebhookHeaders[http.CanonicalHeaderKey(name)] {
+			return fmt.Errorf("header %q cannot be set", name)
+		}
+		if !headerValueRe.MatchString(value) {
+			return fmt.Errorf("invalid value of header %q", name)
+		}
+		headersBytes += len(name) + len(value)
+	}
+
+	if headersBytes > maxWebhookHeadersBytes {
+		return fmt.Errorf("'headers' are too large, at most %d bytes are allowed", maxWebhookHeadersBytes)
+	}
+
+	if r.hasJSONBody() && r.BodyBase64 != "" {
+		return errors.New("only one of 'body' or 'body_base64' can be set")
+	}
+
+	body, err := r.body()
+	if err != nil {
+		return errors.New("invalid 'POST' field 'body_base64'")
+	}
+
+	if len(body) > maxWebhookBodyBytes {
+		return fmt.Errorf("the webhook body is too large, at most %d bytes are allowed", maxWebhookBodyBytes)
+	}
+
+	if len(r.SigningSecrets) > maxSigningSecrets {
+		return fmt.Errorf("too many 'signing_secrets', at most %d are allowed", maxSigningSecrets)
+	}
+
+	if _, err := webhooks.ParseSecrets(r.SigningSecrets); err != nil {
+		return errors.New("invalid 'POST' field 'signing_secrets', expected secrets in the whsec_<base64> format of 24 to 64 bytes")
+	}
+
+	timeout, err := r.timeout()
+	if err != nil {
+		return errors.New("invalid 'POST' field 'timeout', expected an ISO-8601 or Go duration")
+	}
+
+	if _, err := (timer.Webhook{}).WithTimeout(timeout); err != nil {
+		return fmt.Errorf("invalid 'POST' field 'timeout', %v", err)
 	}
 
 	return nil
 }
 
+func (r *SetTimersRequest) hasJSONBody() bool {
+	return len(r.Body) > 0 && string(r.Body) != "null"
+}
+
+func (r *SetTimersRequest) body() ([]byte, error) {
+	if r.hasJSONBody() {
+		return r.Body, nil
+	}
+
+	if r.BodyBase64 == "" {
+		return nil, nil
+	}
+
+	return base64.StdEncoding.DecodeString(r.BodyBase64)
+}
+
+func (r *SetTimersRequest) contentType() string {
+	if r.ContentType == "" && r.hasJSONBody() {
+		return "application/json"
+	}
+	return r.ContentType
+}
+
+func (r *SetTimersRequest) validateURL() error {
+	if r.URLTemplate == "" {
+		if _, err := url.ParseRequestURI(r.URL); err != nil {
+			return errors.New("invalid 'POST' field 'url'")
+		}
+		return nil
+	}
+
+	if r.URL != "" {
+		return errors.New("only one of 'url' or 'url_template' can be set")
+	}
+
+	if r.AppendID != nil {
+		return errors.New("'append_id' can only be set with 'url'")
+	}
+
+	if err := timer.ValidateURLTemplate(r.URLTemplate); err != nil {
+		return fmt.Errorf("invalid 'POST' field 'url_template', %v", err)
+	}
+
+	return nil
+}
+
+func (r *SetTimersRequest) validateRecurrence() error {
+	if r.Cron == "" {
+		if r.Timezone != "" || r.EndAt != "" || r.MaxRuns != 0 {
+			return errors.New("'timezone', 'end_at' and 'max_runs' can only be set with 'cron'")
+		}
+		return nil
+	}
+
+	endAt, err := r.endAt()
+	if err != nil {
+		return errors.New("invalid 'POST' field 'end_at', expected an RFC3339 timestamp")
+	}
+
+	recurrence, err := timer.NewRecurrence(r.Cron, r.Timezone, endAt, r.MaxRuns)
+	if err != nil {
+		return fmt.Errorf("invalid 'POST' field 'cron', %v", err)
+	}
+
+	if _, ok := recurrence.Next(time.Now()); !ok {
+		return errors.New("the recurrence has no occurrence before 'end_at'")
+	}
+
+	return nil
+}
+
+// toRetryPolicy returns nil when the retry policy is not set.
+func (r *RetryPolicyRequest) toRetryPolicy() (*timer.RetryPolicy, error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	policy := &timer.RetryPolicy{
+		MaxAttempts: r.MaxAttempts,
+		Multiplier:  r.Multiplier,
+		Jitter:      r.Jitter,
+	}
+
+	durations := []struct {
+		field string
+		value string
+		dest  *time.Duration
+	}{
+		{field: "initial_backoff", value: r.InitialBackoff, dest: &policy.InitialBackoff},
+		{field: "max_backoff", value: r.MaxBackoff, dest: &policy.MaxBackoff},
+		{field: "deadline", value: r.Deadline, dest: &policy.Deadline},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		duration, err := parseDuration(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'retry_policy' field '%s', expected an ISO-8601 or Go duration", d.field)
+		}
+		*d.dest = duration
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid 'retry_policy', %v", err)
+	}
+
+	return policy, nil
+}
+
+// toSuccessCriteria returns nil when the success criteria are not set.
+func (r *SuccessCriteriaRequest) toSuccessCriteria() (*timer.SuccessCriteria, error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	if len(r.AcceptedStatusCodes) > maxSuccessStatusCodes || len(r.RetryableStatusCodes) > maxSuccessStatusCodes {
+		return nil, fmt.Errorf("too many status codes in 'success_criteria', at most %d are allowed", maxSuccessStatusCodes)
+	}
+
+	if len(r.BodyPattern) > maxSuccessMatchLength || len(r.JSONPath) > maxSuccessMatchLength || len(r.JSONValue) > maxSuccessMatchLength {
+		return nil, fmt.Errorf("'success_criteria' are too long, at most %d characters are allowed per field", maxSuccessMatchLength)
+	}
+
+	criteria := &timer.SuccessCriteria{
+		AcceptedStatusCodes:  r.AcceptedStatusCodes,
+		RetryableStatusCodes: r.RetryableStatusCodes,
+		BodyPattern:          r.BodyPattern,
+		JSONPath:             r.JSONPath,
+		JSONValue:            r.JSONValue,
+	}
+
+	if err := criteria.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid 'success_criteria', %v", err)
+	}
+
+	return criteria, nil
+}
+
+func (r *SetTimersRequest) endAt() (time.Time, error) {
+	if r.EndAt == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, r.EndAt)
+}
+
+func (r *SetTimersRequest) fireAt() (time.Time, error) {
+	if r.FireAt == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, r.FireAt)
+}
+
+func (r *SetTimersRequest) delay() (time.Duration, error) {
+	if r.Delay == "" {
+		return 0, nil
+	}
+	return parseDuration(r.Delay)
+}
+
+func (r *SetTimersRequest) timeout() (time.Duration, error) {
+	if r.Timeout == "" {
+		return 0, nil
+	}
+	return parseDuration(r.Timeout)
+}
+
 func toSetTimerCommand(w http.ResponseWriter, req *http.Request) (timer.SetTimerCommand, error) {
 	request := &SetTimersRequest{}
-	if err := json.NewDecoder(req.Body).Decode(request); err != nil {
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxSetTimerRequestBytes)).Decode(request); err != nil {
 		_ = BadRequest(w, "cannot set timers, bad request payload")
 		return timer.SetTimerCommand{}, err
 	}
@@ -47,16 +486,271 @@ func toSetTimerCommand(w http.ResponseWriter, req *http.Request) (timer.SetTimer
 		return timer.SetTimerCommand{}, err
 	}
 
+	idempotencyKey := req.Header.Get(idempotencyKeyHeader)
+	if err := validateIdempotencyKey(idempotencyKey); err != nil {
+		_ = InvalidParams(w, fmt.Sprintf("invalid param: %v", err))
+		return timer.SetTimerCommand{}, err
+	}
+
+	command := request.toSetTimerCommand()
+	command.IdempotencyKey = idempotencyKey
+
+	return command, nil
+}
+
+func validateIdempotencyKey(key string) error {
+	if len(key) > maxIdempotencyKeyLength {
+		return fmt.Errorf("the %s header is too long, at most %d characters are allowed", idempotencyKeyHeader, maxIdempotencyKeyLength)
+	}
+
+	if !idempotencyKeyRe.MatchString(key) {
+		return fmt.Errorf("the %s header must only contain visible ASCII characters", idempotencyKeyHeader)
+	}
+
+	return nil
+}
+
+// toSetTimerCommand must only be called on a valid request.
+func (r *SetTimersRequest) toSetTimerCommand() timer.SetTimerCommand {
+	// the errors are already checked by Validate
+	fireAt, _ := r.fireAt()
+	delay, _ := r.delay()
+	endAt, _ := r.endAt()
+	body, _ := r.body()
+	timeout, _ := r.timeout()
+	retryPolicy, _ := r.RetryPolicy.toRetryPolicy()
+	successCriteria, _ := r.SuccessCriteria.toSuccessCriteria()
+
 	return timer.SetTimerCommand{
+		ID:       r.ID,
+		Hours:    r.Hours,
+		Minutes:  r.Minutes,
+		Seconds:  r.Seconds,
+		URLRaw:   r.URL,
+		FireAt:   fireAt,
+		Delay:    delay,
+		Cron:     r.Cron,
+		Timezone: r.Timezone,
+		EndAt:    endAt,
+		MaxRuns:  r.MaxRuns,
+
+		URLTemplate:     r.URLTemplate,
+		DisableIDAppend: r.AppendID != nil && !*r.AppendID,
+
+		Method:      r.Method,
+		Headers:     r.Headers,
+		Body:        body,
+		ContentType: r.contentType(),
+
+		SigningSecrets: r.SigningSecrets,
+		Timeout:        timeout,
+
+		Labels: r.Labels,
+
+		RetryPolicy:     retryPolicy,
+		SuccessCriteria: successCriteria,
+	}
+}
+
+func toSetTimerResponse(t *timer.Timer) SetTimerResponse {
+	return SetTimerResponse{ID: t.ID}
+}
+
+const (
+	// maxBatchItems is the maximum number of the timers in a batch.
+	maxBatchItems = 10000
+	// maxBatchRequestBytes is the maximum size of the batch request payload.
+	maxBatchRequestBytes = 32 << 20
+	// ndjsonContentType is the content type of a batch that is streamed as newline delimited JSON.
+	ndjsonContentType = "application/x-ndjson"
+)
+
+var errBatchTooLarge = fmt.Errorf("too many timers, at most %d are allowed", maxBatchItems)
+
+// BatchSetTimersResponse is the response model to set a batch of timers
+//
+// swagger:model batchSetTimersResponse
+type BatchSetTimersResponse struct {
+	Created int `json:"created"`
+	Failed  int `json:"failed"`
+	// Results are in the order of the requested timers.
+	Results []BatchSetTimerResult `json:"results"`
+}
+
+// BatchSetTimerResult is the outcome of setting one of the timers of a batch. either ID or Error is set.
+//
+// swagger:model batchSetTimerResult
+type BatchSetTimerResult struct {
+	// Index of the timer in the batch, starting from 0.
+	Index int        `json:"index"`
+	ID    string     `json:"id,omitempty"`
+	Error *JsonError `json:"error,omitempty"`
+}
+
+// decodeBatchSetTimersRequest decodes either a JSON array or a newline delimited JSON stream of SetTimersRequest.
+// an item with a wrong type of field is reported as nil, but malformed JSON fails the whole batch.
+func decodeBatchSetTimersRequest(w http.ResponseWriter, req *http.Request) ([]*SetTimersRequest, error) {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBatchRequestBytes))
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	isArray := mediaType != ndjsonContentType
+	if isArray {
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return nil, errors.New("expected a JSON array of timers")
+		}
+	}
+
+	requests := make([]*SetTimersRequest, 0)
+	for decoder.More() {
+		if len(requests) == maxBatchItems {
+			return nil, errBatchTooLarge
+		}
+
+		request := &SetTimersRequest{}
+		var typeErr *json.UnmarshalTypeError
+		switch err := decoder.Decode(request); {
+		case errors.As(err, &typeErr):
+			request = nil
+		case err != nil:
+			return nil, fmt.Errorf("timer %d is malformed", len(requests))
+		}
+
+		requests = append(requests, request)
+	}
+
+	if isArray {
+		if _, err := decoder.Token(); err != nil {
+			return nil, errors.New("expected a JSON array of timers")
+		}
+	}
+
+	return requests, nil
+}
+
+// RescheduleTimerRequest is the request model to reschedule an existing timer
+//
+// swagger:model rescheduleTimerRequest
+type RescheduleTimerRequest struct {
+	Hours   int `json:"hours"`
+	Minutes int `json:"minutes"`
+	Seconds int `json:"seconds"`
+}
+
+func toRescheduleTimerCommand(w http.ResponseWriter, req *http.Request, timerID string) (timer.RescheduleTimerCommand, error) {
+	request := &RescheduleTimerRequest{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxRescheduleTimerRequestBytes)).Decode(request); err != nil {
+		_ = BadRequest(w, "cannot reschedule timer, bad request payload")
+		return timer.RescheduleTimerCommand{}, err
+	}
+
+	return timer.RescheduleTimerCommand{
+		TimerID: timerID,
 		Hours:   request.Hours,
 		Minutes: request.Minutes,
 		Seconds: request.Seconds,
-		URLRaw:  request.URL,
 	}, nil
 }
 
-func toSetTimerResponse(t *timer.Timer) SetTimerResponse {
-	return SetTimerResponse{ID: t.ID}
+func toListTimersQuery(req *http.Request) (timer.ListTimersQuery, error) {
+	values := req.URL.Query()
+	query := timer.ListTimersQuery{
+		Status: timer.State(values.Get("status")),
+		Host:   values.Get("host"),
+		Cursor: values.Get("cursor"),
+	}
+
+	if query.Status != "" && !query.Status.IsListed() {
+		return query, fmt.Errorf("invalid query param 'status', expected one of %q, %q, %q and %q",
+			timer.StatePending, timer.StateRelayed, timer.StateInFlight, timer.StateRetrying)
+	}
+
+	var err error
+	if due := values.Get("due"); due != "" {
+		var isDue bool
+		if isDue, err = strconv.ParseBool(due); err != nil {
+			return query, errors.New("invalid query param 'due', expected true or false")
+		}
+		query.Due = &isDue
+	}
+
+	if query.From, err = parseTimeParam(values.Get("from")); err != nil {
+		return query, errors.New("invalid query param 'from', expected an RFC3339 timestamp")
+	}
+
+	if query.To, err = parseTimeParam(values.Get("to")); err != nil {
+		return query, errors.New("invalid query param 'to', expected an RFC3339 timestamp")
+	}
+
+	if !query.From.IsZero() && !query.To.IsZero() && query.To.Before(query.From) {
+		return query, errors.New("invalid query params, 'to' is before 'from'")
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 || query.Limit > timer.MaxListLimit {
+			return query, fmt.Errorf("invalid query param 'limit', expected a number between 1 and %d", timer.MaxListLimit)
+		}
+	}
+
+	for _, label := range values["label"] {
+		key, value, ok := strings.Cut(label, ":")
+		if !ok {
+			return query, fmt.Errorf("invalid query param 'label' %q, expected key:value", label)
+		}
+		if query.Labels == nil {
+			query.Labels = make(map[string]string)
+		}
+		query.Labels[key] = value
+	}
+
+	return query, validateLabels(query.Labels)
+}
+
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// ListTimersResponse is the response model to list the timers
+//
+// swagger:model ListTimersResponse
+type ListTimersResponse struct {
+	Timers []ListTimersItem `json:"timers"`
+	// NextCursor is passed as the cursor query param to get the next page. it is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListTimersItem is the model of a timer in the list of timers
+//
+// swagger:model ListTimersItem
+type ListTimersItem struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Status is one of pending, relayed, in-flight and retrying.
+	Status          string              `json:"status"`
+	FireAt          string              `json:"fire_at"`
+	TimeLeftSeconds int                 `json:"time_left"`
+	Labels          map[string]string   `json:"labels,omitempty"`
+	Recurrence      *RecurrenceResponse `json:"recurrence,omitempty"`
+}
+
+func toListTimersResponse(page *timer.TimersPage) ListTimersResponse {
+	items := make([]ListTimersItem, 0, len(page.Timers))
+	for _, state := range page.Timers {
+		t := state.Timer
+		items = append(items, ListTimersItem{
+			ID:              t.ID,
+			URL:             t.URL.String(),
+			Status:          string(state.State),
+			FireAt:          t.FireAt.Format(time.RFC3339),
+			TimeLeftSeconds: int(t.DelayFromNowSeconds()),
+			Labels:          t.Labels,
+			Recurrence:      toRecurrenceResponse(t),
+		})
+	}
+
+	return ListTimersResponse{Timers: items, NextCursor: page.NextCursor}
 }
 
 // GetTimerResponse is the response model to get a timer
@@ -65,19 +759,315 @@ func toSetTimerResponse(t *timer.Timer) SetTimerResponse {
 type GetTimerResponse struct {
 	ID              string `json:"ID"`
 	TimeLeftSeconds int    `json:"time_left"`
+	// Status is one of pending, relayed, in-flight, retrying, succeeded, failed and cancelled.
+	Status    string `json:"status,omitempty"`
+	FireAt    string `json:"fire_at,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+	// Attempts is the number of the delivery attempts of the current run so far.
+	Attempts int `json:"attempts"`
+	// LastError is the failure of the last attempt, if it failed.
+	LastError string `json:"last_error,omitempty"`
+	// FinishedAt is the time the timer got into a final state, i.e. succeeded, failed or cancelled.
+	FinishedAt string              `json:"finished_at,omitempty"`
+	Recurrence *RecurrenceResponse `json:"recurrence,omitempty"`
+	// AttemptHistory are the latest delivery attempts, the latest first. they are responded when
+	// expand=attempt_history is given.
+	AttemptHistory []AttemptResponse `json:"attempt_history,omitempty"`
 }
 
+// RecurrenceResponse is the response model of the recurrence of a timer
+//
+// swagger:model RecurrenceResponse
+type RecurrenceResponse struct {
+	Cron       string `json:"cron"`
+	Timezone   string `json:"timezone,omitempty"`
+	NextFireAt string `json:"next_fire_at"`
+	Runs       int    `json:"runs"`
+	MaxRuns    int    `json:"max_runs,omitempty"`
+	EndAt      string `json:"end_at,omitempty"`
+}
+
+// getArchivedTimerResponse is the response of an archived timer whose state is no longer known.
 func getArchivedTimerResponse(timerID string) GetTimerResponse {
 	return GetTimerResponse{
 		ID: timerID,
 	}
 }
 
-func toGetTimersResponse(t *timer.Timer) GetTimerResponse {
-	timeLeft := t.DelayFromNowSeconds()
-
+// getCancelledTimerResponse is the response of a cancelled timer whose state is no longer known.
+func getCancelledTimerResponse(timerID string) GetTimerResponse {
 	return GetTimerResponse{
-		ID:              t.ID,
-		TimeLeftSeconds: int(timeLeft),
+		ID:     timerID,
+		Status: string(timer.StateCancelled),
+	}
+}
+
+func toGetTimerResponse(state *timer.TimerState) GetTimerResponse {
+	resp := GetTimerResponse{
+		ID:        state.TimerID,
+		Status:    string(state.State),
+		Attempts:  state.Attempts,
+		LastError: state.LastError,
+	}
+
+	// only the outcome of an archived timer is known by its tombstone
+	if !state.FireAt.IsZero() {
+		resp.FireAt = state.FireAt.Format(time.RFC3339)
+	}
+
+	if !state.CreatedAt.IsZero() {
+		resp.CreatedAt = state.CreatedAt.Format(time.RFC3339)
+	}
+
+	if !state.FinishedAt.IsZero() {
+		resp.FinishedAt = state.FinishedAt.Format(time.RFC3339)
 	}
+
+	if state.Timer != nil {
+		resp.TimeLeftSeconds = int(state.Timer.DelayFromNowSeconds())
+		resp.Recurrence = toRecurrenceResponse(state.Timer)
+	}
+
+	return resp
+}
+
+func toRecurrenceResponse(t *timer.Timer) *RecurrenceResponse {
+	if !t.IsRecurring() {
+		return nil
+	}
+
+	var endAt string
+	if !t.Recurrence.EndAt.IsZero() {
+		endAt = t.Recurrence.EndAt.Format(time.RFC3339)
+	}
+
+	return &RecurrenceResponse{
+		Cron:       t.Recurrence.Cron,
+		Timezone:   t.Recurrence.Timezone,
+		NextFireAt: t.FireAt.Format(time.RFC3339),
+		Runs:       t.Recurrence.Runs,
+		MaxRuns:    t.Recurrence.MaxRuns,
+		EndAt:      endAt,
+	}
+}
+
+// expandAttemptHistory is the value of the expand query param that adds the attempt history to the timer response.
+const expandAttemptHistory = "attempt_history"
+
+// AttemptResponse is the response model of a delivery attempt
+//
+// swagger:model AttemptResponse
+type AttemptResponse struct {
+	// Run is the run of the timer the attempt belongs to, starting from 1.
+	Run int `json:"run"`
+	// Attempt is the number of the attempt within the run, starting from 1.
+	Attempt    int    `json:"attempt"`
+	StartedAt  string `json:"started_at"`
+	DurationMs int64  `json:"duration_ms"`
+	// StatusCode is of the response. it is missing when there was no response.
+	StatusCode int `json:"status_code,omitempty"`
+	// Outcome is one of success, retry and failure, i.e. the attempt succeeded, is retried or failed permanently.
+	Outcome string `json:"outcome,omitempty"`
+	// ErrorClass is one of timeout, connection, http_status, response_body, request and circuit_open. it is missing
+	// when the attempt succeeded.
+	ErrorClass string `json:"error_class,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// RetryAt is when the failed attempt is retried. it is missing when the run is not retried.
+	RetryAt string `json:"retry_at,omitempty"`
+}
+
+// ListAttemptsResponse is the response model to list the delivery attempts of a timer
+//
+// swagger:model ListAttemptsResponse
+type ListAttemptsResponse struct {
+	Attempts []AttemptResponse `json:"attempts"`
+}
+
+func isExpanded(req *http.Request, field string) bool {
+	for _, value := range req.URL.Query()["expand"] {
+		for _, expanded := range strings.Split(value, ",") {
+			if strings.TrimSpace(expanded) == field {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func toAttemptsResponse(attempts []*timer.Attempt) []AttemptResponse {
+	items := make([]AttemptResponse, 0, len(attempts))
+	for _, a := range attempts {
+		var retryAt string
+		if !a.RetryAt.IsZero() {
+			retryAt = a.RetryAt.Format(time.RFC3339)
+		}
+
+		items = append(items, AttemptResponse{
+			Run:        a.Run,
+			Attempt:    a.Number,
+			StartedAt:  a.StartedAt.Format(time.RFC3339Nano),
+			DurationMs: a.Duration.Milliseconds(),
+			StatusCode: a.StatusCode,
+			Outcome:    string(a.Outcome),
+			ErrorClass: a.ErrorClass,
+			Error:      a.Error,
+			RetryAt:    retryAt,
+		})
+	}
+
+	return items
+}
+
+// maxDeadLetterIDs is the maximum number of the dead letters that are replayed or purged by ID at once.
+const maxDeadLetterIDs = 1000
+
+// DeadLetterResponse is the response model of a dead letter
+//
+// swagger:model DeadLetterResponse
+type DeadLetterResponse struct {
+	// ID identifies the dead letter. it is the timer ID, followed by the run for the recurring timers, and the time the
+	// run failed in unix milliseconds, e.g. order-42:3@1700000000000
+	ID      string            `json:"id"`
+	TimerID string            `json:"timer_id"`
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	FireAt  string            `json:"fire_at"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	// Error is the reason of the last failure.
+	Error string `json:"error"`
+	// StatusCode and ResponseBody are of the last response, if there was any. the body is an excerpt.
+	StatusCode   int    `json:"status_code,omitempty"`
+	ResponseBody string `json:"response_body,omitempty"`
+	Attempts     int    `json:"attempts"`
+	DeadAt       string `json:"dead_at"`
+}
+
+func toDeadLetterResponse(d *timer.DeadLetter) DeadLetterResponse {
+	return DeadLetterResponse{
+		ID:           d.ID,
+		TimerID:      d.Timer.ID,
+		URL:          d.Timer.URL.String(),
+		Method:       d.Timer.Webhook.MethodOrDefault(),
+		FireAt:       d.Timer.FireAt.Format(time.RFC3339),
+		Labels:       d.Timer.Labels,
+		Error:        d.Error,
+		StatusCode:   d.StatusCode,
+		ResponseBody: d.ResponseBody,
+		Attempts:     d.Attempts,
+		DeadAt:       d.DeadAt.Format(time.RFC3339),
+	}
+}
+
+// ListDeadLettersResponse is the response model to list the dead letters
+//
+// swagger:model ListDeadLettersResponse
+type ListDeadLettersResponse struct {
+	DeadLetters []DeadLetterResponse `json:"dead_letters"`
+	// NextCursor is passed as the cursor query param to get the next page. it is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func toListDeadLettersQuery(req *http.Request) (timer.ListDeadLettersQuery, error) {
+	values := req.URL.Query()
+	query := timer.ListDeadLettersQuery{Cursor: values.Get("cursor")}
+
+	if limit := values.Get("limit"); limit != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 || query.Limit > timer.MaxListLimit {
+			return query, fmt.Errorf("invalid query param 'limit', expected a number between 1 and %d", timer.MaxListLimit)
+		}
+	}
+
+	return query, nil
+}
+
+func toListDeadLettersResponse(page *timer.DeadLettersPage) ListDeadLettersResponse {
+	items := make([]DeadLetterResponse, 0, len(page.DeadLetters))
+	for _, d := range page.DeadLetters {
+		items = append(items, toDeadLetterResponse(d))
+	}
+
+	return ListDeadLettersResponse{DeadLetters: items, NextCursor: page.NextCursor}
+}
+
+// ReplayDeadLettersRequest is the request model to replay dead letters
+//
+// swagger:model replayDeadLettersRequest
+type ReplayDeadLettersRequest struct {
+	// IDs of the dead letters, at most 1000.
+	IDs []string `json:"ids"`
+	// URL optionally sends the webhooks to another URL as is.
+	URL string `json:"url,omitempty"`
+}
+
+func (r *ReplayDeadLettersRequest) Validate() error {
+	if len(r.IDs) == 0 {
+		return errors.New("'ids' cannot be empty")
+	}
+
+	if len(r.IDs) > maxDeadLetterIDs {
+		return fmt.Errorf("too many 'ids', at most %d are allowed", maxDeadLetterIDs)
+	}
+
+	if r.URL != "" {
+		if _, err := url.ParseRequestURI(r.URL); err != nil {
+			return errors.New("invalid 'POST' field 'url'")
+		}
+	}
+
+	return nil
+}
+
+func (r *ReplayDeadLettersRequest) toReplayDeadLettersCommand() timer.ReplayDeadLettersCommand {
+	return timer.ReplayDeadLettersCommand{IDs: r.IDs, URLRaw: r.URL}
+}
+
+// ReplayDeadLettersResponse is the response model to replay dead letters
+//
+// swagger:model replayDeadLettersResponse
+type ReplayDeadLettersResponse struct {
+	Replayed int `json:"replayed"`
+	Failed   int `json:"failed"`
+	// Results are in the order of the requested IDs.
+	Results []ReplayDeadLetterResult `json:"results"`
+}
+
+// ReplayDeadLetterResult is the outcome of replaying one of the dead letters. either TimerID or Error is set.
+//
+// swagger:model replayDeadLetterResult
+type ReplayDeadLetterResult struct {
+	ID string `json:"id"`
+	// TimerID is the ID of the timer that shoots the webhook again.
+	TimerID string     `json:"timer_id,omitempty"`
+	Error   *JsonError `json:"error,omitempty"`
+}
+
+// PurgeDeadLettersRequest is the request model to purge dead letters
+//
+// swagger:model purgeDeadLettersRequest
+type PurgeDeadLettersRequest struct {
+	// IDs of the dead letters, at most 1000.
+	IDs []string `json:"ids,omitempty"`
+	// All purges all the dead letters. it cannot be set with IDs.
+	All bool `json:"all,omitempty"`
+}
+
+func (r *PurgeDeadLettersRequest) Validate() error {
+	switch {
+	case r.All && len(r.IDs) > 0:
+		return errors.New("only one of 'ids' or 'all' can be set")
+	case !r.All && len(r.IDs) == 0:
+		return errors.New("either 'ids' or 'all' must be set")
+	case len(r.IDs) > maxDeadLetterIDs:
+		return fmt.Errorf("too many 'ids', at most %d are allowed", maxDeadLetterIDs)
+	}
+
+	return nil
+}
+
+// PurgeDeadLettersResponse is the response model to purge dead letters
+//
+// swagger:model purgeDeadLettersResponse
+type PurgeDeadLettersResponse struct {
+	Purged int `json:"purged"`
 }
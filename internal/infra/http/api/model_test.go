@@ -1,7 +1,10 @@
 package api
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -12,6 +15,30 @@ func Test_setTimersRequest_Validate(t *testing.T) {
 		Minutes int
 		Seconds int
 		URL     string
+
+		URLTemplate string
+		AppendID    *bool
+
+		FireAt   string
+		Delay    string
+		Cron     string
+		Timezone string
+		EndAt    string
+		MaxRuns  int
+
+		Method     string
+		Headers    map[string]string
+		Body       string
+		BodyBase64 string
+
+		SigningSecrets []string
+		Timeout        string
+
+		Labels map[string]string
+
+		RetryPolicy *RetryPolicyRequest
+
+		SuccessCriteria *SuccessCriteriaRequest
 	}
 	tests := []struct {
 		name    string
@@ -28,6 +55,223 @@ func Test_setTimersRequest_Validate(t *testing.T) {
 			fields:  fields{URL: "invalid.url"},
 			wantErr: assert.Error,
 		},
+		{
+			name:    "valid url_template",
+			fields:  fields{URLTemplate: "http://valid.url/hooks?timer={{.ID}}"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid url_template",
+			fields:  fields{URLTemplate: "http://valid.url/hooks?timer={{.ID}"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "url and url_template",
+			fields:  fields{URL: "http://valid.url", URLTemplate: "http://valid.url/hooks?timer={{.ID}}"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "append_id with url_template",
+			fields:  fields{URLTemplate: "http://valid.url/hooks?timer={{.ID}}", AppendID: new(bool)},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "append_id with url",
+			fields:  fields{URL: "http://valid.url", AppendID: new(bool)},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "valid fire_at",
+			fields:  fields{URL: "http://valid.url", FireAt: time.Now().Add(time.Hour).Format(time.RFC3339)},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "fire_at without timezone",
+			fields:  fields{URL: "http://valid.url", FireAt: "2100-01-02T15:04:05"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "fire_at in the past",
+			fields:  fields{URL: "http://valid.url", FireAt: "2000-01-02T15:04:05Z"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "valid delay",
+			fields:  fields{URL: "http://valid.url", Delay: "PT1H"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid delay",
+			fields:  fields{URL: "http://valid.url", Delay: "one hour"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "negative delay",
+			fields:  fields{URL: "http://valid.url", Delay: "-1h"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "delay and seconds",
+			fields:  fields{URL: "http://valid.url", Delay: "PT1H", Seconds: 1},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "valid cron",
+			fields:  fields{URL: "http://valid.url", Cron: "0 9 * * 1-5", Timezone: "Europe/Berlin", MaxRuns: 10},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid cron",
+			fields:  fields{URL: "http://valid.url", Cron: "0 9 * *"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "cron and delay",
+			fields:  fields{URL: "http://valid.url", Cron: "@hourly", Delay: "PT1H"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "timezone without cron",
+			fields:  fields{URL: "http://valid.url", Timezone: "Europe/Berlin"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "end_at before the first occurrence",
+			fields:  fields{URL: "http://valid.url", Cron: "@hourly", EndAt: "2000-01-02T15:04:05Z"},
+			wantErr: assert.Error,
+		},
+		{
+			name: "valid webhook",
+			fields: fields{
+				URL:     "http://valid.url",
+				Method:  "PUT",
+				Headers: map[string]string{"X-Order-ID": "42"},
+				Body:    `{"order_id":42}`,
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "valid base64 body",
+			fields:  fields{URL: "http://valid.url", BodyBase64: "aGVsbG8="},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid base64 body",
+			fields:  fields{URL: "http://valid.url", BodyBase64: "not base64!"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "both body and base64 body",
+			fields:  fields{URL: "http://valid.url", Body: `{}`, BodyBase64: "aGVsbG8="},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "valid success criteria",
+			fields:  fields{URL: "http://valid.url", SuccessCriteria: &SuccessCriteriaRequest{AcceptedStatusCodes: []string{"200-299"}, BodyPattern: "^OK$"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid success criteria",
+			fields:  fields{URL: "http://valid.url", SuccessCriteria: &SuccessCriteriaRequest{RetryableStatusCodes: []string{"5xx", "oops"}}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "too many success status codes",
+			fields:  fields{URL: "http://valid.url", SuccessCriteria: &SuccessCriteriaRequest{AcceptedStatusCodes: make([]string, maxSuccessStatusCodes+1)}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "valid timeout",
+			fields:  fields{URL: "http://valid.url", Timeout: "PT10S"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid timeout",
+			fields:  fields{URL: "http://valid.url", Timeout: "soon"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "timeout is too long",
+			fields:  fields{URL: "http://valid.url", Timeout: "10m"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "body is too large",
+			fields:  fields{URL: "http://valid.url", Body: `"` + strings.Repeat("a", maxWebhookBodyBytes) + `"`},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "unsupported method",
+			fields:  fields{URL: "http://valid.url", Method: "CONNECT"},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "invalid header name",
+			fields:  fields{URL: "http://valid.url", Headers: map[string]string{"X Order": "42"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "invalid header value",
+			fields:  fields{URL: "http://valid.url", Headers: map[string]string{"X-Order": "42\r\nX-Injected: 1"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "header value with a tab",
+			fields:  fields{URL: "http://valid.url", Headers: map[string]string{"X-Order": "42\t43"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "forbidden header",
+			fields:  fields{URL: "http://valid.url", Headers: map[string]string{"host": "evil.url"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "fire_at and delay",
+			fields:  fields{URL: "http://valid.url", Delay: "PT1H", FireAt: time.Now().Add(time.Hour).Format(time.RFC3339)},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "valid signing secrets",
+			fields:  fields{URL: "http://valid.url", SigningSecrets: []string{"whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid signing secret",
+			fields:  fields{URL: "http://valid.url", SigningSecrets: []string{"secret"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "valid labels",
+			fields:  fields{URL: "http://valid.url", Labels: map[string]string{"team": "payments", "app.kind": "invoice"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "invalid label key",
+			fields:  fields{URL: "http://valid.url", Labels: map[string]string{"team name": "payments"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "label value is too long",
+			fields:  fields{URL: "http://valid.url", Labels: map[string]string{"team": strings.Repeat("a", 256)}},
+			wantErr: assert.Error,
+		},
+		{
+			name: "valid retry policy",
+			fields: fields{URL: "http://valid.url", RetryPolicy: &RetryPolicyRequest{
+				MaxAttempts: 5, InitialBackoff: "10s", MaxBackoff: "PT1H", Multiplier: 2, Jitter: 0.2, Deadline: "6h",
+			}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "retry policy with invalid duration",
+			fields:  fields{URL: "http://valid.url", RetryPolicy: &RetryPolicyRequest{InitialBackoff: "soon"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "retry policy with too many attempts",
+			fields:  fields{URL: "http://valid.url", RetryPolicy: &RetryPolicyRequest{MaxAttempts: 1000}},
+			wantErr: assert.Error,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -36,6 +280,30 @@ func Test_setTimersRequest_Validate(t *testing.T) {
 				Minutes: tt.fields.Minutes,
 				Seconds: tt.fields.Seconds,
 				URL:     tt.fields.URL,
+
+				URLTemplate: tt.fields.URLTemplate,
+				AppendID:    tt.fields.AppendID,
+
+				FireAt:   tt.fields.FireAt,
+				Delay:    tt.fields.Delay,
+				Cron:     tt.fields.Cron,
+				Timezone: tt.fields.Timezone,
+				EndAt:    tt.fields.EndAt,
+				MaxRuns:  tt.fields.MaxRuns,
+
+				Method:     tt.fields.Method,
+				Headers:    tt.fields.Headers,
+				Body:       json.RawMessage(tt.fields.Body),
+				BodyBase64: tt.fields.BodyBase64,
+
+				SigningSecrets: tt.fields.SigningSecrets,
+				Timeout:        tt.fields.Timeout,
+
+				Labels: tt.fields.Labels,
+
+				RetryPolicy: tt.fields.RetryPolicy,
+
+				SuccessCriteria: tt.fields.SuccessCriteria,
 			}
 			tt.wantErr(t, r.Validate(), "Validate()")
 		})
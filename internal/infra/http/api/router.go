@@ -63,7 +63,18 @@ func New(service timer.Service) (*Router, error) {
 
 	router.GET("/health", h.health)
 	router.POST("/timers", chain.Wrap(h.setTimer))
+	router.GET("/timers", chain.Wrap(h.listTimers))
+	// httprouter has no static routes with a colon, so the custom methods of /timers, i.e. /timers:batch,
+	// are routed by the action param.
+	router.POST("/timers:action", chain.Wrap(h.batchSetTimers))
 	router.GET("/timers/:id", chain.Wrap(h.getTimer))
+	router.GET("/timers/:id/attempts", chain.Wrap(h.listAttempts))
+	router.PATCH("/timers/:id", chain.Wrap(h.rescheduleTimer))
+	router.DELETE("/timers/:id", chain.Wrap(h.cancelTimer))
+	router.GET("/dead-letters", chain.Wrap(h.listDeadLetters))
+	router.POST("/dead-letters:action", chain.Wrap(h.deadLettersAction))
+	router.GET("/dead-letters/:id", chain.Wrap(h.getDeadLetter))
+	router.DELETE("/dead-letters/:id", chain.Wrap(h.deleteDeadLetter))
 
 	h.Handler = router
 	return h, nil
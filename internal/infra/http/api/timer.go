@@ -2,6 +2,8 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
@@ -14,13 +16,16 @@ import (
 // setTimer is the handler for
 // swagger:route POST /timers setTimersRequest
 //
-// Schedule a new timer.
+// Schedule a new timer. a request with the Idempotency-Key header of an already created timer responds
+// the ID of that timer with 200 instead of creating a new one.
 //
 // Responses:
 //
+//	200: setTimers
 //	201: setTimers
 //	400: invalidRequestBody
 //	404: notFoundError
+//	409: conflictError
 //	422: invalidParams
 //	500: serverError
 func (h *Router) setTimer(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -30,15 +35,33 @@ func (h *Router) setTimer(w http.ResponseWriter, r *http.Request, _ httprouter.P
 		return
 	}
 
+	status := http.StatusCreated
+
 	t, err := h.service.CreateTimer(r.Context(), command)
-	if err != nil {
+	switch {
+	case err == timer.ErrTimerAlreadyCreated:
+		status = http.StatusOK
+		w.Header().Set(idempotentReplayedHeader, "true")
+	case err == timer.ErrTimerExists:
+		_ = Conflict(w, "a timer with the id already exists")
+		return
+	case err == timer.ErrFireAtInPast:
+		_ = InvalidParams(w, "invalid param: the fire time is in the past")
+		return
+	case err == timer.ErrRecurrenceEnded:
+		_ = InvalidParams(w, "invalid param: the recurrence has no occurrence")
+		return
+	case errors.Is(err, timer.ErrDestinationNotAllowed):
+		_ = InvalidParams(w, fmt.Sprintf("invalid param: %v", err))
+		return
+	case err != nil:
 		log.WithError(err).Errorf("setTimers: service %s", err)
 		api500Count.With(prometheus.Labels{"method": "setTimers", "reason": "service"}).Inc()
 		_ = InternalError(w, "failed to set timers due to server internal error")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(toSetTimerResponse(t)); err != nil {
 		log.WithError(err).Errorf("setTimer: encoder %s", err)
 		api500Count.With(prometheus.Labels{"method": "setTimer", "reason": "encoder"}).Inc()
@@ -47,10 +70,111 @@ func (h *Router) setTimer(w http.ResponseWriter, r *http.Request, _ httprouter.P
 	}
 }
 
+// batchSetTimers is the handler for
+// swagger:route POST /timers:batch batchSetTimersRequest
+//
+// Schedules a batch of timers. the batch is either a JSON array or a newline delimited JSON stream
+// (Content-Type: application/x-ndjson) of timers. every timer is validated and created on its own.
+//
+// Responses:
+//
+//	200: batchSetTimers
+//	400: invalidRequestBody
+//	404: notFoundError
+//	422: invalidParams
+//	500: serverError
+func (h *Router) batchSetTimers(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if p.ByName("action") != ":batch" {
+		_ = NotFound(w, "")
+		return
+	}
+
+	requests, err := decodeBatchSetTimersRequest(w, r)
+	switch {
+	case err == errBatchTooLarge:
+		_ = InvalidParams(w, fmt.Sprintf("invalid param: %v", err))
+		return
+	case err != nil:
+		_ = BadRequest(w, fmt.Sprintf("cannot set timers, %v", err))
+		return
+	case len(requests) == 0:
+		_ = InvalidParams(w, "invalid param: the batch is empty")
+		return
+	}
+
+	resp := BatchSetTimersResponse{Results: make([]BatchSetTimerResult, len(requests))}
+
+	cmds := make([]timer.SetTimerCommand, 0, len(requests))
+	indexes := make([]int, 0, len(requests))
+	for i, request := range requests {
+		resp.Results[i].Index = i
+
+		if request == nil {
+			resp.Results[i].Error = batchItemError(errBadRequest, "bad timer payload")
+			continue
+		}
+
+		if err := request.Validate(); err != nil {
+			resp.Results[i].Error = batchItemError(errInvalidParams, fmt.Sprintf("invalid param: %v", err))
+			continue
+		}
+
+		cmds = append(cmds, request.toSetTimerCommand())
+		indexes = append(indexes, i)
+	}
+
+	var serviceErr error
+	for j, result := range h.service.CreateTimers(r.Context(), cmds) {
+		i := indexes[j]
+		switch {
+		case result.Err == timer.ErrTimerExists:
+			resp.Results[i].Error = batchItemError(errConflict, "a timer with the id already exists")
+		case result.Err == timer.ErrFireAtInPast:
+			resp.Results[i].Error = batchItemError(errInvalidParams, "invalid param: the fire time is in the past")
+		case result.Err == timer.ErrRecurrenceEnded:
+			resp.Results[i].Error = batchItemError(errInvalidParams, "invalid param: the recurrence has no occurrence")
+		case errors.Is(result.Err, timer.ErrDestinationNotAllowed):
+			resp.Results[i].Error = batchItemError(errInvalidParams, fmt.Sprintf("invalid param: %v", result.Err))
+		case result.Err != nil:
+			serviceErr = result.Err
+			resp.Results[i].Error = batchItemError(errInternalError, "failed to set timer due to server internal error")
+		default:
+			resp.Results[i].ID = result.Timer.ID
+		}
+	}
+
+	if serviceErr != nil {
+		log.WithError(serviceErr).Errorf("batchSetTimers: service %s", serviceErr)
+		api500Count.With(prometheus.Labels{"method": "batchSetTimers", "reason": "service"}).Inc()
+	}
+
+	for _, result := range resp.Results {
+		if result.Error != nil {
+			resp.Failed++
+			continue
+		}
+		resp.Created++
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.WithError(err).Errorf("batchSetTimers: encoder %s", err)
+		api500Count.With(prometheus.Labels{"method": "batchSetTimers", "reason": "encoder"}).Inc()
+		_ = InternalError(w, "cannot encode response")
+		return
+	}
+}
+
+func batchItemError(errorType errorType, details string) *JsonError {
+	e := newJsonError(errorType, details)
+	return &e
+}
+
 // getTimer is the handler for
 // swagger:route GET /timers/{timer_id} getTimerRequest
 //
-// Responds how much time remains until the timer's webhook is shot.
+// Responds the lifecycle status of the timer and how much time remains until its webhook is shot. the latest
+// delivery attempts are responded as well when expand=attempt_history is given.
 //
 // Responses:
 //
@@ -67,20 +191,33 @@ func (h *Router) getTimer(w http.ResponseWriter, r *http.Request, p httprouter.P
 
 	var resp GetTimerResponse
 
-	t, err := h.service.GetTimer(r.Context(), timerID)
+	state, err := h.service.GetTimerState(r.Context(), timerID)
 	switch {
 	case err == timer.ErrTimerNotFound:
 		_ = NotFound(w, "timer does not exist")
 		return
 	case err == timer.ErrTimerArchived:
 		resp = getArchivedTimerResponse(timerID)
+	case err == timer.ErrTimerCancelled:
+		resp = getCancelledTimerResponse(timerID)
 	case err != nil:
 		log.WithError(err).Errorf("getTimers: service %s", err)
 		api500Count.With(prometheus.Labels{"method": "getTimers", "reason": "service"}).Inc()
 		_ = InternalError(w, "failed to get timers due to server internal error")
 		return
 	default:
-		resp = toGetTimersResponse(t)
+		resp = toGetTimerResponse(state)
+	}
+
+	if isExpanded(r, expandAttemptHistory) {
+		attempts, err := h.service.ListAttempts(r.Context(), timerID)
+		if err != nil {
+			log.WithError(err).Errorf("getTimer: service %s", err)
+			api500Count.With(prometheus.Labels{"method": "getTimer", "reason": "service"}).Inc()
+			_ = InternalError(w, "failed to get timer attempts due to server internal error")
+			return
+		}
+		resp.AttemptHistory = toAttemptsResponse(attempts)
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -91,3 +228,174 @@ func (h *Router) getTimer(w http.ResponseWriter, r *http.Request, p httprouter.P
 		return
 	}
 }
+
+// listAttempts is the handler for
+// swagger:route GET /timers/{timer_id}/attempts listAttemptsRequest
+//
+// Lists the latest delivery attempts of the timer's webhook, the latest first.
+//
+// Responses:
+//
+//	200: listAttempts
+//	404: notFoundError
+//	500: serverError
+func (h *Router) listAttempts(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	attempts, err := h.service.ListAttempts(r.Context(), p.ByName("id"))
+	switch {
+	case err == timer.ErrTimerNotFound:
+		_ = NotFound(w, "timer does not exist")
+		return
+	case err != nil:
+		log.WithError(err).Errorf("listAttempts: service %s", err)
+		api500Count.With(prometheus.Labels{"method": "listAttempts", "reason": "service"}).Inc()
+		_ = InternalError(w, "failed to list attempts due to server internal error")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(ListAttemptsResponse{Attempts: toAttemptsResponse(attempts)}); err != nil {
+		log.WithError(err).Errorf("listAttempts: encoder %s", err)
+		api500Count.With(prometheus.Labels{"method": "listAttempts", "reason": "encoder"}).Inc()
+		_ = InternalError(w, "cannot encode response")
+		return
+	}
+}
+
+// listTimers is the handler for
+// swagger:route GET /timers listTimersRequest
+//
+// Lists the scheduled timers ordered by their fire time.
+//
+// Responses:
+//
+//	200: listTimers
+//	422: invalidParams
+//	500: serverError
+func (h *Router) listTimers(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query, err := toListTimersQuery(r)
+	if err != nil {
+		_ = InvalidParams(w, fmt.Sprintf("invalid param: %v", err))
+		return
+	}
+
+	page, err := h.service.ListTimers(r.Context(), query)
+	switch {
+	case err == timer.ErrInvalidCursor:
+		_ = InvalidParams(w, "invalid param: cursor is invalid")
+		return
+	case err != nil:
+		log.WithError(err).Errorf("listTimers: service %s", err)
+		api500Count.With(prometheus.Labels{"method": "listTimers", "reason": "service"}).Inc()
+		_ = InternalError(w, "failed to list timers due to server internal error")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(toListTimersResponse(page)); err != nil {
+		log.WithError(err).Errorf("listTimers: encoder %s", err)
+		api500Count.With(prometheus.Labels{"method": "listTimers", "reason": "encoder"}).Inc()
+		_ = InternalError(w, "cannot encode response")
+		return
+	}
+}
+
+// rescheduleTimer is the handler for
+// swagger:route PATCH /timers/{timer_id} rescheduleTimerRequest
+//
+// Moves the fire time of a scheduled timer.
+//
+// Responses:
+//
+//	200: getTimer
+//	400: invalidRequestBody
+//	404: notFoundError
+//	409: conflictError
+//	422: invalidParams
+//	500: serverError
+func (h *Router) rescheduleTimer(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	timerID := p.ByName("id")
+	if timerID == "" {
+		_ = InvalidParams(w, "invalid param: id is empty")
+		return
+	}
+
+	command, err := toRescheduleTimerCommand(w, r, timerID)
+	if err != nil {
+		// toRescheduleTimerCommand responds with a proper error
+		return
+	}
+
+	t, err := h.service.RescheduleTimer(r.Context(), command)
+	switch {
+	case err == timer.ErrTimerNotFound:
+		_ = NotFound(w, "timer does not exist")
+		return
+	case err == timer.ErrTimerArchived:
+		_ = Conflict(w, "timer is already archived")
+		return
+	case err == timer.ErrTimerCancelled:
+		_ = Conflict(w, "timer is cancelled")
+		return
+	case err == timer.ErrTimerModified:
+		_ = Conflict(w, "timer is modified concurrently, try again")
+		return
+	case err == timer.ErrFireAtInPast:
+		_ = InvalidParams(w, "invalid param: the new fire time is in the past")
+		return
+	case err != nil:
+		log.WithError(err).Errorf("rescheduleTimer: service %s", err)
+		api500Count.With(prometheus.Labels{"method": "rescheduleTimer", "reason": "service"}).Inc()
+		_ = InternalError(w, "failed to reschedule timer due to server internal error")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(toGetTimerResponse(timer.NewTimerState(t, timer.StatePending))); err != nil {
+		log.WithError(err).Errorf("rescheduleTimer: encoder %s", err)
+		api500Count.With(prometheus.Labels{"method": "rescheduleTimer", "reason": "encoder"}).Inc()
+		_ = InternalError(w, "cannot encode response")
+		return
+	}
+}
+
+// cancelTimer is the handler for
+// swagger:route DELETE /timers/{timer_id} cancelTimerRequest
+//
+// Cancels a scheduled timer so that its webhook is never shot.
+//
+// Responses:
+//
+//	204: cancelTimer
+//	404: notFoundError
+//	409: conflictError
+//	422: invalidParams
+//	500: serverError
+func (h *Router) cancelTimer(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	timerID := p.ByName("id")
+	if timerID == "" {
+		_ = InvalidParams(w, "invalid param: id is empty")
+		return
+	}
+
+	err := h.service.CancelTimer(r.Context(), timerID)
+	switch {
+	case err == timer.ErrTimerNotFound:
+		_ = NotFound(w, "timer does not exist")
+		return
+	case err == timer.ErrTimerArchived:
+		_ = Conflict(w, "timer is already archived")
+		return
+	case err == timer.ErrTimerCancelled:
+		// cancelling is idempotent
+	case err == timer.ErrTimerModified:
+		_ = Conflict(w, "timer is modified concurrently, try again")
+		return
+	case err != nil:
+		log.WithError(err).Errorf("cancelTimer: service %s", err)
+		api500Count.With(prometheus.Labels{"method": "cancelTimer", "reason": "service"}).Inc()
+		_ = InternalError(w, "failed to cancel timer due to server internal error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,9 +2,12 @@ package api_test
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -24,6 +27,7 @@ type spec struct {
 	ExpectedBody   string
 	Method         string
 	Target         string
+	Headers        map[string]string
 	MockFn         func(s *mocks.Service)
 }
 
@@ -41,6 +45,9 @@ func (s *spec) HandlerTest(t *testing.T, h *api.Router) {
 	t.Helper()
 
 	req := httptest.NewRequest(s.Method, s.Target, strings.NewReader(s.ReqBody))
+	for name, value := range s.Headers {
+		req.Header.Set(name, value)
+	}
 
 	rec := httptest.NewRecorder()
 	h.ServeHTTP(rec, req)
@@ -104,6 +111,215 @@ func TestRouter_setTimer(t *testing.T) {
 			ExpectedBody:   `{"error":{"code":422, "details":"Invalid params - invalid param: invalid 'POST' field 'url'"}}`,
 			ExpectedStatus: http.StatusUnprocessableEntity,
 		},
+		{
+			Name:   "fire time in the past",
+			Method: http.MethodPost,
+			Target: "/timers",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CreateTimer(gomock.Any(), gomock.Any()).Return(nil, timer.ErrFireAtInPast)
+			},
+			ReqBody:        `{"url":"http://valid.url","hours":-1,"minutes":0,"seconds":0}`,
+			ExpectedBody:   `{"error":{"code":422, "details":"Invalid params - invalid param: the fire time is in the past"}}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "destination is not allowed",
+			Method: http.MethodPost,
+			Target: "/timers",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CreateTimer(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("%w: address 169.254.169.254 is not public", timer.ErrDestinationNotAllowed))
+			},
+			ReqBody:        `{"url":"http://169.254.169.254","seconds":1}`,
+			ExpectedBody:   `{"error":{"code":422, "details":"Invalid params - invalid param: destination is not allowed: address 169.254.169.254 is not public"}}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "ok with delay",
+			Method: http.MethodPost,
+			Target: "/timers",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CreateTimer(gomock.Any(), timer.SetTimerCommand{URLRaw: "http://valid.url", Delay: time.Hour}).Return(&timer.Timer{
+					ID: "1",
+				}, nil)
+			},
+			ReqBody:        `{"url":"http://valid.url","delay":"PT1H"}`,
+			ExpectedBody:   `{"id":"1"}`,
+			ExpectedStatus: http.StatusCreated,
+		},
+		{
+			Name:   "ok with webhook",
+			Method: http.MethodPost,
+			Target: "/timers",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CreateTimer(gomock.Any(), timer.SetTimerCommand{
+					URLRaw:      "http://valid.url",
+					Method:      http.MethodPut,
+					Headers:     map[string]string{"X-Order-ID": "42"},
+					Body:        []byte(`{"order_id":42}`),
+					ContentType: "application/json",
+				}).Return(&timer.Timer{
+					ID: "1",
+				}, nil)
+			},
+			ReqBody:        `{"url":"http://valid.url","method":"PUT","headers":{"X-Order-ID":"42"},"body":{"order_id":42}}`,
+			ExpectedBody:   `{"id":"1"}`,
+			ExpectedStatus: http.StatusCreated,
+		},
+		{
+			Name:   "ok with raw body",
+			Method: http.MethodPost,
+			Target: "/timers",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CreateTimer(gomock.Any(), timer.SetTimerCommand{
+					URLRaw:      "http://valid.url",
+					Body:        []byte("hello"),
+					ContentType: "text/plain",
+				}).Return(&timer.Timer{
+					ID: "1",
+				}, nil)
+			},
+			ReqBody:        `{"url":"http://valid.url","body_base64":"aGVsbG8=","content_type":"text/plain"}`,
+			ExpectedBody:   `{"id":"1"}`,
+			ExpectedStatus: http.StatusCreated,
+		},
+		{
+			Name:   "ok with timeout",
+			Method: http.MethodPost,
+			Target: "/timers",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CreateTimer(gomock.Any(), timer.SetTimerCommand{
+					URLRaw:  "http://valid.url",
+					Timeout: 10 * time.Second,
+				}).Return(&timer.Timer{
+					ID: "1",
+				}, nil)
+			},
+			ReqBody:        `{"url":"http://valid.url","timeout":"10s"}`,
+			ExpectedBody:   `{"id":"1"}`,
+			ExpectedStatus: http.StatusCreated,
+		},
+		{
+			Name:   "ok with success criteria",
+			Method: http.MethodPost,
+			Target: "/timers",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CreateTimer(gomock.Any(), timer.SetTimerCommand{
+					URLRaw: "http://valid.url",
+					SuccessCriteria: &timer.SuccessCriteria{
+						AcceptedStatusCodes: []string{"2xx", "410"},
+						JSONPath:            "status",
+						JSONValue:           "ok",
+					},
+				}).Return(&timer.Timer{
+					ID: "1",
+				}, nil)
+			},
+			ReqBody:        `{"url":"http://valid.url","success_criteria":{"accepted_status_codes":["2xx","410"],"json_path":"status","json_value":"ok"}}`,
+			ExpectedBody:   `{"id":"1"}`,
+			ExpectedStatus: http.StatusCreated,
+		},
+		{
+			Name:   "ok without appending the ID",
+			Method: http.MethodPost,
+			Target: "/timers",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CreateTimer(gomock.Any(), timer.SetTimerCommand{URLRaw: "http://valid.url", DisableIDAppend: true}).Return(&timer.Timer{
+					ID: "1",
+				}, nil)
+			},
+			ReqBody:        `{"url":"http://valid.url","append_id":false}`,
+			ExpectedBody:   `{"id":"1"}`,
+			ExpectedStatus: http.StatusCreated,
+		},
+		{
+			Name:   "ok with url template",
+			Method: http.MethodPost,
+			Target: "/timers",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CreateTimer(gomock.Any(), timer.SetTimerCommand{URLTemplate: "http://valid.url/hooks?timer={{.ID}}"}).Return(&timer.Timer{
+					ID: "1",
+				}, nil)
+			},
+			ReqBody:        `{"url_template":"http://valid.url/hooks?timer={{.ID}}"}`,
+			ExpectedBody:   `{"id":"1"}`,
+			ExpectedStatus: http.StatusCreated,
+		},
+		{
+			Name:    "idempotent replay",
+			Method:  http.MethodPost,
+			Target:  "/timers",
+			Headers: map[string]string{"Idempotency-Key": "order-42"},
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CreateTimer(gomock.Any(), timer.SetTimerCommand{URLRaw: "http://valid.url", IdempotencyKey: "order-42"}).Return(&timer.Timer{
+					ID: "1",
+				}, timer.ErrTimerAlreadyCreated)
+			},
+			ReqBody:        `{"url":"http://valid.url"}`,
+			ExpectedBody:   `{"id":"1"}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:           "invalid idempotency key",
+			Method:         http.MethodPost,
+			Target:         "/timers",
+			Headers:        map[string]string{"Idempotency-Key": "order 42"},
+			MockFn:         func(s *mocks.Service) {},
+			ReqBody:        `{"url":"http://valid.url"}`,
+			ExpectedBody:   `{"error":{"code":422, "details":"Invalid params - invalid param: the Idempotency-Key header must only contain visible ASCII characters"}}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:           "invalid header value",
+			Method:         http.MethodPost,
+			Target:         "/timers",
+			MockFn:         func(s *mocks.Service) {},
+			ReqBody:        `{"url":"http://valid.url","headers":{"X-Order-ID":"42\r\nX-Injected: 1"}}`,
+			ExpectedBody:   `{"error":{"code":422, "details":"Invalid params - invalid param: invalid value of header \"X-Order-ID\""}}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "ok with id",
+			Method: http.MethodPost,
+			Target: "/timers",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CreateTimer(gomock.Any(), timer.SetTimerCommand{ID: "order-42", URLRaw: "http://valid.url"}).Return(&timer.Timer{
+					ID: "order-42",
+				}, nil)
+			},
+			ReqBody:        `{"id":"order-42","url":"http://valid.url"}`,
+			ExpectedBody:   `{"id":"order-42"}`,
+			ExpectedStatus: http.StatusCreated,
+		},
+		{
+			Name:   "id already exists",
+			Method: http.MethodPost,
+			Target: "/timers",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CreateTimer(gomock.Any(), timer.SetTimerCommand{ID: "order-42", URLRaw: "http://valid.url"}).Return(nil, timer.ErrTimerExists)
+			},
+			ReqBody:        `{"id":"order-42","url":"http://valid.url"}`,
+			ExpectedBody:   `{"error":{"code":409, "details":"Conflict - a timer with the id already exists"}}`,
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			Name:           "invalid id",
+			Method:         http.MethodPost,
+			Target:         "/timers",
+			MockFn:         func(s *mocks.Service) {},
+			ReqBody:        `{"id":"order/42","url":"http://valid.url"}`,
+			ExpectedBody:   `{"error":{"code":422, "details":"Invalid params - invalid param: invalid 'POST' field 'id'"}}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:           "more than one schedule form",
+			Method:         http.MethodPost,
+			MockFn:         func(s *mocks.Service) {},
+			Target:         "/timers",
+			ReqBody:        `{"url":"http://valid.url","seconds":1,"delay":"PT1H"}`,
+			ExpectedBody:   `{"error":{"code":422, "details":"Invalid params - invalid param: only one of 'hours/minutes/seconds', 'fire_at', 'delay' or 'cron' can be set"}}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
 		{
 			Name:           "empty body",
 			Method:         http.MethodPost,
@@ -120,10 +336,101 @@ func TestRouter_setTimer(t *testing.T) {
 	}
 }
 
+func TestRouter_batchSetTimers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := mocks.NewService(ctrl)
+
+	specs := []spec{
+		{
+			Name:    "array",
+			Method:  http.MethodPost,
+			Target:  "/timers:batch",
+			ReqBody: `[{"url":"http://valid.url","seconds":1}, {"url":"invalid.url"}, {"url":"http://valid.url","seconds":"1"}, {"url":"http://valid.url","seconds":2}]`,
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CreateTimers(gomock.Any(), []timer.SetTimerCommand{
+					{URLRaw: "http://valid.url", Seconds: 1},
+					{URLRaw: "http://valid.url", Seconds: 2},
+				}).Return([]timer.CreateTimerResult{
+					{Timer: &timer.Timer{ID: "1"}},
+					{Err: assert.AnError},
+				})
+			},
+			ExpectedBody: `{"created":1, "failed":3, "results":[
+				{"index":0, "id":"1"},
+				{"index":1, "error":{"code":422, "details":"Invalid params - invalid param: invalid 'POST' field 'url'"}},
+				{"index":2, "error":{"code":400, "details":"Bad Request - bad timer payload"}},
+				{"index":3, "error":{"code":500, "details":"Internal error - failed to set timer due to server internal error"}}
+			]}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:    "ndjson",
+			Method:  http.MethodPost,
+			Target:  "/timers:batch",
+			Headers: map[string]string{"Content-Type": "application/x-ndjson"},
+			ReqBody: "{\"url\":\"http://valid.url\",\"seconds\":1}\n{\"url\":\"http://valid.url\",\"seconds\":2}\n",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CreateTimers(gomock.Any(), []timer.SetTimerCommand{
+					{URLRaw: "http://valid.url", Seconds: 1},
+					{URLRaw: "http://valid.url", Seconds: 2},
+				}).Return([]timer.CreateTimerResult{
+					{Timer: &timer.Timer{ID: "1"}},
+					{Err: timer.ErrFireAtInPast},
+				})
+			},
+			ExpectedBody: `{"created":1, "failed":1, "results":[
+				{"index":0, "id":"1"},
+				{"index":1, "error":{"code":422, "details":"Invalid params - invalid param: the fire time is in the past"}}
+			]}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:           "not an array",
+			Method:         http.MethodPost,
+			Target:         "/timers:batch",
+			ReqBody:        `{"url":"http://valid.url"}`,
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedBody:   `{"error":{"code":400, "details":"Bad Request - cannot set timers, expected a JSON array of timers"}}`,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			Name:           "malformed item",
+			Method:         http.MethodPost,
+			Target:         "/timers:batch",
+			ReqBody:        `[{"url":"http://valid.url"}, {"url":]`,
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedBody:   `{"error":{"code":400, "details":"Bad Request - cannot set timers, timer 1 is malformed"}}`,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			Name:           "empty batch",
+			Method:         http.MethodPost,
+			Target:         "/timers:batch",
+			ReqBody:        `[]`,
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedBody:   `{"error":{"code":422, "details":"Invalid params - invalid param: the batch is empty"}}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:           "unknown action",
+			Method:         http.MethodPost,
+			Target:         "/timers:import",
+			ReqBody:        `[]`,
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedStatus: http.StatusNotFound,
+		},
+	}
+	for _, s := range specs {
+		t.Run(s.Name, s.execHTTPTestCases(service))
+	}
+}
+
 func TestRouter_getTimer(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	service := mocks.NewService(ctrl)
 	now := time.Now()
+	fireAt := now.Add(2 * time.Second)
+	createdAt := now.Add(-time.Hour)
 
 	specs := []spec{
 		{
@@ -131,12 +438,81 @@ func TestRouter_getTimer(t *testing.T) {
 			Method: http.MethodGet,
 			Target: "/timers/1",
 			MockFn: func(s *mocks.Service) {
-				s.EXPECT().GetTimer(gomock.Any(), "1").Return(&timer.Timer{
+				s.EXPECT().GetTimerState(gomock.Any(), "1").Return(timer.NewTimerState(&timer.Timer{
+					ID:        "1",
+					FireAt:    fireAt,
+					CreatedAt: createdAt,
+				}, timer.StatePending), nil)
+			},
+			ExpectedBody: fmt.Sprintf(`{"ID":"1", "time_left":1, "status":"pending", "fire_at":"%s", "created_at":"%s", "attempts":0}`,
+				fireAt.Format(time.RFC3339), createdAt.Format(time.RFC3339)),
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:   "retrying",
+			Method: http.MethodGet,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().GetTimerState(gomock.Any(), "1").Return(timer.NewTimerState(&timer.Timer{
+					ID:        "1",
+					FireAt:    fireAt,
+					CreatedAt: createdAt,
+				}, timer.StateRetrying).WithAttempt(2, errors.New("unexpected HTTP status 503")), nil)
+			},
+			ExpectedBody: fmt.Sprintf(`{"ID":"1", "time_left":1, "status":"retrying", "fire_at":"%s", "created_at":"%s", "attempts":2, "last_error":"unexpected HTTP status 503"}`,
+				fireAt.Format(time.RFC3339), createdAt.Format(time.RFC3339)),
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:   "recurring",
+			Method: http.MethodGet,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().GetTimerState(gomock.Any(), "1").Return(timer.NewTimerState(&timer.Timer{
 					ID:     "1",
-					FireAt: now.Add(2 * time.Second),
+					FireAt: fireAt,
+					Recurrence: &timer.Recurrence{
+						Cron:     "0 9 * * 1-5",
+						Timezone: "Europe/Berlin",
+						MaxRuns:  10,
+						Runs:     3,
+					},
+				}, timer.StateRelayed), nil)
+			},
+			ExpectedBody: fmt.Sprintf(`{"ID":"1", "time_left":1, "status":"relayed", "fire_at":"%[1]s", "attempts":0, "recurrence":{"cron":"0 9 * * 1-5", "timezone":"Europe/Berlin", "next_fire_at":"%[1]s", "runs":3, "max_runs":10}}`,
+				fireAt.Format(time.RFC3339)),
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:   "succeeded",
+			Method: http.MethodGet,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().GetTimerState(gomock.Any(), "1").Return(&timer.TimerState{
+					TimerID:   "1",
+					State:     timer.StateSucceeded,
+					FireAt:    fireAt,
+					CreatedAt: createdAt,
+					Attempts:  1,
 				}, nil)
 			},
-			ExpectedBody:   `{"ID":"1", "time_left":1}`,
+			ExpectedBody: fmt.Sprintf(`{"ID":"1", "time_left":0, "status":"succeeded", "fire_at":"%s", "created_at":"%s", "attempts":1}`,
+				fireAt.Format(time.RFC3339), createdAt.Format(time.RFC3339)),
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:   "outcome of an archived timer by its tombstone",
+			Method: http.MethodGet,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().GetTimerState(gomock.Any(), "1").Return(&timer.TimerState{
+					TimerID:    "1",
+					State:      timer.StateFailed,
+					FinishedAt: fireAt,
+				}, nil)
+			},
+			ExpectedBody: fmt.Sprintf(`{"ID":"1", "time_left":0, "status":"failed", "attempts":0, "finished_at":"%s"}`,
+				fireAt.Format(time.RFC3339)),
 			ExpectedStatus: http.StatusOK,
 		},
 		{
@@ -144,19 +520,29 @@ func TestRouter_getTimer(t *testing.T) {
 			Method: http.MethodGet,
 			Target: "/timers/1",
 			MockFn: func(s *mocks.Service) {
-				s.EXPECT().GetTimer(gomock.Any(), "1").Return(nil, timer.ErrTimerNotFound)
+				s.EXPECT().GetTimerState(gomock.Any(), "1").Return(nil, timer.ErrTimerNotFound)
 			},
 			ExpectedBody:   `{"error":{"code":404, "details":"Not found - timer does not exist"}}`,
 			ExpectedStatus: http.StatusNotFound,
 		},
 		{
-			Name:   "timer is archived",
+			Name:   "timer is archived and its state is unknown",
+			Method: http.MethodGet,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().GetTimerState(gomock.Any(), "1").Return(nil, timer.ErrTimerArchived)
+			},
+			ExpectedBody:   `{"ID":"1", "time_left":0, "attempts":0}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:   "timer is cancelled and its state is unknown",
 			Method: http.MethodGet,
 			Target: "/timers/1",
 			MockFn: func(s *mocks.Service) {
-				s.EXPECT().GetTimer(gomock.Any(), "1").Return(nil, timer.ErrTimerArchived)
+				s.EXPECT().GetTimerState(gomock.Any(), "1").Return(nil, timer.ErrTimerCancelled)
 			},
-			ExpectedBody:   `{"ID":"1", "time_left":0}`,
+			ExpectedBody:   `{"ID":"1", "time_left":0, "status":"cancelled", "attempts":0}`,
 			ExpectedStatus: http.StatusOK,
 		},
 		{
@@ -164,11 +550,382 @@ func TestRouter_getTimer(t *testing.T) {
 			Method: http.MethodGet,
 			Target: "/timers/1",
 			MockFn: func(s *mocks.Service) {
-				s.EXPECT().GetTimer(gomock.Any(), "1").Return(nil, assert.AnError)
+				s.EXPECT().GetTimerState(gomock.Any(), "1").Return(nil, assert.AnError)
 			},
 			ExpectedBody:   `{"error":{"code":500, "details":"Internal error - failed to get timers due to server internal error"}}`,
 			ExpectedStatus: http.StatusInternalServerError,
 		},
+		{
+			Name:   "expand attempt history",
+			Method: http.MethodGet,
+			Target: "/timers/1?expand=attempt_history",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().GetTimerState(gomock.Any(), "1").Return(nil, timer.ErrTimerArchived)
+				s.EXPECT().ListAttempts(gomock.Any(), "1").Return([]*timer.Attempt{
+					{Run: 1, Number: 1, StartedAt: attemptStartedAt, Duration: 120 * time.Millisecond, StatusCode: http.StatusOK},
+				}, nil)
+			},
+			ExpectedBody:   `{"ID":"1", "time_left":0, "attempts":0, "attempt_history":[{"run":1, "attempt":1, "started_at":"2026-10-18T07:30:00.5Z", "duration_ms":120, "status_code":200}]}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:   "expand attempt history service unknown error",
+			Method: http.MethodGet,
+			Target: "/timers/1?expand=attempt_history",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().GetTimerState(gomock.Any(), "1").Return(nil, timer.ErrTimerArchived)
+				s.EXPECT().ListAttempts(gomock.Any(), "1").Return(nil, assert.AnError)
+			},
+			ExpectedBody:   `{"error":{"code":500, "details":"Internal error - failed to get timer attempts due to server internal error"}}`,
+			ExpectedStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, s := range specs {
+		t.Run(s.Name, s.execHTTPTestCases(service))
+	}
+}
+
+var attemptStartedAt = time.Date(2026, 10, 18, 7, 30, 0, 500*int(time.Millisecond), time.UTC)
+
+func TestRouter_listAttempts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := mocks.NewService(ctrl)
+
+	specs := []spec{
+		{
+			Name:   "ok",
+			Method: http.MethodGet,
+			Target: "/timers/1/attempts",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().ListAttempts(gomock.Any(), "1").Return([]*timer.Attempt{
+					{
+						Run:        1,
+						Number:     2,
+						StartedAt:  attemptStartedAt,
+						Duration:   3 * time.Second,
+						Outcome:    timer.OutcomeRetry,
+						ErrorClass: timer.AttemptErrorTimeout,
+						Error:      "context deadline exceeded",
+						RetryAt:    attemptStartedAt.Add(time.Minute),
+					},
+					{Run: 1, Number: 1, StartedAt: attemptStartedAt.Add(-time.Minute), StatusCode: http.StatusServiceUnavailable, ErrorClass: timer.AttemptErrorHTTPStatus, Error: "unexpected HTTP status 503"},
+				}, nil)
+			},
+			ExpectedBody: `{"attempts":[
+				{"run":1, "attempt":2, "started_at":"2026-10-18T07:30:00.5Z", "duration_ms":3000, "outcome":"retry", "error_class":"timeout", "error":"context deadline exceeded", "retry_at":"2026-10-18T07:31:00Z"},
+				{"run":1, "attempt":1, "started_at":"2026-10-18T07:29:00.5Z", "duration_ms":0, "status_code":503, "error_class":"http_status", "error":"unexpected HTTP status 503"}
+			]}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:   "no attempts yet",
+			Method: http.MethodGet,
+			Target: "/timers/1/attempts",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().ListAttempts(gomock.Any(), "1").Return([]*timer.Attempt{}, nil)
+			},
+			ExpectedBody:   `{"attempts":[]}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:   "not found",
+			Method: http.MethodGet,
+			Target: "/timers/1/attempts",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().ListAttempts(gomock.Any(), "1").Return(nil, timer.ErrTimerNotFound)
+			},
+			ExpectedBody:   `{"error":{"code":404, "details":"Not found - timer does not exist"}}`,
+			ExpectedStatus: http.StatusNotFound,
+		},
+		{
+			Name:   "service unknown error",
+			Method: http.MethodGet,
+			Target: "/timers/1/attempts",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().ListAttempts(gomock.Any(), "1").Return(nil, assert.AnError)
+			},
+			ExpectedBody:   `{"error":{"code":500, "details":"Internal error - failed to list attempts due to server internal error"}}`,
+			ExpectedStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, s := range specs {
+		t.Run(s.Name, s.execHTTPTestCases(service))
+	}
+}
+
+func TestRouter_listTimers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := mocks.NewService(ctrl)
+	now := time.Now()
+	fireAt := now.Add(2 * time.Second)
+
+	specs := []spec{
+		{
+			Name:   "ok",
+			Method: http.MethodGet,
+			Target: "/timers?status=relayed&due=false&host=valid.url&label=team:payments&limit=1",
+			MockFn: func(s *mocks.Service) {
+				due := false
+				s.EXPECT().ListTimers(gomock.Any(), timer.ListTimersQuery{
+					Status: timer.StateRelayed,
+					Due:    &due,
+					Host:   "valid.url",
+					Labels: map[string]string{"team": "payments"},
+					Limit:  1,
+				}).Return(&timer.TimersPage{
+					Timers: []*timer.TimerState{{
+						TimerID: "1",
+						State:   timer.StateRelayed,
+						Timer: &timer.Timer{
+							ID:     "1",
+							URL:    url.URL{Scheme: "http", Host: "valid.url", Path: "/1"},
+							FireAt: fireAt,
+							Labels: map[string]string{"team": "payments"},
+						},
+					}},
+					NextCursor: "next",
+				}, nil)
+			},
+			ExpectedBody: fmt.Sprintf(`{"timers":[{"id":"1", "url":"http://valid.url/1", "status":"relayed", "fire_at":"%s", "time_left":1, "labels":{"team":"payments"}}], "next_cursor":"next"}`,
+				fireAt.Format(time.RFC3339)),
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:   "empty page",
+			Method: http.MethodGet,
+			Target: "/timers",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().ListTimers(gomock.Any(), timer.ListTimersQuery{}).Return(&timer.TimersPage{}, nil)
+			},
+			ExpectedBody:   `{"timers":[]}`,
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:           "invalid status",
+			Method:         http.MethodGet,
+			Target:         "/timers?status=succeeded",
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedBody:   `{"error":{"code":422, "details":"Invalid params - invalid param: invalid query param 'status', expected one of \"pending\", \"relayed\", \"in-flight\" and \"retrying\""}}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:           "invalid due",
+			Method:         http.MethodGet,
+			Target:         "/timers?due=soon",
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedBody:   `{"error":{"code":422, "details":"Invalid params - invalid param: invalid query param 'due', expected true or false"}}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:           "invalid limit",
+			Method:         http.MethodGet,
+			Target:         "/timers?limit=501",
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:           "invalid from",
+			Method:         http.MethodGet,
+			Target:         "/timers?from=yesterday",
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:           "invalid label",
+			Method:         http.MethodGet,
+			Target:         "/timers?label=team",
+			MockFn:         func(s *mocks.Service) {},
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "invalid cursor",
+			Method: http.MethodGet,
+			Target: "/timers?cursor=x",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().ListTimers(gomock.Any(), timer.ListTimersQuery{Cursor: "x"}).Return(nil, timer.ErrInvalidCursor)
+			},
+			ExpectedBody:   `{"error":{"code":422, "details":"Invalid params - invalid param: cursor is invalid"}}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "service unknown error",
+			Method: http.MethodGet,
+			Target: "/timers",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().ListTimers(gomock.Any(), gomock.Any()).Return(nil, assert.AnError)
+			},
+			ExpectedBody:   `{"error":{"code":500, "details":"Internal error - failed to list timers due to server internal error"}}`,
+			ExpectedStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, s := range specs {
+		t.Run(s.Name, s.execHTTPTestCases(service))
+	}
+}
+
+func TestRouter_rescheduleTimer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := mocks.NewService(ctrl)
+	now := time.Now()
+
+	specs := []spec{
+		{
+			Name:   "ok",
+			Method: http.MethodPatch,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().RescheduleTimer(gomock.Any(), timer.RescheduleTimerCommand{TimerID: "1", Seconds: 2}).Return(&timer.Timer{
+					ID:       "1",
+					FireAt:   now.Add(2 * time.Second),
+					Revision: 1,
+				}, nil)
+			},
+			ReqBody:        `{"hours":0,"minutes":0,"seconds":2}`,
+			ExpectedBody:   fmt.Sprintf(`{"ID":"1", "time_left":1, "status":"pending", "fire_at":"%s", "attempts":0}`, now.Add(2*time.Second).Format(time.RFC3339)),
+			ExpectedStatus: http.StatusOK,
+		},
+		{
+			Name:           "empty body",
+			Method:         http.MethodPatch,
+			Target:         "/timers/1",
+			MockFn:         func(s *mocks.Service) {},
+			ReqBody:        ``,
+			ExpectedBody:   `{"error":{"code":400, "details":"Bad Request - cannot reschedule timer, bad request payload"}}`,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			Name:   "not found",
+			Method: http.MethodPatch,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().RescheduleTimer(gomock.Any(), gomock.Any()).Return(nil, timer.ErrTimerNotFound)
+			},
+			ReqBody:        `{"seconds":2}`,
+			ExpectedBody:   `{"error":{"code":404, "details":"Not found - timer does not exist"}}`,
+			ExpectedStatus: http.StatusNotFound,
+		},
+		{
+			Name:   "timer is archived",
+			Method: http.MethodPatch,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().RescheduleTimer(gomock.Any(), gomock.Any()).Return(nil, timer.ErrTimerArchived)
+			},
+			ReqBody:        `{"seconds":2}`,
+			ExpectedBody:   `{"error":{"code":409, "details":"Conflict - timer is already archived"}}`,
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			Name:   "timer is rescheduled concurrently",
+			Method: http.MethodPatch,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().RescheduleTimer(gomock.Any(), gomock.Any()).Return(nil, timer.ErrTimerModified)
+			},
+			ReqBody:        `{"seconds":2}`,
+			ExpectedBody:   `{"error":{"code":409, "details":"Conflict - timer is modified concurrently, try again"}}`,
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			Name:           "too large body",
+			Method:         http.MethodPatch,
+			Target:         "/timers/1",
+			MockFn:         func(s *mocks.Service) {},
+			ReqBody:        `{"seconds":2,"padding":"` + strings.Repeat("a", 4<<10) + `"}`,
+			ExpectedBody:   `{"error":{"code":400, "details":"Bad Request - cannot reschedule timer, bad request payload"}}`,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		{
+			Name:   "fire time in the past",
+			Method: http.MethodPatch,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().RescheduleTimer(gomock.Any(), gomock.Any()).Return(nil, timer.ErrFireAtInPast)
+			},
+			ReqBody:        `{"seconds":-2}`,
+			ExpectedBody:   `{"error":{"code":422, "details":"Invalid params - invalid param: the new fire time is in the past"}}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "service unknown error",
+			Method: http.MethodPatch,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().RescheduleTimer(gomock.Any(), gomock.Any()).Return(nil, assert.AnError)
+			},
+			ReqBody:        `{"seconds":2}`,
+			ExpectedBody:   `{"error":{"code":500, "details":"Internal error - failed to reschedule timer due to server internal error"}}`,
+			ExpectedStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, s := range specs {
+		t.Run(s.Name, s.execHTTPTestCases(service))
+	}
+}
+
+func TestRouter_cancelTimer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := mocks.NewService(ctrl)
+
+	specs := []spec{
+		{
+			Name:   "ok",
+			Method: http.MethodDelete,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CancelTimer(gomock.Any(), "1").Return(nil)
+			},
+			ExpectedStatus: http.StatusNoContent,
+		},
+		{
+			Name:   "already cancelled",
+			Method: http.MethodDelete,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CancelTimer(gomock.Any(), "1").Return(timer.ErrTimerCancelled)
+			},
+			ExpectedStatus: http.StatusNoContent,
+		},
+		{
+			Name:   "not found",
+			Method: http.MethodDelete,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CancelTimer(gomock.Any(), "1").Return(timer.ErrTimerNotFound)
+			},
+			ExpectedBody:   `{"error":{"code":404, "details":"Not found - timer does not exist"}}`,
+			ExpectedStatus: http.StatusNotFound,
+		},
+		{
+			Name:   "timer is archived",
+			Method: http.MethodDelete,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CancelTimer(gomock.Any(), "1").Return(timer.ErrTimerArchived)
+			},
+			ExpectedBody:   `{"error":{"code":409, "details":"Conflict - timer is already archived"}}`,
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			Name:   "timer is created again",
+			Method: http.MethodDelete,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CancelTimer(gomock.Any(), "1").Return(timer.ErrTimerModified)
+			},
+			ExpectedBody:   `{"error":{"code":409, "details":"Conflict - timer is modified concurrently, try again"}}`,
+			ExpectedStatus: http.StatusConflict,
+		},
+		{
+			Name:   "service unknown error",
+			Method: http.MethodDelete,
+			Target: "/timers/1",
+			MockFn: func(s *mocks.Service) {
+				s.EXPECT().CancelTimer(gomock.Any(), "1").Return(assert.AnError)
+			},
+			ExpectedBody:   `{"error":{"code":500, "details":"Internal error - failed to cancel timer due to server internal error"}}`,
+			ExpectedStatus: http.StatusInternalServerError,
+		},
 	}
 	for _, s := range specs {
 		t.Run(s.Name, s.execHTTPTestCases(service))
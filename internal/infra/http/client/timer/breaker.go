@@ -0,0 +1,174 @@
+package timer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/cubny/httpqueue/internal/config"
+)
+
+const (
+	// breakerKeyFmt holds the circuit breaker of a destination host.
+	breakerKeyFmt = "webhookBreaker:%s"
+	// breakerTTL is how long the breaker of a host that is not called anymore is kept.
+	breakerTTL = 24 * time.Hour
+)
+
+// BreakerState is the state of the circuit breaker of a destination host.
+type BreakerState int
+
+const (
+	// BreakerClosed lets the requests through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails the requests fast, without sending them.
+	BreakerOpen
+	// BreakerHalfOpen lets a few probe requests through to find out whether the host has recovered.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker is a circuit breaker per destination host. the breakers are kept in Redis so that all the workers share
+// them.
+//
+// a breaker opens after a number of consecutive retryable failures of its host, and fails the requests to the host
+// fast for the open duration. after that it is half-open and lets a few probe requests through per open duration.
+// a successful probe closes the breaker and a failed one opens it again.
+type Breaker struct {
+	redisClient      redis.UniversalClient
+	failureThreshold int
+	failureWindow    time.Duration
+	openDuration     time.Duration
+	probes           int
+}
+
+// NewBreaker constructs a Breaker.
+func NewBreaker(redisClient redis.UniversalClient, cfg *config.Webhook) (*Breaker, error) {
+	switch {
+	case redisClient == nil:
+		return nil, errors.New("redisClient is not set up")
+	case cfg.BreakerFailureThreshold <= 0:
+		return nil, errors.New("breaker failure threshold must be positive")
+	case cfg.BreakerFailureWindow <= 0:
+		return nil, errors.New("breaker failure window must be positive")
+	case cfg.BreakerOpenDuration <= 0:
+		return nil, errors.New("breaker open duration must be positive")
+	case cfg.BreakerProbes <= 0:
+		return nil, errors.New("breaker probes must be positive")
+	}
+
+	return &Breaker{
+		redisClient:      redisClient,
+		failureThreshold: cfg.BreakerFailureThreshold,
+		failureWindow:    cfg.BreakerFailureWindow,
+		openDuration:     cfg.BreakerOpenDuration,
+		probes:           cfg.BreakerProbes,
+	}, nil
+}
+
+// Allow tells whether a request to the host can be sent. it returns the state of the breaker, which is
+// BreakerHalfOpen when the request is a probe. when the breaker is open it returns how long the requests are failed
+// fast.
+func (b *Breaker) Allow(ctx context.Context, host string, now time.Time) (BreakerState, time.Duration, error) {
+	res, err := allowBreakerScript.Run(ctx, b.redisClient, []string{breakerKey(host)},
+		now.UnixMilli(), b.probes, b.openDuration.Milliseconds(), breakerTTL.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return BreakerClosed, 0, err
+	}
+
+	if len(res) != 2 {
+		return BreakerClosed, 0, fmt.Errorf("unexpected breaker reply %v", res)
+	}
+
+	return BreakerState(res[0]), time.Duration(res[1]) * time.Millisecond, nil
+}
+
+// Record keeps the outcome of a request to the host that was let through, and returns the state of the breaker
+// after that, i.e. either BreakerClosed or BreakerOpen.
+func (b *Breaker) Record(ctx context.Context, host string, failed bool, now time.Time) (BreakerState, error) {
+	state, err := recordBreakerScript.Run(ctx, b.redisClient, []string{breakerKey(host)},
+		now.UnixMilli(), failed, b.failureThreshold, b.openDuration.Milliseconds(),
+		b.failureWindow.Milliseconds(), breakerTTL.Milliseconds(),
+	).Int64()
+	if err != nil {
+		return BreakerClosed, err
+	}
+
+	return BreakerState(state), nil
+}
+
+func breakerKey(host string) string {
+	return fmt.Sprintf(breakerKeyFmt, host)
+}
+
+// the breaker of a host is a hash of the number of the consecutive failures (f) while it is closed, the time it is
+// open until (o), and the number of the probes (p) in the current probe window that ends at (w) while it is
+// half-open. a closed breaker without failures does not exist.
+
+// allowBreakerScript tells whether a request can be sent. it returns the state of the breaker, along with how long
+// the requests are failed fast when the breaker is open.
+//
+// KEYS: breaker
+// ARGV: now (ms), probes, open duration (ms), TTL (ms)
+var allowBreakerScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local openUntil = tonumber(redis.call('HGET', KEYS[1], 'o') or '0')
+if openUntil == 0 then
+	return {0, 0}
+end
+if now < openUntil then
+	return {1, openUntil - now}
+end
+local window = tonumber(redis.call('HGET', KEYS[1], 'w') or '0')
+if now >= window then
+	window = now + tonumber(ARGV[3])
+	redis.call('HSET', KEYS[1], 'p', 0, 'w', window)
+	redis.call('PEXPIRE', KEYS[1], ARGV[4])
+end
+if redis.call('HINCRBY', KEYS[1], 'p', 1) <= tonumber(ARGV[2]) then
+	return {2, 0}
+end
+return {1, window - now}
+`)
+
+// recordBreakerScript keeps the outcome of a request. it returns the state of the breaker after that, 0 when it is
+// closed and 1 when it is open. the outcomes of the requests that were sent before the breaker opened are ignored.
+//
+// KEYS: breaker
+// ARGV: now (ms), failed, failure threshold, open duration (ms), failure window (ms), TTL (ms)
+var recordBreakerScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local openUntil = tonumber(redis.call('HGET', KEYS[1], 'o') or '0')
+if openUntil > now then
+	return 1
+end
+if ARGV[2] ~= '1' then
+	redis.call('DEL', KEYS[1])
+	return 0
+end
+if openUntil == 0 then
+	local failures = redis.call('HINCRBY', KEYS[1], 'f', 1)
+	if failures < tonumber(ARGV[3]) then
+		redis.call('PEXPIRE', KEYS[1], ARGV[5])
+		return 0
+	end
+end
+redis.call('DEL', KEYS[1])
+redis.call('HSET', KEYS[1], 'o', now + tonumber(ARGV[4]))
+redis.call('PEXPIRE', KEYS[1], ARGV[6])
+return 1
+`)
@@ -0,0 +1,162 @@
+package timer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubny/httpqueue/internal/config"
+	mocks "github.com/cubny/httpqueue/internal/mocks/external/redis"
+)
+
+func newBreakerConfig() *config.Webhook {
+	return &config.Webhook{
+		BreakerFailureThreshold: 5,
+		BreakerFailureWindow:    time.Minute,
+		BreakerOpenDuration:     30 * time.Second,
+		BreakerProbes:           1,
+		// the tests call the servers on the loopback
+		AllowPrivateDestinations: true,
+	}
+}
+
+func TestNewBreaker(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	redisClient := mocks.NewRedisClient(ctrl)
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *config.Webhook)
+		wantErr bool
+	}{
+		{name: "valid config", modify: func(cfg *config.Webhook) {}},
+		{name: "zero failure threshold", modify: func(cfg *config.Webhook) { cfg.BreakerFailureThreshold = 0 }, wantErr: true},
+		{name: "zero failure window", modify: func(cfg *config.Webhook) { cfg.BreakerFailureWindow = 0 }, wantErr: true},
+		{name: "zero open duration", modify: func(cfg *config.Webhook) { cfg.BreakerOpenDuration = 0 }, wantErr: true},
+		{name: "zero probes", modify: func(cfg *config.Webhook) { cfg.BreakerProbes = 0 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newBreakerConfig()
+			tt.modify(cfg)
+
+			_, err := NewBreaker(redisClient, cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	_, err := NewBreaker(nil, newBreakerConfig())
+	assert.Error(t, err)
+}
+
+func TestBreaker_Allow(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+
+	tests := []struct {
+		name       string
+		evalResult *redis.Cmd
+		wantState  BreakerState
+		wantDelay  time.Duration
+		wantErr    error
+	}{
+		{
+			name:       "closed",
+			evalResult: redis.NewCmdResult([]interface{}{int64(0), int64(0)}, nil),
+			wantState:  BreakerClosed,
+		},
+		{
+			name:       "open",
+			evalResult: redis.NewCmdResult([]interface{}{int64(1), int64(12000)}, nil),
+			wantState:  BreakerOpen,
+			wantDelay:  12 * time.Second,
+		},
+		{
+			name:       "half-open probe",
+			evalResult: redis.NewCmdResult([]interface{}{int64(2), int64(0)}, nil),
+			wantState:  BreakerHalfOpen,
+		},
+		{
+			name:       "script fails",
+			evalResult: redis.NewCmdResult(nil, assert.AnError),
+			wantErr:    assert.AnError,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			b, err := NewBreaker(redisClient, newBreakerConfig())
+			require.NoError(t, err)
+
+			redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(),
+				[]string{"webhookBreaker:example.com"}, int64(1_000_000), 1, int64(30000), breakerTTL.Milliseconds(),
+			).Return(tt.evalResult)
+
+			state, delay, err := b.Allow(context.Background(), "example.com", now)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantState, state)
+			assert.Equal(t, tt.wantDelay, delay)
+		})
+	}
+}
+
+func TestBreaker_Record(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+
+	tests := []struct {
+		name       string
+		failed     bool
+		evalResult *redis.Cmd
+		want       BreakerState
+		wantErr    error
+	}{
+		{
+			name:       "success closes",
+			evalResult: redis.NewCmdResult(int64(0), nil),
+			want:       BreakerClosed,
+		},
+		{
+			name:       "failure opens",
+			failed:     true,
+			evalResult: redis.NewCmdResult(int64(1), nil),
+			want:       BreakerOpen,
+		},
+		{
+			name:       "script fails",
+			failed:     true,
+			evalResult: redis.NewCmdResult(nil, assert.AnError),
+			wantErr:    assert.AnError,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			b, err := NewBreaker(redisClient, newBreakerConfig())
+			require.NoError(t, err)
+
+			redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(),
+				[]string{"webhookBreaker:example.com"}, int64(1_000_000), tt.failed, 5, int64(30000), int64(60000),
+				breakerTTL.Milliseconds(),
+			).Return(tt.evalResult)
+
+			got, err := b.Record(context.Background(), "example.com", tt.failed, now)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
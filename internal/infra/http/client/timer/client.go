@@ -1,16 +1,26 @@
 package timer
 
 import (
+	"bytes"
 	"context"
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
-	"strings"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
 
 	"github.com/cubny/httpqueue/internal/app/timer"
+	"github.com/cubny/httpqueue/internal/config"
+	"github.com/cubny/httpqueue/pkg/webhooks"
 )
 
 var (
@@ -31,84 +41,483 @@ var (
 	notTrustedErrorRe = regexp.MustCompile(`certificate is not trusted`)
 )
 
+const (
+	// maxResponseBodyExcerpt is the maximum number of bytes of the response body that is kept in ResponseError.
+	maxResponseBodyExcerpt = 1 << 10
+	// maxMatchedResponseBody is the maximum number of bytes of the response body that the success criteria match.
+	maxMatchedResponseBody = 64 << 10
+)
+
+// ResponseError is a failed request that got a response. it is an ErrRetryableRequestFailure when the failure is
+// retryable.
+type ResponseError struct {
+	StatusCode int
+	// Body is an excerpt of the response body for troubleshooting.
+	Body      string
+	Retryable bool
+	// UnexpectedBody is set when the status code is accepted, but the body did not match the success criteria.
+	UnexpectedBody bool
+}
+
+func (e *ResponseError) Error() string {
+	if e.UnexpectedBody {
+		return fmt.Sprintf("unexpected HTTP response body with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected HTTP status %d", e.StatusCode)
+}
+
+// Is makes a retryable ResponseError an ErrRetryableRequestFailure.
+func (e *ResponseError) Is(target error) bool {
+	return e.Retryable && target == ErrRetryableRequestFailure
+}
+
+// RequestError is a failed request that did not get a response, e.g. because of a timeout or a connection failure.
+// it is an ErrRetryableRequestFailure when the failure is retryable.
+type RequestError struct {
+	Err       error
+	Retryable bool
+}
+
+func (e *RequestError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes a retryable RequestError an ErrRetryableRequestFailure.
+func (e *RequestError) Is(target error) bool {
+	return e.Retryable && target == ErrRetryableRequestFailure
+}
+
+// RetryAfterError is a retryable failure whose response asked for retrying after Delay, using the Retry-After header.
+type RetryAfterError struct {
+	Delay time.Duration
+	// Response is the failed response, if known.
+	Response *ResponseError
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%v, retry after %s", ErrRetryableRequestFailure, e.Delay)
+}
+
+// Unwrap makes RetryAfterError an ErrRetryableRequestFailure.
+func (e *RetryAfterError) Unwrap() error {
+	if e.Response != nil {
+		return e.Response
+	}
+	return ErrRetryableRequestFailure
+}
+
+// CircuitOpenError is a request that is not sent because the circuit breaker of its host is open. it is a retryable
+// failure that is retried after Delay at the earliest.
+type CircuitOpenError struct {
+	Host  string
+	Delay time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker of %s is open for %s", e.Host, e.Delay)
+}
+
+// Is makes CircuitOpenError an ErrRetryableRequestFailure.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrRetryableRequestFailure
+}
+
 // Client is a specialized HTTP Client for calling timer webhook.
 type Client struct {
-	httpClient *http.Client
+	httpClient     *http.Client
+	signingSecrets []webhooks.Secret
+	// breaker is nil when the circuit breakers are disabled.
+	breaker *Breaker
+	policy  *DestinationPolicy
+	// criteria are the success criteria of the timers that do not have their own.
+	criteria timer.SuccessCriteria
+	// timeout is the overall timeout of the requests of the timers that do not have their own. zero means no timeout.
+	timeout time.Duration
 }
 
-func NewClient() *Client {
-	httpClient := http.DefaultClient
-	return &Client{httpClient: httpClient}
+// NewClient constructs a Client. the webhooks are signed with the signing secrets of the config, unless the timer
+// has its own secrets. the circuit breakers of the destination hosts are kept in Redis, unless they are disabled
+// by the config. the webhooks are sent only where the destination policy of the config allows, using the timeouts,
+// TLS and proxy settings of the config. the responses are successful by the success criteria of the config, unless
+// the timer has its own.
+func NewClient(cfg *config.Webhook, redisClient redis.UniversalClient) (*Client, error) {
+	signingSecrets, err := webhooks.ParseSecrets(cfg.SigningSecrets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing secrets: %w", err)
+	}
+
+	policy, err := NewDestinationPolicy(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination policy: %w", err)
+	}
+
+	criteria := timer.SuccessCriteria{
+		AcceptedStatusCodes:  cfg.AcceptedStatusCodes,
+		RetryableStatusCodes: cfg.RetryableStatusCodes,
+		BodyPattern:          cfg.SuccessBodyPattern,
+		JSONPath:             cfg.SuccessJSONPath,
+		JSONValue:            cfg.SuccessJSONValue,
+	}
+	if err = criteria.Validate(); err != nil {
+		return nil, err
+	}
+
+	httpClient, err := newHTTPClient(cfg, policy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP client: %w", err)
+	}
+
+	var breaker *Breaker
+	if cfg.BreakerFailureThreshold > 0 {
+		if breaker, err = NewBreaker(redisClient, cfg); err != nil {
+			return nil, fmt.Errorf("invalid circuit breaker: %w", err)
+		}
+	}
+
+	return &Client{
+		httpClient:     httpClient,
+		signingSecrets: signingSecrets,
+		breaker:        breaker,
+		policy:         policy,
+		criteria:       criteria,
+		timeout:        cfg.Timeout,
+	}, nil
 }
 
 // Shoot calls the timer's webhook and return ErrRetryableRequestFailure if the request fails because of a retryable
-// reason, such as HTTP status code 500.
-func (c *Client) Shoot(ctx context.Context, timer *timer.Timer) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, timer.URL.String(), strings.NewReader(""))
+// reason, such as HTTP status code 500. the response fails unless it meets the success criteria. it returns the status code of the response, or zero when there was no
+// response. the request is not sent while the circuit breaker of its host is open, and a CircuitOpenError is
+// returned instead. the request is cut off after the timeout of the timer, or else the timeout of the client. the
+// requests that the host throttles are not recorded on the breaker, as the host is up and only asks for slowing down.
+func (c *Client) Shoot(ctx context.Context, timer *timer.Timer, attempt int) (int, error) {
+	reqCtx, cancel := c.withTimeout(ctx, timer)
+	defer cancel()
+
+	req, err := newRequest(reqCtx, timer, attempt)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
+	if err = c.policy.AllowURL(req.URL); err != nil {
+		return 0, fmt.Errorf("http request is not sent: %w", &RequestError{Err: err})
+	}
+
+	if err = c.sign(req, timer); err != nil {
+		return 0, err
+	}
+
+	host := req.URL.Host
+	state, delay, ok := c.allow(ctx, host)
+	if state == BreakerOpen {
+		breakerRejectedInc()
+		return 0, fmt.Errorf("http request is not sent: %w", &CircuitOpenError{Host: host, Delay: delay})
+	}
+
+	statusCode, err := c.do(req, c.successCriteria(timer))
+	if ok && !isThrottled(err) {
+		c.record(ctx, host, state, errors.Is(err, ErrRetryableRequestFailure))
+	}
+
+	return statusCode, err
+}
+
+// do sends the request and classifies its outcome by the success criteria.
+func (c *Client) do(req *http.Request, criteria timer.SuccessCriteria) (int, error) {
 	resp, doErr := c.httpClient.Do(req)
+	if doErr != nil {
+		if isRequestErrorRetryable(doErr) {
+			return 0, fmt.Errorf("http request failed: %w", &RequestError{Err: doErr, Retryable: true})
+		}
+		return 0, fmt.Errorf("http request failed permanently: %w", &RequestError{Err: doErr})
+	}
+	defer resp.Body.Close()
+
+	body := readBody(resp, criteria)
+	outcome := criteria.Classify(resp.StatusCode, body)
+	if outcome == timer.OutcomeSuccess {
+		return resp.StatusCode, nil
+	}
+
+	shouldRetry := outcome == timer.OutcomeRetry
+	respErr := &ResponseError{
+		StatusCode:     resp.StatusCode,
+		Body:           bodyExcerpt(body),
+		Retryable:      shouldRetry,
+		UnexpectedBody: criteria.Accepts(resp.StatusCode),
+	}
+
+	// HTTP status code 429 and 503 responses may include the `Retry-After` header which is respected in order to
+	// implement a "polite" client.
+	if delay, ok := retryAfter(resp, time.Now()); shouldRetry && ok {
+		return resp.StatusCode, fmt.Errorf("http request failed: %s, %w", resp.Status, &RetryAfterError{Delay: delay, Response: respErr})
+	}
+
+	return resp.StatusCode, fmt.Errorf("http request failed: %s, %w", resp.Status, respErr)
+}
+
+// successCriteria returns the success criteria of the timer, or else the ones of the client.
+func (c *Client) successCriteria(t *timer.Timer) timer.SuccessCriteria {
+	if t.SuccessCriteria == nil {
+		return c.criteria
+	}
+	return t.SuccessCriteria.WithDefaults(c.criteria)
+}
+
+// withTimeout applies the timeout of the webhook of the timer, or else the timeout of the client, to the context.
+func (c *Client) withTimeout(ctx context.Context, t *timer.Timer) (context.Context, context.CancelFunc) {
+	timeout := c.timeout
+	if t.Webhook.Timeout > 0 {
+		timeout = t.Webhook.Timeout
+	}
+
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// isThrottled tells whether the request failed because the host throttles it, i.e. responded with 429, or with 503
+// and the Retry-After header.
+func isThrottled(err error) bool {
+	var (
+		retryAfterErr *RetryAfterError
+		respErr       *ResponseError
+	)
+
+	switch {
+	case errors.As(err, &retryAfterErr):
+		return true
+	case errors.As(err, &respErr):
+		return respErr.StatusCode == http.StatusTooManyRequests
+	default:
+		return false
+	}
+}
+
+// ErrorClass classifies the failure of Shoot as one of the attempt error classes of the timer package, or returns
+// empty when there is no failure.
+func ErrorClass(err error) string {
+	var (
+		respErr *ResponseError
+		reqErr  *RequestError
+		openErr *CircuitOpenError
+		netErr  net.Error
+	)
 
-	// Note: HTTP status code 429 response may include additional data in the header such as `Retry-After` which
-	// should be respected in order to implement a "polite" client.
-	// TODO: implement special treatment for 429 client error.
-	shouldRetry, checkErr := isHTTPStatusCodeRetryable(resp, doErr)
 	switch {
-	case shouldRetry && checkErr != nil:
-		return fmt.Errorf("http request failed: %v, %w", checkErr, ErrRetryableRequestFailure)
-	case shouldRetry && checkErr == nil:
-		return fmt.Errorf("http request failed: %v, %w", checkErr, ErrRetryableRequestFailure)
-	case !shouldRetry && checkErr == nil:
+	case err == nil:
+		return ""
+	case errors.As(err, &openErr):
+		return timer.AttemptErrorCircuitOpen
+	case errors.Is(err, timer.ErrDestinationNotAllowed):
+		return timer.AttemptErrorRequest
+	case errors.As(err, &respErr) && respErr.UnexpectedBody:
+		return timer.AttemptErrorResponseBody
+	case errors.As(err, &respErr):
+		return timer.AttemptErrorHTTPStatus
+	case !errors.As(err, &reqErr):
+		return timer.AttemptErrorRequest
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return timer.AttemptErrorTimeout
+	default:
+		return timer.AttemptErrorConnection
+	}
+}
+
+// Outcome classifies the result of Shoot as a success, a retryable failure or a permanent failure.
+func Outcome(err error) timer.Outcome {
+	switch {
+	case err == nil:
+		return timer.OutcomeSuccess
+	case errors.Is(err, ErrRetryableRequestFailure):
+		return timer.OutcomeRetry
+	default:
+		return timer.OutcomeFailure
+	}
+}
+
+// allow asks the breaker of the host whether the request can be sent. the request is sent when the breakers are
+// disabled or unavailable, in which case ok is false and the outcome of the request is not recorded.
+func (c *Client) allow(ctx context.Context, host string) (state BreakerState, delay time.Duration, ok bool) {
+	if c.breaker == nil {
+		return BreakerClosed, 0, false
+	}
+
+	now := time.Now()
+	state, delay, err := c.breaker.Allow(ctx, host, now)
+	if err != nil {
+		breakerErrorInc("allow")
+		logrus.WithError(err).WithFields(logrus.Fields{"host": host}).Error("failed to check the circuit breaker")
+		return BreakerClosed, 0, false
+	}
+
+	// an open breaker is due to half-open after the delay, and a half-open one to open or close within the open
+	// duration.
+	until := now.Add(delay)
+	if state == BreakerHalfOpen {
+		until = now.Add(c.breaker.openDuration)
+	}
+	breakerStateSet(host, state, until)
+
+	return state, delay, true
+}
+
+// record keeps the outcome of the request in the breaker of the host. the requests that are cut short by the
+// context, e.g. because the worker is shutting down, are not held against the host.
+func (c *Client) record(ctx context.Context, host string, state BreakerState, failed bool) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	next, err := c.breaker.Record(ctx, host, failed, now)
+	if err != nil {
+		breakerErrorInc("record")
+		logrus.WithError(err).WithFields(logrus.Fields{"host": host}).Error("failed to record the outcome on the circuit breaker")
+		return
+	}
+
+	breakerStateSet(host, next, now.Add(c.breaker.openDuration))
+	if next != state {
+		breakerTransitionInc(next)
+		logrus.WithFields(logrus.Fields{"host": host, "state": next}).Info("circuit breaker state changed")
+	}
+}
+
+// readBody reads the beginning of the response body, as much as the success criteria need.
+func readBody(resp *http.Response, criteria timer.SuccessCriteria) []byte {
+	limit := int64(maxResponseBodyExcerpt)
+	if criteria.MatchesBody() {
+		limit = maxMatchedResponseBody
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, limit))
+	return body
+}
+
+// bodyExcerpt returns the beginning of the response body.
+func bodyExcerpt(body []byte) string {
+	if len(body) > maxResponseBodyExcerpt {
+		body = body[:maxResponseBodyExcerpt]
+	}
+	return string(body)
+}
+
+// newRequest composes the HTTP request of the timer's webhook.
+func newRequest(ctx context.Context, timer *timer.Timer, attempt int) (*http.Request, error) {
+	webhookURL, err := timer.WebhookURL(attempt)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := timer.Webhook
+	req, err := http.NewRequestWithContext(ctx, webhook.MethodOrDefault(), webhookURL, bytes.NewReader(webhook.Body))
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range webhook.Headers {
+		req.Header.Set(name, value)
+	}
+
+	if webhook.ContentType != "" {
+		req.Header.Set("Content-Type", webhook.ContentType)
+	}
+
+	return req, nil
+}
+
+// sign sets the Standard Webhooks headers on the request, if the timer or the client has signing secrets.
+func (c *Client) sign(req *http.Request, timer *timer.Timer) error {
+	secrets := c.signingSecrets
+	if len(timer.Webhook.SigningSecrets) > 0 {
+		var err error
+		if secrets, err = webhooks.ParseSecrets(timer.Webhook.SigningSecrets); err != nil {
+			return err
+		}
+	}
+
+	if len(secrets) == 0 {
 		return nil
 	}
 
+	webhooks.SetHeaders(req.Header, secrets, timer.MessageID(), time.Now(), timer.Webhook.Body)
 	return nil
 }
 
-// isHTTPStatusCodeRetryable is aware of retry-ability of the request based on the response.
-// The content of this function is inspired from HashiCorp's go-retryablehttp https://github.com/hashicorp/go-retryablehttp
-func isHTTPStatusCodeRetryable(resp *http.Response, err error) (bool, error) {
-	if err != nil {
-		if v, ok := err.(*url.Error); ok {
-			// Don't retry if the error was due to too many redirects.
-			if redirectsErrorRe.MatchString(v.Error()) {
-				return false, v
-			}
-
-			// Don't retry if the error was due to an invalid protocol scheme.
-			if schemeErrorRe.MatchString(v.Error()) {
-				return false, v
-			}
-
-			// Don't retry if the error was due to TLS cert verification failure.
-			if notTrustedErrorRe.MatchString(v.Error()) {
-				return false, v
-			}
-			if _, ok := v.Err.(x509.UnknownAuthorityError); ok {
-				return false, v
-			}
+// maxRetryAfterSeconds is the longest Retry-After, in seconds, that fits in a time.Duration.
+const maxRetryAfterSeconds = math.MaxInt64 / int64(time.Second)
+
+// retryAfter parses the Retry-After header of the 429 and 503 responses. the header is either a number of seconds
+// or an HTTP date.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	// the seconds out of the range of int64 are parsed as its bounds.
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil || errors.Is(err, strconv.ErrRange) {
+		if seconds < 0 {
+			return 0, false
+		}
+		// the duration of more seconds overflows. it is capped by the retry delay anyway.
+		if seconds > maxRetryAfterSeconds {
+			seconds = maxRetryAfterSeconds
 		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
 
-		// The error is likely recoverable so retry.
-		return true, nil
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
 	}
 
-	// 429 Too Many Requests is recoverable. Sometimes the server puts
-	// a Retry-After response header to indicate when the server is
-	// available to start processing request from client.
-	if resp.StatusCode == http.StatusTooManyRequests {
-		return true, nil
+	return 0, true
+}
+
+// isRequestErrorRetryable is aware of retry-ability of the request that did not get a response. the responses are
+// classified by the success criteria instead, see timer.SuccessCriteria.
+// The content of this function is inspired from HashiCorp's go-retryablehttp https://github.com/hashicorp/go-retryablehttp
+func isRequestErrorRetryable(err error) bool {
+	// Don't retry if the destination is not allowed, e.g. a redirect to a private address.
+	if errors.Is(err, timer.ErrDestinationNotAllowed) {
+		return false
 	}
 
-	// Check the response code. We retry on 500-range responses to allow
-	// the server time to recover, as 500's are typically not permanent
-	// errors and may relate to outages on the server side.
-	// This will catch invalid response codes as well, like 0 and 999.
-	if resp.StatusCode == 0 || (resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented) {
-		return true, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	if v, ok := err.(*url.Error); ok {
+		// Don't retry if the error was due to too many redirects.
+		if redirectsErrorRe.MatchString(v.Error()) {
+			return false
+		}
+
+		// Don't retry if the error was due to an invalid protocol scheme.
+		if schemeErrorRe.MatchString(v.Error()) {
+			return false
+		}
+
+		// Don't retry if the error was due to TLS cert verification failure.
+		if notTrustedErrorRe.MatchString(v.Error()) {
+			return false
+		}
+		if _, ok := v.Err.(x509.UnknownAuthorityError); ok {
+			return false
+		}
 	}
 
-	return false, nil
+	// The error is likely recoverable so retry.
+	return true
 }
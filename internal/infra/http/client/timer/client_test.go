@@ -2,14 +2,25 @@ package timer
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/cubny/httpqueue/internal/app/timer"
+	"github.com/cubny/httpqueue/internal/config"
+	mocks "github.com/cubny/httpqueue/internal/mocks/external/redis"
+	"github.com/cubny/httpqueue/pkg/webhooks"
 )
 
 func TestClient_Shoot(t *testing.T) {
@@ -50,8 +61,10 @@ func TestClient_Shoot(t *testing.T) {
 			tm, err := timer.NewTimer(ts.URL, 0, 0, 0)
 			require.NoError(t, err)
 
-			client := NewClient()
-			err = client.Shoot(context.Background(), tm)
+			client, err := NewClient(&config.Webhook{AllowPrivateDestinations: true}, nil)
+			require.NoError(t, err)
+			statusCode, err := client.Shoot(context.Background(), tm, 1)
+			assert.Equal(t, tt.serverStatusCode, statusCode)
 
 			if tt.wantRetryableError {
 				assert.ErrorIs(t, err, ErrRetryableRequestFailure)
@@ -61,3 +74,516 @@ func TestClient_Shoot(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_Shoot_SendsWebhookVerbatim(t *testing.T) {
+	var (
+		gotMethod string
+		gotHeader http.Header
+		gotBody   []byte
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotHeader = r.Header
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	tm, err := timer.NewTimerFromCommand(timer.SetTimerCommand{
+		URLRaw:      ts.URL,
+		Method:      http.MethodPut,
+		Headers:     map[string]string{"X-Order-ID": "42"},
+		Body:        []byte(`{"order_id":42}`),
+		ContentType: "application/json",
+	})
+	require.NoError(t, err)
+
+	client, err := NewClient(&config.Webhook{AllowPrivateDestinations: true}, nil)
+	require.NoError(t, err)
+	_, err = client.Shoot(context.Background(), tm, 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodPut, gotMethod)
+	assert.Equal(t, "42", gotHeader.Get("X-Order-ID"))
+	assert.Equal(t, "application/json", gotHeader.Get("Content-Type"))
+	assert.Equal(t, `{"order_id":42}`, string(gotBody))
+}
+
+func TestClient_Shoot_RendersURLTemplate(t *testing.T) {
+	var gotURL string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	tm, err := timer.NewTimerFromCommand(timer.SetTimerCommand{URLTemplate: ts.URL + "/hooks?timer={{.ID}}&attempt={{.Attempt}}"})
+	require.NoError(t, err)
+
+	client, err := NewClient(&config.Webhook{AllowPrivateDestinations: true}, nil)
+	require.NoError(t, err)
+	_, err = client.Shoot(context.Background(), tm, 2)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/hooks?timer="+tm.ID+"&attempt=2", gotURL)
+}
+
+func TestClient_Shoot_SignsWebhook(t *testing.T) {
+	const (
+		clientSecret = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+		timerSecret  = "whsec_dGhpcyBpcyB0aGUgcm90YXRlZCBzZWNyZXQ="
+	)
+
+	tests := []struct {
+		name           string
+		clientSecrets  []string
+		timerSecrets   []string
+		verifySecret   string
+		wantSigned     bool
+		wantVerifyFail bool
+	}{
+		{
+			name:       "not signed without secrets",
+			wantSigned: false,
+		},
+		{
+			name:          "signed with the secrets of the client",
+			clientSecrets: []string{clientSecret},
+			verifySecret:  clientSecret,
+			wantSigned:    true,
+		},
+		{
+			name:          "the secrets of the timer take precedence",
+			clientSecrets: []string{clientSecret},
+			timerSecrets:  []string{timerSecret},
+			verifySecret:  clientSecret,
+			wantSigned:    true,
+			// the client secret is not used
+			wantVerifyFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				gotHeader http.Header
+				gotBody   []byte
+			)
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotHeader = r.Header
+				gotBody, _ = io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer ts.Close()
+
+			tm, err := timer.NewTimerFromCommand(timer.SetTimerCommand{
+				URLRaw:         ts.URL,
+				Body:           []byte(`{"order_id":42}`),
+				SigningSecrets: tt.timerSecrets,
+			})
+			require.NoError(t, err)
+
+			client, err := NewClient(&config.Webhook{SigningSecrets: tt.clientSecrets, AllowPrivateDestinations: true}, nil)
+			require.NoError(t, err)
+			_, err = client.Shoot(context.Background(), tm, 1)
+			require.NoError(t, err)
+
+			if !tt.wantSigned {
+				assert.Empty(t, gotHeader.Get(webhooks.HeaderSignature))
+				return
+			}
+
+			assert.Equal(t, tm.ID, gotHeader.Get(webhooks.HeaderID))
+
+			verifier, err := webhooks.NewVerifier(tt.verifySecret)
+			require.NoError(t, err)
+
+			err = verifier.Verify(gotHeader, gotBody)
+			if tt.wantVerifyFail {
+				assert.ErrorIs(t, err, webhooks.ErrNoMatchingSignature)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewClient_InvalidSecret(t *testing.T) {
+	_, err := NewClient(&config.Webhook{SigningSecrets: []string{"secret"}}, nil)
+	assert.ErrorIs(t, err, webhooks.ErrInvalidSecret)
+}
+
+func TestClient_Shoot_RetryAfter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	tm, err := timer.NewTimer(ts.URL, 0, 0, 0)
+	require.NoError(t, err)
+
+	client, err := NewClient(&config.Webhook{AllowPrivateDestinations: true}, nil)
+	require.NoError(t, err)
+
+	_, err = client.Shoot(context.Background(), tm, 1)
+	assert.ErrorIs(t, err, ErrRetryableRequestFailure)
+
+	var retryAfterErr *RetryAfterError
+	require.ErrorAs(t, err, &retryAfterErr)
+	assert.Equal(t, 2*time.Minute, retryAfterErr.Delay)
+
+	var respErr *ResponseError
+	require.ErrorAs(t, err, &respErr)
+	assert.Equal(t, http.StatusTooManyRequests, respErr.StatusCode)
+}
+
+func Test_retryAfter(t *testing.T) {
+	now := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		statusCode int
+		retryAfter string
+		want       time.Duration
+		wantOK     bool
+	}{
+		{name: "seconds", statusCode: http.StatusTooManyRequests, retryAfter: "30", want: 30 * time.Second, wantOK: true},
+		{name: "http date", statusCode: http.StatusServiceUnavailable, retryAfter: "Mon, 02 Jan 2023 15:05:05 GMT", want: time.Minute, wantOK: true},
+		{name: "http date in the past", statusCode: http.StatusServiceUnavailable, retryAfter: "Mon, 02 Jan 2023 15:00:00 GMT", want: 0, wantOK: true},
+		{name: "huge seconds", statusCode: http.StatusTooManyRequests, retryAfter: "10000000000", want: time.Duration(maxRetryAfterSeconds) * time.Second, wantOK: true},
+		{name: "seconds out of int64", statusCode: http.StatusTooManyRequests, retryAfter: "99999999999999999999", want: time.Duration(maxRetryAfterSeconds) * time.Second, wantOK: true},
+		{name: "negative seconds", statusCode: http.StatusTooManyRequests, retryAfter: "-1", wantOK: false},
+		{name: "malformed", statusCode: http.StatusTooManyRequests, retryAfter: "soon", wantOK: false},
+		{name: "missing", statusCode: http.StatusTooManyRequests, wantOK: false},
+		{name: "other status", statusCode: http.StatusInternalServerError, retryAfter: "30", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.statusCode, Header: http.Header{}}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+
+			got, ok := retryAfter(resp, now)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClient_Shoot_ResponseError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+		_, _ = w.Write([]byte(strings.Repeat("a", 2*maxResponseBodyExcerpt)))
+	}))
+	defer ts.Close()
+
+	tm, err := timer.NewTimer(ts.URL, 0, 0, 0)
+	require.NoError(t, err)
+
+	client, err := NewClient(&config.Webhook{AllowPrivateDestinations: true}, nil)
+	require.NoError(t, err)
+
+	_, err = client.Shoot(context.Background(), tm, 1)
+	assert.NotErrorIs(t, err, ErrRetryableRequestFailure)
+
+	var respErr *ResponseError
+	require.ErrorAs(t, err, &respErr)
+	assert.Equal(t, http.StatusGone, respErr.StatusCode)
+	assert.Len(t, respErr.Body, maxResponseBodyExcerpt)
+}
+
+func TestClient_Shoot_SuccessCriteria(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		statusCode, _ := strconv.Atoi(r.URL.Query().Get("status"))
+		w.WriteHeader(statusCode)
+		_, _ = w.Write([]byte(r.URL.Query().Get("body")))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		cfg         config.Webhook
+		criteria    *timer.SuccessCriteria
+		statusCode  int
+		body        string
+		wantOutcome timer.Outcome
+		wantClass   string
+	}{
+		{
+			name:        "redirect fails by default",
+			statusCode:  http.StatusFound,
+			wantOutcome: timer.OutcomeFailure,
+			wantClass:   timer.AttemptErrorHTTPStatus,
+		},
+		{
+			name:        "accepted status code of the config",
+			cfg:         config.Webhook{AcceptedStatusCodes: []string{"2xx", "410"}},
+			statusCode:  http.StatusGone,
+			wantOutcome: timer.OutcomeSuccess,
+		},
+		{
+			name:        "retryable status code of the timer",
+			cfg:         config.Webhook{RetryableStatusCodes: []string{"5xx"}},
+			criteria:    &timer.SuccessCriteria{RetryableStatusCodes: []string{"409"}},
+			statusCode:  http.StatusConflict,
+			wantOutcome: timer.OutcomeRetry,
+			wantClass:   timer.AttemptErrorHTTPStatus,
+		},
+		{
+			name:        "body matches the JSON path of the timer",
+			criteria:    &timer.SuccessCriteria{JSONPath: "status", JSONValue: "ok"},
+			statusCode:  http.StatusOK,
+			body:        `{"status":"ok"}`,
+			wantOutcome: timer.OutcomeSuccess,
+		},
+		{
+			name:        "body does not match the pattern of the config",
+			cfg:         config.Webhook{SuccessBodyPattern: "^OK$"},
+			statusCode:  http.StatusOK,
+			body:        "FAILED",
+			wantOutcome: timer.OutcomeFailure,
+			wantClass:   timer.AttemptErrorResponseBody,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.AllowPrivateDestinations = true
+			client, err := NewClient(&tt.cfg, nil)
+			require.NoError(t, err)
+
+			tm, err := timer.NewTimer(fmt.Sprintf("%s?status=%d&body=%s", ts.URL, tt.statusCode, url.QueryEscape(tt.body)), 0, 0, 0)
+			require.NoError(t, err)
+			tm.SuccessCriteria = tt.criteria
+
+			statusCode, err := client.Shoot(context.Background(), tm, 1)
+			assert.Equal(t, tt.statusCode, statusCode)
+			assert.Equal(t, tt.wantOutcome, Outcome(err))
+			assert.Equal(t, tt.wantClass, ErrorClass(err))
+		})
+	}
+}
+
+func TestNewClient_InvalidSuccessCriteria(t *testing.T) {
+	_, err := NewClient(&config.Webhook{AcceptedStatusCodes: []string{"ok"}}, nil)
+	assert.ErrorIs(t, err, timer.ErrInvalidSuccessCriteria)
+}
+
+func TestClient_Shoot_Timeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		clientTimeout time.Duration
+		timerTimeout  time.Duration
+	}{
+		{name: "client timeout", clientTimeout: 20 * time.Millisecond},
+		{name: "timer timeout overrides the client timeout", clientTimeout: time.Minute, timerTimeout: 20 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm, err := timer.NewTimer(ts.URL, 0, 0, 0)
+			require.NoError(t, err)
+			tm.Webhook.Timeout = tt.timerTimeout
+
+			client, err := NewClient(&config.Webhook{AllowPrivateDestinations: true, Timeout: tt.clientTimeout}, nil)
+			require.NoError(t, err)
+
+			started := time.Now()
+			_, err = client.Shoot(context.Background(), tm, 1)
+			assert.ErrorIs(t, err, ErrRetryableRequestFailure)
+			assert.Equal(t, timer.AttemptErrorTimeout, ErrorClass(err))
+			assert.Less(t, time.Since(started), 500*time.Millisecond)
+		})
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	client, err := NewClient(&config.Webhook{AllowPrivateDestinations: true}, nil)
+	require.NoError(t, err)
+
+	shoot := func(rawURL string, timeout time.Duration) error {
+		tm, err := timer.NewTimer(rawURL, 0, 0, 0)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		_, err = client.Shoot(ctx, tm, 1)
+		return err
+	}
+
+	assert.Equal(t, "", ErrorClass(nil))
+	assert.Equal(t, timer.AttemptErrorHTTPStatus, ErrorClass(shoot(ts.URL, time.Second)))
+	assert.Equal(t, timer.AttemptErrorTimeout, ErrorClass(shoot(slow.URL, 10*time.Millisecond)))
+	assert.Equal(t, timer.AttemptErrorConnection, ErrorClass(shoot(closed.URL, time.Second)))
+	assert.Equal(t, timer.AttemptErrorRequest, ErrorClass(assert.AnError))
+	assert.Equal(t, timer.AttemptErrorCircuitOpen, ErrorClass(&CircuitOpenError{Host: "example.com"}))
+}
+
+func TestClient_Shoot_CircuitBreaker(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	tm, err := timer.NewTimer(ts.URL, 0, 0, 0)
+	require.NoError(t, err)
+	host := strings.TrimPrefix(ts.URL, "http://")
+
+	newClient := func(t *testing.T) (*Client, *mocks.RedisClient) {
+		ctrl := gomock.NewController(t)
+		redisClient := mocks.NewRedisClient(ctrl)
+		client, err := NewClient(newBreakerConfig(), redisClient)
+		require.NoError(t, err)
+		return client, redisClient
+	}
+
+	t.Run("fails fast while the breaker is open", func(t *testing.T) {
+		requests = 0
+		client, redisClient := newClient(t)
+		redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(), []string{breakerKey(host)}, gomock.Any()).
+			Return(redis.NewCmdResult([]interface{}{int64(1), int64(12000)}, nil))
+
+		statusCode, err := client.Shoot(context.Background(), tm, 1)
+		assert.Zero(t, statusCode)
+		assert.ErrorIs(t, err, ErrRetryableRequestFailure)
+
+		var openErr *CircuitOpenError
+		require.ErrorAs(t, err, &openErr)
+		assert.Equal(t, host, openErr.Host)
+		assert.Equal(t, 12*time.Second, openErr.Delay)
+		assert.Zero(t, requests)
+	})
+
+	t.Run("records the failure of a probe", func(t *testing.T) {
+		requests = 0
+		client, redisClient := newClient(t)
+		gomock.InOrder(
+			redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(), []string{breakerKey(host)}, gomock.Any()).
+				Return(redis.NewCmdResult([]interface{}{int64(2), int64(0)}, nil)),
+			redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(), []string{breakerKey(host)},
+				gomock.Any(), true, 5, int64(30000), int64(60000), breakerTTL.Milliseconds()).
+				Return(redis.NewCmdResult(int64(1), nil)),
+		)
+
+		statusCode, err := client.Shoot(context.Background(), tm, 1)
+		assert.Equal(t, http.StatusInternalServerError, statusCode)
+		assert.ErrorIs(t, err, ErrRetryableRequestFailure)
+		assert.Equal(t, 1, requests)
+	})
+
+	t.Run("does not hold the throttling against the host", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			statusCode int
+			retryAfter string
+			wantRecord bool
+		}{
+			{name: "429", statusCode: http.StatusTooManyRequests},
+			{name: "429 with Retry-After", statusCode: http.StatusTooManyRequests, retryAfter: "30"},
+			{name: "503 with Retry-After", statusCode: http.StatusServiceUnavailable, retryAfter: "30"},
+			{name: "503 without Retry-After", statusCode: http.StatusServiceUnavailable, wantRecord: true},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				throttling := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.statusCode)
+				}))
+				defer throttling.Close()
+
+				throttlingTimer, err := timer.NewTimer(throttling.URL, 0, 0, 0)
+				require.NoError(t, err)
+				throttlingHost := strings.TrimPrefix(throttling.URL, "http://")
+
+				client, redisClient := newClient(t)
+				redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(), []string{breakerKey(throttlingHost)}, gomock.Any()).
+					Return(redis.NewCmdResult([]interface{}{int64(0), int64(0)}, nil))
+				if tt.wantRecord {
+					redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(), []string{breakerKey(throttlingHost)},
+						gomock.Any(), true, 5, int64(30000), int64(60000), breakerTTL.Milliseconds()).
+						Return(redis.NewCmdResult(int64(0), nil))
+				}
+
+				statusCode, err := client.Shoot(context.Background(), throttlingTimer, 1)
+				assert.Equal(t, tt.statusCode, statusCode)
+				assert.ErrorIs(t, err, ErrRetryableRequestFailure)
+			})
+		}
+	})
+
+	t.Run("sends the request when the breaker is unavailable", func(t *testing.T) {
+		requests = 0
+		client, redisClient := newClient(t)
+		redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(), []string{breakerKey(host)}, gomock.Any()).
+			Return(redis.NewCmdResult(nil, assert.AnError))
+
+		statusCode, err := client.Shoot(context.Background(), tm, 1)
+		assert.Equal(t, http.StatusInternalServerError, statusCode)
+		assert.ErrorIs(t, err, ErrRetryableRequestFailure)
+		assert.Equal(t, 1, requests)
+	})
+}
+
+func TestClient_Shoot_DestinationNotAllowed(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+	}))
+	defer ts.Close()
+
+	tm, err := timer.NewTimer(ts.URL, 0, 0, 0)
+	require.NoError(t, err)
+
+	t.Run("refuses a private destination", func(t *testing.T) {
+		client, err := NewClient(&config.Webhook{}, nil)
+		require.NoError(t, err)
+
+		statusCode, err := client.Shoot(context.Background(), tm, 1)
+		assert.Zero(t, statusCode)
+		assert.ErrorIs(t, err, timer.ErrDestinationNotAllowed)
+		assert.NotErrorIs(t, err, ErrRetryableRequestFailure)
+		assert.Equal(t, timer.AttemptErrorRequest, ErrorClass(err))
+		assert.Zero(t, requests)
+	})
+
+	t.Run("refuses a redirect to a private destination", func(t *testing.T) {
+		client, err := NewClient(&config.Webhook{AllowedCIDRs: []string{"127.0.0.1/32"}, MaxRedirects: 10}, nil)
+		require.NoError(t, err)
+
+		_, err = client.Shoot(context.Background(), tm, 1)
+		assert.ErrorIs(t, err, timer.ErrDestinationNotAllowed)
+		assert.NotErrorIs(t, err, ErrRetryableRequestFailure)
+		assert.Equal(t, 1, requests)
+	})
+}
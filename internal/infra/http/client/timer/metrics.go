@@ -0,0 +1,123 @@
+package timer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the metrics of the breakers are not labelled by the host, as the hosts are chosen by the callers of the API and
+// every host would be a series of its own.
+var (
+	breakerHosts           = newBreakerHostsCollector()
+	breakerTransitionCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "httpqueue",
+			Subsystem: "webhook_breaker",
+			Name:      "transition_counter",
+			Help:      "Counter of the circuit breakers that the worker opened or closed",
+		}, []string{"state"})
+	breakerRejectedCount = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "httpqueue",
+			Subsystem: "webhook_breaker",
+			Name:      "rejected_counter",
+			Help:      "Counter of the webhooks that are failed fast because the circuit breaker of the destination host is open",
+		})
+	breakerErrorCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "httpqueue",
+			Subsystem: "webhook_breaker",
+			Name:      "error_counter",
+			Help:      "Counter of the failures to allow or record a request on the circuit breaker",
+		}, []string{"operation"})
+)
+
+func init() {
+	prometheus.MustRegister(breakerHosts, breakerTransitionCount, breakerRejectedCount, breakerErrorCount)
+}
+
+// breakerStateSet keeps the state of the breaker of the host as seen by the worker, until the breaker is due to
+// change its state.
+func breakerStateSet(host string, state BreakerState, until time.Time) {
+	breakerHosts.set(host, state, until)
+}
+
+// breakerTransitionInc counts the breakers that are opened or closed.
+func breakerTransitionInc(state BreakerState) {
+	breakerTransitionCount.With(prometheus.Labels{"state": state.String()}).Inc()
+}
+
+// breakerRejectedInc counts the requests that are failed fast.
+func breakerRejectedInc() {
+	breakerRejectedCount.Inc()
+}
+
+// breakerErrorInc counts the failures of the operation on the breaker, i.e. allow or record.
+func breakerErrorInc(operation string) {
+	breakerErrorCount.With(prometheus.Labels{"operation": operation}).Inc()
+}
+
+// breakerHostsCollector counts the hosts whose breaker is open or half-open as last seen by the worker. a host is
+// counted until its breaker closes or is due to change its state, so that the hosts that are not called anymore are
+// forgotten.
+type breakerHostsCollector struct {
+	desc *prometheus.Desc
+
+	mu    sync.Mutex
+	hosts map[string]breakerHost
+}
+
+type breakerHost struct {
+	state BreakerState
+	until time.Time
+}
+
+func newBreakerHostsCollector() *breakerHostsCollector {
+	return &breakerHostsCollector{
+		desc: prometheus.NewDesc("httpqueue_webhook_breaker_hosts",
+			"Number of the destination hosts whose circuit breaker is open or half-open as last seen by the worker",
+			[]string{"state"}, nil),
+		hosts: make(map[string]breakerHost),
+	}
+}
+
+func (c *breakerHostsCollector) set(host string, state BreakerState, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if state == BreakerClosed {
+		delete(c.hosts, host)
+		return
+	}
+	c.hosts[host] = breakerHost{state: state, until: until}
+}
+
+// count returns the number of the hosts in the state, and forgets the hosts whose breaker is due to change its state.
+func (c *breakerHostsCollector) count(now time.Time) map[BreakerState]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := map[BreakerState]int{BreakerOpen: 0, BreakerHalfOpen: 0}
+	for host, h := range c.hosts {
+		if !now.Before(h.until) {
+			delete(c.hosts, host)
+			continue
+		}
+		counts[h.state]++
+	}
+	return counts
+}
+
+// Describe implements prometheus.Collector.
+func (c *breakerHostsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c *breakerHostsCollector) Collect(ch chan<- prometheus.Metric) {
+	for state, n := range c.count(time.Now()) {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), state.String())
+	}
+}
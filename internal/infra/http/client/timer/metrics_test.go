@@ -0,0 +1,28 @@
+package timer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreakerHostsCollector_count(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := newBreakerHostsCollector()
+
+	c.set("a.url", BreakerOpen, now.Add(time.Minute))
+	c.set("b.url", BreakerOpen, now.Add(time.Second))
+	c.set("c.url", BreakerHalfOpen, now.Add(time.Minute))
+	c.set("d.url", BreakerOpen, now.Add(time.Minute))
+	c.set("d.url", BreakerClosed, now.Add(time.Minute))
+
+	assert.Equal(t, map[BreakerState]int{BreakerOpen: 2, BreakerHalfOpen: 1}, c.count(now))
+
+	// the breaker of b.url is due to half-open, and is forgotten until the worker sees it again
+	assert.Equal(t, map[BreakerState]int{BreakerOpen: 1, BreakerHalfOpen: 1}, c.count(now.Add(time.Second)))
+	assert.Len(t, c.hosts, 2)
+
+	assert.Equal(t, map[BreakerState]int{BreakerOpen: 0, BreakerHalfOpen: 0}, c.count(now.Add(time.Minute)))
+	assert.Empty(t, c.hosts)
+}
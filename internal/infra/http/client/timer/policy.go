@@ -0,0 +1,270 @@
+package timer
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+	"github.com/cubny/httpqueue/internal/config"
+)
+
+// nonPublicPrefixes are the address ranges that are not reachable on the public internet, besides the loopback,
+// private, link-local, multicast and unspecified addresses.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // this network
+	netip.MustParsePrefix("100.64.0.0/10"),   // shared address space of carrier-grade NATs
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, along with the limited broadcast
+	netip.MustParsePrefix("100::/64"),        // discard-only
+	netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments, including Teredo
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, which embeds any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use IPv4/IPv6 translation
+}
+
+// nat64Prefix is the well-known prefix of NAT64, whose addresses embed an IPv4 address in their last 4 bytes.
+var nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// portRange is an inclusive range of ports.
+type portRange struct {
+	from, to int
+}
+
+// DestinationPolicy tells where the webhooks can be sent to, by the scheme, the port, the hostname and the address of
+// their URL. it is checked when a timer is created and when its webhook is sent. the address is checked once more when
+// the connection is dialed, i.e. after the hostname is resolved, so that a hostname that resolves to a blocked
+// address, e.g. by DNS rebinding, is refused.
+type DestinationPolicy struct {
+	allowPrivate bool
+	allowedCIDRs []netip.Prefix
+	deniedCIDRs  []netip.Prefix
+	allowedHosts []string
+	deniedHosts  []string
+	schemes      map[string]bool
+	ports        []portRange
+}
+
+// NewDestinationPolicy constructs a DestinationPolicy.
+func NewDestinationPolicy(cfg *config.Webhook) (*DestinationPolicy, error) {
+	p := &DestinationPolicy{
+		allowPrivate: cfg.AllowPrivateDestinations,
+		allowedHosts: normalizeHosts(cfg.AllowedHosts),
+		deniedHosts:  normalizeHosts(cfg.DeniedHosts),
+		schemes:      map[string]bool{},
+	}
+
+	var err error
+	if p.allowedCIDRs, err = parsePrefixes(cfg.AllowedCIDRs); err != nil {
+		return nil, fmt.Errorf("invalid allowed CIDRs: %w", err)
+	}
+
+	if p.deniedCIDRs, err = parsePrefixes(cfg.DeniedCIDRs); err != nil {
+		return nil, fmt.Errorf("invalid denied CIDRs: %w", err)
+	}
+
+	if p.ports, err = parsePortRanges(cfg.AllowedPorts); err != nil {
+		return nil, fmt.Errorf("invalid allowed ports: %w", err)
+	}
+
+	schemes := cfg.AllowedSchemes
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+	for _, scheme := range schemes {
+		p.schemes[strings.ToLower(strings.TrimSpace(scheme))] = true
+	}
+
+	return p, nil
+}
+
+// AllowURL returns timer.ErrDestinationNotAllowed when the webhooks cannot be sent to the URL.
+func (p *DestinationPolicy) AllowURL(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if !p.schemes[scheme] {
+		return notAllowed("scheme %q", scheme)
+	}
+
+	port, err := urlPort(u)
+	if err != nil {
+		return notAllowed("invalid port: %v", err)
+	}
+
+	if !p.allowPort(port) {
+		return notAllowed("port %d", port)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return notAllowed("URL has no host")
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if len(p.allowedHosts) > 0 && !containsAddr(p.allowedCIDRs, addr.Unmap()) {
+			return notAllowed("address %s is not an allowed host", addr)
+		}
+		return p.allowAddr(addr)
+	}
+
+	return p.allowHost(host)
+}
+
+// control is the Control of the dialer of the webhooks. it checks the address that is actually dialed.
+func (p *DestinationPolicy) control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return notAllowed("invalid address %q", address)
+	}
+
+	if !p.allowPort(int(addrPort.Port())) {
+		return notAllowed("port %d", addrPort.Port())
+	}
+
+	return p.allowAddr(addrPort.Addr())
+}
+
+func (p *DestinationPolicy) allowHost(host string) error {
+	switch {
+	case matchHost(p.deniedHosts, host):
+		return notAllowed("host %s is denied", host)
+	case len(p.allowedHosts) > 0 && !matchHost(p.allowedHosts, host):
+		return notAllowed("host %s is not allowed", host)
+	case !p.allowPrivate && (host == "localhost" || strings.HasSuffix(host, ".localhost")):
+		return notAllowed("host %s is loopback", host)
+	}
+
+	return nil
+}
+
+func (p *DestinationPolicy) allowAddr(addr netip.Addr) error {
+	addr = addr.Unmap().WithZone("")
+	switch {
+	case containsAddr(p.deniedCIDRs, addr):
+		return notAllowed("address %s is denied", addr)
+	case containsAddr(p.allowedCIDRs, addr):
+		return nil
+	case !p.allowPrivate && !isPublic(addr):
+		return notAllowed("address %s is not public", addr)
+	}
+
+	return nil
+}
+
+func (p *DestinationPolicy) allowPort(port int) bool {
+	if len(p.ports) == 0 {
+		return true
+	}
+
+	for _, r := range p.ports {
+		if port >= r.from && port <= r.to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isPublic tells whether the address is reachable on the public internet.
+func isPublic(addr netip.Addr) bool {
+	if nat64Prefix.Contains(addr) {
+		b := addr.As16()
+		return isPublic(netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]}))
+	}
+
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsMulticast() ||
+		addr.IsUnspecified() || !addr.IsGlobalUnicast() {
+		return false
+	}
+
+	return !containsAddr(nonPublicPrefixes, addr)
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchHost tells whether the host is one of the hosts, where *.example.com matches the subdomains of example.com.
+func matchHost(hosts []string, host string) bool {
+	for _, h := range hosts {
+		if h == host || (strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// urlPort returns the port of the URL, or the default port of its scheme.
+func urlPort(u *url.URL) (int, error) {
+	port := u.Port()
+	switch {
+	case port != "":
+		return strconv.Atoi(port)
+	case strings.EqualFold(u.Scheme, "https"):
+		return 443, nil
+	default:
+		return 80, nil
+	}
+}
+
+func normalizeHosts(hosts []string) []string {
+	normalized := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		if h = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(h)), "."); h != "" {
+			normalized = append(normalized, h)
+		}
+	}
+	return normalized
+}
+
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func parsePortRanges(ports []string) ([]portRange, error) {
+	ranges := make([]portRange, 0, len(ports))
+	for _, port := range ports {
+		from, to, isRange := strings.Cut(strings.TrimSpace(port), "-")
+		if !isRange {
+			to = from
+		}
+
+		r := portRange{}
+		var err error
+		if r.from, err = strconv.Atoi(from); err != nil {
+			return nil, err
+		}
+		if r.to, err = strconv.Atoi(to); err != nil {
+			return nil, err
+		}
+		if r.from < 1 || r.to > 65535 || r.from > r.to {
+			return nil, fmt.Errorf("invalid port range %q", port)
+		}
+
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func notAllowed(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", timer.ErrDestinationNotAllowed, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,136 @@
+package timer
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+	"github.com/cubny/httpqueue/internal/config"
+)
+
+func TestDestinationPolicy_AllowURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Webhook
+		rawURL  string
+		allowed bool
+	}{
+		{name: "public host", rawURL: "https://example.com/hooks", allowed: true},
+		{name: "public address", rawURL: "http://93.184.216.34/hooks", allowed: true},
+		{name: "loopback address", rawURL: "http://127.0.0.1:8080/hooks"},
+		{name: "IPv4-mapped loopback address", rawURL: "http://[::ffff:127.0.0.1]/hooks"},
+		{name: "IPv6 loopback address", rawURL: "http://[::1]/hooks"},
+		{name: "metadata service", rawURL: "http://169.254.169.254/latest/meta-data"},
+		{name: "private address", rawURL: "http://10.0.0.1/hooks"},
+		{name: "carrier-grade NAT address", rawURL: "http://100.64.0.1/hooks"},
+		{name: "unspecified address", rawURL: "http://0.0.0.0/hooks"},
+		{name: "NAT64 of a private address", rawURL: "http://[64:ff9b::a00:1]/hooks"},
+		{name: "NAT64 of a public address", rawURL: "http://[64:ff9b::5db8:d822]/hooks", allowed: true},
+		{name: "localhost", rawURL: "http://localhost:8080/hooks"},
+		{name: "subdomain of localhost", rawURL: "http://api.localhost./hooks"},
+		{
+			name:    "private address when private destinations are allowed",
+			cfg:     config.Webhook{AllowPrivateDestinations: true},
+			rawURL:  "http://10.0.0.1/hooks",
+			allowed: true,
+		},
+		{
+			name:    "allowed CIDR",
+			cfg:     config.Webhook{AllowedCIDRs: []string{"10.1.0.0/16"}},
+			rawURL:  "http://10.1.2.3/hooks",
+			allowed: true,
+		},
+		{
+			name:   "denied CIDR takes precedence",
+			cfg:    config.Webhook{AllowPrivateDestinations: true, AllowedCIDRs: []string{"10.0.0.0/8"}, DeniedCIDRs: []string{"10.1.0.0/16"}},
+			rawURL: "http://10.1.2.3/hooks",
+		},
+		{name: "scheme", rawURL: "ftp://example.com/hooks"},
+		{
+			name:    "allowed scheme",
+			cfg:     config.Webhook{AllowedSchemes: []string{"https"}},
+			rawURL:  "https://example.com/hooks",
+			allowed: true,
+		},
+		{
+			name:   "scheme that is not allowed",
+			cfg:    config.Webhook{AllowedSchemes: []string{"https"}},
+			rawURL: "http://example.com/hooks",
+		},
+		{
+			name:    "allowed port range",
+			cfg:     config.Webhook{AllowedPorts: []string{"443", "8000-8999"}},
+			rawURL:  "http://example.com:8080/hooks",
+			allowed: true,
+		},
+		{
+			name:   "default port that is not allowed",
+			cfg:    config.Webhook{AllowedPorts: []string{"443"}},
+			rawURL: "http://example.com/hooks",
+		},
+		{
+			name:    "allowed host",
+			cfg:     config.Webhook{AllowedHosts: []string{"*.example.com"}},
+			rawURL:  "https://api.example.com/hooks",
+			allowed: true,
+		},
+		{
+			name:   "host that is not allowed",
+			cfg:    config.Webhook{AllowedHosts: []string{"*.example.com"}},
+			rawURL: "https://example.org/hooks",
+		},
+		{
+			name:   "address when only hosts are allowed",
+			cfg:    config.Webhook{AllowedHosts: []string{"*.example.com"}},
+			rawURL: "https://93.184.216.34/hooks",
+		},
+		{
+			name:   "denied host",
+			cfg:    config.Webhook{DeniedHosts: []string{"Internal.Example.com"}},
+			rawURL: "https://internal.example.com./hooks",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewDestinationPolicy(&tt.cfg)
+			require.NoError(t, err)
+
+			u, err := url.Parse(tt.rawURL)
+			require.NoError(t, err)
+
+			err = p.AllowURL(u)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, timer.ErrDestinationNotAllowed)
+			}
+		})
+	}
+}
+
+func TestDestinationPolicy_control(t *testing.T) {
+	p, err := NewDestinationPolicy(&config.Webhook{AllowedPorts: []string{"443"}})
+	require.NoError(t, err)
+
+	assert.NoError(t, p.control("tcp", "93.184.216.34:443", nil))
+	assert.ErrorIs(t, p.control("tcp", "127.0.0.1:443", nil), timer.ErrDestinationNotAllowed)
+	assert.ErrorIs(t, p.control("tcp6", "[fe80::1%eth0]:443", nil), timer.ErrDestinationNotAllowed)
+	assert.ErrorIs(t, p.control("tcp", "93.184.216.34:80", nil), timer.ErrDestinationNotAllowed)
+}
+
+func TestNewDestinationPolicy_Invalid(t *testing.T) {
+	for _, cfg := range []config.Webhook{
+		{AllowedCIDRs: []string{"10.0.0.0"}},
+		{DeniedCIDRs: []string{"invalid"}},
+		{AllowedPorts: []string{"0"}},
+		{AllowedPorts: []string{"9000-8000"}},
+		{AllowedPorts: []string{"http"}},
+	} {
+		_, err := NewDestinationPolicy(&cfg)
+		assert.Error(t, err, cfg)
+	}
+}
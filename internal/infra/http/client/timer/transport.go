@@ -0,0 +1,249 @@
+package timer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cubny/httpqueue/internal/config"
+)
+
+// newHTTPClient returns the HTTP client of the webhooks, which dials and follows redirects only where the destination
+// policy allows. the overall timeout is not set on the client but on the context of every request, so that a timer
+// can override it.
+func newHTTPClient(cfg *config.Webhook, policy *DestinationPolicy) (*http.Client, error) {
+	switch {
+	case cfg.Timeout < 0 || cfg.ConnectTimeout < 0 || cfg.TLSHandshakeTimeout < 0 || cfg.ResponseHeaderTimeout < 0:
+		return nil, errors.New("timeouts must not be negative")
+	case cfg.MaxIdleConnsPerHost < 0:
+		return nil, errors.New("max idle conns per host must not be negative")
+	case cfg.MaxRedirects < 0:
+		return nil, errors.New("max redirects must not be negative")
+	}
+
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := newProxy(cfg, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   cfg.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+		Control:   policy.control,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = proxy.dialContext(dialer)
+	transport.TLSClientConfig = tlsConfig
+	transport.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+
+	maxRedirects := cfg.MaxRedirects
+	return &http.Client{
+		Transport: proxy.roundTripper(transport),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			switch {
+			case maxRedirects == 0:
+				return http.ErrUseLastResponse
+			case len(via) > maxRedirects:
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return policy.AllowURL(req.URL)
+		},
+	}, nil
+}
+
+// newTLSConfig returns the TLS config that trusts the certificate authorities of the system along with the ones of
+// the CA file, and presents the client certificate, if any. the files are read once, i.e. a renewed certificate is
+// picked up on restart.
+func newTLSConfig(cfg *config.Webhook) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if cfg.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		bundle, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the CA file: %w", err)
+		}
+
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errors.New("no certificate is found in the CA file")
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if (cfg.ClientCertFile == "") != (cfg.ClientKeyFile == "") {
+		return nil, errors.New("the client certificate and key must be set together")
+	}
+
+	if cfg.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load the client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// proxy sends the webhooks through an HTTP(S) proxy, except the ones to the hosts of noProxy. a nil proxy sends
+// every webhook directly.
+//
+// as the proxy dials the webhooks that go through it, the addresses of their hostnames are checked by the destination
+// policy before the webhooks are handed to the proxy. the proxy resolves the hostnames once more though, so a hostname
+// whose addresses change in between, e.g. by DNS rebinding, can still reach an address that the policy does not allow.
+type proxy struct {
+	url *url.URL
+	// addr is the address the transport dials the proxy at, i.e. its host and port.
+	addr    string
+	noProxy []string
+	policy  *DestinationPolicy
+	// lookup resolves the hostnames of the webhooks that go through the proxy.
+	lookup func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// newProxy returns nil when no proxy is configured.
+func newProxy(cfg *config.Webhook, policy *DestinationPolicy) (*proxy, error) {
+	if cfg.ProxyURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(cfg.ProxyURL)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", cfg.ProxyURL)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+
+	port, err := urlPort(u)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy port: %w", err)
+	}
+
+	return &proxy{
+		url:     u,
+		addr:    net.JoinHostPort(u.Hostname(), strconv.Itoa(port)),
+		noProxy: normalizeHosts(cfg.NoProxy),
+		policy:  policy,
+		lookup: func(ctx context.Context, host string) ([]netip.Addr, error) {
+			return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		},
+	}, nil
+}
+
+// proxiedKey is the context key of the requests that go through the proxy. its value is the URL of the proxy.
+type proxiedKey struct{}
+
+// roundTripperFunc is an http.RoundTripper function.
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// roundTripper decides by proxyURL whether a request goes through the proxy, and marks the context of the ones that do,
+// so that the transport sends them to the proxy and dialContext tells its dials to the proxy apart from the others.
+func (p *proxy) roundTripper(transport *http.Transport) http.RoundTripper {
+	if p == nil {
+		return transport
+	}
+
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		u, _ := req.Context().Value(proxiedKey{}).(*url.URL)
+		return u, nil
+	}
+
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		u, err := p.proxyURL(req)
+		if err != nil {
+			if req.Body != nil {
+				_ = req.Body.Close()
+			}
+			return nil, err
+		}
+
+		if u != nil {
+			req = req.WithContext(context.WithValue(req.Context(), proxiedKey{}, u))
+		}
+
+		return transport.RoundTrip(req)
+	})
+}
+
+// proxyURL decides whether the request goes through the proxy. it fails when an address of the host of the request is not allowed by the
+// destination policy.
+func (p *proxy) proxyURL(req *http.Request) (*url.URL, error) {
+	host := strings.TrimSuffix(strings.ToLower(req.URL.Hostname()), ".")
+	if p == nil || matchHost(p.noProxy, host) {
+		return nil, nil
+	}
+
+	if err := p.allowHost(req.Context(), host); err != nil {
+		return nil, err
+	}
+
+	return p.url, nil
+}
+
+// allowHost checks the addresses of the host by the destination policy, as the proxy does not.
+func (p *proxy) allowHost(ctx context.Context, host string) error {
+	addrs := make([]netip.Addr, 0, 1)
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, addr)
+	} else if addrs, err = p.lookup(ctx, host); err != nil {
+		return fmt.Errorf("cannot resolve the host %s: %w", host, err)
+	}
+
+	for _, addr := range addrs {
+		if err := p.policy.allowAddr(addr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dialContext is the DialContext of the transport. the proxy is dialed without the destination policy, as it is
+// usually on a private network. the webhooks that go through the proxy are checked by proxyURL instead. only the dials
+// of the requests that roundTripper marks as proxied skip the policy, so that a webhook that is sent directly to the
+// address of the proxy, e.g. because its host is in noProxy, is still checked.
+func (p *proxy) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if p == nil {
+		return dialer.DialContext
+	}
+
+	proxyDialer := *dialer
+	proxyDialer.Control = nil
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if proxied, _ := ctx.Value(proxiedKey{}).(*url.URL); proxied != nil && addr == p.addr {
+			return proxyDialer.DialContext(ctx, network, addr)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
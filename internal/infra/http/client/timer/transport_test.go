@@ -0,0 +1,233 @@
+package timer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+	"github.com/cubny/httpqueue/internal/config"
+)
+
+func newTestHTTPClient(t *testing.T, cfg *config.Webhook) *http.Client {
+	policy, err := NewDestinationPolicy(cfg)
+	require.NoError(t, err)
+
+	client, err := newHTTPClient(cfg, policy)
+	require.NoError(t, err)
+	return client
+}
+
+func TestNewHTTPClient_Redirects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/done":
+			w.WriteHeader(http.StatusNoContent)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			http.Redirect(w, r, "/done", http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	t.Run("does not follow the redirects", func(t *testing.T) {
+		client := newTestHTTPClient(t, &config.Webhook{AllowPrivateDestinations: true})
+
+		resp, err := client.Get(ts.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+	})
+
+	t.Run("follows the redirects", func(t *testing.T) {
+		client := newTestHTTPClient(t, &config.Webhook{AllowPrivateDestinations: true, MaxRedirects: 1})
+
+		resp, err := client.Get(ts.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("stops after the max redirects", func(t *testing.T) {
+		client := newTestHTTPClient(t, &config.Webhook{AllowPrivateDestinations: true, MaxRedirects: 2})
+
+		_, err := client.Get(ts.URL + "/loop")
+		require.Error(t, err)
+		assert.False(t, isRequestErrorRetryable(err))
+	})
+}
+
+func TestNewHTTPClient_TLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	// the certificate of the test server is used as the CA bundle and as the client certificate.
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	key, err := x509.MarshalPKCS8PrivateKey(ts.TLS.Certificates[0].PrivateKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600))
+
+	t.Run("does not trust an unknown authority", func(t *testing.T) {
+		client := newTestHTTPClient(t, &config.Webhook{AllowPrivateDestinations: true})
+
+		_, err := client.Get(ts.URL)
+		assert.Error(t, err)
+	})
+
+	t.Run("trusts the CA bundle and presents the client certificate", func(t *testing.T) {
+		client := newTestHTTPClient(t, &config.Webhook{
+			AllowPrivateDestinations: true,
+			CAFile:                   certFile,
+			ClientCertFile:           certFile,
+			ClientKeyFile:            keyFile,
+		})
+
+		resp, err := client.Get(ts.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		for _, cfg := range []config.Webhook{
+			{CAFile: filepath.Join(dir, "missing.pem")},
+			{CAFile: keyFile},
+			{ClientCertFile: certFile},
+			{ClientCertFile: certFile, ClientKeyFile: certFile},
+		} {
+			_, err := newHTTPClient(&cfg, &DestinationPolicy{})
+			assert.Error(t, err)
+		}
+	})
+}
+
+func TestNewHTTPClient_Proxy(t *testing.T) {
+	var proxied *url.URL
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxyServer.Close()
+
+	// the proxy is on the loopback address, which the destination policy does not allow.
+	client := newTestHTTPClient(t, &config.Webhook{ProxyURL: proxyServer.URL})
+
+	resp, err := client.Get("http://93.184.216.34/hooks")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.NotNil(t, proxied)
+	assert.Equal(t, "http://93.184.216.34/hooks", proxied.String())
+
+	// the addresses of the webhooks are checked before they are handed to the proxy
+	proxied = nil
+	_, err = client.Get("http://10.0.0.1/hooks")
+	assert.ErrorIs(t, err, timer.ErrDestinationNotAllowed)
+	assert.Nil(t, proxied)
+}
+
+func TestNewHTTPClient_NoProxyToTheProxyAddress(t *testing.T) {
+	var requests int
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxyServer.Close()
+
+	// the webhook is sent directly, to the same address as the proxy, which the destination policy does not allow.
+	client := newTestHTTPClient(t, &config.Webhook{ProxyURL: proxyServer.URL, NoProxy: []string{"127.0.0.1"}})
+
+	_, err := client.Get(proxyServer.URL + "/hooks")
+	assert.ErrorIs(t, err, timer.ErrDestinationNotAllowed)
+	assert.Zero(t, requests)
+
+	// the requests that go through the proxy still reach it
+	resp, err := client.Get("http://93.184.216.34/hooks")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, 1, requests)
+}
+
+func TestProxy_ProxyURL(t *testing.T) {
+	cfg := &config.Webhook{ProxyURL: "http://proxy:3128", NoProxy: []string{"*.internal.example", "direct.example"}}
+	policy, err := NewDestinationPolicy(cfg)
+	require.NoError(t, err)
+
+	p, err := newProxy(cfg, policy)
+	require.NoError(t, err)
+	assert.Equal(t, "proxy:3128", p.addr)
+
+	p.lookup = func(_ context.Context, host string) ([]netip.Addr, error) {
+		switch host {
+		case "example.com":
+			return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+		case "redis.internal.svc":
+			return []netip.Addr{netip.MustParseAddr("10.0.0.7")}, nil
+		case "mixed.example":
+			return []netip.Addr{netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("127.0.0.1")}, nil
+		default:
+			return nil, assert.AnError
+		}
+	}
+
+	tests := []struct {
+		rawURL  string
+		proxied bool
+		wantErr error
+	}{
+		{rawURL: "https://example.com/hooks", proxied: true},
+		{rawURL: "https://93.184.216.34/hooks", proxied: true},
+		{rawURL: "https://direct.example/hooks"},
+		{rawURL: "https://api.internal.example/hooks"},
+		{rawURL: "http://redis.internal.svc/", wantErr: timer.ErrDestinationNotAllowed},
+		{rawURL: "https://mixed.example/hooks", wantErr: timer.ErrDestinationNotAllowed},
+		{rawURL: "https://10.0.0.1/hooks", wantErr: timer.ErrDestinationNotAllowed},
+		{rawURL: "https://unknown.example/hooks", wantErr: assert.AnError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rawURL, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, tt.rawURL, nil)
+			require.NoError(t, err)
+
+			got, err := p.proxyURL(req)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.proxied, got != nil)
+		})
+	}
+
+	var none *proxy
+	got, err := none.proxyURL(&http.Request{URL: &url.URL{Host: "example.com"}})
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	_, err = newProxy(&config.Webhook{ProxyURL: "socks5://proxy:1080"}, policy)
+	assert.Error(t, err)
+}
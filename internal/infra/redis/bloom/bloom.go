@@ -0,0 +1,134 @@
+// Package bloom implements a Bloom filter on top of plain Redis bitmaps, so that it works without the RedisBloom
+// module. the bits of an item are set and read by a single BITFIELD command per bitmap.
+package bloom
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	extRedis "github.com/go-redis/redis/v8"
+)
+
+// MaxBits is the maximum size of a filter, which is the maximum size of a Redis string, i.e. 512MB.
+const MaxBits = 1 << 32
+
+var ErrInvalidParams = errors.New("invalid bloom filter params")
+
+// Filter is a Bloom filter that is stored in Redis bitmaps. a filter can be stored in many bitmaps, e.g. one per
+// partition of time, as long as all of them are named after the filter. see Name.
+type Filter struct {
+	bits   uint64
+	hashes int
+}
+
+// New returns the filter that holds capacity items at the false positive rate per bitmap.
+func New(capacity uint64, falsePositiveRate float64) (*Filter, error) {
+	bits, hashes, err := optimalParams(capacity, falsePositiveRate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Filter{bits: bits, hashes: hashes}, nil
+}
+
+// optimalParams returns the number of the bits and the hash functions of a filter that holds capacity items at the
+// false positive rate.
+func optimalParams(capacity uint64, falsePositiveRate float64) (uint64, int, error) {
+	switch {
+	case capacity == 0:
+		return 0, 0, fmt.Errorf("%w: capacity must be positive", ErrInvalidParams)
+	case falsePositiveRate <= 0 || falsePositiveRate >= 1:
+		return 0, 0, fmt.Errorf("%w: false positive rate must be between 0 and 1", ErrInvalidParams)
+	}
+
+	n := float64(capacity)
+	m := math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	if m > MaxBits {
+		return 0, 0, fmt.Errorf("%w: %.0f bits exceed the maximum size of a Redis string", ErrInvalidParams, m)
+	}
+
+	k := int(math.Max(1, math.Round(m/n*math.Ln2)))
+	return uint64(m), k, nil
+}
+
+// Name returns the key of a bitmap of the filter. the size of the bitmap and the number of the hash functions are a
+// part of the key, so that changing the params of the filter starts new, empty bitmaps rather than reading the
+// existing ones wrongly.
+func (f *Filter) Name(prefix string) string {
+	return fmt.Sprintf("%s:%d:%d", prefix, f.bits, f.hashes)
+}
+
+// Add sets the bits of the item in the bitmap of the key. it can be a part of a pipeline.
+func (f *Filter) Add(ctx context.Context, c extRedis.Cmdable, key, item string) *extRedis.IntSliceCmd {
+	return c.BitField(ctx, key, f.args("SET", item, 1)...)
+}
+
+// Exists tells whether the item is probably added to any of the bitmaps of the keys. it is never false for an added
+// item. the bitmaps are read in a single pipeline.
+func (f *Filter) Exists(ctx context.Context, c extRedis.Cmdable, item string, keys ...string) (bool, error) {
+	args := f.args("GET", item)
+	pipe := c.Pipeline()
+	cmds := make([]*extRedis.IntSliceCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.BitField(ctx, key, args...)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	for _, cmd := range cmds {
+		if allSet(cmd.Val()) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func allSet(bits []int64) bool {
+	for _, bit := range bits {
+		if bit == 0 {
+			return false
+		}
+	}
+
+	return len(bits) > 0
+}
+
+// args are the BITFIELD subcommands of the bits of the item.
+func (f *Filter) args(subcommand, item string, value ...interface{}) []interface{} {
+	locations := f.locations(item)
+	args := make([]interface{}, 0, len(locations)*(3+len(value)))
+	for _, location := range locations {
+		args = append(args, subcommand, "u1", location)
+		args = append(args, value...)
+	}
+	return args
+}
+
+// locations are the bits of the item. they are derived from the two halves of a 128-bit hash of the item, using
+// double hashing, rather than from k independent hash functions.
+func (f *Filter) locations(item string) []uint64 {
+	h := fnv.New128a()
+	_, _ = h.Write([]byte(item))
+	sum := h.Sum(nil)
+
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	// the step is made odd only so that it is never zero. the number of the bits is not a power of two though, so the
+	// step may share a factor with it, or even be a multiple of it, in which case some of the bits of the item coincide.
+	// that merely raises the false positive rate for the few items it happens to. the locations are not changed to
+	// avoid it, as the bits of the items that are already in the bitmaps would move.
+	h2 := binary.BigEndian.Uint64(sum[8:]) | 1
+
+	locations := make([]uint64, f.hashes)
+	for i := range locations {
+		locations[i] = (h1 + uint64(i)*h2) % f.bits
+	}
+
+	return locations
+}
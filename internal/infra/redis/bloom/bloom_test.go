@@ -0,0 +1,189 @@
+package bloom
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mocks "github.com/cubny/httpqueue/internal/mocks/external/redis"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name              string
+		capacity          uint64
+		falsePositiveRate float64
+		wantName          string
+		wantBits          uint64
+		wantHashes        int
+		wantErr           error
+	}{
+		{
+			name:              "1% of a thousand",
+			capacity:          1000,
+			falsePositiveRate: 0.01,
+			wantName:          "filter:9586:7",
+			wantBits:          9586,
+			wantHashes:        7,
+		},
+		{
+			name:              "0.1% of ten million",
+			capacity:          10_000_000,
+			falsePositiveRate: 0.001,
+			wantName:          "filter:143775876:10",
+			wantBits:          143775876,
+			wantHashes:        10,
+		},
+		{
+			name:              "zero capacity",
+			falsePositiveRate: 0.01,
+			wantErr:           ErrInvalidParams,
+		},
+		{
+			name:              "zero false positive rate",
+			capacity:          1000,
+			falsePositiveRate: 0,
+			wantErr:           ErrInvalidParams,
+		},
+		{
+			name:              "false positive rate of 1",
+			capacity:          1000,
+			falsePositiveRate: 1,
+			wantErr:           ErrInvalidParams,
+		},
+		{
+			name:              "too large",
+			capacity:          1_000_000_000,
+			falsePositiveRate: 0.0001,
+			wantErr:           ErrInvalidParams,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(tt.capacity, tt.falsePositiveRate)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantName, f.Name("filter"))
+			assert.Equal(t, tt.wantBits, f.bits)
+			assert.Equal(t, tt.wantHashes, f.hashes)
+		})
+	}
+}
+
+func TestFilter_locations(t *testing.T) {
+	f, err := New(1000, 0.01)
+	require.NoError(t, err)
+
+	locations := f.locations("item")
+	assert.Len(t, locations, f.hashes)
+	assert.Equal(t, locations, f.locations("item"))
+	assert.NotEqual(t, locations, f.locations("another item"))
+	for _, location := range locations {
+		assert.Less(t, location, f.bits)
+	}
+}
+
+func TestFilter_falsePositiveRate(t *testing.T) {
+	f, err := New(10_000, 0.01)
+	require.NoError(t, err)
+
+	bitmap := make(map[uint64]bool)
+	for i := 0; i < 10_000; i++ {
+		for _, location := range f.locations(fmt.Sprintf("added-%d", i)) {
+			bitmap[location] = true
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10_000; i++ {
+		exists := true
+		for _, location := range f.locations(fmt.Sprintf("not-added-%d", i)) {
+			exists = exists && bitmap[location]
+		}
+		if exists {
+			falsePositives++
+		}
+	}
+
+	assert.Less(t, falsePositives, 200)
+}
+
+func TestFilter_Add(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	f, err := New(1000, 0.01)
+	require.NoError(t, err)
+
+	pipeliner := mocks.NewRedisPipeliner(ctrl)
+	pipeliner.EXPECT().BitField(gomock.Any(), "key", f.args("SET", "item", 1)...)
+
+	f.Add(context.Background(), pipeliner, "key", "item")
+}
+
+func TestFilter_Exists(t *testing.T) {
+	allSet := []int64{1, 1, 1, 1, 1, 1, 1}
+	oneUnset := []int64{1, 1, 1, 0, 1, 1, 1}
+
+	tests := []struct {
+		name      string
+		bitfields []*redis.IntSliceCmd
+		execErr   error
+		want      bool
+		wantErr   assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "all the bits are set in a bitmap",
+			bitfields: []*redis.IntSliceCmd{newIntSliceResult(oneUnset, nil), newIntSliceResult(allSet, nil)},
+			want:      true,
+			wantErr:   assert.NoError,
+		},
+		{
+			name:      "a bit is not set in any bitmap",
+			bitfields: []*redis.IntSliceCmd{newIntSliceResult(oneUnset, nil), newIntSliceResult(oneUnset, nil)},
+			want:      false,
+			wantErr:   assert.NoError,
+		},
+		{
+			name:      "pipeline fails",
+			bitfields: []*redis.IntSliceCmd{newIntSliceResult(nil, assert.AnError), newIntSliceResult(allSet, nil)},
+			execErr:   assert.AnError,
+			want:      false,
+			wantErr:   assert.Error,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	f, err := New(1000, 0.01)
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			pipeliner := mocks.NewRedisPipeliner(ctrl)
+			redisClient.EXPECT().Pipeline().Return(pipeliner)
+			pipeliner.EXPECT().BitField(gomock.Any(), "key1", f.args("GET", "item")...).Return(tt.bitfields[0])
+			pipeliner.EXPECT().BitField(gomock.Any(), "key2", f.args("GET", "item")...).Return(tt.bitfields[1])
+			pipeliner.EXPECT().Exec(gomock.Any()).Return(nil, tt.execErr)
+
+			got, err := f.Exists(context.Background(), redisClient, "item", "key1", "key2")
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func newIntSliceResult(val []int64, err error) *redis.IntSliceCmd {
+	cmd := redis.NewIntSliceCmd(context.Background())
+	cmd.SetVal(val)
+	cmd.SetErr(err)
+	return cmd
+}
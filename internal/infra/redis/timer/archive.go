@@ -0,0 +1,161 @@
+package timer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	extRedis "github.com/go-redis/redis/v8"
+
+	"github.com/cubny/httpqueue/internal/config"
+	"github.com/cubny/httpqueue/internal/infra/redis/bloom"
+)
+
+const (
+	// ArchiveIndexBitmap is a Bloom filter on plain Redis bitmaps. it works with any Redis.
+	ArchiveIndexBitmap = "bitmap"
+	// ArchiveIndexRedisBloom is a Bloom filter of the RedisBloom module.
+	ArchiveIndexRedisBloom = "redisbloom"
+
+	// timerArchiveName is the name of the bitmaps of the archived timers. see bloom.Filter.Name.
+	timerArchiveName = "timerArchive"
+	// timerBloomFilterName is the name of the RedisBloom filters of the archived timers.
+	timerBloomFilterName = "timerBloomFilter"
+)
+
+var (
+	// ErrUnknownArchiveIndex indicates an archive index that is not supported.
+	ErrUnknownArchiveIndex = fmt.Errorf("unknown archive index")
+	// ErrInvalidArchivePartition indicates a partition or a retention of the archive that is not valid.
+	ErrInvalidArchivePartition = fmt.Errorf("invalid archive partition")
+)
+
+// ArchiveIndex remembers the IDs of the archived timers. for space efficiency the index is probabilistic: it can
+// tell that a timer is archived when it is not, but never the other way around.
+// the keys of the index are not pinned to the hash slot of the timers, as they are large and only written in the
+// transaction that archives a timer, which the cluster client splits by the hash slot.
+type ArchiveIndex interface {
+	// Add adds the timer ID that is archived at the time to the index as a part of the pipeline.
+	Add(ctx context.Context, pipe extRedis.Pipeliner, timerID string, at time.Time)
+	// Exists checks whether the timer ID is probably added to the index within the retention before now.
+	Exists(ctx context.Context, client extRedis.UniversalClient, timerID string, now time.Time) (bool, error)
+}
+
+// NewArchiveIndex constructs the archive index of the config.
+func NewArchiveIndex(cfg *config.DB) (ArchiveIndex, error) {
+	switch {
+	case cfg.ArchivePartition <= 0:
+		return nil, fmt.Errorf("%w: partition must be positive", ErrInvalidArchivePartition)
+	case cfg.ArchiveRetention < cfg.ArchivePartition:
+		return nil, fmt.Errorf("%w: retention cannot be shorter than a partition", ErrInvalidArchivePartition)
+	}
+
+	switch cfg.ArchiveIndex {
+	case ArchiveIndexBitmap:
+		filter, err := bloom.New(cfg.ArchiveCapacity, cfg.ArchiveFalsePositiveRate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the bitmap archive index: %w", err)
+		}
+		p := newPartitions(filter.Name(timerArchiveName), cfg.ArchivePartition, cfg.ArchiveRetention)
+		return &bitmapArchiveIndex{filter: filter, partitions: p}, nil
+	case ArchiveIndexRedisBloom:
+		p := newPartitions(timerBloomFilterName, cfg.ArchivePartition, cfg.ArchiveRetention)
+		return &redisBloomArchiveIndex{partitions: p}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownArchiveIndex, cfg.ArchiveIndex)
+	}
+}
+
+// partitions split an archive index by time, e.g. daily, so that the index does not grow forever. a partition
+// expires after the retention passes its end, and then the timers of the partition are not known to be archived
+// anymore.
+type partitions struct {
+	name      string
+	size      time.Duration
+	retention time.Duration
+}
+
+func newPartitions(name string, size, retention time.Duration) partitions {
+	return partitions{name: name, size: size, retention: retention}
+}
+
+// number is of the partition of the time, counted from the Unix epoch.
+func (p partitions) number(at time.Time) int64 {
+	return at.UnixNano() / int64(p.size)
+}
+
+// key is of the partition of the time.
+func (p partitions) key(at time.Time) string {
+	return fmt.Sprintf("%s:%d", p.name, p.number(at))
+}
+
+// expireAt is the time the partition of the time expires.
+func (p partitions) expireAt(at time.Time) time.Time {
+	end := time.Unix(0, (p.number(at)+1)*int64(p.size))
+	return end.Add(p.retention)
+}
+
+// keys are of the partitions that are not expired by now, the latest first.
+func (p partitions) keys(now time.Time) []string {
+	latest := p.number(now)
+	oldest := p.number(now.Add(-p.retention))
+
+	keys := make([]string, 0, latest-oldest+1)
+	for n := latest; n >= oldest; n-- {
+		keys = append(keys, fmt.Sprintf("%s:%d", p.name, n))
+	}
+	return keys
+}
+
+// bitmapArchiveIndex is a Bloom filter on plain Redis bitmaps, a bitmap per partition.
+type bitmapArchiveIndex struct {
+	filter     *bloom.Filter
+	partitions partitions
+}
+
+func (i *bitmapArchiveIndex) Add(ctx context.Context, pipe extRedis.Pipeliner, timerID string, at time.Time) {
+	key := i.partitions.key(at)
+	i.filter.Add(ctx, pipe, key, timerID)
+	pipe.ExpireAt(ctx, key, i.partitions.expireAt(at))
+}
+
+func (i *bitmapArchiveIndex) Exists(ctx context.Context, client extRedis.UniversalClient, timerID string, now time.Time) (bool, error) {
+	return i.filter.Exists(ctx, client, timerID, i.partitions.keys(now)...)
+}
+
+// redisBloomArchiveIndex is a Bloom filter of the RedisBloom module per partition. the filter is created by the
+// module on the first BF.ADD with its default capacity and error rate.
+type redisBloomArchiveIndex struct {
+	partitions partitions
+}
+
+func (i *redisBloomArchiveIndex) Add(ctx context.Context, pipe extRedis.Pipeliner, timerID string, at time.Time) {
+	key := i.partitions.key(at)
+	pipe.Do(ctx, "BF.ADD", key, timerID)
+	pipe.ExpireAt(ctx, key, i.partitions.expireAt(at))
+}
+
+func (i *redisBloomArchiveIndex) Exists(ctx context.Context, client extRedis.UniversalClient, timerID string, now time.Time) (bool, error) {
+	keys := i.partitions.keys(now)
+	pipe := client.Pipeline()
+	cmds := make([]*extRedis.Cmd, len(keys))
+	for n, key := range keys {
+		cmds[n] = pipe.Do(ctx, "BF.EXISTS", key, timerID)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	for _, cmd := range cmds {
+		exists, err := cmd.Bool()
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,305 @@
+package timer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+	"github.com/cubny/httpqueue/internal/config"
+	"github.com/cubny/httpqueue/internal/infra/redis/bloom"
+	mocks "github.com/cubny/httpqueue/internal/mocks/external/redis"
+)
+
+const day = 24 * time.Hour
+
+func TestNewArchiveIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *config.DB
+		want    ArchiveIndex
+		wantErr error
+	}{
+		{
+			name: "bitmap",
+			cfg: &config.DB{ArchiveIndex: ArchiveIndexBitmap, ArchiveCapacity: 1000, ArchiveFalsePositiveRate: 0.01,
+				ArchivePartition: day, ArchiveRetention: 30 * day},
+			want: &bitmapArchiveIndex{},
+		},
+		{
+			name: "redisbloom",
+			cfg:  &config.DB{ArchiveIndex: ArchiveIndexRedisBloom, ArchivePartition: day, ArchiveRetention: 30 * day},
+			want: &redisBloomArchiveIndex{},
+		},
+		{
+			name: "bitmap of invalid params",
+			cfg: &config.DB{ArchiveIndex: ArchiveIndexBitmap, ArchiveFalsePositiveRate: 0.01,
+				ArchivePartition: day, ArchiveRetention: 30 * day},
+			wantErr: bloom.ErrInvalidParams,
+		},
+		{
+			name:    "zero partition",
+			cfg:     &config.DB{ArchiveIndex: ArchiveIndexRedisBloom, ArchiveRetention: 30 * day},
+			wantErr: ErrInvalidArchivePartition,
+		},
+		{
+			name:    "retention shorter than a partition",
+			cfg:     &config.DB{ArchiveIndex: ArchiveIndexRedisBloom, ArchivePartition: day, ArchiveRetention: time.Hour},
+			wantErr: ErrInvalidArchivePartition,
+		},
+		{
+			name:    "unknown",
+			cfg:     &config.DB{ArchiveIndex: "cuckoo", ArchivePartition: day, ArchiveRetention: 30 * day},
+			wantErr: ErrUnknownArchiveIndex,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewArchiveIndex(tt.cfg)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.IsType(t, tt.want, got)
+		})
+	}
+}
+
+func TestPartitions(t *testing.T) {
+	p := newPartitions("archive", day, 2*day)
+	at := time.Unix(int64(10*day/time.Second)+5, 0)
+
+	assert.Equal(t, "archive:10", p.key(at))
+	assert.Equal(t, time.Unix(int64(13*day/time.Second), 0), p.expireAt(at))
+	assert.Equal(t, []string{"archive:10", "archive:9", "archive:8"}, p.keys(at))
+}
+
+func TestDB_Archive(t *testing.T) {
+	finishedAt := time.Unix(int64(10*day/time.Second)+5, 0)
+	expireAt := time.Unix(int64(12*day/time.Second), 0)
+	tombstone := &timer.Tombstone{TimerID: "1", State: timer.StateSucceeded, FinishedAt: finishedAt}
+
+	tests := []struct {
+		name         string
+		archiveIndex string
+		tombstoneTTL time.Duration
+		expectAdd    func(pipeliner *mocks.RedisPipeliner)
+	}{
+		{
+			name:         "bitmap",
+			archiveIndex: ArchiveIndexBitmap,
+			expectAdd: func(pipeliner *mocks.RedisPipeliner) {
+				pipeliner.EXPECT().BitField(gomock.Any(), "timerArchive:9586:7:10", gomock.Any())
+				pipeliner.EXPECT().ExpireAt(gomock.Any(), "timerArchive:9586:7:10", expireAt)
+			},
+		},
+		{
+			name:         "redisbloom",
+			archiveIndex: ArchiveIndexRedisBloom,
+			expectAdd: func(pipeliner *mocks.RedisPipeliner) {
+				pipeliner.EXPECT().Do(gomock.Any(), "BF.ADD", "timerBloomFilter:10", "1")
+				pipeliner.EXPECT().ExpireAt(gomock.Any(), "timerBloomFilter:10", expireAt)
+			},
+		},
+		{
+			name:         "bitmap with tombstone",
+			archiveIndex: ArchiveIndexBitmap,
+			tombstoneTTL: day,
+			expectAdd: func(pipeliner *mocks.RedisPipeliner) {
+				pipeliner.EXPECT().BitField(gomock.Any(), "timerArchive:9586:7:10", gomock.Any())
+				pipeliner.EXPECT().ExpireAt(gomock.Any(), "timerArchive:9586:7:10", expireAt)
+				pipeliner.EXPECT().Set(gomock.Any(), serializeTombstoneKey("1"),
+					serializeTombstoneValue(fromInternalTombstone(tombstone)), day)
+			},
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			d := newDB(t, redisClient, &config.DB{
+				ArchiveIndex:             tt.archiveIndex,
+				ArchiveCapacity:          1000,
+				ArchiveFalsePositiveRate: 0.01,
+				ArchivePartition:         day,
+				ArchiveRetention:         day,
+				ArchiveTombstoneTTL:      tt.tombstoneTTL,
+			})
+
+			pipeliner := mocks.NewRedisPipeliner(ctrl)
+			redisClient.EXPECT().TxPipeline().Return(pipeliner)
+			pipeliner.EXPECT().Del(gomock.Any(), serializeKey("1"))
+			pipeliner.EXPECT().ZRem(gomock.Any(), timerIndexName, "1")
+			tt.expectAdd(pipeliner)
+			pipeliner.EXPECT().Exec(gomock.Any()).Return(nil, nil)
+
+			err := d.Archive(context.Background(), tombstone)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestDB_IsArchived(t *testing.T) {
+	tests := []struct {
+		name         string
+		archiveIndex string
+		expectExists func(pipeliner *mocks.RedisPipeliner)
+		execErr      error
+		want         bool
+		wantErr      assert.ErrorAssertionFunc
+	}{
+		{
+			name:         "archived in a bitmap",
+			archiveIndex: ArchiveIndexBitmap,
+			expectExists: func(pipeliner *mocks.RedisPipeliner) {
+				pipeliner.EXPECT().BitField(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(newIntSliceResult([]int64{1, 0, 1, 1, 1, 1, 1}, nil))
+				pipeliner.EXPECT().BitField(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(newIntSliceResult([]int64{1, 1, 1, 1, 1, 1, 1}, nil))
+			},
+			want:    true,
+			wantErr: assert.NoError,
+		},
+		{
+			name:         "not archived in any bitmap",
+			archiveIndex: ArchiveIndexBitmap,
+			expectExists: func(pipeliner *mocks.RedisPipeliner) {
+				pipeliner.EXPECT().BitField(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(newIntSliceResult([]int64{1, 0, 1, 1, 1, 1, 1}, nil)).Times(2)
+			},
+			want:    false,
+			wantErr: assert.NoError,
+		},
+		{
+			name:         "bitmap fails",
+			archiveIndex: ArchiveIndexBitmap,
+			expectExists: func(pipeliner *mocks.RedisPipeliner) {
+				pipeliner.EXPECT().BitField(gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(newIntSliceResult(nil, assert.AnError)).Times(2)
+			},
+			execErr: assert.AnError,
+			want:    false,
+			wantErr: assert.Error,
+		},
+		{
+			name:         "archived in a redisbloom filter",
+			archiveIndex: ArchiveIndexRedisBloom,
+			expectExists: func(pipeliner *mocks.RedisPipeliner) {
+				pipeliner.EXPECT().Do(gomock.Any(), "BF.EXISTS", gomock.Any(), "1").
+					Return(redis.NewCmdResult(int64(0), nil))
+				pipeliner.EXPECT().Do(gomock.Any(), "BF.EXISTS", gomock.Any(), "1").
+					Return(redis.NewCmdResult(int64(1), nil))
+			},
+			want:    true,
+			wantErr: assert.NoError,
+		},
+		{
+			name:         "redisbloom fails",
+			archiveIndex: ArchiveIndexRedisBloom,
+			expectExists: func(pipeliner *mocks.RedisPipeliner) {
+				pipeliner.EXPECT().Do(gomock.Any(), "BF.EXISTS", gomock.Any(), "1").
+					Return(redis.NewCmdResult(nil, assert.AnError)).Times(2)
+			},
+			execErr: assert.AnError,
+			want:    false,
+			wantErr: assert.Error,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			d := newDB(t, redisClient, &config.DB{
+				ArchiveIndex:             tt.archiveIndex,
+				ArchiveCapacity:          1000,
+				ArchiveFalsePositiveRate: 0.01,
+				ArchivePartition:         day,
+				ArchiveRetention:         day,
+			})
+
+			pipeliner := mocks.NewRedisPipeliner(ctrl)
+			redisClient.EXPECT().Pipeline().Return(pipeliner)
+			tt.expectExists(pipeliner)
+			pipeliner.EXPECT().Exec(gomock.Any()).Return(nil, tt.execErr)
+
+			got, err := d.IsArchived(context.Background(), "1")
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDB_FindTombstone(t *testing.T) {
+	tombstone := &timer.Tombstone{TimerID: "1", State: timer.StateFailed, FinishedAt: time.Unix(1700000000, 0)}
+
+	tests := []struct {
+		name         string
+		tombstoneTTL time.Duration
+		getResult    *redis.StringCmd
+		want         *timer.Tombstone
+		wantErr      error
+	}{
+		{
+			name:         "found",
+			tombstoneTTL: day,
+			getResult:    redis.NewStringResult(serializeTombstoneValue(fromInternalTombstone(tombstone)), nil),
+			want:         tombstone,
+		},
+		{
+			name:         "not found",
+			tombstoneTTL: day,
+			getResult:    redis.NewStringResult("", redis.Nil),
+		},
+		{
+			name:         "tombstones are not kept",
+			tombstoneTTL: 0,
+		},
+		{
+			name:         "invalid value",
+			tombstoneTTL: day,
+			getResult:    redis.NewStringResult("{", nil),
+			wantErr:      ErrDeserialization,
+		},
+		{
+			name:         "get fails",
+			tombstoneTTL: day,
+			getResult:    redis.NewStringResult("", assert.AnError),
+			wantErr:      assert.AnError,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			d := newDB(t, redisClient, &config.DB{ArchiveTombstoneTTL: tt.tombstoneTTL})
+			if tt.getResult != nil {
+				redisClient.EXPECT().Get(gomock.Any(), serializeTombstoneKey("1")).Return(tt.getResult)
+			}
+
+			got, err := d.FindTombstone(context.Background(), "1")
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func newIntSliceResult(val []int64, err error) *redis.IntSliceCmd {
+	cmd := redis.NewIntSliceCmd(context.Background())
+	cmd.SetVal(val)
+	cmd.SetErr(err)
+	return cmd
+}
@@ -0,0 +1,40 @@
+package timer
+
+import (
+	"context"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+)
+
+// AddAttempt prepends the attempt to the attempt history of the timer. only the latest attempts are kept, and the
+// history expires along with the timer.
+func (d *DB) AddAttempt(ctx context.Context, timerID string, attempt *timer.Attempt) error {
+	key := serializeAttemptsKey(timerID)
+	pipe := d.redisClient.TxPipeline()
+
+	pipe.LPush(ctx, key, serializeAttemptValue(fromInternalAttempt(attempt)))
+	pipe.LTrim(ctx, key, 0, d.attemptsSize-1)
+	pipe.PExpire(ctx, key, d.maxTTL)
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ListAttempts returns the attempt history of the timer, the latest attempt first.
+func (d *DB) ListAttempts(ctx context.Context, timerID string) ([]*timer.Attempt, error) {
+	vals, err := d.redisClient.LRange(ctx, serializeAttemptsKey(timerID), 0, d.attemptsSize-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := make([]*timer.Attempt, 0, len(vals))
+	for _, val := range vals {
+		a, err := deserializeAttemptValue(val)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, toInternalAttempt(a))
+	}
+
+	return attempts, nil
+}
@@ -0,0 +1,107 @@
+package timer
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+	"github.com/cubny/httpqueue/internal/config"
+	mocks "github.com/cubny/httpqueue/internal/mocks/external/redis"
+)
+
+func newAttempt() *timer.Attempt {
+	startedAt := time.UnixMilli(time.Now().UnixMilli())
+	return &timer.Attempt{
+		Run:        1,
+		Number:     2,
+		StartedAt:  startedAt,
+		Duration:   150 * time.Millisecond,
+		StatusCode: http.StatusServiceUnavailable,
+		Outcome:    timer.OutcomeRetry,
+		ErrorClass: timer.AttemptErrorHTTPStatus,
+		Error:      "unexpected HTTP status 503",
+		RetryAt:    startedAt.Add(time.Minute),
+	}
+}
+
+func TestDB_AddAttempt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cfg := &config.DB{TimerMaxTTLDays: 10, AttemptHistorySize: 50}
+
+	redisClient := mocks.NewRedisClient(ctrl)
+	d := newDB(t, redisClient, cfg)
+
+	attempt := newAttempt()
+
+	pipeliner := mocks.NewRedisPipeliner(ctrl)
+	redisClient.EXPECT().TxPipeline().Return(pipeliner)
+
+	pipeliner.EXPECT().LPush(gomock.Any(), serializeAttemptsKey("1"), serializeAttemptValue(fromInternalAttempt(attempt)))
+	pipeliner.EXPECT().LTrim(gomock.Any(), serializeAttemptsKey("1"), int64(0), int64(49))
+	pipeliner.EXPECT().PExpire(gomock.Any(), serializeAttemptsKey("1"), 10*24*time.Hour)
+	pipeliner.EXPECT().Exec(gomock.Any()).Return(nil, nil)
+
+	require.NoError(t, d.AddAttempt(context.Background(), "1", attempt))
+}
+
+func TestDB_ListAttempts(t *testing.T) {
+	attempt := newAttempt()
+	succeeded := &timer.Attempt{Run: 1, Number: 3, StartedAt: attempt.RetryAt, StatusCode: http.StatusOK}
+
+	tests := []struct {
+		name              string
+		redisLRangeResult *redis.StringSliceCmd
+		want              []*timer.Attempt
+		wantErr           bool
+	}{
+		{
+			name: "lists the attempts",
+			redisLRangeResult: redis.NewStringSliceResult([]string{
+				serializeAttemptValue(fromInternalAttempt(succeeded)),
+				serializeAttemptValue(fromInternalAttempt(attempt)),
+			}, nil),
+			want: []*timer.Attempt{succeeded, attempt},
+		},
+		{
+			name:              "no attempts",
+			redisLRangeResult: redis.NewStringSliceResult([]string{}, nil),
+			want:              []*timer.Attempt{},
+		},
+		{
+			name:              "LRange returns error",
+			redisLRangeResult: redis.NewStringSliceResult(nil, assert.AnError),
+			wantErr:           true,
+		},
+		{
+			name:              "malformed value",
+			redisLRangeResult: redis.NewStringSliceResult([]string{"{malformed, json}"}, nil),
+			wantErr:           true,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			d := newDB(t, redisClient, &config.DB{TimerMaxTTLDays: 10, AttemptHistorySize: 50})
+
+			redisClient.EXPECT().LRange(gomock.Any(), serializeAttemptsKey("1"), int64(0), int64(49)).Return(tt.redisLRangeResult)
+
+			got, err := d.ListAttempts(context.Background(), "1")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package timer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	extRedis "github.com/go-redis/redis/v8"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+)
+
+// timerDeadLetterIndexName is a sorted set of the IDs of the dead letters, scored by the time they failed.
+const timerDeadLetterIndexName = keyTag + ":timerDeadLetters"
+
+// purgeBatchSize is the number of the dead letters that are removed at once when all of them are purged.
+const purgeBatchSize = 500
+
+// AddDeadLetter keeps the dead letter until the max TTL of timers.
+func (d *DB) AddDeadLetter(ctx context.Context, deadLetter *timer.DeadLetter) error {
+	internalDeadLetter := fromInternalDeadLetter(deadLetter)
+	pipe := d.redisClient.TxPipeline()
+
+	pipe.Set(ctx, serializeDeadLetterKey(internalDeadLetter.ID), serializeDeadLetterValue(internalDeadLetter), d.maxTTL)
+	pipe.ZAdd(ctx, timerDeadLetterIndexName, deadLetterIndexMember(internalDeadLetter))
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// FindDeadLetter returns nil, nil when nothing found.
+func (d *DB) FindDeadLetter(ctx context.Context, id string) (*timer.DeadLetter, error) {
+	val, err := d.redisClient.Get(ctx, serializeDeadLetterKey(id)).Result()
+	switch {
+	case err == extRedis.Nil:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	return deserializeDeadLetter(val)
+}
+
+// ListDeadLetters walks the dead letter index in the order of the time they failed, starting after the cursor.
+func (d *DB) ListDeadLetters(ctx context.Context, query timer.ListDeadLettersQuery) (*timer.DeadLettersPage, error) {
+	var (
+		cursor *listCursor
+		err    error
+	)
+	if query.Cursor != "" {
+		if cursor, err = decodeListCursor(query.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := d.rangeAfter(ctx, timerDeadLetterIndexName, cursor, scoreMin(time.Time{}, cursor), "+inf", query.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetters, err := d.findIndexedDeadLetters(ctx, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &timer.DeadLettersPage{DeadLetters: make([]*timer.DeadLetter, 0, len(entries))}
+	for i := range entries {
+		if deadLetters[i] != nil {
+			page.DeadLetters = append(page.DeadLetters, deadLetters[i])
+		}
+	}
+
+	if len(entries) == query.Limit {
+		last := entries[len(entries)-1]
+		page.NextCursor = encodeListCursor(listCursor{score: int64(last.Score), id: fmt.Sprint(last.Member)})
+	}
+
+	return page, nil
+}
+
+// findIndexedDeadLetters fetches the dead letters of the index entries in a pipeline. the entries whose dead letter
+// is expired are removed from the index and their dead letter is nil.
+func (d *DB) findIndexedDeadLetters(ctx context.Context, entries []extRedis.Z) ([]*timer.DeadLetter, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	pipe := d.redisClient.Pipeline()
+
+	cmds := make([]*extRedis.StringCmd, 0, len(entries))
+	for _, entry := range entries {
+		cmds = append(cmds, pipe.Get(ctx, serializeDeadLetterKey(fmt.Sprint(entry.Member))))
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && err != extRedis.Nil {
+		return nil, err
+	}
+
+	deadLetters := make([]*timer.DeadLetter, len(entries))
+	expired := make([]interface{}, 0)
+	for i, cmd := range cmds {
+		val, err := cmd.Result()
+		switch {
+		case err == extRedis.Nil:
+			expired = append(expired, entries[i].Member)
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		if deadLetters[i], err = deserializeDeadLetter(val); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(expired) > 0 {
+		if err := d.redisClient.ZRem(ctx, timerDeadLetterIndexName, expired...).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return deadLetters, nil
+}
+
+// ReplayDeadLetter adds the replayed timer following the outbox pattern and removes the dead letter in a single
+// script. it returns timer.ErrTimerExists when a timer with the same ID already exists.
+func (d *DB) ReplayDeadLetter(ctx context.Context, id string, t *timer.Timer) error {
+	internalTimer := fromInternal(t)
+
+	keys := []string{
+		serializeKey(internalTimer.ID),
+		timerIndexName,
+		timerTaskQueueName,
+		serializeDeadLetterKey(id),
+		timerDeadLetterIndexName,
+	}
+	keys = append(keys, staleKeys(internalTimer.ID)...)
+	args := []interface{}{
+		internalTimer.ID,
+		serializeValue(internalTimer),
+		d.maxTTL.Milliseconds(),
+		internalTimer.FireAtSecond,
+		id,
+	}
+
+	added, err := replayDeadLetterScript.Run(ctx, d.redisClient, keys, args...).Int()
+	switch {
+	case err != nil:
+		return err
+	case added == 0:
+		return timer.ErrTimerExists
+	}
+
+	return nil
+}
+
+// PurgeDeadLetters removes the dead letters of the IDs, or all of them in batches when no ID is given.
+func (d *DB) PurgeDeadLetters(ctx context.Context, ids []string) (int, error) {
+	if len(ids) > 0 {
+		return d.removeDeadLetters(ctx, ids)
+	}
+
+	purged := 0
+	for {
+		ids, err := d.redisClient.ZRange(ctx, timerDeadLetterIndexName, 0, purgeBatchSize-1).Result()
+		if err != nil || len(ids) == 0 {
+			return purged, err
+		}
+
+		n, err := d.removeDeadLetters(ctx, ids)
+		purged += n
+		if err != nil {
+			return purged, err
+		}
+	}
+}
+
+func (d *DB) removeDeadLetters(ctx context.Context, ids []string) (int, error) {
+	members := make([]interface{}, 0, len(ids))
+	pipe := d.redisClient.TxPipeline()
+	for _, id := range ids {
+		pipe.Del(ctx, serializeDeadLetterKey(id))
+		members = append(members, id)
+	}
+	removed := pipe.ZRem(ctx, timerDeadLetterIndexName, members...)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return int(removed.Val()), nil
+}
+
+func deserializeDeadLetter(val string) (*timer.DeadLetter, error) {
+	dsDeadLetter, err := deserializeDeadLetterValue(val)
+	if err != nil {
+		return nil, ErrDeserialization
+	}
+
+	deadLetter, err := toInternalDeadLetter(dsDeadLetter)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+
+	return deadLetter, nil
+}
@@ -0,0 +1,238 @@
+package timer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+	"github.com/cubny/httpqueue/internal/config"
+	mocks "github.com/cubny/httpqueue/internal/mocks/external/redis"
+)
+
+func newDeadLetter(t *testing.T) *timer.DeadLetter {
+	tm, err := timer.NewTimer("http://valid.url/hooks", 0, 0, 0)
+	require.NoError(t, err)
+
+	deadLetter := timer.NewDeadLetter(tm, 3, assert.AnError).WithResponse(http.StatusNotFound, "no such hook")
+	deadLetter.DeadAt = time.Unix(deadLetter.DeadAt.Unix(), 0)
+	deadLetter.Timer.FireAt = time.Unix(deadLetter.Timer.FireAt.Unix(), 0)
+	deadLetter.Timer.CreatedAt = time.Unix(deadLetter.Timer.CreatedAt.Unix(), 0)
+	return deadLetter
+}
+
+func TestDB_AddDeadLetter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cfg := &config.DB{TimerMaxTTLDays: 10}
+
+	redisClient := mocks.NewRedisClient(ctrl)
+	d := newDB(t, redisClient, cfg)
+
+	deadLetter := fromInternalDeadLetter(newDeadLetter(t))
+
+	pipeliner := mocks.NewRedisPipeliner(ctrl)
+	redisClient.EXPECT().TxPipeline().Return(pipeliner)
+
+	pipeliner.EXPECT().Set(gomock.Any(), serializeDeadLetterKey(deadLetter.ID), serializeDeadLetterValue(deadLetter), 10*24*time.Hour)
+	pipeliner.EXPECT().ZAdd(gomock.Any(), timerDeadLetterIndexName, deadLetterIndexMember(deadLetter))
+	pipeliner.EXPECT().Exec(gomock.Any()).Return(nil, nil)
+
+	deadLetterInternal, err := toInternalDeadLetter(deadLetter)
+	require.NoError(t, err)
+	require.NoError(t, d.AddDeadLetter(context.Background(), deadLetterInternal))
+}
+
+func TestDB_FindDeadLetter(t *testing.T) {
+	deadLetter := newDeadLetter(t)
+
+	tests := []struct {
+		name           string
+		redisGetResult *redis.StringCmd
+		want           *timer.DeadLetter
+		wantErr        bool
+	}{
+		{
+			name:           "finds the dead letter",
+			redisGetResult: redis.NewStringResult(serializeDeadLetterValue(fromInternalDeadLetter(deadLetter)), nil),
+			want:           deadLetter,
+		},
+		{
+			name:           "does not exist",
+			redisGetResult: redis.NewStringResult("", redis.Nil),
+		},
+		{
+			name:           "Get returns error",
+			redisGetResult: redis.NewStringResult("", assert.AnError),
+			wantErr:        true,
+		},
+		{
+			name:           "malformed value",
+			redisGetResult: redis.NewStringResult("{malformed, json}", nil),
+			wantErr:        true,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	cfg := &config.DB{TimerMaxTTLDays: 10}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			d := newDB(t, redisClient, cfg)
+
+			redisClient.EXPECT().Get(gomock.Any(), serializeDeadLetterKey(deadLetter.ID)).Return(tt.redisGetResult)
+
+			got, err := d.FindDeadLetter(context.Background(), deadLetter.ID)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDB_ListDeadLetters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cfg := &config.DB{TimerMaxTTLDays: 10}
+
+	redisClient := mocks.NewRedisClient(ctrl)
+	d := newDB(t, redisClient, cfg)
+
+	deadLetter := newDeadLetter(t)
+	entries := []redis.Z{
+		{Score: float64(deadLetter.DeadAt.Unix()), Member: deadLetter.ID},
+		{Score: float64(deadLetter.DeadAt.Unix()), Member: deadLetter.ID + "-expired"},
+	}
+
+	redisClient.EXPECT().ZRangeByScoreWithScores(gomock.Any(), timerDeadLetterIndexName, &redis.ZRangeBy{Min: "-inf", Max: "+inf", Count: 2}).
+		Return(redis.NewZSliceCmdResult(entries, nil))
+
+	pipeliner := mocks.NewRedisPipeliner(ctrl)
+	redisClient.EXPECT().Pipeline().Return(pipeliner)
+	pipeliner.EXPECT().Get(gomock.Any(), serializeDeadLetterKey(deadLetter.ID)).
+		Return(redis.NewStringResult(serializeDeadLetterValue(fromInternalDeadLetter(deadLetter)), nil))
+	pipeliner.EXPECT().Get(gomock.Any(), serializeDeadLetterKey(deadLetter.ID+"-expired")).
+		Return(redis.NewStringResult("", redis.Nil))
+	pipeliner.EXPECT().Exec(gomock.Any()).Return(nil, redis.Nil)
+
+	redisClient.EXPECT().ZRem(gomock.Any(), timerDeadLetterIndexName, deadLetter.ID+"-expired").
+		Return(redis.NewIntResult(1, nil))
+
+	page, err := d.ListDeadLetters(context.Background(), timer.ListDeadLettersQuery{Limit: 2})
+	require.NoError(t, err)
+
+	assert.Equal(t, []*timer.DeadLetter{deadLetter}, page.DeadLetters)
+	assert.Equal(t, encodeListCursor(listCursor{score: deadLetter.DeadAt.Unix(), id: deadLetter.ID + "-expired"}), page.NextCursor)
+
+	// the expired entry is removed meanwhile, so the entries of the next page are found by the score of the cursor.
+	redisClient.EXPECT().ZRangeByScoreWithScores(gomock.Any(), timerDeadLetterIndexName,
+		&redis.ZRangeBy{Min: fmt.Sprint(deadLetter.DeadAt.Unix()), Max: "+inf", Count: 2}).
+		Return(redis.NewZSliceCmdResult(entries[:1], nil))
+
+	page, err = d.ListDeadLetters(context.Background(), timer.ListDeadLettersQuery{Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+
+	assert.Empty(t, page.DeadLetters)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestDB_ReplayDeadLetter(t *testing.T) {
+	tests := []struct {
+		name       string
+		evalResult *redis.Cmd
+		wantErr    error
+	}{
+		{
+			name:       "timer is added and the dead letter is removed",
+			evalResult: redis.NewCmdResult(int64(1), nil),
+		},
+		{
+			name:       "timer ID is taken",
+			evalResult: redis.NewCmdResult(int64(0), nil),
+			wantErr:    timer.ErrTimerExists,
+		},
+		{
+			name:       "script fails",
+			evalResult: redis.NewCmdResult(nil, assert.AnError),
+			wantErr:    assert.AnError,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	cfg := &config.DB{TimerMaxTTLDays: 10}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			d := newDB(t, redisClient, cfg)
+
+			deadLetter := newDeadLetter(t)
+			tm, err := deadLetter.Replay("", time.Now())
+			require.NoError(t, err)
+
+			redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(),
+				append([]string{serializeKey(tm.ID), timerIndexName, timerTaskQueueName, serializeDeadLetterKey(deadLetter.ID), timerDeadLetterIndexName},
+					staleKeys(tm.ID)...),
+				tm.ID, serializeValue(fromInternal(tm)), (10 * 24 * time.Hour).Milliseconds(), tm.FireAt.Unix(), deadLetter.ID,
+			).Return(tt.evalResult)
+
+			err = d.ReplayDeadLetter(context.Background(), deadLetter.ID, tm)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestDB_PurgeDeadLetters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cfg := &config.DB{TimerMaxTTLDays: 10}
+
+	expectRemove := func(redisClient *mocks.RedisClient, ids []string, removed int64) {
+		pipeliner := mocks.NewRedisPipeliner(ctrl)
+		redisClient.EXPECT().TxPipeline().Return(pipeliner)
+
+		members := make([]interface{}, 0, len(ids))
+		for _, id := range ids {
+			pipeliner.EXPECT().Del(gomock.Any(), serializeDeadLetterKey(id))
+			members = append(members, id)
+		}
+		pipeliner.EXPECT().ZRem(gomock.Any(), timerDeadLetterIndexName, members...).Return(redis.NewIntResult(removed, nil))
+		pipeliner.EXPECT().Exec(gomock.Any()).Return(nil, nil)
+	}
+
+	t.Run("by IDs", func(t *testing.T) {
+		redisClient := mocks.NewRedisClient(ctrl)
+		d := newDB(t, redisClient, cfg)
+
+		expectRemove(redisClient, []string{"1", "2"}, 1)
+
+		purged, err := d.PurgeDeadLetters(context.Background(), []string{"1", "2"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		redisClient := mocks.NewRedisClient(ctrl)
+		d := newDB(t, redisClient, cfg)
+
+		gomock.InOrder(
+			redisClient.EXPECT().ZRange(gomock.Any(), timerDeadLetterIndexName, int64(0), int64(purgeBatchSize-1)).
+				Return(redis.NewStringSliceResult([]string{"1", "2"}, nil)),
+			redisClient.EXPECT().ZRange(gomock.Any(), timerDeadLetterIndexName, int64(0), int64(purgeBatchSize-1)).
+				Return(redis.NewStringSliceResult(nil, nil)),
+		)
+		expectRemove(redisClient, []string{"1", "2"}, 2)
+
+		purged, err := d.PurgeDeadLetters(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, 2, purged)
+	})
+}
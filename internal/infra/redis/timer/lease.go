@@ -0,0 +1,36 @@
+package timer
+
+import (
+	"context"
+	"time"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+)
+
+const (
+	// relayLeaseName holds the holder of the lease of the relays until the lease expires.
+	relayLeaseName = keyTag + ":relayLease"
+	// relayLeaseTokenName holds the token of the latest lease of the relays. it never expires.
+	relayLeaseTokenName = keyTag + ":relayLeaseToken"
+)
+
+// AcquireLease makes the holder the leader of the relays for the TTL, or extends the lease of the holder.
+// it returns nil, nil when another holder leads.
+func (d *DB) AcquireLease(ctx context.Context, holder string, ttl time.Duration) (*timer.Lease, error) {
+	keys := []string{relayLeaseName, relayLeaseTokenName}
+	token, err := acquireLeaseScript.Run(ctx, d.redisClient, keys, holder, ttl.Milliseconds()).Int64()
+	switch {
+	case err != nil:
+		return nil, err
+	case token == 0:
+		return nil, nil
+	}
+
+	return &timer.Lease{Holder: holder, Token: token}, nil
+}
+
+// ReleaseLease gives the lease up, if it is still held.
+func (d *DB) ReleaseLease(ctx context.Context, lease *timer.Lease) error {
+	keys := []string{relayLeaseName, relayLeaseTokenName}
+	return releaseLeaseScript.Run(ctx, d.redisClient, keys, lease.Holder, lease.Token).Err()
+}
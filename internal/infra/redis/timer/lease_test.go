@@ -0,0 +1,69 @@
+package timer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+	"github.com/cubny/httpqueue/internal/config"
+	mocks "github.com/cubny/httpqueue/internal/mocks/external/redis"
+)
+
+func TestDB_AcquireLease(t *testing.T) {
+	tests := []struct {
+		name       string
+		evalResult *redis.Cmd
+		want       *timer.Lease
+		wantErr    error
+	}{
+		{
+			name:       "lease is acquired",
+			evalResult: redis.NewCmdResult(int64(3), nil),
+			want:       &timer.Lease{Holder: "relay-1", Token: 3},
+		},
+		{
+			name:       "another holder leads",
+			evalResult: redis.NewCmdResult(int64(0), nil),
+		},
+		{
+			name:       "script fails",
+			evalResult: redis.NewCmdResult(nil, assert.AnError),
+			wantErr:    assert.AnError,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			d := newDB(t, redisClient, &config.DB{TimerMaxTTLDays: 10})
+
+			redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(),
+				[]string{relayLeaseName, relayLeaseTokenName}, "relay-1", int64(10000),
+			).Return(tt.evalResult)
+
+			got, err := d.AcquireLease(context.Background(), "relay-1", 10*time.Second)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDB_ReleaseLease(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	redisClient := mocks.NewRedisClient(ctrl)
+	d := newDB(t, redisClient, &config.DB{TimerMaxTTLDays: 10})
+
+	redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(),
+		[]string{relayLeaseName, relayLeaseTokenName}, "relay-1", int64(3),
+	).Return(redis.NewCmdResult(int64(1), nil))
+
+	err := d.ReleaseLease(context.Background(), &timer.Lease{Holder: "relay-1", Token: 3})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,202 @@
+package timer
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	extRedis "github.com/go-redis/redis/v8"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+)
+
+// listMaxScan bounds the number of index entries that are scanned for a single page, so that a page stays cheap
+// even when the filters are very selective. a page may therefore hold fewer timers than the limit, while it still
+// has a next cursor.
+const listMaxScan = 1000
+
+// listCursor is the position of the last scanned index entry.
+type listCursor struct {
+	score int64
+	id    string
+}
+
+func encodeListCursor(c listCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%s", c.score, c.id)))
+}
+
+func decodeListCursor(s string) (*listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, timer.ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, timer.ErrInvalidCursor
+	}
+
+	score, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, timer.ErrInvalidCursor
+	}
+
+	return &listCursor{score: score, id: parts[1]}, nil
+}
+
+// isAfter tells whether the index entry comes after the cursor in the order of the sorted set.
+func (c *listCursor) isAfter(score int64, id string) bool {
+	return score > c.score || (score == c.score && id > c.id)
+}
+
+// List walks the timer index in the order of the fire time, starting after the cursor or from the lower bound of the
+// fire time. the timers are fetched in batches along with their states, and the filters are applied on them.
+func (d *DB) List(ctx context.Context, query timer.ListTimersQuery) (*timer.TimersPage, error) {
+	var (
+		cursor *listCursor
+		err    error
+	)
+	if query.Cursor != "" {
+		if cursor, err = decodeListCursor(query.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	max := "+inf"
+	if !query.To.IsZero() {
+		max = strconv.FormatInt(query.To.Unix(), 10)
+	}
+
+	// last is the position of the last scanned entry, from which the next page continues.
+	last := cursor
+	page := &timer.TimersPage{Timers: make([]*timer.TimerState, 0, query.Limit)}
+	for scanned := 0; scanned < listMaxScan; {
+		entries, err := d.rangeAfter(ctx, timerIndexName, last, scoreMin(query.From, last), max, query.Limit)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(entries) == 0 {
+			return page, nil
+		}
+
+		states, err := d.findIndexed(ctx, entries)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, entry := range entries {
+			scanned++
+
+			last = &listCursor{score: int64(entry.Score), id: fmt.Sprint(entry.Member)}
+			if states[i] == nil || !query.Matches(states[i]) {
+				continue
+			}
+
+			page.Timers = append(page.Timers, states[i])
+			if len(page.Timers) == query.Limit {
+				page.NextCursor = encodeListCursor(*last)
+				return page, nil
+			}
+		}
+	}
+
+	if last != nil {
+		page.NextCursor = encodeListCursor(*last)
+	}
+
+	return page, nil
+}
+
+// scoreMin is the lower bound of the scores to range from, i.e. the score of the position, unless the lower bound of
+// the time is after it.
+func scoreMin(from time.Time, pos *listCursor) string {
+	switch {
+	case pos != nil && (from.IsZero() || pos.score >= from.Unix()):
+		return strconv.FormatInt(pos.score, 10)
+	case !from.IsZero():
+		return strconv.FormatInt(from.Unix(), 10)
+	}
+	return "-inf"
+}
+
+// rangeAfter returns up to count entries of the sorted set that come after the position, within the scores between
+// min and max. the entries are found by their score rather than their rank, so that the entries that are removed
+// meanwhile, e.g. the expired ones, do not shift the window. the entries of the score of the position up to the
+// position are skipped, and more entries are fetched when a whole batch of them is skipped.
+func (d *DB) rangeAfter(ctx context.Context, key string, pos *listCursor, min, max string, count int) ([]extRedis.Z, error) {
+	for n := int64(count); ; n *= 2 {
+		entries, err := d.redisClient.ZRangeByScoreWithScores(ctx, key, &extRedis.ZRangeBy{Min: min, Max: max, Count: n}).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		skipped := 0
+		for pos != nil && skipped < len(entries) && !pos.isAfter(int64(entries[skipped].Score), fmt.Sprint(entries[skipped].Member)) {
+			skipped++
+		}
+
+		if skipped < len(entries) || int64(len(entries)) < n {
+			return entries[skipped:], nil
+		}
+	}
+}
+
+// findIndexed fetches the timers of the index entries along with their states in a pipeline, and returns the states
+// of the timers. see timer.TimerState.Of. the entries whose timer is expired are removed from the index and their
+// state is nil.
+func (d *DB) findIndexed(ctx context.Context, entries []extRedis.Z) ([]*timer.TimerState, error) {
+	pipe := d.redisClient.Pipeline()
+
+	cmds := make([]*extRedis.StringCmd, 0, len(entries))
+	stateCmds := make([]*extRedis.StringCmd, 0, len(entries))
+	for _, entry := range entries {
+		cmds = append(cmds, pipe.Get(ctx, serializeKey(fmt.Sprint(entry.Member))))
+		stateCmds = append(stateCmds, pipe.Get(ctx, serializeStateKey(fmt.Sprint(entry.Member))))
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && err != extRedis.Nil {
+		return nil, err
+	}
+
+	states := make([]*timer.TimerState, len(entries))
+	expired := make([]interface{}, 0)
+	for i, cmd := range cmds {
+		val, err := cmd.Result()
+		switch {
+		case err == extRedis.Nil:
+			expired = append(expired, entries[i].Member)
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		dsTimer, err := deserializeValue(val)
+		if err != nil {
+			return nil, ErrDeserialization
+		}
+
+		t, err := toInternal(dsTimer)
+		if err != nil {
+			return nil, ErrInvalidURL
+		}
+
+		state, err := findState(stateCmds[i])
+		if err != nil {
+			return nil, err
+		}
+
+		states[i] = state.Of(t)
+	}
+
+	if len(expired) > 0 {
+		if err := d.redisClient.ZRem(ctx, timerIndexName, expired...).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return states, nil
+}
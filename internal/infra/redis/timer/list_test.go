@@ -0,0 +1,197 @@
+package timer
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+	"github.com/cubny/httpqueue/internal/config"
+	mocks "github.com/cubny/httpqueue/internal/mocks/external/redis"
+)
+
+func TestListCursor(t *testing.T) {
+	c := listCursor{score: 1700000000, id: "a:b"}
+
+	got, err := decodeListCursor(encodeListCursor(c))
+	require.NoError(t, err)
+	assert.Equal(t, c, *got)
+
+	for _, raw := range []string{"1700000000", "x:id", "1:"} {
+		_, err := decodeListCursor(base64.RawURLEncoding.EncodeToString([]byte(raw)))
+		assert.ErrorIs(t, err, timer.ErrInvalidCursor, raw)
+	}
+
+	_, err = decodeListCursor("!!")
+	assert.ErrorIs(t, err, timer.ErrInvalidCursor)
+}
+
+func TestDB_List(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cfg := &config.DB{TimerMaxTTLDays: 10}
+
+	timers := make([]*timer.Timer, 0, 3)
+	for i, team := range []string{"a", "b", "a"} {
+		tm, err := timer.NewTimer("http://valid.url", 0, 0, time.Duration(i+1))
+		require.NoError(t, err)
+		tm.Labels = map[string]string{"team": team}
+		timers = append(timers, tm)
+	}
+
+	// entries are in the order of the sorted set, i.e. by score then by member.
+	expiredID := timers[0].ID + "-expired"
+	entries := []redis.Z{
+		{Score: float64(timers[0].FireAt.Unix()), Member: timers[0].ID},
+		{Score: float64(timers[0].FireAt.Unix()), Member: expiredID},
+		{Score: float64(timers[1].FireAt.Unix()), Member: timers[1].ID},
+		{Score: float64(timers[2].FireAt.Unix()), Member: timers[2].ID},
+	}
+
+	expectRange := func(client *mocks.RedisClient, min string, count int64, batch []redis.Z) {
+		client.EXPECT().ZRangeByScoreWithScores(gomock.Any(), timerIndexName, &redis.ZRangeBy{Min: min, Max: "+inf", Count: count}).
+			Return(redis.NewZSliceCmdResult(batch, nil))
+	}
+
+	// states are of the timers that are relayed, the other timers are pending as they have no state.
+	states := map[string]*timer.TimerState{}
+
+	expectFind := func(client *mocks.RedisClient, batch []redis.Z, values []*redis.StringCmd) {
+		pipeliner := mocks.NewRedisPipeliner(ctrl)
+		client.EXPECT().Pipeline().Return(pipeliner)
+		for i, entry := range batch {
+			id := entry.Member.(string)
+			pipeliner.EXPECT().Get(gomock.Any(), serializeKey(id)).Return(values[i])
+
+			state := redis.NewStringResult("", redis.Nil)
+			if states[id] != nil {
+				state = redis.NewStringResult(serializeStateValue(fromInternalState(states[id])), nil)
+			}
+			pipeliner.EXPECT().Get(gomock.Any(), serializeStateKey(id)).Return(state)
+		}
+		pipeliner.EXPECT().Exec(gomock.Any()).Return(nil, nil)
+	}
+
+	valueOf := func(tm *timer.Timer) *redis.StringCmd {
+		return redis.NewStringResult(serializeValue(fromInternal(tm)), nil)
+	}
+
+	scoreOf := func(tm *timer.Timer) string {
+		return fmt.Sprint(tm.FireAt.Unix())
+	}
+
+	t.Run("filters by label, removes expired entries and pages", func(t *testing.T) {
+		redisClient := mocks.NewRedisClient(ctrl)
+		d := newDB(t, redisClient, cfg)
+
+		expectRange(redisClient, "-inf", 2, entries[:2])
+		expectFind(redisClient, entries[:2], []*redis.StringCmd{valueOf(timers[0]), redis.NewStringResult("", redis.Nil)})
+		redisClient.EXPECT().ZRem(gomock.Any(), timerIndexName, expiredID).Return(redis.NewIntResult(1, nil))
+		// the expired entry is removed, so the next batch starts at the entry of the first timer, which is skipped.
+		expectRange(redisClient, scoreOf(timers[0]), 2, []redis.Z{entries[0], entries[2]})
+		expectFind(redisClient, entries[2:3], []*redis.StringCmd{valueOf(timers[1])})
+		expectRange(redisClient, scoreOf(timers[1]), 2, entries[2:])
+		expectFind(redisClient, entries[3:], []*redis.StringCmd{valueOf(timers[2])})
+
+		page, err := d.List(context.Background(), timer.ListTimersQuery{Labels: map[string]string{"team": "a"}, Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page.Timers, 2)
+		assert.Equal(t, timers[0].ID, page.Timers[0].TimerID)
+		assert.Equal(t, timers[2].ID, page.Timers[1].TimerID)
+		assert.Equal(t, encodeListCursor(listCursor{score: timers[2].FireAt.Unix(), id: timers[2].ID}), page.NextCursor)
+	})
+
+	t.Run("does not skip the timers after the expired entries of a batch", func(t *testing.T) {
+		redisClient := mocks.NewRedisClient(ctrl)
+		d := newDB(t, redisClient, cfg)
+
+		expectRange(redisClient, "-inf", 2, entries[:2])
+		expectFind(redisClient, entries[:2], []*redis.StringCmd{valueOf(timers[0]), redis.NewStringResult("", redis.Nil)})
+		redisClient.EXPECT().ZRem(gomock.Any(), timerIndexName, expiredID).Return(redis.NewIntResult(1, nil))
+		expectRange(redisClient, scoreOf(timers[0]), 2, []redis.Z{entries[0], entries[2]})
+		expectFind(redisClient, entries[2:3], []*redis.StringCmd{valueOf(timers[1])})
+
+		page, err := d.List(context.Background(), timer.ListTimersQuery{Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page.Timers, 2)
+		assert.Equal(t, timers[0].ID, page.Timers[0].TimerID)
+		assert.Equal(t, timers[1].ID, page.Timers[1].TimerID)
+	})
+
+	t.Run("continues from the cursor until the index is exhausted", func(t *testing.T) {
+		redisClient := mocks.NewRedisClient(ctrl)
+		d := newDB(t, redisClient, cfg)
+
+		cursor := encodeListCursor(listCursor{score: timers[1].FireAt.Unix(), id: timers[1].ID})
+		expectRange(redisClient, scoreOf(timers[1]), 2, entries[2:])
+		expectFind(redisClient, entries[3:], []*redis.StringCmd{valueOf(timers[2])})
+		expectRange(redisClient, scoreOf(timers[2]), 2, entries[3:])
+
+		page, err := d.List(context.Background(), timer.ListTimersQuery{Limit: 2, Cursor: cursor})
+		require.NoError(t, err)
+		require.Len(t, page.Timers, 1)
+		assert.Equal(t, timers[2].ID, page.Timers[0].TimerID)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("fetches more entries when a whole batch is up to the cursor", func(t *testing.T) {
+		redisClient := mocks.NewRedisClient(ctrl)
+		d := newDB(t, redisClient, cfg)
+
+		cursor := encodeListCursor(listCursor{score: timers[0].FireAt.Unix(), id: expiredID})
+		expectRange(redisClient, scoreOf(timers[0]), 1, entries[:1])
+		expectRange(redisClient, scoreOf(timers[0]), 2, []redis.Z{entries[0], entries[2]})
+		expectFind(redisClient, entries[2:3], []*redis.StringCmd{valueOf(timers[1])})
+
+		page, err := d.List(context.Background(), timer.ListTimersQuery{Limit: 1, Cursor: cursor})
+		require.NoError(t, err)
+		require.Len(t, page.Timers, 1)
+		assert.Equal(t, timers[1].ID, page.Timers[0].TimerID)
+	})
+
+	t.Run("starts from the lower bound of the fire time", func(t *testing.T) {
+		redisClient := mocks.NewRedisClient(ctrl)
+		d := newDB(t, redisClient, cfg)
+
+		expectRange(redisClient, scoreOf(timers[2]), 2, entries[3:])
+		expectFind(redisClient, entries[3:], []*redis.StringCmd{valueOf(timers[2])})
+		expectRange(redisClient, scoreOf(timers[2]), 2, entries[3:])
+
+		page, err := d.List(context.Background(), timer.ListTimersQuery{Limit: 2, From: timers[2].FireAt})
+		require.NoError(t, err)
+		require.Len(t, page.Timers, 1)
+		assert.Equal(t, timers[2].ID, page.Timers[0].TimerID)
+	})
+
+	t.Run("filters by status", func(t *testing.T) {
+		redisClient := mocks.NewRedisClient(ctrl)
+		d := newDB(t, redisClient, cfg)
+
+		states[timers[2].ID] = timer.NewTimerState(timers[2], timer.StateRelayed)
+		defer delete(states, timers[2].ID)
+
+		expectRange(redisClient, scoreOf(timers[1]), 2, entries[2:])
+		expectFind(redisClient, entries[2:], []*redis.StringCmd{valueOf(timers[1]), valueOf(timers[2])})
+		expectRange(redisClient, scoreOf(timers[2]), 2, entries[3:])
+
+		page, err := d.List(context.Background(), timer.ListTimersQuery{Status: timer.StateRelayed, Limit: 2, From: timers[1].FireAt})
+		require.NoError(t, err)
+		require.Len(t, page.Timers, 1)
+		assert.Equal(t, timers[2].ID, page.Timers[0].TimerID)
+		assert.Equal(t, timer.StateRelayed, page.Timers[0].State)
+		assert.Equal(t, timers[2].ID, page.Timers[0].Timer.ID)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		d := newDB(t, mocks.NewRedisClient(ctrl), cfg)
+
+		_, err := d.List(context.Background(), timer.ListTimersQuery{Limit: 2, Cursor: "!!"})
+		assert.ErrorIs(t, err, timer.ErrInvalidCursor)
+	})
+}
@@ -6,23 +6,162 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/go-redis/redis/v8"
+
 	"github.com/cubny/httpqueue/internal/app/timer"
 )
 
+// keyTag is the hash tag of the keys of the timers. the scripts add, replay or cancel a timer along with its index
+// and outbox entries atomically, which Redis Cluster only allows for the keys of one hash slot, so all the keys of
+// the timers are pinned to one slot on purpose, like asynq pins the keys of a queue. the archive is not, see
+// ArchiveIndex.
+const keyTag = "{timers}"
+
+// the kind of a key is followed by a colon, which a timer ID cannot contain, so that a client supplied ID, e.g.
+// cancelled-42, never makes the key of a record of another timer, e.g. the cancellation of the timer 42.
 const (
-	timerKeyFmt = "timer-%s" // timer-<timer_id>
+	timerKeyFmt          = keyTag + ":timer:%s"             // {timers}:timer:<timer_id>
+	cancelledTimerKeyFmt = keyTag + ":timer-cancelled:%s"   // {timers}:timer-cancelled:<timer_id>
+	idempotencyKeyFmt    = keyTag + ":timer-idempotency:%s" // {timers}:timer-idempotency:<idempotency_key>
+	deadLetterKeyFmt     = keyTag + ":timer-dead:%s"        // {timers}:timer-dead:<dead_letter_id>
+	attemptsKeyFmt       = keyTag + ":timer-attempts:%s"    // {timers}:timer-attempts:<timer_id>
+	stateKeyFmt          = keyTag + ":timer-state:%s"       // {timers}:timer-state:<timer_id>
+	tombstoneKeyFmt      = keyTag + ":timer-tombstone:%s"   // {timers}:timer-tombstone:<timer_id>
 )
 
 type redisTimer struct {
 	ID           string `json:"id"`
 	FireAtSecond int64  `json:"fire_at"`
-	URL          string `json:"url"`
+	// CreatedAtSecond is missing for the timers that were created before it was kept.
+	CreatedAtSecond int64  `json:"created_at,omitempty"`
+	URL             string `json:"url"`
+	URLTemplate     string `json:"url_template,omitempty"`
+	Revision        int    `json:"revision,omitempty"`
+	Generation      int64  `json:"generation,omitempty"`
+
+	Recurrence *redisRecurrence `json:"recurrence,omitempty"`
+
+	Method      string            `json:"method,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	// SigningSecrets are kept as is, because they are needed to sign the webhook.
+	SigningSecrets []string `json:"signing_secrets,omitempty"`
+	TimeoutMs      int64    `json:"timeout,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty"`
+
+	RetryPolicy     *redisRetryPolicy     `json:"retry_policy,omitempty"`
+	SuccessCriteria *redisSuccessCriteria `json:"success_criteria,omitempty"`
+}
+
+type redisRecurrence struct {
+	Cron        string `json:"cron"`
+	Timezone    string `json:"tz,omitempty"`
+	EndAtSecond int64  `json:"end_at,omitempty"`
+	MaxRuns     int    `json:"max_runs,omitempty"`
+	Runs        int    `json:"runs,omitempty"`
+}
+
+type redisRetryPolicy struct {
+	MaxAttempts      int     `json:"max_attempts,omitempty"`
+	InitialBackoffMs int64   `json:"initial_backoff,omitempty"`
+	MaxBackoffMs     int64   `json:"max_backoff,omitempty"`
+	Multiplier       float64 `json:"multiplier,omitempty"`
+	Jitter           float64 `json:"jitter,omitempty"`
+	DeadlineMs       int64   `json:"deadline,omitempty"`
+}
+
+type redisSuccessCriteria struct {
+	AcceptedStatusCodes  []string `json:"accepted_status_codes,omitempty"`
+	RetryableStatusCodes []string `json:"retryable_status_codes,omitempty"`
+	BodyPattern          string   `json:"body_pattern,omitempty"`
+	JSONPath             string   `json:"json_path,omitempty"`
+	JSONValue            string   `json:"json_value,omitempty"`
+}
+
+type redisDeadLetter struct {
+	ID           string     `json:"id"`
+	Timer        redisTimer `json:"timer"`
+	Error        string     `json:"error"`
+	StatusCode   int        `json:"status_code,omitempty"`
+	ResponseBody string     `json:"response_body,omitempty"`
+	Attempts     int        `json:"attempts"`
+	DeadAtSecond int64      `json:"dead_at"`
+}
+
+type redisAttempt struct {
+	Run         int    `json:"run"`
+	Number      int    `json:"number"`
+	StartedAtMs int64  `json:"started_at"`
+	DurationMs  int64  `json:"duration"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	Outcome     string `json:"outcome,omitempty"`
+	ErrorClass  string `json:"error_class,omitempty"`
+	Error       string `json:"error,omitempty"`
+	RetryAtMs   int64  `json:"retry_at,omitempty"`
+}
+
+type redisState struct {
+	TimerID          string `json:"timer_id"`
+	State            string `json:"state"`
+	Revision         int    `json:"revision,omitempty"`
+	FireAtSecond     int64  `json:"fire_at"`
+	CreatedAtSecond  int64  `json:"created_at,omitempty"`
+	Attempts         int    `json:"attempts,omitempty"`
+	LastError        string `json:"last_error,omitempty"`
+	FinishedAtSecond int64  `json:"finished_at,omitempty"`
+}
+
+// redisTombstone is compact, as there is one per archived timer. the timer ID is a part of the key.
+type redisTombstone struct {
+	State            string `json:"s"`
+	FinishedAtSecond int64  `json:"f"`
 }
 
 func serializeKey(timerID string) string {
 	return fmt.Sprintf(timerKeyFmt, timerID)
 }
 
+func serializeCancelledKey(timerID string) string {
+	return fmt.Sprintf(cancelledTimerKeyFmt, timerID)
+}
+
+func serializeIdempotencyKey(idempotencyKey string) string {
+	return fmt.Sprintf(idempotencyKeyFmt, idempotencyKey)
+}
+
+func serializeDeadLetterKey(deadLetterID string) string {
+	return fmt.Sprintf(deadLetterKeyFmt, deadLetterID)
+}
+
+func serializeAttemptsKey(timerID string) string {
+	return fmt.Sprintf(attemptsKeyFmt, timerID)
+}
+
+func serializeStateKey(timerID string) string {
+	return fmt.Sprintf(stateKeyFmt, timerID)
+}
+
+func serializeTombstoneKey(timerID string) string {
+	return fmt.Sprintf(tombstoneKeyFmt, timerID)
+}
+
+func indexMember(t redisTimer) *redis.Z {
+	return &redis.Z{Score: float64(t.FireAtSecond), Member: t.ID}
+}
+
+// staleKeys are the keys that a previous timer with the same ID may have left behind, i.e. its cancellation, state,
+// tombstone and attempts. they are removed when a timer is added, so that they are not taken for the new timer's.
+func staleKeys(timerID string) []string {
+	return []string{
+		serializeCancelledKey(timerID),
+		serializeStateKey(timerID),
+		serializeTombstoneKey(timerID),
+		serializeAttemptsKey(timerID),
+	}
+}
+
 func serializeValue(t redisTimer) string {
 	// ignore the error because we know the model is valid (doesn't contain channels, cyclic data structures, etc.)
 	bytes, _ := json.Marshal(t)
@@ -37,9 +176,109 @@ func deserializeValue(str string) (redisTimer, error) {
 
 func fromInternal(t *timer.Timer) redisTimer {
 	return redisTimer{
-		ID:           t.ID,
-		FireAtSecond: t.FireAt.Unix(),
-		URL:          t.URL.String(),
+		ID:              t.ID,
+		FireAtSecond:    t.FireAt.Unix(),
+		CreatedAtSecond: unixOrZero(t.CreatedAt),
+		URL:             t.URL.String(),
+		URLTemplate:     t.URLTemplate,
+		Revision:        t.Revision,
+		Generation:      t.Generation,
+		Recurrence:      fromInternalRecurrence(t.Recurrence),
+		Method:          t.Webhook.Method,
+		Headers:         t.Webhook.Headers,
+		Body:            t.Webhook.Body,
+		ContentType:     t.Webhook.ContentType,
+		SigningSecrets:  t.Webhook.SigningSecrets,
+		TimeoutMs:       t.Webhook.Timeout.Milliseconds(),
+		Labels:          t.Labels,
+		RetryPolicy:     fromInternalRetryPolicy(t.RetryPolicy),
+		SuccessCriteria: fromInternalSuccessCriteria(t.SuccessCriteria),
+	}
+}
+
+func fromInternalRetryPolicy(p *timer.RetryPolicy) *redisRetryPolicy {
+	if p == nil {
+		return nil
+	}
+
+	return &redisRetryPolicy{
+		MaxAttempts:      p.MaxAttempts,
+		InitialBackoffMs: p.InitialBackoff.Milliseconds(),
+		MaxBackoffMs:     p.MaxBackoff.Milliseconds(),
+		Multiplier:       p.Multiplier,
+		Jitter:           p.Jitter,
+		DeadlineMs:       p.Deadline.Milliseconds(),
+	}
+}
+
+func toInternalRetryPolicy(p *redisRetryPolicy) *timer.RetryPolicy {
+	if p == nil {
+		return nil
+	}
+
+	return &timer.RetryPolicy{
+		MaxAttempts:    p.MaxAttempts,
+		InitialBackoff: time.Duration(p.InitialBackoffMs) * time.Millisecond,
+		MaxBackoff:     time.Duration(p.MaxBackoffMs) * time.Millisecond,
+		Multiplier:     p.Multiplier,
+		Jitter:         p.Jitter,
+		Deadline:       time.Duration(p.DeadlineMs) * time.Millisecond,
+	}
+}
+
+func fromInternalSuccessCriteria(c *timer.SuccessCriteria) *redisSuccessCriteria {
+	if c == nil {
+		return nil
+	}
+
+	return &redisSuccessCriteria{
+		AcceptedStatusCodes:  c.AcceptedStatusCodes,
+		RetryableStatusCodes: c.RetryableStatusCodes,
+		BodyPattern:          c.BodyPattern,
+		JSONPath:             c.JSONPath,
+		JSONValue:            c.JSONValue,
+	}
+}
+
+func toInternalSuccessCriteria(c *redisSuccessCriteria) *timer.SuccessCriteria {
+	if c == nil {
+		return nil
+	}
+
+	return &timer.SuccessCriteria{
+		AcceptedStatusCodes:  c.AcceptedStatusCodes,
+		RetryableStatusCodes: c.RetryableStatusCodes,
+		BodyPattern:          c.BodyPattern,
+		JSONPath:             c.JSONPath,
+		JSONValue:            c.JSONValue,
+	}
+}
+
+func fromInternalRecurrence(r *timer.Recurrence) *redisRecurrence {
+	if r == nil {
+		return nil
+	}
+
+	return &redisRecurrence{
+		Cron:        r.Cron,
+		Timezone:    r.Timezone,
+		EndAtSecond: unixOrZero(r.EndAt),
+		MaxRuns:     r.MaxRuns,
+		Runs:        r.Runs,
+	}
+}
+
+func toInternalRecurrence(r *redisRecurrence) *timer.Recurrence {
+	if r == nil {
+		return nil
+	}
+
+	return &timer.Recurrence{
+		Cron:     r.Cron,
+		Timezone: r.Timezone,
+		EndAt:    timeOrZero(r.EndAtSecond),
+		MaxRuns:  r.MaxRuns,
+		Runs:     r.Runs,
 	}
 }
 
@@ -50,8 +289,199 @@ func toInternal(r redisTimer) (*timer.Timer, error) {
 	}
 
 	return &timer.Timer{
-		ID:     r.ID,
-		URL:    *URL,
-		FireAt: time.Unix(r.FireAtSecond, 0),
+		ID:          r.ID,
+		URL:         *URL,
+		URLTemplate: r.URLTemplate,
+		FireAt:      time.Unix(r.FireAtSecond, 0),
+		CreatedAt:   timeOrZero(r.CreatedAtSecond),
+		Revision:    r.Revision,
+		Generation:  r.Generation,
+		Recurrence:  toInternalRecurrence(r.Recurrence),
+		Webhook: timer.Webhook{
+			Method:         r.Method,
+			Headers:        r.Headers,
+			Body:           r.Body,
+			ContentType:    r.ContentType,
+			SigningSecrets: r.SigningSecrets,
+			Timeout:        time.Duration(r.TimeoutMs) * time.Millisecond,
+		},
+		Labels:          r.Labels,
+		RetryPolicy:     toInternalRetryPolicy(r.RetryPolicy),
+		SuccessCriteria: toInternalSuccessCriteria(r.SuccessCriteria),
 	}, nil
 }
+
+func fromInternalDeadLetter(d *timer.DeadLetter) redisDeadLetter {
+	return redisDeadLetter{
+		ID:           d.ID,
+		Timer:        fromInternal(d.Timer),
+		Error:        d.Error,
+		StatusCode:   d.StatusCode,
+		ResponseBody: d.ResponseBody,
+		Attempts:     d.Attempts,
+		DeadAtSecond: d.DeadAt.Unix(),
+	}
+}
+
+func toInternalDeadLetter(d redisDeadLetter) (*timer.DeadLetter, error) {
+	t, err := toInternal(d.Timer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &timer.DeadLetter{
+		ID:           d.ID,
+		Timer:        t,
+		Error:        d.Error,
+		StatusCode:   d.StatusCode,
+		ResponseBody: d.ResponseBody,
+		Attempts:     d.Attempts,
+		DeadAt:       time.Unix(d.DeadAtSecond, 0),
+	}, nil
+}
+
+func deadLetterIndexMember(d redisDeadLetter) *redis.Z {
+	return &redis.Z{Score: float64(d.DeadAtSecond), Member: d.ID}
+}
+
+func serializeDeadLetterValue(d redisDeadLetter) string {
+	// ignore the error because we know the model is valid
+	bytes, _ := json.Marshal(d)
+	return string(bytes)
+}
+
+func deserializeDeadLetterValue(str string) (redisDeadLetter, error) {
+	d := redisDeadLetter{}
+	err := json.Unmarshal([]byte(str), &d)
+	return d, err
+}
+
+func fromInternalAttempt(a *timer.Attempt) redisAttempt {
+	var retryAtMs int64
+	if !a.RetryAt.IsZero() {
+		retryAtMs = a.RetryAt.UnixMilli()
+	}
+
+	return redisAttempt{
+		Run:         a.Run,
+		Number:      a.Number,
+		StartedAtMs: a.StartedAt.UnixMilli(),
+		DurationMs:  a.Duration.Milliseconds(),
+		StatusCode:  a.StatusCode,
+		Outcome:     string(a.Outcome),
+		ErrorClass:  a.ErrorClass,
+		Error:       a.Error,
+		RetryAtMs:   retryAtMs,
+	}
+}
+
+func toInternalAttempt(a redisAttempt) *timer.Attempt {
+	var retryAt time.Time
+	if a.RetryAtMs != 0 {
+		retryAt = time.UnixMilli(a.RetryAtMs)
+	}
+
+	return &timer.Attempt{
+		Run:        a.Run,
+		Number:     a.Number,
+		StartedAt:  time.UnixMilli(a.StartedAtMs),
+		Duration:   time.Duration(a.DurationMs) * time.Millisecond,
+		StatusCode: a.StatusCode,
+		Outcome:    timer.Outcome(a.Outcome),
+		ErrorClass: a.ErrorClass,
+		Error:      a.Error,
+		RetryAt:    retryAt,
+	}
+}
+
+func serializeAttemptValue(a redisAttempt) string {
+	// ignore the error because we know the model is valid
+	bytes, _ := json.Marshal(a)
+	return string(bytes)
+}
+
+func deserializeAttemptValue(str string) (redisAttempt, error) {
+	a := redisAttempt{}
+	err := json.Unmarshal([]byte(str), &a)
+	return a, err
+}
+
+func fromInternalState(s *timer.TimerState) redisState {
+	return redisState{
+		TimerID:          s.TimerID,
+		State:            string(s.State),
+		Revision:         s.Revision,
+		FireAtSecond:     s.FireAt.Unix(),
+		CreatedAtSecond:  unixOrZero(s.CreatedAt),
+		Attempts:         s.Attempts,
+		LastError:        s.LastError,
+		FinishedAtSecond: unixOrZero(s.FinishedAt),
+	}
+}
+
+func toInternalState(s redisState) *timer.TimerState {
+	return &timer.TimerState{
+		TimerID:    s.TimerID,
+		State:      timer.State(s.State),
+		Revision:   s.Revision,
+		FireAt:     time.Unix(s.FireAtSecond, 0),
+		CreatedAt:  timeOrZero(s.CreatedAtSecond),
+		Attempts:   s.Attempts,
+		LastError:  s.LastError,
+		FinishedAt: timeOrZero(s.FinishedAtSecond),
+	}
+}
+
+func serializeStateValue(s redisState) string {
+	// ignore the error because we know the model is valid
+	bytes, _ := json.Marshal(s)
+	return string(bytes)
+}
+
+func deserializeStateValue(str string) (redisState, error) {
+	s := redisState{}
+	err := json.Unmarshal([]byte(str), &s)
+	return s, err
+}
+
+func fromInternalTombstone(t *timer.Tombstone) redisTombstone {
+	return redisTombstone{
+		State:            string(t.State),
+		FinishedAtSecond: t.FinishedAt.Unix(),
+	}
+}
+
+func toInternalTombstone(timerID string, t redisTombstone) *timer.Tombstone {
+	return &timer.Tombstone{
+		TimerID:    timerID,
+		State:      timer.State(t.State),
+		FinishedAt: time.Unix(t.FinishedAtSecond, 0),
+	}
+}
+
+func serializeTombstoneValue(t redisTombstone) string {
+	// ignore the error because we know the model is valid
+	bytes, _ := json.Marshal(t)
+	return string(bytes)
+}
+
+func deserializeTombstoneValue(str string) (redisTombstone, error) {
+	t := redisTombstone{}
+	err := json.Unmarshal([]byte(str), &t)
+	return t, err
+}
+
+// unixOrZero keeps the zero time as zero rather than as a negative unix time.
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func timeOrZero(second int64) time.Time {
+	if second == 0 {
+		return time.Time{}
+	}
+	return time.Unix(second, 0)
+}
@@ -0,0 +1,213 @@
+package timer
+
+import (
+	extRedis "github.com/go-redis/redis/v8"
+)
+
+// the timers are added by scripts rather than transactions, because a timer must only be added when its ID is not
+// taken, i.e. SET NX, and the outbox entry must be added if and only if the timer is added.
+
+// addTimersScript adds the timers and their outbox entries, and removes the stale keys of the previous timers with
+// the same IDs. the timers whose key already exists are skipped. it returns the IDs of the skipped timers.
+//
+// KEYS: index, outbox, then the timer key followed by the 4 stale keys of every timer
+// ARGV: max TTL (ms), then timer ID, timer value and fire at (s) of every timer
+var addTimersScript = extRedis.NewScript(`
+local conflicts = {}
+for i = 3, #KEYS, 5 do
+	local arg = (i - 3) / 5 * 3
+	local id = ARGV[arg + 2]
+	if redis.call('SET', KEYS[i], ARGV[arg + 3], 'NX', 'PX', ARGV[1]) then
+		redis.call('DEL', KEYS[i + 1], KEYS[i + 2], KEYS[i + 3], KEYS[i + 4])
+		redis.call('ZADD', KEYS[1], ARGV[arg + 4], id)
+		redis.call('LPUSH', KEYS[2], id)
+	else
+		table.insert(conflicts, id)
+	end
+end
+return conflicts
+`)
+
+// replies of addTimerIdempotentlyScript.
+const (
+	addTimerReplyAdded    = "added"
+	addTimerReplyReplayed = "replayed"
+	addTimerReplyExists   = "exists"
+)
+
+// addTimerIdempotentlyScript adds the timer and its outbox entry like addTimersScript, unless the idempotency key
+// is already taken. it replies either {"added"}, {"exists"} when the timer ID is taken, or {"replayed", original ID}
+// when the idempotency key is taken.
+//
+// KEYS: idempotency key, timer key, index, outbox, then the stale keys of the timer
+// ARGV: timer ID, idempotency window (ms), timer value, max TTL (ms), fire at (s)
+//
+// the idempotency key is taken before anything is written, as a script is not rolled back when a command fails, and
+// it is released when the timer ID is taken.
+var addTimerIdempotentlyScript = extRedis.NewScript(`
+if not redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return {'` + addTimerReplyReplayed + `', redis.call('GET', KEYS[1])}
+end
+if not redis.call('SET', KEYS[2], ARGV[3], 'NX', 'PX', ARGV[4]) then
+	redis.call('DEL', KEYS[1])
+	return {'` + addTimerReplyExists + `'}
+end
+redis.call('DEL', KEYS[5], KEYS[6], KEYS[7], KEYS[8])
+redis.call('ZADD', KEYS[3], ARGV[5], ARGV[1])
+redis.call('LPUSH', KEYS[4], ARGV[1])
+return {'` + addTimerReplyAdded + `'}
+`)
+
+// replayDeadLetterScript adds the replayed timer and its outbox entry like addTimersScript, and removes the dead
+// letter if and only if the timer is added. it returns 0 when the timer ID is taken.
+//
+// KEYS: timer key, index, outbox, dead letter key, dead letter index, then the stale keys of the timer
+// ARGV: timer ID, timer value, max TTL (ms), fire at (s), dead letter ID
+var replayDeadLetterScript = extRedis.NewScript(`
+if not redis.call('SET', KEYS[1], ARGV[2], 'NX', 'PX', ARGV[3]) then
+	return 0
+end
+redis.call('DEL', KEYS[6], KEYS[7], KEYS[8], KEYS[9])
+redis.call('ZADD', KEYS[2], ARGV[4], ARGV[1])
+redis.call('LPUSH', KEYS[3], ARGV[1])
+redis.call('DEL', KEYS[4])
+redis.call('ZREM', KEYS[5], ARGV[5])
+return 1
+`)
+
+// rescheduleTimerScript overwrites the timer and adds it to the outbox again, if and only if the stored timer is of
+// the previous revision, so that concurrent reschedules of the same revision do not both succeed. it returns 0 when
+// the timer does not exist, -1 when its revision is not the previous one, and 1 when it is rescheduled.
+//
+// KEYS: timer key, index, outbox
+// ARGV: timer ID, previous revision, timer value, max TTL (ms), fire at (s)
+var rescheduleTimerScript = extRedis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	return 0
+end
+local revision = cjson.decode(value)['revision']
+if (tonumber(revision) or 0) ~= tonumber(ARGV[2]) then
+	return -1
+end
+redis.call('SET', KEYS[1], ARGV[3], 'PX', ARGV[4])
+redis.call('ZADD', KEYS[2], 'XX', ARGV[5], ARGV[1])
+redis.call('LPUSH', KEYS[3], ARGV[1])
+return 1
+`)
+
+// cancelTimerScript removes the timer and keeps its cancellation and its state, if and only if the stored timer is of
+// the generation, so that a timer that is archived, or archived and created again with the same ID, meanwhile is not
+// taken for cancelled. it returns 0 when the timer does not exist, -1 when it is of another generation, and 1 when it
+// is cancelled. the generations are compared as Lua numbers, i.e. doubles, which tell apart the generations that are
+// more than a fraction of a microsecond apart.
+//
+// KEYS: timer key, index, cancellation key, state key
+// ARGV: timer ID, generation, max TTL (ms), state value
+var cancelTimerScript = extRedis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	return 0
+end
+local generation = cjson.decode(value)['generation']
+if (tonumber(generation) or 0) ~= tonumber(ARGV[2]) then
+	return -1
+end
+redis.call('DEL', KEYS[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('SET', KEYS[3], 1, 'PX', ARGV[3])
+redis.call('SET', KEYS[4], ARGV[4], 'PX', ARGV[3])
+return 1
+`)
+
+// the outbox is a reliable queue: the relay moves the entries into the processing set rather than popping them, so
+// that an entry is not lost when the relay dies before the timer is handed to the workers. an entry stays in the
+// processing set until it is acknowledged or requeued, or until its visibility deadline passes and it is reclaimed.
+
+// dequeueOutboxScript moves up to the batch size of entries from the outbox into the processing set, scored by their
+// visibility deadline. it returns the IDs of the moved entries, or nil when the fencing token is not of the latest
+// lease of the relays.
+//
+// KEYS: outbox, processing, lease token
+// ARGV: batch size, visibility deadline (ms), fencing token
+var dequeueOutboxScript = extRedis.NewScript(`
+if redis.call('GET', KEYS[3]) ~= ARGV[3] then
+	return false
+end
+local ids = {}
+for i = 1, tonumber(ARGV[1]) do
+	local id = redis.call('RPOP', KEYS[1])
+	if not id then
+		break
+	end
+	redis.call('ZADD', KEYS[2], ARGV[2], id)
+	table.insert(ids, id)
+end
+return ids
+`)
+
+// requeueOutboxScript moves the entries of the IDs from the processing set back to the end of the outbox, so that
+// the other entries are relayed first. the entries that are no longer being processed, e.g. they are already
+// reclaimed, are skipped. it returns the number of the requeued entries.
+//
+// KEYS: outbox, processing
+// ARGV: ID of every entry
+var requeueOutboxScript = extRedis.NewScript(`
+local requeued = 0
+for i = 1, #ARGV do
+	if redis.call('ZREM', KEYS[2], ARGV[i]) == 1 then
+		redis.call('LPUSH', KEYS[1], ARGV[i])
+		requeued = requeued + 1
+	end
+end
+return requeued
+`)
+
+// reclaimOutboxScript moves up to the limit of the entries whose visibility deadline has passed from the processing
+// set back to the front of the outbox, so that they are relayed next. it returns the number of the reclaimed entries.
+//
+// KEYS: outbox, processing
+// ARGV: now (ms), limit
+var reclaimOutboxScript = extRedis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, id in ipairs(ids) do
+	redis.call('ZREM', KEYS[2], id)
+	redis.call('RPUSH', KEYS[1], id)
+end
+return #ids
+`)
+
+// the relays elect a leader by a lease, and the token of the lease grows every time the lease changes hands. see
+// timer.Lease.
+
+// acquireLeaseScript extends the lease when the holder holds it, or gives the lease to the holder when nobody holds
+// it. it returns the token of the lease, or 0 when another holder holds it.
+//
+// KEYS: lease, lease token
+// ARGV: holder, TTL (ms)
+var acquireLeaseScript = extRedis.NewScript(`
+local holder = redis.call('GET', KEYS[1])
+if holder == ARGV[1] then
+	local token = redis.call('GET', KEYS[2])
+	if token then
+		redis.call('PEXPIRE', KEYS[1], ARGV[2])
+		return tonumber(token)
+	end
+elseif holder then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return redis.call('INCR', KEYS[2])
+`)
+
+// releaseLeaseScript removes the lease when it is still held by the holder with the token. it returns 1 when the
+// lease is removed.
+//
+// KEYS: lease, lease token
+// ARGV: holder, token
+var releaseLeaseScript = extRedis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] and redis.call('GET', KEYS[2]) == ARGV[2] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
@@ -0,0 +1,53 @@
+package timer
+
+import (
+	"context"
+
+	extRedis "github.com/go-redis/redis/v8"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+)
+
+// SetState keeps the state of the current run of the timer until the max TTL of timers.
+func (d *DB) SetState(ctx context.Context, state *timer.TimerState) error {
+	return d.redisClient.Set(ctx, serializeStateKey(state.TimerID), serializeStateValue(fromInternalState(state)), d.maxTTL).Err()
+}
+
+// FindState returns nil, nil when nothing found.
+func (d *DB) FindState(ctx context.Context, timerID string) (*timer.TimerState, error) {
+	return findState(d.redisClient.Get(ctx, serializeStateKey(timerID)))
+}
+
+// findState returns the state of the reply of a GET, or nil when the state is not found.
+func findState(cmd *extRedis.StringCmd) (*timer.TimerState, error) {
+	val, err := cmd.Result()
+	switch {
+	case err == extRedis.Nil:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	state, err := deserializeStateValue(val)
+	if err != nil {
+		return nil, ErrDeserialization
+	}
+
+	return toInternalState(state), nil
+}
+
+// MarkRelayed sets the state of the timers to timer.StateRelayed at once.
+func (d *DB) MarkRelayed(ctx context.Context, timers []*timer.Timer) error {
+	if len(timers) == 0 {
+		return nil
+	}
+
+	pipe := d.redisClient.Pipeline()
+	for _, t := range timers {
+		state := fromInternalState(timer.NewTimerState(t, timer.StateRelayed))
+		pipe.Set(ctx, serializeStateKey(state.TimerID), serializeStateValue(state), d.maxTTL)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,116 @@
+package timer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubny/httpqueue/internal/app/timer"
+	"github.com/cubny/httpqueue/internal/config"
+	mocks "github.com/cubny/httpqueue/internal/mocks/external/redis"
+)
+
+func newTimerState(t *testing.T, state timer.State) *timer.TimerState {
+	tm, err := timer.NewTimer("http://valid.url/hooks", 0, 0, 0)
+	require.NoError(t, err)
+
+	s := timer.NewTimerState(tm, state).WithAttempt(2, assert.AnError)
+	s.FireAt = time.Unix(s.FireAt.Unix(), 0)
+	s.CreatedAt = time.Unix(s.CreatedAt.Unix(), 0)
+	if !s.FinishedAt.IsZero() {
+		s.FinishedAt = time.Unix(s.FinishedAt.Unix(), 0)
+	}
+	s.Timer = nil
+	return s
+}
+
+func TestDB_SetState(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	redisClient := mocks.NewRedisClient(ctrl)
+	d := newDB(t, redisClient, &config.DB{TimerMaxTTLDays: 10})
+
+	state := newTimerState(t, timer.StateRetrying)
+	redisClient.EXPECT().
+		Set(gomock.Any(), serializeStateKey(state.TimerID), serializeStateValue(fromInternalState(state)), 10*24*time.Hour).
+		Return(redis.NewStatusResult("OK", nil))
+
+	require.NoError(t, d.SetState(context.Background(), state))
+}
+
+func TestDB_FindState(t *testing.T) {
+	state := newTimerState(t, timer.StateFailed)
+
+	tests := []struct {
+		name           string
+		redisGetResult *redis.StringCmd
+		want           *timer.TimerState
+		wantErr        bool
+	}{
+		{
+			name:           "finds the state",
+			redisGetResult: redis.NewStringResult(serializeStateValue(fromInternalState(state)), nil),
+			want:           state,
+		},
+		{
+			name:           "does not exist",
+			redisGetResult: redis.NewStringResult("", redis.Nil),
+		},
+		{
+			name:           "Get returns error",
+			redisGetResult: redis.NewStringResult("", assert.AnError),
+			wantErr:        true,
+		},
+		{
+			name:           "malformed value",
+			redisGetResult: redis.NewStringResult("{malformed, json}", nil),
+			wantErr:        true,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			d := newDB(t, redisClient, &config.DB{TimerMaxTTLDays: 10})
+
+			redisClient.EXPECT().Get(gomock.Any(), serializeStateKey(state.TimerID)).Return(tt.redisGetResult)
+
+			got, err := d.FindState(context.Background(), state.TimerID)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDB_MarkRelayed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	redisClient := mocks.NewRedisClient(ctrl)
+	d := newDB(t, redisClient, &config.DB{TimerMaxTTLDays: 10})
+
+	tm1, err := timer.NewTimer("http://valid.url/hooks", 0, 0, 0)
+	require.NoError(t, err)
+	tm2, err := timer.NewTimer("http://valid.url/hooks", 0, 0, 0)
+	require.NoError(t, err)
+
+	pipeliner := mocks.NewRedisPipeliner(ctrl)
+	redisClient.EXPECT().Pipeline().Return(pipeliner)
+
+	for _, tm := range []*timer.Timer{tm1, tm2} {
+		state := fromInternalState(timer.NewTimerState(tm, timer.StateRelayed))
+		pipeliner.EXPECT().Set(gomock.Any(), serializeStateKey(tm.ID), serializeStateValue(state), 10*24*time.Hour)
+	}
+	pipeliner.EXPECT().Exec(gomock.Any()).Return(nil, nil)
+
+	require.NoError(t, d.MarkRelayed(context.Background(), []*timer.Timer{tm1, tm2}))
+	require.NoError(t, d.MarkRelayed(context.Background(), nil))
+}
@@ -12,8 +12,13 @@ import (
 )
 
 const (
-	timerTaskQueueName   = "timerTaskQueue"
-	timerBloomFilterName = "timerBloomFilter"
+	timerTaskQueueName = keyTag + ":timerTaskQueue"
+	// timerTaskProcessingName is a sorted set of the IDs of the timers that are dequeued from the outbox but not
+	// relayed yet, scored by their visibility deadline.
+	timerTaskProcessingName = keyTag + ":timerTaskProcessing"
+	// timerIndexName is a sorted set of the IDs of the timers that are neither archived nor cancelled,
+	// scored by their fire time.
+	timerIndexName = keyTag + ":timerIndex"
 )
 
 var (
@@ -21,37 +26,148 @@ var (
 	ErrDeserialization = fmt.Errorf("failed to deserialize timer value")
 	// ErrInvalidURL is
 	ErrInvalidURL = fmt.Errorf("timer has invalid URL")
+	// ErrInvalidIdempotencyWindow indicates that the idempotency window of the config is not positive.
+	ErrInvalidIdempotencyWindow = fmt.Errorf("idempotency window must be positive")
+	// ErrInvalidAttemptHistorySize indicates that the attempt history size of the config is less than 1.
+	ErrInvalidAttemptHistorySize = fmt.Errorf("attempt history size must be at least 1")
 )
 
 // DB holds repo functionalities for timers.
 type DB struct {
-	redisClient extRedis.UniversalClient
-	maxTTL      time.Duration
+	redisClient       extRedis.UniversalClient
+	maxTTL            time.Duration
+	idempotencyWindow time.Duration
+	attemptsSize      int64
+	archive           ArchiveIndex
+	tombstoneTTL      time.Duration
 }
 
-// NewDB constructs a DB
-func NewDB(client extRedis.UniversalClient, cfg *config.DB) *DB {
-	return &DB{
-		redisClient: client,
-		maxTTL:      time.Duration(cfg.TimerMaxTTLDays) * time.Hour * 24,
+// NewDB constructs a DB. it fails when the archive index, the idempotency window or the attempt history size of the
+// config is invalid.
+func NewDB(client extRedis.UniversalClient, cfg *config.DB) (*DB, error) {
+	switch {
+	case cfg.IdempotencyWindow <= 0:
+		return nil, ErrInvalidIdempotencyWindow
+	case cfg.AttemptHistorySize < 1:
+		return nil, ErrInvalidAttemptHistorySize
+	}
+
+	archive, err := NewArchiveIndex(cfg)
+	if err != nil {
+		return nil, err
 	}
+
+	return &DB{
+		redisClient:       client,
+		maxTTL:            time.Duration(cfg.TimerMaxTTLDays) * time.Hour * 24,
+		idempotencyWindow: cfg.IdempotencyWindow,
+		attemptsSize:      int64(cfg.AttemptHistorySize),
+		archive:           archive,
+		tombstoneTTL:      cfg.ArchiveTombstoneTTL,
+	}, nil
 }
 
 // AddTimer follows the outbox pattern:
 // 1. adds the timer object to the repo
 // 2. adds the timer key to the outbox table (for the message relay to pick it up)
-func (d *DB) AddTimer(ctx context.Context, timer *timer.Timer) error {
-	internalTimer := fromInternal(timer)
-	value := serializeValue(internalTimer)
-	key := serializeKey(internalTimer.ID)
+// it returns timer.ErrTimerExists when a timer with the same ID already exists.
+func (d *DB) AddTimer(ctx context.Context, t *timer.Timer) error {
+	conflicts, err := d.AddTimers(ctx, []*timer.Timer{t})
+	switch {
+	case err != nil:
+		return err
+	case len(conflicts) > 0:
+		return timer.ErrTimerExists
+	}
 
-	pipe := d.redisClient.TxPipeline()
+	return nil
+}
 
-	pipe.Set(ctx, key, value, d.maxTTL)
-	pipe.LPush(ctx, timerTaskQueueName, internalTimer.ID)
+// AddTimerIdempotently adds the timer like AddTimer, unless another timer is already added with the same
+// idempotency key within the idempotency window. the key is taken atomically with adding the timer.
+func (d *DB) AddTimerIdempotently(ctx context.Context, t *timer.Timer, idempotencyKey string) (string, error) {
+	internalTimer := fromInternal(t)
 
-	_, err := pipe.Exec(ctx)
-	return err
+	keys := []string{
+		serializeIdempotencyKey(idempotencyKey),
+		serializeKey(internalTimer.ID),
+		timerIndexName,
+		timerTaskQueueName,
+	}
+	keys = append(keys, staleKeys(internalTimer.ID)...)
+	args := []interface{}{
+		internalTimer.ID,
+		d.idempotencyWindow.Milliseconds(),
+		serializeValue(internalTimer),
+		d.maxTTL.Milliseconds(),
+		internalTimer.FireAtSecond,
+	}
+
+	reply, err := addTimerIdempotentlyScript.Run(ctx, d.redisClient, keys, args...).StringSlice()
+	if err != nil {
+		return "", err
+	}
+
+	switch reply[0] {
+	case addTimerReplyReplayed:
+		return reply[1], nil
+	case addTimerReplyExists:
+		return "", timer.ErrTimerExists
+	}
+
+	return "", nil
+}
+
+// AddTimers adds a batch of timers following the outbox pattern in a single script, so the whole batch costs
+// one round trip. the timers whose ID is already taken are skipped and their IDs are returned.
+func (d *DB) AddTimers(ctx context.Context, timers []*timer.Timer) ([]string, error) {
+	if len(timers) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, 5*len(timers)+2)
+	keys = append(keys, timerIndexName, timerTaskQueueName)
+
+	args := make([]interface{}, 0, 3*len(timers)+1)
+	args = append(args, d.maxTTL.Milliseconds())
+
+	for _, t := range timers {
+		internalTimer := fromInternal(t)
+		keys = append(keys, serializeKey(internalTimer.ID))
+		keys = append(keys, staleKeys(internalTimer.ID)...)
+		args = append(args, internalTimer.ID, serializeValue(internalTimer), internalTimer.FireAtSecond)
+	}
+
+	return addTimersScript.Run(ctx, d.redisClient, keys, args...).StringSlice()
+}
+
+// Reschedule overwrites an existing timer and adds it to the outbox table again, so that the relay schedules the
+// new revision. the timer is only overwritten if it still exists, i.e. it has not been archived or cancelled meanwhile,
+// otherwise timer.ErrTimerNotFound is returned. it returns timer.ErrTimerModified when the stored timer is not of the
+// previous revision, i.e. it is rescheduled concurrently.
+func (d *DB) Reschedule(ctx context.Context, t *timer.Timer) error {
+	internalTimer := fromInternal(t)
+
+	keys := []string{serializeKey(internalTimer.ID), timerIndexName, timerTaskQueueName}
+	args := []interface{}{
+		internalTimer.ID,
+		internalTimer.Revision - 1,
+		serializeValue(internalTimer),
+		d.maxTTL.Milliseconds(),
+		internalTimer.FireAtSecond,
+	}
+
+	res, err := rescheduleTimerScript.Run(ctx, d.redisClient, keys, args...).Int()
+	switch {
+	case err != nil:
+		return err
+	case res == 0:
+		return timer.ErrTimerNotFound
+	case res < 0:
+		return timer.ErrTimerModified
+	}
+
+	return nil
 }
 
 // Find looks up a key in the k/v DB.
@@ -79,45 +195,165 @@ func (d *DB) Find(ctx context.Context, timerID string) (*timer.Timer, error) {
 	return t, nil
 }
 
-// IsArchived checks whether a timer is archived.
+// IsArchived checks whether a timer is archived within the retention of the archive.
 func (d *DB) IsArchived(ctx context.Context, timerID string) (bool, error) {
-	return d.redisClient.Do(ctx, "BF.EXISTS", timerBloomFilterName, timerID).Bool()
+	return d.archive.Exists(ctx, d.redisClient, timerID, time.Now())
 }
 
-// Archive a timer. for space efficiency it uses a Bloom Filter.
-func (d *DB) Archive(ctx context.Context, timerID string) error {
-	key := serializeKey(timerID)
+// Archive a timer. for space efficiency it uses a Bloom Filter. see ArchiveIndex.
+// the tombstone is kept until its TTL, unless the TTL is zero.
+func (d *DB) Archive(ctx context.Context, tombstone *timer.Tombstone) error {
+	key := serializeKey(tombstone.TimerID)
 	pipe := d.redisClient.TxPipeline()
 
 	pipe.Del(ctx, key)
-	pipe.Do(ctx, "BF.ADD", timerBloomFilterName, timerID)
+	pipe.ZRem(ctx, timerIndexName, tombstone.TimerID)
+	d.archive.Add(ctx, pipe, tombstone.TimerID, tombstone.FinishedAt)
+	if d.tombstoneTTL > 0 {
+		value := serializeTombstoneValue(fromInternalTombstone(tombstone))
+		pipe.Set(ctx, serializeTombstoneKey(tombstone.TimerID), value, d.tombstoneTTL)
+	}
 
 	_, err := pipe.Exec(ctx)
 	return err
 }
 
-// DequeueOutbox serves a message relay. It pops timers' keys out of the outbox queue.
-func (d *DB) DequeueOutbox(ctx context.Context, batchSize int) ([]*timer.Timer, error) {
+// FindTombstone returns nil, nil when nothing found, or the tombstones are not kept.
+func (d *DB) FindTombstone(ctx context.Context, timerID string) (*timer.Tombstone, error) {
+	if d.tombstoneTTL <= 0 {
+		return nil, nil
+	}
+
+	val, err := d.redisClient.Get(ctx, serializeTombstoneKey(timerID)).Result()
+	switch {
+	case err == extRedis.Nil:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	tombstone, err := deserializeTombstoneValue(val)
+	if err != nil {
+		return nil, ErrDeserialization
+	}
+
+	return toInternalTombstone(timerID, tombstone), nil
+}
+
+// IsCancelled checks whether a timer is cancelled.
+func (d *DB) IsCancelled(ctx context.Context, timerID string) (bool, error) {
+	n, err := d.redisClient.Exists(ctx, serializeCancelledKey(timerID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Cancel a timer. the timer record is removed and a cancellation marker is kept until the max TTL of timers,
+// so that the already enqueued task is skipped by the workers. the state of the timer is kept as cancelled.
+// the timer is only cancelled if it still exists, otherwise timer.ErrTimerNotFound is returned. it returns
+// timer.ErrTimerModified when the stored timer is of another generation, i.e. it is created again meanwhile.
+func (d *DB) Cancel(ctx context.Context, t *timer.Timer) error {
+	state := fromInternalState(timer.NewTimerState(t, timer.StateCancelled))
+
+	keys := []string{serializeKey(t.ID), timerIndexName, serializeCancelledKey(t.ID), serializeStateKey(t.ID)}
+	args := []interface{}{t.ID, t.Generation, d.maxTTL.Milliseconds(), serializeStateValue(state)}
+
+	res, err := cancelTimerScript.Run(ctx, d.redisClient, keys, args...).Int()
+	switch {
+	case err != nil:
+		return err
+	case res == 0:
+		return timer.ErrTimerNotFound
+	case res < 0:
+		return timer.ErrTimerModified
+	}
+
+	return nil
+}
+
+// DequeueOutbox serves a message relay. It moves timers' keys out of the outbox queue into the processing set, where
+// they stay until they are acknowledged or requeued, or until the visibility timeout passes. see ReclaimOutbox.
+// the keys of the timers that are gone, or cannot be relayed as they are malformed, are acknowledged right away.
+// the timers that are dequeued before an error are returned along with the error. it returns timer.ErrLeaseLost when
+// the fencing token is not of the latest lease of the relays.
+func (d *DB) DequeueOutbox(ctx context.Context, fencingToken int64, batchSize int, visibilityTimeout time.Duration) ([]*timer.Timer, error) {
+	keys := []string{timerTaskQueueName, timerTaskProcessingName, relayLeaseTokenName}
 	timers := make([]*timer.Timer, 0, batchSize)
 	for len(timers) < batchSize {
-		timerID, err := d.redisClient.RPop(ctx, timerTaskQueueName).Result()
+		deadline := time.Now().Add(visibilityTimeout).UnixMilli()
+		timerIDs, err := dequeueOutboxScript.Run(ctx, d.redisClient, keys, batchSize-len(timers), deadline, fencingToken).StringSlice()
 		switch {
 		case err == extRedis.Nil:
-			return timers, nil
+			return timers, timer.ErrLeaseLost
 		case err != nil:
-			return nil, err
+			return timers, err
+		case len(timerIDs) == 0:
+			return timers, nil
 		}
 
-		t, err := d.Find(ctx, timerID)
-		switch {
-		case err != nil:
-			return nil, err
-		case t == nil:
-			continue
+		var dropped []string
+		var findErr error
+		for _, timerID := range timerIDs {
+			t, err := d.Find(ctx, timerID)
+			switch {
+			case err == ErrDeserialization || err == ErrInvalidURL:
+				dropped = append(dropped, timerID)
+				findErr = err
+			case err != nil:
+				// the key is reclaimed after the visibility timeout
+				findErr = err
+			case t == nil:
+				dropped = append(dropped, timerID)
+			default:
+				timers = append(timers, t)
+			}
 		}
 
-		timers = append(timers, t)
+		if err = d.AckOutbox(ctx, dropped); err != nil {
+			return timers, err
+		}
+
+		if findErr != nil {
+			return timers, findErr
+		}
 	}
 
 	return timers, nil
 }
+
+// AckOutbox removes the keys of the relayed timers from the processing set.
+func (d *DB) AckOutbox(ctx context.Context, timerIDs []string) error {
+	if len(timerIDs) == 0 {
+		return nil
+	}
+
+	members := make([]interface{}, len(timerIDs))
+	for i, timerID := range timerIDs {
+		members[i] = timerID
+	}
+
+	return d.redisClient.ZRem(ctx, timerTaskProcessingName, members...).Err()
+}
+
+// RequeueOutbox moves the keys of the timers that failed to be relayed from the processing set back to the outbox.
+func (d *DB) RequeueOutbox(ctx context.Context, timerIDs []string) error {
+	if len(timerIDs) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(timerIDs))
+	for i, timerID := range timerIDs {
+		args[i] = timerID
+	}
+
+	keys := []string{timerTaskQueueName, timerTaskProcessingName}
+	return requeueOutboxScript.Run(ctx, d.redisClient, keys, args...).Err()
+}
+
+// ReclaimOutbox moves up to the limit of the keys whose visibility timeout has passed, e.g. their relay died, from the
+// processing set back to the outbox. it returns the number of the reclaimed keys.
+func (d *DB) ReclaimOutbox(ctx context.Context, limit int) (int, error) {
+	keys := []string{timerTaskQueueName, timerTaskProcessingName}
+	return reclaimOutboxScript.Run(ctx, d.redisClient, keys, time.Now().UnixMilli(), limit).Int()
+}
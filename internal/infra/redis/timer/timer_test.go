@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/golang/mock/gomock"
@@ -18,25 +20,352 @@ import (
 )
 
 func TestDB_AddTimer(t *testing.T) {
+	tests := []struct {
+		name       string
+		evalResult *redis.Cmd
+		wantErr    error
+	}{
+		{
+			name:       "timer is added",
+			evalResult: redis.NewCmdResult([]interface{}{}, nil),
+		},
+		{
+			name:       "timer ID is taken",
+			evalResult: redis.NewCmdResult([]interface{}{"1"}, nil),
+			wantErr:    timer.ErrTimerExists,
+		},
+		{
+			name:       "script fails",
+			evalResult: redis.NewCmdResult(nil, assert.AnError),
+			wantErr:    assert.AnError,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	cfg := &config.DB{TimerMaxTTLDays: 10}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			d := newDB(t, redisClient, cfg)
+
+			tm, err := timer.NewTimer("http://valid.url", 0, 0, 0)
+			require.NoError(t, err)
+
+			redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(),
+				append([]string{timerIndexName, timerTaskQueueName, serializeKey(tm.ID)}, staleKeys(tm.ID)...),
+				(10 * 24 * time.Hour).Milliseconds(), tm.ID, serializeValue(fromInternal(tm)), tm.FireAt.Unix(),
+			).Return(tt.evalResult)
+
+			err = d.AddTimer(context.Background(), tm)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestNewDB(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.DB
+		wantErr error
+	}{
+		{
+			name: "valid",
+			cfg:  config.DB{IdempotencyWindow: time.Hour, AttemptHistorySize: 50},
+		},
+		{
+			name:    "zero idempotency window",
+			cfg:     config.DB{AttemptHistorySize: 50},
+			wantErr: ErrInvalidIdempotencyWindow,
+		},
+		{
+			name:    "negative idempotency window",
+			cfg:     config.DB{IdempotencyWindow: -time.Hour, AttemptHistorySize: 50},
+			wantErr: ErrInvalidIdempotencyWindow,
+		},
+		{
+			name:    "zero attempt history size",
+			cfg:     config.DB{IdempotencyWindow: time.Hour},
+			wantErr: ErrInvalidAttemptHistorySize,
+		},
+		{
+			name:    "negative attempt history size",
+			cfg:     config.DB{IdempotencyWindow: time.Hour, AttemptHistorySize: -1},
+			wantErr: ErrInvalidAttemptHistorySize,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.ArchiveIndex, cfg.ArchiveCapacity, cfg.ArchiveFalsePositiveRate = ArchiveIndexBitmap, 1000, 0.01
+			cfg.ArchivePartition, cfg.ArchiveRetention = 24*time.Hour, 24*time.Hour
+
+			_, err := NewDB(nil, &cfg)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestDB_AddTimerIdempotently(t *testing.T) {
+	tests := []struct {
+		name       string
+		evalResult *redis.Cmd
+		want       string
+		wantErr    error
+	}{
+		{
+			name:       "timer is added",
+			evalResult: redis.NewCmdResult([]interface{}{addTimerReplyAdded}, nil),
+			want:       "",
+		},
+		{
+			name:       "idempotency key is taken",
+			evalResult: redis.NewCmdResult([]interface{}{addTimerReplyReplayed, "1"}, nil),
+			want:       "1",
+		},
+		{
+			name:       "timer ID is taken",
+			evalResult: redis.NewCmdResult([]interface{}{addTimerReplyExists}, nil),
+			want:       "",
+			wantErr:    timer.ErrTimerExists,
+		},
+		{
+			name:       "script fails",
+			evalResult: redis.NewCmdResult(nil, assert.AnError),
+			want:       "",
+			wantErr:    assert.AnError,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	cfg := &config.DB{TimerMaxTTLDays: 10, IdempotencyWindow: time.Hour}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			d := newDB(t, redisClient, cfg)
+
+			tm, err := timer.NewTimer("http://valid.url", 0, 0, 1)
+			require.NoError(t, err)
 
+			keys := []string{serializeIdempotencyKey("order-42"), serializeKey(tm.ID), timerIndexName, timerTaskQueueName}
+			keys = append(keys, staleKeys(tm.ID)...)
+			redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(), keys,
+				tm.ID, time.Hour.Milliseconds(), serializeValue(fromInternal(tm)), (10 * 24 * time.Hour).Milliseconds(), tm.FireAt.Unix(),
+			).Return(tt.evalResult)
+
+			got, err := d.AddTimerIdempotently(context.Background(), tm, "order-42")
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDB_AddTimers(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	cfg := &config.DB{TimerMaxTTLDays: 10}
 
 	redisClient := mocks.NewRedisClient(ctrl)
-	d := NewDB(redisClient, cfg)
+	d := newDB(t, redisClient, cfg)
 
-	tm, err := timer.NewTimer("http://valid.url", 0, 0, 0)
+	tm1, err := timer.NewTimer("http://valid.url", 0, 0, 1)
+	require.NoError(t, err)
+	tm2, err := timer.NewTimer("http://valid.url", 0, 0, 2)
 	require.NoError(t, err)
 
-	pipeliner := mocks.NewRedisPipeliner(ctrl)
-	redisClient.EXPECT().TxPipeline().Return(pipeliner)
+	redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(),
+		[]string{
+			timerIndexName, timerTaskQueueName,
+			serializeKey(tm1.ID), serializeCancelledKey(tm1.ID), serializeStateKey(tm1.ID), serializeTombstoneKey(tm1.ID), serializeAttemptsKey(tm1.ID),
+			serializeKey(tm2.ID), serializeCancelledKey(tm2.ID), serializeStateKey(tm2.ID), serializeTombstoneKey(tm2.ID), serializeAttemptsKey(tm2.ID),
+		},
+		(10 * 24 * time.Hour).Milliseconds(),
+		tm1.ID, serializeValue(fromInternal(tm1)), tm1.FireAt.Unix(),
+		tm2.ID, serializeValue(fromInternal(tm2)), tm2.FireAt.Unix(),
+	).Return(redis.NewCmdResult([]interface{}{tm2.ID}, nil))
 
-	pipeliner.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
-	pipeliner.EXPECT().LPush(gomock.Any(), gomock.Any(), gomock.Any())
-	pipeliner.EXPECT().Exec(gomock.Any()).Return(nil, nil)
+	conflicts, err := d.AddTimers(context.Background(), []*timer.Timer{tm1, tm2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{tm2.ID}, conflicts)
+}
 
-	err = d.AddTimer(context.Background(), tm)
+func TestDB_AddTimers_IDsOfTheRecordsOfAnotherTimer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	cfg := &config.DB{TimerMaxTTLDays: 10}
+
+	redisClient := mocks.NewRedisClient(ctrl)
+	d := newDB(t, redisClient, cfg)
+
+	ids := []string{"x", "cancelled-x", "state-x", "tombstone-x", "attempts-x", "dead-x", "idempotency-x"}
+	timers := make([]*timer.Timer, 0, len(ids))
+	for _, id := range ids {
+		tm, err := timer.NewTimerFromCommand(timer.SetTimerCommand{ID: id, URLRaw: "http://valid.url", Seconds: 1})
+		require.NoError(t, err)
+		timers = append(timers, tm)
+	}
+
+	var keys []string
+	redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Do(func(_ context.Context, _ string, k []string, _ ...interface{}) { keys = k }).
+		Return(redis.NewCmdResult([]interface{}{}, nil))
+
+	_, err := d.AddTimers(context.Background(), timers)
 	require.NoError(t, err)
+
+	// the keys of x, including its dead letters and idempotency keys, are not touched when the other timers are added
+	xKeys := map[string]bool{serializeDeadLetterKey("x"): true, serializeIdempotencyKey("x"): true}
+	for _, key := range keys[2:7] {
+		xKeys[key] = true
+	}
+	for _, key := range keys[7:] {
+		assert.False(t, xKeys[key], "the key %s of another timer is a key of x", key)
+	}
+}
+
+func TestDB_Reschedule(t *testing.T) {
+	tests := []struct {
+		name       string
+		evalResult *redis.Cmd
+		wantErr    error
+	}{
+		{
+			name:       "timer is rescheduled",
+			evalResult: redis.NewCmdResult(int64(1), nil),
+		},
+		{
+			name:       "timer does not exist",
+			evalResult: redis.NewCmdResult(int64(0), nil),
+			wantErr:    timer.ErrTimerNotFound,
+		},
+		{
+			name:       "timer is rescheduled concurrently",
+			evalResult: redis.NewCmdResult(int64(-1), nil),
+			wantErr:    timer.ErrTimerModified,
+		},
+		{
+			name:       "script fails",
+			evalResult: redis.NewCmdResult(nil, assert.AnError),
+			wantErr:    assert.AnError,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	cfg := &config.DB{TimerMaxTTLDays: 10}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			d := newDB(t, redisClient, cfg)
+
+			tm, err := timer.NewTimer("http://valid.url", 0, 0, 0)
+			require.NoError(t, err)
+			require.NoError(t, tm.Reschedule(1, 0, 0))
+
+			redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(),
+				[]string{serializeKey(tm.ID), timerIndexName, timerTaskQueueName},
+				tm.ID, 0, serializeValue(fromInternal(tm)), (10 * 24 * time.Hour).Milliseconds(), tm.FireAt.Unix(),
+			).Return(tt.evalResult)
+
+			err = d.Reschedule(context.Background(), tm)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestDB_Cancel(t *testing.T) {
+	tests := []struct {
+		name       string
+		evalResult *redis.Cmd
+		wantErr    error
+	}{
+		{
+			name:       "timer is cancelled",
+			evalResult: redis.NewCmdResult(int64(1), nil),
+		},
+		{
+			name:       "timer does not exist",
+			evalResult: redis.NewCmdResult(int64(0), nil),
+			wantErr:    timer.ErrTimerNotFound,
+		},
+		{
+			name:       "timer is of another generation",
+			evalResult: redis.NewCmdResult(int64(-1), nil),
+			wantErr:    timer.ErrTimerModified,
+		},
+		{
+			name:       "script fails",
+			evalResult: redis.NewCmdResult(nil, assert.AnError),
+			wantErr:    assert.AnError,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	cfg := &config.DB{TimerMaxTTLDays: 10}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			d := newDB(t, redisClient, cfg)
+
+			tm, err := timer.NewTimer("http://valid.url/hooks", 0, 0, 0)
+			require.NoError(t, err)
+			tm.Generation = tm.CreatedAt.UnixNano()
+			state := fromInternalState(timer.NewTimerState(tm, timer.StateCancelled))
+
+			redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(),
+				[]string{serializeKey(tm.ID), timerIndexName, serializeCancelledKey(tm.ID), serializeStateKey(tm.ID)},
+				tm.ID, tm.Generation, (10 * 24 * time.Hour).Milliseconds(), serializeStateValue(state),
+			).Return(tt.evalResult)
+
+			err = d.Cancel(context.Background(), tm)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestDB_IsCancelled(t *testing.T) {
+	tests := []struct {
+		name              string
+		redisExistsResult *redis.IntCmd
+		want              bool
+		wantErr           assert.ErrorAssertionFunc
+	}{
+		{
+			name:              "cancelled",
+			redisExistsResult: redis.NewIntResult(1, nil),
+			want:              true,
+			wantErr:           assert.NoError,
+		},
+		{
+			name:              "not cancelled",
+			redisExistsResult: redis.NewIntResult(0, nil),
+			want:              false,
+			wantErr:           assert.NoError,
+		},
+		{
+			name:              "Exists returns error",
+			redisExistsResult: redis.NewIntResult(0, assert.AnError),
+			want:              false,
+			wantErr:           assert.Error,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	cfg := &config.DB{TimerMaxTTLDays: 10}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redisClient := mocks.NewRedisClient(ctrl)
+			d := newDB(t, redisClient, cfg)
+
+			redisClient.EXPECT().Exists(gomock.Any(), serializeCancelledKey("1")).Return(tt.redisExistsResult)
+
+			got, err := d.IsCancelled(context.Background(), "1")
+			tt.wantErr(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
 }
 
 func TestDB_Find(t *testing.T) {
@@ -53,6 +382,18 @@ func TestDB_Find(t *testing.T) {
 	require.NoError(t, err)
 
 	aTimerInRedisTimerJSONString := string(aTimerInRedisTimerJSON)
+
+	recurrence, err := timer.NewRecurrence("0 9 * * 1-5", "Europe/Berlin", time.Unix(4102444800, 0), 10)
+	require.NoError(t, err)
+	aRecurringTimer, err := timer.NewRecurringTimer("http://valid.url", recurrence)
+	require.NoError(t, err)
+	aRecurringTimer.Webhook, err = timer.NewWebhook("PUT", map[string]string{"X-Key": "value"}, []byte("hello"), "text/plain")
+	require.NoError(t, err)
+	aRecurringTimer.Webhook, err = aRecurringTimer.Webhook.WithTimeout(15 * time.Second)
+	require.NoError(t, err)
+	aRecurringTimer.RetryPolicy = &timer.RetryPolicy{MaxAttempts: 3, InitialBackoff: 5 * time.Second, Multiplier: 1.5, Deadline: time.Hour}
+	aRecurringTimer.SuccessCriteria = &timer.SuccessCriteria{AcceptedStatusCodes: []string{"2xx", "410"}, JSONPath: "status", JSONValue: "ok"}
+	aRecurringTimer.Generation = 1700000000000000000
 	fmt.Println(aTimerInRedisTimerJSONString)
 
 	tests := []struct {
@@ -67,6 +408,12 @@ func TestDB_Find(t *testing.T) {
 			want:           aTimer,
 			wantErr:        false,
 		},
+		{
+			name:           "finds the recurring timer in DB",
+			redisGetResult: redis.NewStringResult(serializeValue(fromInternal(aRecurringTimer)), nil),
+			want:           aRecurringTimer,
+			wantErr:        false,
+		},
 		{
 			name:           "does not exist",
 			redisGetResult: redis.NewStringResult("", redis.Nil),
@@ -99,7 +446,7 @@ func TestDB_Find(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			redisClient := mocks.NewRedisClient(ctrl)
-			d := NewDB(redisClient, cfg)
+			d := newDB(t, redisClient, cfg)
 
 			redisClient.EXPECT().Get(gomock.Any(), gomock.Any()).Return(tt.redisGetResult)
 
@@ -125,17 +472,32 @@ func assertTimer(t *testing.T, expected, actual *timer.Timer) {
 	assert.Equal(t, expected.ID, actual.ID)
 	assert.Equal(t, expected.URL.String(), actual.URL.String())
 	assert.Equal(t, expected.FireAt.Second(), actual.FireAt.Second())
+	assert.Equal(t, expected.Recurrence, actual.Recurrence)
+	assert.Equal(t, expected.Webhook.MethodOrDefault(), actual.Webhook.MethodOrDefault())
+	assert.Equal(t, expected.Webhook.Headers, actual.Webhook.Headers)
+	assert.Equal(t, expected.Webhook.Body, actual.Webhook.Body)
+	assert.Equal(t, expected.Webhook.ContentType, actual.Webhook.ContentType)
+	assert.Equal(t, expected.Webhook.Timeout, actual.Webhook.Timeout)
+	assert.Equal(t, expected.SuccessCriteria, actual.SuccessCriteria)
 }
 
 func TestDB_DequeueOutbox(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	cfg := &config.DB{TimerMaxTTLDays: 10}
+	outboxKeys := []string{timerTaskQueueName, timerTaskProcessingName, relayLeaseTokenName}
 
 	tenTimers := make([]*timer.Timer, 0, 10)
+	tenIDs := make([]interface{}, 0, 10)
 	for i := 0; i < 10; i++ {
 		aTimer, err := timer.NewTimer("http://valid.url", 0, 0, 0)
 		require.NoError(t, err)
 		tenTimers = append(tenTimers, aTimer)
+		tenIDs = append(tenIDs, aTimer.ID)
+	}
+
+	expectGet := func(client *mocks.RedisClient, tm *timer.Timer) {
+		getResult := redis.NewStringResult(serializeValue(fromInternal(tm)), nil)
+		client.EXPECT().Get(gomock.Any(), serializeKey(tm.ID)).Return(getResult)
 	}
 
 	tests := []struct {
@@ -148,14 +510,11 @@ func TestDB_DequeueOutbox(t *testing.T) {
 		{
 			name: "dequeue 10 timers",
 			mockFn: func(client *mocks.RedisClient) {
+				client.EXPECT().EvalSha(gomock.Any(), gomock.Any(), outboxKeys, 10, gomock.Any(), int64(1)).
+					Return(redis.NewCmdResult(tenIDs, nil))
 				for i := 0; i < 10; i++ {
-					rPopResult := redis.NewStringResult(tenTimers[i].ID, nil)
-					client.EXPECT().RPop(gomock.Any(), gomock.Any()).Return(rPopResult)
-
-					getResult := redis.NewStringResult(serializeValue(fromInternal(tenTimers[i])), nil)
-					client.EXPECT().Get(gomock.Any(), serializeKey(tenTimers[i].ID)).Return(getResult)
+					expectGet(client, tenTimers[i])
 				}
-
 			},
 			batchSize: 10,
 			want:      tenTimers,
@@ -164,66 +523,184 @@ func TestDB_DequeueOutbox(t *testing.T) {
 		{
 			name: "returns early when queue is empty",
 			mockFn: func(client *mocks.RedisClient) {
-				rPopResult := redis.NewStringResult("", redis.Nil)
-				client.EXPECT().RPop(gomock.Any(), gomock.Any()).Return(rPopResult)
+				client.EXPECT().EvalSha(gomock.Any(), gomock.Any(), outboxKeys, 10, gomock.Any(), int64(1)).
+					Return(redis.NewCmdResult([]interface{}{}, nil))
 			},
 			batchSize: 10,
 			want:      []*timer.Timer{},
 			wantErr:   assert.NoError,
 		},
 		{
-			name: "keeps popping when keys are not found",
+			name: "acknowledges the keys that are not found and keeps dequeuing",
 			mockFn: func(client *mocks.RedisClient) {
-				for i := 0; i < 10; i++ {
-					rPopResult := redis.NewStringResult(tenTimers[i].ID, nil)
-					client.EXPECT().RPop(gomock.Any(), gomock.Any()).Return(rPopResult)
-
-					getResult := redis.NewStringResult("", redis.Nil)
-					client.EXPECT().Get(gomock.Any(), serializeKey(tenTimers[i].ID)).Return(getResult)
+				client.EXPECT().EvalSha(gomock.Any(), gomock.Any(), outboxKeys, 10, gomock.Any(), int64(1)).
+					Return(redis.NewCmdResult([]interface{}{"gone", tenTimers[0].ID}, nil))
+				client.EXPECT().Get(gomock.Any(), serializeKey("gone")).Return(redis.NewStringResult("", redis.Nil))
+				expectGet(client, tenTimers[0])
+				client.EXPECT().ZRem(gomock.Any(), timerTaskProcessingName, "gone").Return(redis.NewIntResult(1, nil))
+
+				client.EXPECT().EvalSha(gomock.Any(), gomock.Any(), outboxKeys, 9, gomock.Any(), int64(1)).
+					Return(redis.NewCmdResult(tenIDs[1:], nil))
+				for i := 1; i < 10; i++ {
+					expectGet(client, tenTimers[i])
 				}
-
-				for i := 0; i < 10; i++ {
-					rPopResult := redis.NewStringResult(tenTimers[i].ID, nil)
-					client.EXPECT().RPop(gomock.Any(), gomock.Any()).Return(rPopResult)
-
-					getResult := redis.NewStringResult(serializeValue(fromInternal(tenTimers[i])), nil)
-					client.EXPECT().Get(gomock.Any(), serializeKey(tenTimers[i].ID)).Return(getResult)
-				}
-
 			},
 			batchSize: 10,
 			want:      tenTimers,
 			wantErr:   assert.NoError,
 		},
+		{
+			name: "acknowledges the malformed keys and returns the dequeued timers along with the error",
+			mockFn: func(client *mocks.RedisClient) {
+				client.EXPECT().EvalSha(gomock.Any(), gomock.Any(), outboxKeys, 2, gomock.Any(), int64(1)).
+					Return(redis.NewCmdResult([]interface{}{tenTimers[0].ID, "malformed"}, nil))
+				expectGet(client, tenTimers[0])
+				client.EXPECT().Get(gomock.Any(), serializeKey("malformed")).Return(redis.NewStringResult("{", nil))
+				client.EXPECT().ZRem(gomock.Any(), timerTaskProcessingName, "malformed").Return(redis.NewIntResult(1, nil))
+			},
+			batchSize: 2,
+			want:      tenTimers[:1],
+			wantErr:   assert.Error,
+		},
+		{
+			name: "fencing token is stale",
+			mockFn: func(client *mocks.RedisClient) {
+				client.EXPECT().EvalSha(gomock.Any(), gomock.Any(), outboxKeys, 10, gomock.Any(), int64(1)).
+					Return(redis.NewCmdResult(nil, redis.Nil))
+			},
+			batchSize: 10,
+			want:      []*timer.Timer{},
+			wantErr: func(t assert.TestingT, err error, _ ...interface{}) bool {
+				return assert.ErrorIs(t, err, timer.ErrLeaseLost)
+			},
+		},
+		{
+			name: "dequeue fails",
+			mockFn: func(client *mocks.RedisClient) {
+				client.EXPECT().EvalSha(gomock.Any(), gomock.Any(), outboxKeys, 10, gomock.Any(), int64(1)).
+					Return(redis.NewCmdResult(nil, assert.AnError))
+			},
+			batchSize: 10,
+			want:      []*timer.Timer{},
+			wantErr:   assert.Error,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			redisClient := mocks.NewRedisClient(ctrl)
 
-			d := NewDB(redisClient, cfg)
+			d := newDB(t, redisClient, cfg)
 			tt.mockFn(redisClient)
 
-			got, err := d.DequeueOutbox(context.Background(), tt.batchSize)
-			if !tt.wantErr(t, err, fmt.Sprintf("DequeueOutbox(ctx, %d)", tt.batchSize)) {
-				return
-			}
+			got, err := d.DequeueOutbox(context.Background(), 1, tt.batchSize, time.Minute)
+			tt.wantErr(t, err, fmt.Sprintf("DequeueOutbox(ctx, %d)", tt.batchSize))
 
-			assert.Equal(t, len(got), len(tt.want))
-
-			if len(tt.want) == 0 {
-				return
-			}
+			require.Equal(t, len(tt.want), len(got))
 
 			sort.Slice(got, func(i, j int) bool {
 				return got[i].ID < got[j].ID
 			})
-			sort.Slice(tt.want, func(i, j int) bool {
-				return tt.want[i].ID < tt.want[j].ID
+			want := append([]*timer.Timer{}, tt.want...)
+			sort.Slice(want, func(i, j int) bool {
+				return want[i].ID < want[j].ID
 			})
 
-			for i := 0; i < tt.batchSize; i++ {
-				assertTimer(t, got[i], tt.want[i])
+			for i := range want {
+				assertTimer(t, got[i], want[i])
 			}
 		})
 	}
 }
+
+func TestDB_AckOutbox(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	redisClient := mocks.NewRedisClient(ctrl)
+	d := newDB(t, redisClient, &config.DB{TimerMaxTTLDays: 10})
+
+	redisClient.EXPECT().ZRem(gomock.Any(), timerTaskProcessingName, "1", "2").Return(redis.NewIntResult(2, nil))
+	require.NoError(t, d.AckOutbox(context.Background(), []string{"1", "2"}))
+
+	// nothing to acknowledge
+	require.NoError(t, d.AckOutbox(context.Background(), nil))
+}
+
+func TestDB_RequeueOutbox(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	redisClient := mocks.NewRedisClient(ctrl)
+	d := newDB(t, redisClient, &config.DB{TimerMaxTTLDays: 10})
+
+	redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(), []string{timerTaskQueueName, timerTaskProcessingName}, "1", "2").
+		Return(redis.NewCmdResult(int64(2), nil))
+	require.NoError(t, d.RequeueOutbox(context.Background(), []string{"1", "2"}))
+
+	// nothing to requeue
+	require.NoError(t, d.RequeueOutbox(context.Background(), nil))
+}
+
+func TestDB_ReclaimOutbox(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	redisClient := mocks.NewRedisClient(ctrl)
+	d := newDB(t, redisClient, &config.DB{TimerMaxTTLDays: 10})
+
+	redisClient.EXPECT().EvalSha(gomock.Any(), gomock.Any(), []string{timerTaskQueueName, timerTaskProcessingName}, gomock.Any(), 100).
+		Return(redis.NewCmdResult(int64(3), nil))
+
+	got, err := d.ReclaimOutbox(context.Background(), 100)
+	require.NoError(t, err)
+	assert.Equal(t, 3, got)
+}
+
+// newDB constructs a DB with a small daily bitmap archive index, unless the config sets another one.
+func newDB(t *testing.T, client redis.UniversalClient, cfg *config.DB) *DB {
+	c := *cfg
+	if c.ArchiveIndex == "" {
+		c.ArchiveIndex = ArchiveIndexBitmap
+		c.ArchiveCapacity = 1000
+		c.ArchiveFalsePositiveRate = 0.01
+	}
+	if c.ArchivePartition == 0 {
+		c.ArchivePartition = 24 * time.Hour
+		c.ArchiveRetention = 24 * time.Hour
+	}
+	if c.IdempotencyWindow == 0 {
+		c.IdempotencyWindow = 24 * time.Hour
+	}
+	if c.AttemptHistorySize == 0 {
+		c.AttemptHistorySize = 50
+	}
+
+	d, err := NewDB(client, &c)
+	require.NoError(t, err)
+	return d
+}
+
+// the scripts and transactions of the store touch the keys of the timers along with the index and the outbox, which
+// Redis Cluster only allows for the keys of one hash slot.
+func TestKeys_HashSlot(t *testing.T) {
+	keys := []string{
+		timerIndexName,
+		timerTaskQueueName,
+		timerTaskProcessingName,
+		timerDeadLetterIndexName,
+		relayLeaseName,
+		relayLeaseTokenName,
+		serializeKey("x"),
+		serializeIdempotencyKey("{order}-42"),
+		serializeDeadLetterKey("x:3@1700000000000"),
+	}
+	keys = append(keys, staleKeys("x")...)
+
+	for _, key := range keys {
+		assert.Equal(t, "timers", hashTag(key), key)
+	}
+}
+
+// hashTag is the part of the key that Redis Cluster hashes into a slot.
+func hashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
+}
@@ -6,11 +6,9 @@ package mocks
 
 import (
 	context "context"
-	reflect "reflect"
-
-	gomock "github.com/golang/mock/gomock"
-
 	timer "github.com/cubny/httpqueue/internal/app/timer"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
 )
 
 // HttpClient is a mock of HttpClient interface.
@@ -37,15 +35,16 @@ func (m *HttpClient) EXPECT() *HttpClientMockRecorder {
 }
 
 // Shoot mocks base method.
-func (m *HttpClient) Shoot(arg0 context.Context, arg1 *timer.Timer) error {
+func (m *HttpClient) Shoot(arg0 context.Context, arg1 *timer.Timer, arg2 int) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Shoot", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "Shoot", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Shoot indicates an expected call of Shoot.
-func (mr *HttpClientMockRecorder) Shoot(arg0, arg1 interface{}) *gomock.Call {
+func (mr *HttpClientMockRecorder) Shoot(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shoot", reflect.TypeOf((*HttpClient)(nil).Shoot), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shoot", reflect.TypeOf((*HttpClient)(nil).Shoot), arg0, arg1, arg2)
 }
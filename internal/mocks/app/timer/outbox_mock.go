@@ -6,11 +6,10 @@ package mocks
 
 import (
 	context "context"
-	reflect "reflect"
-
-	gomock "github.com/golang/mock/gomock"
-
 	timer "github.com/cubny/httpqueue/internal/app/timer"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+	time "time"
 )
 
 // Outbox is a mock of Outbox interface.
@@ -36,17 +35,103 @@ func (m *Outbox) EXPECT() *OutboxMockRecorder {
 	return m.recorder
 }
 
+// AckOutbox mocks base method.
+func (m *Outbox) AckOutbox(arg0 context.Context, arg1 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AckOutbox", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AckOutbox indicates an expected call of AckOutbox.
+func (mr *OutboxMockRecorder) AckOutbox(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AckOutbox", reflect.TypeOf((*Outbox)(nil).AckOutbox), arg0, arg1)
+}
+
+// AcquireLease mocks base method.
+func (m *Outbox) AcquireLease(arg0 context.Context, arg1 string, arg2 time.Duration) (*timer.Lease, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcquireLease", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*timer.Lease)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcquireLease indicates an expected call of AcquireLease.
+func (mr *OutboxMockRecorder) AcquireLease(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcquireLease", reflect.TypeOf((*Outbox)(nil).AcquireLease), arg0, arg1, arg2)
+}
+
 // DequeueOutbox mocks base method.
-func (m *Outbox) DequeueOutbox(arg0 context.Context, arg1 int) ([]*timer.Timer, error) {
+func (m *Outbox) DequeueOutbox(arg0 context.Context, arg1 int64, arg2 int, arg3 time.Duration) ([]*timer.Timer, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DequeueOutbox", arg0, arg1)
+	ret := m.ctrl.Call(m, "DequeueOutbox", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].([]*timer.Timer)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DequeueOutbox indicates an expected call of DequeueOutbox.
-func (mr *OutboxMockRecorder) DequeueOutbox(arg0, arg1 interface{}) *gomock.Call {
+func (mr *OutboxMockRecorder) DequeueOutbox(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DequeueOutbox", reflect.TypeOf((*Outbox)(nil).DequeueOutbox), arg0, arg1, arg2, arg3)
+}
+
+// MarkRelayed mocks base method.
+func (m *Outbox) MarkRelayed(arg0 context.Context, arg1 []*timer.Timer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkRelayed", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkRelayed indicates an expected call of MarkRelayed.
+func (mr *OutboxMockRecorder) MarkRelayed(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkRelayed", reflect.TypeOf((*Outbox)(nil).MarkRelayed), arg0, arg1)
+}
+
+// ReclaimOutbox mocks base method.
+func (m *Outbox) ReclaimOutbox(arg0 context.Context, arg1 int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReclaimOutbox", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReclaimOutbox indicates an expected call of ReclaimOutbox.
+func (mr *OutboxMockRecorder) ReclaimOutbox(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReclaimOutbox", reflect.TypeOf((*Outbox)(nil).ReclaimOutbox), arg0, arg1)
+}
+
+// ReleaseLease mocks base method.
+func (m *Outbox) ReleaseLease(arg0 context.Context, arg1 *timer.Lease) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseLease", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseLease indicates an expected call of ReleaseLease.
+func (mr *OutboxMockRecorder) ReleaseLease(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseLease", reflect.TypeOf((*Outbox)(nil).ReleaseLease), arg0, arg1)
+}
+
+// RequeueOutbox mocks base method.
+func (m *Outbox) RequeueOutbox(arg0 context.Context, arg1 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequeueOutbox", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequeueOutbox indicates an expected call of RequeueOutbox.
+func (mr *OutboxMockRecorder) RequeueOutbox(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DequeueOutbox", reflect.TypeOf((*Outbox)(nil).DequeueOutbox), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequeueOutbox", reflect.TypeOf((*Outbox)(nil).RequeueOutbox), arg0, arg1)
 }
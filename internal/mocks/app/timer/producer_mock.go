@@ -6,11 +6,9 @@ package mocks
 
 import (
 	context "context"
-	reflect "reflect"
-
-	gomock "github.com/golang/mock/gomock"
-
 	timer "github.com/cubny/httpqueue/internal/app/timer"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
 )
 
 // Producer is a mock of Producer interface.
@@ -6,11 +6,9 @@ package mocks
 
 import (
 	context "context"
-	reflect "reflect"
-
-	gomock "github.com/golang/mock/gomock"
-
 	timer "github.com/cubny/httpqueue/internal/app/timer"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
 )
 
 // Repo is a mock of Repo interface.
@@ -36,6 +34,34 @@ func (m *Repo) EXPECT() *RepoMockRecorder {
 	return m.recorder
 }
 
+// AddAttempt mocks base method.
+func (m *Repo) AddAttempt(arg0 context.Context, arg1 string, arg2 *timer.Attempt) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAttempt", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAttempt indicates an expected call of AddAttempt.
+func (mr *RepoMockRecorder) AddAttempt(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAttempt", reflect.TypeOf((*Repo)(nil).AddAttempt), arg0, arg1, arg2)
+}
+
+// AddDeadLetter mocks base method.
+func (m *Repo) AddDeadLetter(arg0 context.Context, arg1 *timer.DeadLetter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDeadLetter", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDeadLetter indicates an expected call of AddDeadLetter.
+func (mr *RepoMockRecorder) AddDeadLetter(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDeadLetter", reflect.TypeOf((*Repo)(nil).AddDeadLetter), arg0, arg1)
+}
+
 // AddTimer mocks base method.
 func (m *Repo) AddTimer(arg0 context.Context, arg1 *timer.Timer) error {
 	m.ctrl.T.Helper()
@@ -50,8 +76,38 @@ func (mr *RepoMockRecorder) AddTimer(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTimer", reflect.TypeOf((*Repo)(nil).AddTimer), arg0, arg1)
 }
 
+// AddTimerIdempotently mocks base method.
+func (m *Repo) AddTimerIdempotently(arg0 context.Context, arg1 *timer.Timer, arg2 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddTimerIdempotently", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddTimerIdempotently indicates an expected call of AddTimerIdempotently.
+func (mr *RepoMockRecorder) AddTimerIdempotently(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTimerIdempotently", reflect.TypeOf((*Repo)(nil).AddTimerIdempotently), arg0, arg1, arg2)
+}
+
+// AddTimers mocks base method.
+func (m *Repo) AddTimers(arg0 context.Context, arg1 []*timer.Timer) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddTimers", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddTimers indicates an expected call of AddTimers.
+func (mr *RepoMockRecorder) AddTimers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTimers", reflect.TypeOf((*Repo)(nil).AddTimers), arg0, arg1)
+}
+
 // Archive mocks base method.
-func (m *Repo) Archive(arg0 context.Context, arg1 string) error {
+func (m *Repo) Archive(arg0 context.Context, arg1 *timer.Tombstone) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Archive", arg0, arg1)
 	ret0, _ := ret[0].(error)
@@ -64,6 +120,20 @@ func (mr *RepoMockRecorder) Archive(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Archive", reflect.TypeOf((*Repo)(nil).Archive), arg0, arg1)
 }
 
+// Cancel mocks base method.
+func (m *Repo) Cancel(arg0 context.Context, arg1 *timer.Timer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Cancel", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Cancel indicates an expected call of Cancel.
+func (mr *RepoMockRecorder) Cancel(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Cancel", reflect.TypeOf((*Repo)(nil).Cancel), arg0, arg1)
+}
+
 // Find mocks base method.
 func (m *Repo) Find(arg0 context.Context, arg1 string) (*timer.Timer, error) {
 	m.ctrl.T.Helper()
@@ -79,6 +149,51 @@ func (mr *RepoMockRecorder) Find(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*Repo)(nil).Find), arg0, arg1)
 }
 
+// FindDeadLetter mocks base method.
+func (m *Repo) FindDeadLetter(arg0 context.Context, arg1 string) (*timer.DeadLetter, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindDeadLetter", arg0, arg1)
+	ret0, _ := ret[0].(*timer.DeadLetter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindDeadLetter indicates an expected call of FindDeadLetter.
+func (mr *RepoMockRecorder) FindDeadLetter(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDeadLetter", reflect.TypeOf((*Repo)(nil).FindDeadLetter), arg0, arg1)
+}
+
+// FindState mocks base method.
+func (m *Repo) FindState(arg0 context.Context, arg1 string) (*timer.TimerState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindState", arg0, arg1)
+	ret0, _ := ret[0].(*timer.TimerState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindState indicates an expected call of FindState.
+func (mr *RepoMockRecorder) FindState(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindState", reflect.TypeOf((*Repo)(nil).FindState), arg0, arg1)
+}
+
+// FindTombstone mocks base method.
+func (m *Repo) FindTombstone(arg0 context.Context, arg1 string) (*timer.Tombstone, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindTombstone", arg0, arg1)
+	ret0, _ := ret[0].(*timer.Tombstone)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindTombstone indicates an expected call of FindTombstone.
+func (mr *RepoMockRecorder) FindTombstone(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTombstone", reflect.TypeOf((*Repo)(nil).FindTombstone), arg0, arg1)
+}
+
 // IsArchived mocks base method.
 func (m *Repo) IsArchived(arg0 context.Context, arg1 string) (bool, error) {
 	m.ctrl.T.Helper()
@@ -93,3 +208,120 @@ func (mr *RepoMockRecorder) IsArchived(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsArchived", reflect.TypeOf((*Repo)(nil).IsArchived), arg0, arg1)
 }
+
+// IsCancelled mocks base method.
+func (m *Repo) IsCancelled(arg0 context.Context, arg1 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsCancelled", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsCancelled indicates an expected call of IsCancelled.
+func (mr *RepoMockRecorder) IsCancelled(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsCancelled", reflect.TypeOf((*Repo)(nil).IsCancelled), arg0, arg1)
+}
+
+// List mocks base method.
+func (m *Repo) List(arg0 context.Context, arg1 timer.ListTimersQuery) (*timer.TimersPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1)
+	ret0, _ := ret[0].(*timer.TimersPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *RepoMockRecorder) List(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*Repo)(nil).List), arg0, arg1)
+}
+
+// ListAttempts mocks base method.
+func (m *Repo) ListAttempts(arg0 context.Context, arg1 string) ([]*timer.Attempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAttempts", arg0, arg1)
+	ret0, _ := ret[0].([]*timer.Attempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAttempts indicates an expected call of ListAttempts.
+func (mr *RepoMockRecorder) ListAttempts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAttempts", reflect.TypeOf((*Repo)(nil).ListAttempts), arg0, arg1)
+}
+
+// ListDeadLetters mocks base method.
+func (m *Repo) ListDeadLetters(arg0 context.Context, arg1 timer.ListDeadLettersQuery) (*timer.DeadLettersPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeadLetters", arg0, arg1)
+	ret0, _ := ret[0].(*timer.DeadLettersPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeadLetters indicates an expected call of ListDeadLetters.
+func (mr *RepoMockRecorder) ListDeadLetters(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeadLetters", reflect.TypeOf((*Repo)(nil).ListDeadLetters), arg0, arg1)
+}
+
+// PurgeDeadLetters mocks base method.
+func (m *Repo) PurgeDeadLetters(arg0 context.Context, arg1 []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeadLetters", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeadLetters indicates an expected call of PurgeDeadLetters.
+func (mr *RepoMockRecorder) PurgeDeadLetters(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeadLetters", reflect.TypeOf((*Repo)(nil).PurgeDeadLetters), arg0, arg1)
+}
+
+// ReplayDeadLetter mocks base method.
+func (m *Repo) ReplayDeadLetter(arg0 context.Context, arg1 string, arg2 *timer.Timer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplayDeadLetter", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplayDeadLetter indicates an expected call of ReplayDeadLetter.
+func (mr *RepoMockRecorder) ReplayDeadLetter(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplayDeadLetter", reflect.TypeOf((*Repo)(nil).ReplayDeadLetter), arg0, arg1, arg2)
+}
+
+// Reschedule mocks base method.
+func (m *Repo) Reschedule(arg0 context.Context, arg1 *timer.Timer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reschedule", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reschedule indicates an expected call of Reschedule.
+func (mr *RepoMockRecorder) Reschedule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reschedule", reflect.TypeOf((*Repo)(nil).Reschedule), arg0, arg1)
+}
+
+// SetState mocks base method.
+func (m *Repo) SetState(arg0 context.Context, arg1 *timer.TimerState) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetState", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetState indicates an expected call of SetState.
+func (mr *RepoMockRecorder) SetState(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetState", reflect.TypeOf((*Repo)(nil).SetState), arg0, arg1)
+}
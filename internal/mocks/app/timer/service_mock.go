@@ -6,11 +6,9 @@ package mocks
 
 import (
 	context "context"
-	reflect "reflect"
-
-	gomock "github.com/golang/mock/gomock"
-
 	timer "github.com/cubny/httpqueue/internal/app/timer"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
 )
 
 // Service is a mock of Service interface.
@@ -37,17 +35,31 @@ func (m *Service) EXPECT() *ServiceMockRecorder {
 }
 
 // ArchiveTimer mocks base method.
-func (m *Service) ArchiveTimer(arg0 context.Context, arg1 string) error {
+func (m *Service) ArchiveTimer(arg0 context.Context, arg1 string, arg2 timer.State) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ArchiveTimer", arg0, arg1)
+	ret := m.ctrl.Call(m, "ArchiveTimer", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // ArchiveTimer indicates an expected call of ArchiveTimer.
-func (mr *ServiceMockRecorder) ArchiveTimer(arg0, arg1 interface{}) *gomock.Call {
+func (mr *ServiceMockRecorder) ArchiveTimer(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArchiveTimer", reflect.TypeOf((*Service)(nil).ArchiveTimer), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArchiveTimer", reflect.TypeOf((*Service)(nil).ArchiveTimer), arg0, arg1, arg2)
+}
+
+// CancelTimer mocks base method.
+func (m *Service) CancelTimer(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelTimer", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelTimer indicates an expected call of CancelTimer.
+func (mr *ServiceMockRecorder) CancelTimer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelTimer", reflect.TypeOf((*Service)(nil).CancelTimer), arg0, arg1)
 }
 
 // CreateTimer mocks base method.
@@ -65,6 +77,49 @@ func (mr *ServiceMockRecorder) CreateTimer(arg0, arg1 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTimer", reflect.TypeOf((*Service)(nil).CreateTimer), arg0, arg1)
 }
 
+// CreateTimers mocks base method.
+func (m *Service) CreateTimers(arg0 context.Context, arg1 []timer.SetTimerCommand) []timer.CreateTimerResult {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTimers", arg0, arg1)
+	ret0, _ := ret[0].([]timer.CreateTimerResult)
+	return ret0
+}
+
+// CreateTimers indicates an expected call of CreateTimers.
+func (mr *ServiceMockRecorder) CreateTimers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTimers", reflect.TypeOf((*Service)(nil).CreateTimers), arg0, arg1)
+}
+
+// DeadLetterTimer mocks base method.
+func (m *Service) DeadLetterTimer(arg0 context.Context, arg1 *timer.DeadLetter) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeadLetterTimer", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeadLetterTimer indicates an expected call of DeadLetterTimer.
+func (mr *ServiceMockRecorder) DeadLetterTimer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeadLetterTimer", reflect.TypeOf((*Service)(nil).DeadLetterTimer), arg0, arg1)
+}
+
+// GetDeadLetter mocks base method.
+func (m *Service) GetDeadLetter(arg0 context.Context, arg1 string) (*timer.DeadLetter, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeadLetter", arg0, arg1)
+	ret0, _ := ret[0].(*timer.DeadLetter)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeadLetter indicates an expected call of GetDeadLetter.
+func (mr *ServiceMockRecorder) GetDeadLetter(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeadLetter", reflect.TypeOf((*Service)(nil).GetDeadLetter), arg0, arg1)
+}
+
 // GetTimer mocks base method.
 func (m *Service) GetTimer(arg0 context.Context, arg1 string) (*timer.Timer, error) {
 	m.ctrl.T.Helper()
@@ -79,3 +134,149 @@ func (mr *ServiceMockRecorder) GetTimer(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTimer", reflect.TypeOf((*Service)(nil).GetTimer), arg0, arg1)
 }
+
+// GetTimerState mocks base method.
+func (m *Service) GetTimerState(arg0 context.Context, arg1 string) (*timer.TimerState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTimerState", arg0, arg1)
+	ret0, _ := ret[0].(*timer.TimerState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTimerState indicates an expected call of GetTimerState.
+func (mr *ServiceMockRecorder) GetTimerState(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTimerState", reflect.TypeOf((*Service)(nil).GetTimerState), arg0, arg1)
+}
+
+// ListAttempts mocks base method.
+func (m *Service) ListAttempts(arg0 context.Context, arg1 string) ([]*timer.Attempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAttempts", arg0, arg1)
+	ret0, _ := ret[0].([]*timer.Attempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAttempts indicates an expected call of ListAttempts.
+func (mr *ServiceMockRecorder) ListAttempts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAttempts", reflect.TypeOf((*Service)(nil).ListAttempts), arg0, arg1)
+}
+
+// ListDeadLetters mocks base method.
+func (m *Service) ListDeadLetters(arg0 context.Context, arg1 timer.ListDeadLettersQuery) (*timer.DeadLettersPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDeadLetters", arg0, arg1)
+	ret0, _ := ret[0].(*timer.DeadLettersPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDeadLetters indicates an expected call of ListDeadLetters.
+func (mr *ServiceMockRecorder) ListDeadLetters(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDeadLetters", reflect.TypeOf((*Service)(nil).ListDeadLetters), arg0, arg1)
+}
+
+// ListTimers mocks base method.
+func (m *Service) ListTimers(arg0 context.Context, arg1 timer.ListTimersQuery) (*timer.TimersPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTimers", arg0, arg1)
+	ret0, _ := ret[0].(*timer.TimersPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTimers indicates an expected call of ListTimers.
+func (mr *ServiceMockRecorder) ListTimers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTimers", reflect.TypeOf((*Service)(nil).ListTimers), arg0, arg1)
+}
+
+// PurgeDeadLetters mocks base method.
+func (m *Service) PurgeDeadLetters(arg0 context.Context, arg1 []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeadLetters", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeadLetters indicates an expected call of PurgeDeadLetters.
+func (mr *ServiceMockRecorder) PurgeDeadLetters(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeadLetters", reflect.TypeOf((*Service)(nil).PurgeDeadLetters), arg0, arg1)
+}
+
+// RecordAttempt mocks base method.
+func (m *Service) RecordAttempt(arg0 context.Context, arg1 string, arg2 *timer.Attempt) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordAttempt", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordAttempt indicates an expected call of RecordAttempt.
+func (mr *ServiceMockRecorder) RecordAttempt(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordAttempt", reflect.TypeOf((*Service)(nil).RecordAttempt), arg0, arg1, arg2)
+}
+
+// ReplayDeadLetters mocks base method.
+func (m *Service) ReplayDeadLetters(arg0 context.Context, arg1 timer.ReplayDeadLettersCommand) []timer.ReplayDeadLetterResult {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplayDeadLetters", arg0, arg1)
+	ret0, _ := ret[0].([]timer.ReplayDeadLetterResult)
+	return ret0
+}
+
+// ReplayDeadLetters indicates an expected call of ReplayDeadLetters.
+func (mr *ServiceMockRecorder) ReplayDeadLetters(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplayDeadLetters", reflect.TypeOf((*Service)(nil).ReplayDeadLetters), arg0, arg1)
+}
+
+// RescheduleTimer mocks base method.
+func (m *Service) RescheduleTimer(arg0 context.Context, arg1 timer.RescheduleTimerCommand) (*timer.Timer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RescheduleTimer", arg0, arg1)
+	ret0, _ := ret[0].(*timer.Timer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RescheduleTimer indicates an expected call of RescheduleTimer.
+func (mr *ServiceMockRecorder) RescheduleTimer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RescheduleTimer", reflect.TypeOf((*Service)(nil).RescheduleTimer), arg0, arg1)
+}
+
+// ScheduleNextRun mocks base method.
+func (m *Service) ScheduleNextRun(arg0 context.Context, arg1 *timer.Timer, arg2 timer.State) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleNextRun", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScheduleNextRun indicates an expected call of ScheduleNextRun.
+func (mr *ServiceMockRecorder) ScheduleNextRun(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleNextRun", reflect.TypeOf((*Service)(nil).ScheduleNextRun), arg0, arg1, arg2)
+}
+
+// SetTimerState mocks base method.
+func (m *Service) SetTimerState(arg0 context.Context, arg1 *timer.TimerState) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTimerState", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTimerState indicates an expected call of SetTimerState.
+func (mr *ServiceMockRecorder) SetTimerState(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTimerState", reflect.TypeOf((*Service)(nil).SetTimerState), arg0, arg1)
+}
@@ -11,6 +11,7 @@ import (
 //go:generate mockgen -destination=./app/timer/service_mock.go -package=mocks -mock_names=Service=Service github.com/cubny/httpqueue/internal/app/timer Service
 //go:generate mockgen -destination=./app/timer/outbox_mock.go -package=mocks -mock_names=Outbox=Outbox github.com/cubny/httpqueue/internal/app/timer Outbox
 //go:generate mockgen -destination=./app/timer/producer_mock.go -package=mocks -mock_names=Producer=Producer github.com/cubny/httpqueue/internal/app/timer Producer
+//go:generate mockgen -destination=./app/timer/destination_policy_mock.go -package=mocks -mock_names=DestinationPolicy=DestinationPolicy github.com/cubny/httpqueue/internal/app/timer DestinationPolicy
 //go:generate mockgen -destination=./app/timer/http_client_mock.go -package=mocks -mock_names=HttpClient=HttpClient github.com/cubny/httpqueue/internal/app/timer HttpClient
 
 //region external
@@ -0,0 +1,184 @@
+// Package webhooks signs and verifies webhooks following the Standard Webhooks scheme
+// (https://www.standardwebhooks.com).
+//
+// httpqueue signs the webhooks of the timers with it, and the receivers written in Go can verify them:
+//
+//	verifier, err := webhooks.NewVerifier("whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw")
+//	if err != nil {
+//		return err
+//	}
+//
+//	body, err := io.ReadAll(r.Body)
+//	if err != nil {
+//		return err
+//	}
+//
+//	if err := verifier.Verify(r.Header, body); err != nil {
+//		w.WriteHeader(http.StatusUnauthorized)
+//		return err
+//	}
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of a signed webhook.
+const (
+	// HeaderID is the unique ID of the message. it is the same for all the delivery attempts of a message.
+	HeaderID = "webhook-id"
+	// HeaderTimestamp is the time of signing in Unix seconds.
+	HeaderTimestamp = "webhook-timestamp"
+	// HeaderSignature is a space delimited list of signatures, one per signing secret.
+	HeaderSignature = "webhook-signature"
+)
+
+const (
+	// secretPrefix is the prefix of the base64 encoded secrets.
+	secretPrefix = "whsec_"
+	// signatureVersion is the version of the HMAC-SHA256 signatures.
+	signatureVersion = "v1"
+	// minSecretBytes is the minimum size of a secret.
+	minSecretBytes = 24
+	// maxSecretBytes is the maximum size of a secret.
+	maxSecretBytes = 64
+	// DefaultTolerance is the maximum difference between the timestamp of a webhook and the time of its verification.
+	DefaultTolerance = 5 * time.Minute
+)
+
+var (
+	// ErrInvalidSecret is returned when a secret is not in the whsec_<base64> format or its size is not between 24
+	// and 64 bytes.
+	ErrInvalidSecret = errors.New("invalid webhook secret")
+	// ErrMissingHeaders is returned when the webhook is not signed.
+	ErrMissingHeaders = errors.New("missing webhook headers")
+	// ErrInvalidTimestamp is returned when the timestamp of the webhook is malformed or out of the tolerance.
+	ErrInvalidTimestamp = errors.New("invalid webhook timestamp")
+	// ErrNoMatchingSignature is returned when none of the signatures of the webhook is made by the secrets.
+	ErrNoMatchingSignature = errors.New("no matching webhook signature")
+)
+
+// Secret is a signing secret.
+type Secret []byte
+
+// ParseSecret parses a secret in the whsec_<base64> format.
+func ParseSecret(s string) (Secret, error) {
+	encoded := strings.TrimPrefix(s, secretPrefix)
+	if encoded == s {
+		return nil, ErrInvalidSecret
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(secret) < minSecretBytes || len(secret) > maxSecretBytes {
+		return nil, ErrInvalidSecret
+	}
+
+	return secret, nil
+}
+
+// ParseSecrets parses a list of secrets in the whsec_<base64> format.
+func ParseSecrets(ss []string) ([]Secret, error) {
+	secrets := make([]Secret, 0, len(ss))
+	for _, s := range ss {
+		secret, err := ParseSecret(s)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, nil
+}
+
+// String returns the secret in the whsec_<base64> format.
+func (s Secret) String() string {
+	return secretPrefix + base64.StdEncoding.EncodeToString(s)
+}
+
+// Sign returns the versioned signature of the message, i.e. v1,<base64 HMAC-SHA256>.
+func (s Secret) Sign(msgID string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, s)
+	_, _ = fmt.Fprintf(mac, "%s.%d.", msgID, timestamp.Unix())
+	_, _ = mac.Write(body)
+
+	return signatureVersion + "," + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SetHeaders signs the message with every secret and sets the webhook headers. signing with more than one secret
+// allows rotating the secrets: the receivers accept the webhook as long as they know one of the secrets.
+func SetHeaders(header http.Header, secrets []Secret, msgID string, timestamp time.Time, body []byte) {
+	signatures := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		signatures = append(signatures, secret.Sign(msgID, timestamp, body))
+	}
+
+	header.Set(HeaderID, msgID)
+	header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	header.Set(HeaderSignature, strings.Join(signatures, " "))
+}
+
+// Verifier verifies the signed webhooks.
+type Verifier struct {
+	secrets   []Secret
+	tolerance time.Duration
+	now       func() time.Time
+}
+
+// NewVerifier constructs a Verifier that accepts the webhooks signed by any of the secrets, so that the secrets
+// can be rotated without downtime.
+func NewVerifier(secrets ...string) (*Verifier, error) {
+	if len(secrets) == 0 {
+		return nil, ErrInvalidSecret
+	}
+
+	parsed, err := ParseSecrets(secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verifier{secrets: parsed, tolerance: DefaultTolerance, now: time.Now}, nil
+}
+
+// WithTolerance sets the maximum difference between the timestamp of a webhook and the time of its verification.
+func (v *Verifier) WithTolerance(tolerance time.Duration) *Verifier {
+	v.tolerance = tolerance
+	return v
+}
+
+// Verify checks the timestamp and the signatures of a webhook. body must be the raw body of the request.
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+	msgID := header.Get(HeaderID)
+	rawTimestamp := header.Get(HeaderTimestamp)
+	rawSignatures := header.Get(HeaderSignature)
+	if msgID == "" || rawTimestamp == "" || rawSignatures == "" {
+		return ErrMissingHeaders
+	}
+
+	seconds, err := strconv.ParseInt(rawTimestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	timestamp := time.Unix(seconds, 0)
+	if diff := v.now().Sub(timestamp); diff > v.tolerance || diff < -v.tolerance {
+		return ErrInvalidTimestamp
+	}
+
+	for _, secret := range v.secrets {
+		expected := secret.Sign(msgID, timestamp, body)
+		for _, signature := range strings.Fields(rawSignatures) {
+			if hmac.Equal([]byte(signature), []byte(expected)) {
+				return nil
+			}
+		}
+	}
+
+	return ErrNoMatchingSignature
+}
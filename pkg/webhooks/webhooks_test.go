@@ -0,0 +1,124 @@
+package webhooks
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the test vector of the Standard Webhooks specification.
+const (
+	testSecret    = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	testMsgID     = "msg_p5jXN8AQM9LWM0D4loKWxJek"
+	testTimestamp = 1614265330
+	testBody      = `{"test": 2432232314}`
+	testSignature = "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="
+)
+
+func TestParseSecret(t *testing.T) {
+	secret, err := ParseSecret(testSecret)
+	require.NoError(t, err)
+	assert.Equal(t, testSecret, secret.String())
+
+	for _, s := range []string{"", "MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw", "whsec_!!", "whsec_c2hvcnQ="} {
+		_, err := ParseSecret(s)
+		assert.ErrorIs(t, err, ErrInvalidSecret, s)
+	}
+}
+
+func TestSecret_Sign(t *testing.T) {
+	secret, err := ParseSecret(testSecret)
+	require.NoError(t, err)
+
+	assert.Equal(t, testSignature, secret.Sign(testMsgID, time.Unix(testTimestamp, 0), []byte(testBody)))
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	const rotatedSecret = "whsec_dGhpcyBpcyB0aGUgcm90YXRlZCBzZWNyZXQ="
+
+	secret, err := ParseSecret(testSecret)
+	require.NoError(t, err)
+	rotated, err := ParseSecret(rotatedSecret)
+	require.NoError(t, err)
+
+	signedHeader := func(secrets ...Secret) http.Header {
+		header := http.Header{}
+		SetHeaders(header, secrets, testMsgID, time.Unix(testTimestamp, 0), []byte(testBody))
+		return header
+	}
+
+	tests := []struct {
+		name     string
+		verifier []string
+		header   http.Header
+		body     string
+		now      time.Time
+		wantErr  error
+	}{
+		{
+			name:     "valid",
+			verifier: []string{testSecret},
+			header:   signedHeader(secret),
+			body:     testBody,
+			now:      time.Unix(testTimestamp, 0),
+		},
+		{
+			name:     "signed with the rotated and the old secrets",
+			verifier: []string{rotatedSecret},
+			header:   signedHeader(rotated, secret),
+			body:     testBody,
+			now:      time.Unix(testTimestamp, 0),
+		},
+		{
+			name:     "verifier knows the old and the rotated secrets",
+			verifier: []string{rotatedSecret, testSecret},
+			header:   signedHeader(secret),
+			body:     testBody,
+			now:      time.Unix(testTimestamp, 0),
+		},
+		{
+			name:     "tampered body",
+			verifier: []string{testSecret},
+			header:   signedHeader(secret),
+			body:     `{"test": 1}`,
+			now:      time.Unix(testTimestamp, 0),
+			wantErr:  ErrNoMatchingSignature,
+		},
+		{
+			name:     "unknown secret",
+			verifier: []string{rotatedSecret},
+			header:   signedHeader(secret),
+			body:     testBody,
+			now:      time.Unix(testTimestamp, 0),
+			wantErr:  ErrNoMatchingSignature,
+		},
+		{
+			name:     "too old",
+			verifier: []string{testSecret},
+			header:   signedHeader(secret),
+			body:     testBody,
+			now:      time.Unix(testTimestamp, 0).Add(DefaultTolerance + time.Second),
+			wantErr:  ErrInvalidTimestamp,
+		},
+		{
+			name:     "not signed",
+			verifier: []string{testSecret},
+			header:   http.Header{},
+			body:     testBody,
+			now:      time.Unix(testTimestamp, 0),
+			wantErr:  ErrMissingHeaders,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewVerifier(tt.verifier...)
+			require.NoError(t, err)
+			v.now = func() time.Time { return tt.now }
+
+			assert.ErrorIs(t, v.Verify(tt.header, []byte(tt.body)), tt.wantErr)
+		})
+	}
+}